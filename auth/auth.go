@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type (
+	adminKey struct{}
+	userKey  struct{}
+)
+
+// Error ошибка авторизации
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions реализует gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+var (
+	// ErrForbidden возвращается при попытке выполнить административную операцию без прав
+	ErrForbidden = &Error{Message: "forbidden", Code: "FORBIDDEN"}
+	// ErrUnauthenticated возвращается при обращении анонимного клиента к данным пользователя
+	ErrUnauthenticated = &Error{Message: "authentication required", Code: "UNAUTHENTICATED"}
+)
+
+// WithAdmin отмечает контекст как принадлежащий администратору
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin сообщает, выполняется ли запрос администратором
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// RequireAdmin возвращает ErrForbidden, если запрос выполняется не администратором
+func RequireAdmin(ctx context.Context) error {
+	if !IsAdmin(ctx) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// WithUser сохраняет в контексте ID аутентифицированного пользователя
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserID возвращает ID аутентифицированного пользователя или пустую строку для анонимного клиента
+func UserID(ctx context.Context) string {
+	userID, _ := ctx.Value(userKey{}).(string)
+	return userID
+}
+
+// RequireUser возвращает ID аутентифицированного пользователя или ErrUnauthenticated
+func RequireUser(ctx context.Context) (string, error) {
+	userID := UserID(ctx)
+	if userID == "" {
+		return "", ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+// Middleware отмечает запросы с заголовком "Authorization: Bearer <adminToken>" как
+// административные, а запросы с токеном, выданным IssueToken(userSecret, ...), — как
+// запросы пользователя. Пустой adminToken отключает административный доступ,
+// пустой userSecret — аутентификацию пользователей.
+func Middleware(adminToken, userSecret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(Authenticate(r.Context(), r.Header.Get("Authorization"), adminToken, userSecret)))
+		})
+	}
+}
+
+// Authenticate отмечает контекст как административный или принадлежащий пользователю
+// согласно значению authorization вида "Bearer <token>" по тем же правилам, что и Middleware
+func Authenticate(ctx context.Context, authorization, adminToken, userSecret string) context.Context {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return ctx
+	}
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return WithAdmin(ctx)
+	}
+	if userID, err := ParseToken(userSecret, token); err == nil {
+		return WithUser(ctx, userID)
+	}
+	return ctx
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidToken возвращается для токенов с неверной подписью
+var ErrInvalidToken = errors.New("invalid token")
+
+// IssueToken выдает токен пользователя userID вида "<userID>.<подпись>", подписанный secret
+func IssueToken(secret, userID string) string {
+	return userID + "." + sign(secret, userID)
+}
+
+// ParseToken проверяет подпись токена и возвращает ID пользователя
+func ParseToken(secret, token string) (string, error) {
+	idx := strings.LastIndexByte(token, '.')
+	if secret == "" || idx <= 0 {
+		return "", ErrInvalidToken
+	}
+
+	userID, signature := token[:idx], token[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, userID))) {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func sign(secret, userID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,80 @@
+// Команда commentsctl администрирует хранилище комментариев, настроенное теми же
+// переменными окружения, что и сервер. Список команд выводит commentsctl -h.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"graphql-comments/commentsctl"
+	"graphql-comments/config"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help") {
+		fmt.Print(commentsctl.Usage)
+		return 0
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config:", err)
+		return 1
+	}
+	// In-memory хранилище существует только внутри процесса сервера
+	if cfg.StorageType != "postgres" {
+		fmt.Fprintf(os.Stderr, "commentsctl requires STORAGE_TYPE=postgres, got %q\n", cfg.StorageType)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	connectCtx, cancel := cfg.Timeouts.Context(ctx, "")
+	store, err := postgres.NewPostgresDataStore(connectCtx, cfg.Postgres.DSN(), cfg.Timeouts)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to PostgreSQL:", err)
+		return 1
+	}
+	defer store.DB.Close()
+
+	var tenants []*tenant.Tenant
+	if cfg.Tenants.File != "" {
+		if tenants, err = tenant.Load(cfg.Tenants.File); err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading tenants:", err)
+			return 1
+		}
+	}
+	registry, err := tenant.NewRegistry(tenants, cfg.Tenants.Default)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading tenants:", err)
+		return 1
+	}
+
+	cli := &commentsctl.CLI{
+		Store:   store,
+		Tenants: registry,
+		Migrate: func(ctx context.Context) error { return postgres.Migrate(ctx, store.DB) },
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+	}
+	if err := cli.Run(ctx, args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		if errors.Is(err, commentsctl.ErrUsage) {
+			fmt.Fprint(os.Stderr, "\n"+commentsctl.Usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+// Команда loadtest нагружает работающий сервер смесью запросов GraphQL и выводит процентили
+// задержек и долю ошибок по сценариям. Сервер должен содержать посты, например созданные
+// командой commentsctl seed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"graphql-comments/loadtest"
+	"graphql-comments/tenant"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// headers значение повторяемого флага -header "Name: value"
+type headers http.Header
+
+func (h headers) String() string {
+	return ""
+}
+
+func (h headers) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("header %q is not in the form Name: value", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	config := loadtest.Config{Header: http.Header{}}
+	flag.StringVar(&config.URL, "url", "http://localhost:8084/graphql", "GraphQL endpoint")
+	flag.IntVar(&config.Concurrency, "c", loadtest.DefaultConcurrency, "number of concurrent clients")
+	flag.DurationVar(&config.Duration, "d", 30*time.Second, "test duration; 0 to stop after -n requests")
+	flag.IntVar(&config.Requests, "n", 0, "total number of requests; 0 for no limit")
+	flag.Uint64Var(&config.Seed, "seed", 0, "random seed")
+	mix := flag.String("mix", "", "comma-separated scenario weights, e.g. comments=5,addComment=1")
+	token := flag.String("token", "", "bearer token sent with every request")
+	apiKey := flag.String("api-key", "", "tenant API key sent in the "+tenant.APIKeyHeader+" header")
+	format := flag.String("o", "table", "output format: table or json")
+	flag.Var(headers(config.Header), "header", "extra request header \"Name: value\"; repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: loadtest [flags]\n\nScenarios: %s, %s, %s, %s, %s, %s\n\nFlags:\n",
+			loadtest.ScenarioPosts, loadtest.ScenarioPost, loadtest.ScenarioComments, loadtest.ScenarioReplies, loadtest.ScenarioPages, loadtest.ScenarioAddComment)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *mix != "" {
+		config.Mix = make(map[string]int)
+		for _, field := range strings.Split(*mix, ",") {
+			name, value, _ := strings.Cut(field, "=")
+			weight, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid scenario weight %q\n", field)
+				return 2
+			}
+			config.Mix[strings.TrimSpace(name)] = weight
+		}
+	}
+	if *token != "" {
+		config.Header.Set("Authorization", "Bearer "+*token)
+	}
+	if *apiKey != "" {
+		config.Header.Set(tenant.APIKeyHeader, *apiKey)
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q\n", *format)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := loadtest.Run(ctx, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "SCENARIO\tREQUESTS\tERRORS\tERROR RATE\tP50\tP90\tP95\tP99\tMAX\t")
+	for _, stats := range append(report.Scenarios, report.Total) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%s\t%s\t%s\t%s\t%s\t\n", stats.Scenario, stats.Requests, stats.Errors, stats.ErrorRate*100,
+			round(stats.P50), round(stats.P90), round(stats.P95), round(stats.P99), round(stats.Max))
+	}
+	w.Flush()
+	fmt.Printf("\n%d requests in %s, %.1f requests/s\n", report.Total.Requests, round(report.Elapsed), report.Throughput)
+	for message, count := range report.ErrorMessages {
+		fmt.Printf("%6d  %s\n", count, message)
+	}
+	return 0
+}
+
+// round округляет задержку для вывода в таблице
+func round(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,680 @@
+// Package commentsctl реализует команды администрирования, работающие напрямую с
+// хранилищем: просмотр постов и веток комментариев, закрытие комментариев, массовое
+// удаление спама, статистику, миграции, выгрузку и загрузку данных. Результат выводится
+// таблицей или в JSON.
+package commentsctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"graphql-comments/dump"
+	"graphql-comments/importers"
+	"graphql-comments/seed"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultActor имя, под которым действия команд записываются в журнал аудита
+const DefaultActor = "commentsctl"
+
+// listPageSize размер страницы при обходе комментариев хранилища
+const listPageSize = 100
+
+// ErrUsage возвращается при неверном вызове команды
+var ErrUsage = errors.New("invalid usage")
+
+// Usage описание команд
+const Usage = `Usage: commentsctl [-o table|json] [-tenant ID] [-actor NAME] <command> [arguments]
+
+Commands:
+  posts                  list posts
+  post <post-id>         show a post with its comment tree, including hidden comments
+  lock <post-id>         close comments on a post
+  unlock <post-id>       open comments on a post
+  purge -pattern REGEXP [-post ID] [-status STATUS] [-reason TEXT] [-dry-run]
+                         delete comments whose content matches REGEXP, with their replies
+  stats                  show post, comment and report counts
+  migrate                apply the database schema
+  export [-file PATH]    write posts, comments and their authors as NDJSON to PATH or stdout
+  import [-on-conflict skip|fail|rename] [-progress PATH] [FILE]
+                         load an export from FILE or stdin; an interrupted import
+                         resumes from the progress file (FILE.progress by default)
+  import-disqus [-namespace NS] [-on-conflict POLICY] [-dry-run] [FILE]
+                         import a Disqus XML export from FILE or stdin; threads become
+                         posts, or threads of namespace NS keyed by identifier or URL
+  import-wordpress [-namespace NS] [-on-conflict POLICY] [-dry-run] [FILE]
+                         import a WordPress WXR export; posts and pages become posts,
+                         or threads of namespace NS keyed by URL
+  seed [-posts N] [-users N] [-min-comments N] [-max-comments N] [-depth WEIGHTS]
+       [-min-length N] [-max-length N] [-seed N]
+                         generate synthetic posts and comment trees; WEIGHTS are
+                         comma-separated relative weights of reply depths 0, 1, ...
+`
+
+// CLI выполняет команды над хранилищем Store
+type CLI struct {
+	Store   storage.DataStore
+	Tenants *tenant.Registry
+	// Migrate применяет миграции хранилища; nil, если хранилище их не поддерживает
+	Migrate func(ctx context.Context) error
+	Stdin   io.Reader
+	Stdout  io.Writer
+}
+
+// command команда CLI; out выводит результат в выбранном формате
+type command func(ctx context.Context, c *CLI, args []string, out *output) error
+
+var commands = map[string]command{
+	"posts":   listPosts,
+	"post":    showPost,
+	"lock":    setAllowComments(false),
+	"unlock":  setAllowComments(true),
+	"purge":   purge,
+	"stats":   showStats,
+	"migrate": migrate,
+	"export":  exportDump,
+	"import":  importDump,
+
+	"import-disqus":    importExternal(importers.ParseDisqus),
+	"import-wordpress": importExternal(importers.ParseWordPress),
+	"seed":             generateSeed,
+}
+
+// Run разбирает общие флаги и выполняет команду, заданную args
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("commentsctl", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("o", "table", "output format: table or json")
+	tenantID := flags.String("tenant", "", "tenant ID; the default tenant if empty")
+	actor := flags.String("actor", DefaultActor, "actor recorded in the audit log")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("%w: unknown output format %q", ErrUsage, *format)
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("%w: command is required", ErrUsage)
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, flags.Arg(0))
+	}
+
+	t, err := c.Tenants.ByID(*tenantID)
+	if err != nil {
+		return fmt.Errorf("%w: %q", err, *tenantID)
+	}
+	ctx = tenant.WithTenant(ctx, t)
+
+	return cmd(ctx, c, flags.Args()[1:], &output{w: c.Stdout, json: *format == "json", actor: *actor})
+}
+
+// output выводит результат команды таблицей или в JSON
+type output struct {
+	w     io.Writer
+	json  bool
+	actor string
+}
+
+// print выводит value в JSON или вызывает table для вывода таблицей
+func (o *output) print(value interface{}, table func(w io.Writer)) error {
+	if o.json {
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+type post struct {
+	ID            string    `json:"id"`
+	AuthorID      string    `json:"authorID,omitempty"`
+	Title         string    `json:"title"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Comments      int       `json:"comments"`
+	AllowComments bool      `json:"allowComments"`
+	Namespace     string    `json:"namespace,omitempty"`
+	ExternalID    string    `json:"externalID,omitempty"`
+}
+
+func newPost(p *types.Post) *post {
+	return &post{
+		ID:            p.ID,
+		AuthorID:      p.AuthorID,
+		Title:         p.Title,
+		CreatedAt:     p.CreatedAt,
+		Comments:      len(p.Comments),
+		AllowComments: p.AllowComments,
+		Namespace:     p.Namespace,
+		ExternalID:    p.ExternalID,
+	}
+}
+
+type comment struct {
+	ID              string     `json:"id"`
+	PostID          string     `json:"postID"`
+	ParentCommentID string     `json:"parentCommentID,omitempty"`
+	AuthorID        string     `json:"authorID,omitempty"`
+	Content         string     `json:"content"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	Status          string     `json:"status"`
+	Replies         []*comment `json:"replies,omitempty"`
+}
+
+func newComment(c *types.Comment) *comment {
+	return &comment{
+		ID:              c.ID,
+		PostID:          c.PostID,
+		ParentCommentID: c.ParentCommentID,
+		AuthorID:        c.AuthorID,
+		Content:         c.Content,
+		CreatedAt:       c.CreatedAt,
+		Status:          c.Status,
+	}
+}
+
+func listPosts(ctx context.Context, c *CLI, args []string, out *output) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: posts takes no arguments", ErrUsage)
+	}
+	posts, err := c.Store.GetPosts(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(posts, func(a, b *types.Post) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	result := make([]*post, 0, len(posts))
+	for _, p := range posts {
+		result = append(result, newPost(p))
+	}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTITLE\tCOMMENTS\tLOCKED\tCREATED")
+		for _, p := range result {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%s\n", p.ID, truncate(p.Title, 40), p.Comments, !p.AllowComments, p.CreatedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func showPost(ctx context.Context, c *CLI, args []string, out *output) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: post takes a post ID", ErrUsage)
+	}
+	p, err := c.Store.GetPostByID(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	comments, err := listComments(ctx, c.Store, p.ID)
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[string]*comment, len(comments))
+	for _, item := range comments {
+		nodes[item.ID] = newComment(item)
+	}
+	// Порядок комментариев от старых к новым сохраняется на каждом уровне дерева
+	roots := make([]*comment, 0)
+	for _, item := range comments {
+		if parent, ok := nodes[item.ParentCommentID]; ok {
+			parent.Replies = append(parent.Replies, nodes[item.ID])
+		} else {
+			roots = append(roots, nodes[item.ID])
+		}
+	}
+
+	result := struct {
+		*post
+		Tree []*comment `json:"tree"`
+	}{newPost(p), roots}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", p.ID)
+		if p.Namespace != "" {
+			fmt.Fprintf(w, "Thread:\t%s/%s\n", p.Namespace, p.ExternalID)
+		} else {
+			fmt.Fprintf(w, "Title:\t%s\n", p.Title)
+		}
+		fmt.Fprintf(w, "Created:\t%s\n", p.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Locked:\t%t\n", !p.AllowComments)
+		fmt.Fprintf(w, "Comments:\t%d\n\n", len(comments))
+
+		var walk func(nodes []*comment, depth int)
+		walk = func(nodes []*comment, depth int) {
+			for _, node := range nodes {
+				fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", strings.Repeat("  ", depth), node.ID, node.Status, node.AuthorID, truncate(node.Content, 60))
+				walk(node.Replies, depth+1)
+			}
+		}
+		walk(roots, 0)
+	})
+}
+
+func setAllowComments(allow bool) command {
+	return func(ctx context.Context, c *CLI, args []string, out *output) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%w: a post ID is required", ErrUsage)
+		}
+		p, err := c.Store.SetAllowComments(ctx, out.actor, args[0], allow)
+		if err != nil {
+			return err
+		}
+		return out.print(newPost(p), func(w io.Writer) {
+			fmt.Fprintln(w, "ID\tLOCKED")
+			fmt.Fprintf(w, "%s\t%t\n", p.ID, !p.AllowComments)
+		})
+	}
+}
+
+func purge(ctx context.Context, c *CLI, args []string, out *output) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	pattern := flags.String("pattern", "", "regular expression matched against comment content")
+	postID := flags.String("post", "", "only comments of this post")
+	status := flags.String("status", "", "only comments with this status")
+	reason := flags.String("reason", "spam", "reason recorded in the audit log")
+	dryRun := flags.Bool("dry-run", false, "list matching comments without deleting them")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if *pattern == "" {
+		return fmt.Errorf("%w: -pattern is required", ErrUsage)
+	}
+	re, err := regexp.Compile(*pattern)
+	if err != nil {
+		return fmt.Errorf("%w: invalid pattern: %v", ErrUsage, err)
+	}
+	if *status != "" && !slices.Contains([]string{types.CommentPublished, types.CommentPending, types.CommentRejected}, *status) {
+		return fmt.Errorf("%w: unknown status %q", ErrUsage, *status)
+	}
+
+	comments, err := listComments(ctx, c.Store, *postID)
+	if err != nil {
+		return err
+	}
+
+	// Ответы удаляются вместе с родителем, поэтому отдельно удаляются только
+	// комментарии, ни один из предков которых не подходит под шаблон
+	parents := make(map[string]string, len(comments))
+	matched := make(map[string]bool)
+	result := struct {
+		Matched []*comment `json:"matched"`
+		Deleted int        `json:"deleted"`
+		DryRun  bool       `json:"dryRun"`
+	}{Matched: make([]*comment, 0), DryRun: *dryRun}
+	for _, item := range comments {
+		parents[item.ID] = item.ParentCommentID
+		if (*status == "" || item.Status == *status) && re.MatchString(item.Content) {
+			matched[item.ID] = true
+			result.Matched = append(result.Matched, newComment(item))
+		}
+	}
+
+	if !*dryRun {
+		for _, m := range result.Matched {
+			covered := false
+			for id := parents[m.ID]; id != "" && !covered; id = parents[id] {
+				covered = matched[id]
+			}
+			if covered {
+				continue
+			}
+			deleted, err := c.Store.DeleteComment(ctx, out.actor, m.ID, *reason)
+			if err != nil {
+				return fmt.Errorf("deleting comment %s: %w", m.ID, err)
+			}
+			result.Deleted += deleted
+		}
+	}
+
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tPOST\tSTATUS\tCONTENT")
+		for _, m := range result.Matched {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, m.PostID, m.Status, truncate(m.Content, 60))
+		}
+		if *dryRun {
+			fmt.Fprintf(w, "\n%d comments match, nothing deleted (dry run)\n", len(result.Matched))
+		} else {
+			fmt.Fprintf(w, "\n%d comments match, %d deleted including replies\n", len(result.Matched), result.Deleted)
+		}
+	})
+}
+
+func showStats(ctx context.Context, c *CLI, args []string, out *output) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: stats takes no arguments", ErrUsage)
+	}
+	stats, err := c.Store.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]int{
+		"posts":     stats.Posts,
+		"threads":   stats.Threads,
+		"published": stats.Published,
+		"pending":   stats.Pending,
+		"rejected":  stats.Rejected,
+		"reports":   stats.Reports,
+		"users":     stats.Users,
+	}
+	return out.print(result, func(w io.Writer) {
+		for _, key := range []string{"posts", "threads", "published", "pending", "rejected", "reports", "users"} {
+			fmt.Fprintf(w, "%s\t%d\n", key, result[key])
+		}
+	})
+}
+
+func migrate(ctx context.Context, c *CLI, args []string, out *output) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: migrate takes no arguments", ErrUsage)
+	}
+	if c.Migrate == nil {
+		return errors.New("storage does not support migrations")
+	}
+	if err := c.Migrate(ctx); err != nil {
+		return err
+	}
+	return out.print(map[string]bool{"migrated": true}, func(w io.Writer) {
+		fmt.Fprintln(w, "schema is up to date")
+	})
+}
+
+func exportDump(ctx context.Context, c *CLI, args []string, out *output) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	path := flags.String("file", "", "output file; stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("%w: export takes no positional arguments", ErrUsage)
+	}
+
+	// Выгрузка в stdout не смешивается с итогом команды
+	if *path == "" {
+		_, err := dump.Export(ctx, c.Store, c.Stdout)
+		return err
+	}
+
+	file, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+	result, err := dump.Export(ctx, c.Store, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return out.print(result, func(w io.Writer) {
+		printResult(w, result)
+	})
+}
+
+func importDump(ctx context.Context, c *CLI, args []string, out *output) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	onConflict := flags.String("on-conflict", "skip", "conflict policy: skip, fail or rename")
+	progressPath := flags.String("progress", "", "progress file; FILE.progress if empty")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("%w: import takes at most one file", ErrUsage)
+	}
+	policy, err := dump.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+
+	input := c.Stdin
+	if path := flags.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+		if *progressPath == "" {
+			*progressPath = path + ".progress"
+		}
+	}
+	if input == nil {
+		return fmt.Errorf("%w: a file is required", ErrUsage)
+	}
+
+	importer := &dump.Importer{Store: c.Store, OnConflict: policy}
+	var progress *dump.Progress
+	if *progressPath != "" {
+		if progress, err = loadProgress(*progressPath); err != nil {
+			return err
+		}
+		importer.Checkpoint = func(progress *dump.Progress) error {
+			return saveProgress(*progressPath, progress)
+		}
+	}
+
+	result, err := importer.Import(ctx, input, progress)
+	if err != nil {
+		if *progressPath != "" {
+			return fmt.Errorf("%w (progress saved to %s)", err, *progressPath)
+		}
+		return err
+	}
+	if *progressPath != "" {
+		if err := os.Remove(*progressPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return out.print(result, func(w io.Writer) {
+		printResult(w, result)
+	})
+}
+
+// importExternal возвращает команду импорта выгрузки другой системы комментариев,
+// разбираемой parse
+func importExternal(parse func(r io.Reader, options importers.Options) (*importers.Data, error)) command {
+	return func(ctx context.Context, c *CLI, args []string, out *output) error {
+		flags := flag.NewFlagSet("import", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		namespace := flags.String("namespace", "", "import pages as threads of this namespace instead of posts")
+		onConflict := flags.String("on-conflict", "skip", "conflict policy: skip, fail or rename")
+		dryRun := flags.Bool("dry-run", false, "report what would be imported without writing")
+		if err := flags.Parse(args); err != nil {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		if flags.NArg() > 1 {
+			return fmt.Errorf("%w: import takes at most one file", ErrUsage)
+		}
+		policy, err := dump.ParseConflictPolicy(*onConflict)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+
+		input := c.Stdin
+		if path := flags.Arg(0); path != "" && path != "-" {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			input = file
+		}
+		if input == nil {
+			return fmt.Errorf("%w: a file is required", ErrUsage)
+		}
+
+		data, err := parse(input, importers.Options{Namespace: *namespace})
+		if err != nil {
+			return err
+		}
+		report := data.Report()
+		if !*dryRun {
+			if report, err = data.Import(ctx, &dump.Importer{Store: c.Store, OnConflict: policy}); err != nil {
+				return err
+			}
+		}
+
+		return out.print(report, func(w io.Writer) {
+			fmt.Fprintf(w, "users\t%d\n", report.Users)
+			fmt.Fprintf(w, "posts\t%d\n", report.Posts)
+			fmt.Fprintf(w, "comments\t%d\n", report.Comments)
+			fmt.Fprintf(w, "orphaned\t%d\n", report.Orphaned)
+			fmt.Fprintf(w, "reparented\t%d\n", report.Reparented)
+			reasons := make([]string, 0, len(report.Skipped))
+			for reason := range report.Skipped {
+				reasons = append(reasons, reason)
+			}
+			slices.Sort(reasons)
+			for _, reason := range reasons {
+				fmt.Fprintf(w, "skipped %s\t%d\n", reason, report.Skipped[reason])
+			}
+			if report.Result == nil {
+				fmt.Fprintln(w, "\nnothing imported (dry run)")
+				return
+			}
+			fmt.Fprintln(w, "\nimported:")
+			printResult(w, report.Result)
+		})
+	}
+}
+
+func generateSeed(ctx context.Context, c *CLI, args []string, out *output) error {
+	config := seed.DefaultConfig
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.IntVar(&config.Posts, "posts", config.Posts, "number of posts")
+	flags.IntVar(&config.Users, "users", config.Users, "number of comment authors; 0 for anonymous comments")
+	flags.IntVar(&config.MinComments, "min-comments", config.MinComments, "minimum comments per post")
+	flags.IntVar(&config.MaxComments, "max-comments", config.MaxComments, "maximum comments per post")
+	flags.IntVar(&config.MinContentLength, "min-length", config.MinContentLength, "minimum comment length")
+	flags.IntVar(&config.MaxContentLength, "max-length", config.MaxContentLength, "maximum comment length")
+	flags.Uint64Var(&config.Seed, "seed", config.Seed, "random seed")
+	depth := flags.String("depth", "", "relative weights of reply depths")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("%w: seed takes no positional arguments", ErrUsage)
+	}
+	if *depth != "" {
+		config.DepthWeights = nil
+		for _, field := range strings.Split(*depth, ",") {
+			weight, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return fmt.Errorf("%w: invalid depth weight %q", ErrUsage, field)
+			}
+			config.DepthWeights = append(config.DepthWeights, weight)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+
+	result, err := seed.Generate(ctx, c.Store, config)
+	if err != nil {
+		return err
+	}
+	return out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "users\t%d\n", result.Users)
+		fmt.Fprintf(w, "posts\t%d\n", result.Posts)
+		fmt.Fprintf(w, "comments\t%d\n", result.Comments)
+		for depth, count := range result.Depths {
+			fmt.Fprintf(w, "depth %d\t%d\n", depth, count)
+		}
+	})
+}
+
+// loadProgress читает прогресс прерванного импорта; nil, если файла нет
+func loadProgress(path string) (*dump.Progress, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress := &dump.Progress{}
+	if err := json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("reading progress file %s: %w", path, err)
+	}
+	return progress, nil
+}
+
+// saveProgress атомарно заменяет файл прогресса, чтобы прерывание записи не испортило его
+func saveProgress(path string, progress *dump.Progress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func printResult(w io.Writer, result *dump.Result) {
+	fmt.Fprintf(w, "users\t%d\n", result.Users)
+	fmt.Fprintf(w, "posts\t%d\n", result.Posts)
+	fmt.Fprintf(w, "comments\t%d\n", result.Comments)
+	fmt.Fprintf(w, "existing\t%d\n", result.Existing)
+	fmt.Fprintf(w, "renamed\t%d\n", result.Renamed)
+	fmt.Fprintf(w, "skipped\t%d\n", result.Skipped)
+	fmt.Fprintf(w, "foreign\t%d\n", result.Foreign)
+	fmt.Fprintf(w, "orphaned\t%d\n", result.Orphaned)
+}
+
+// listComments возвращает все комментарии поста postID (или арендатора, если postID пуст)
+// от старых к новым
+func listComments(ctx context.Context, store storage.DataStore, postID string) ([]*types.Comment, error) {
+	var comments []*types.Comment
+	filter := storage.CommentFilter{PostID: postID, Limit: listPageSize}
+	for {
+		page, err := store.ListComments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		if len(page) < listPageSize {
+			return comments, nil
+		}
+		filter.After = page[len(page)-1].ID
+	}
+}
+
+// truncate сокращает s до n символов и заменяет переводы строк пробелами
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,453 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"graphql-comments/graphql/complexity"
+	"graphql-comments/graphql/persisted"
+	"graphql-comments/markdown"
+	"graphql-comments/ratelimit"
+	"graphql-comments/spam"
+	"graphql-comments/storage"
+	"graphql-comments/storage/cache"
+	"graphql-comments/tenant"
+	"graphql-comments/webhook"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultStorageTimeout     = 5 * time.Second
+	DefaultSlowQueryThreshold = 200 * time.Millisecond
+)
+
+// Config содержит настройки приложения, считываемые из переменных окружения
+type Config struct {
+	// StorageType тип хранилища (STORAGE_TYPE)
+	StorageType string
+	Postgres    Postgres
+	// Timeouts таймауты операций хранилища: STORAGE_TIMEOUT для всех операций и переменные
+	// вида STORAGE_TIMEOUT_GET_COMMENTS для отдельных
+	Timeouts  storage.Timeouts
+	Logging   Logging
+	RateLimit RateLimit
+	// Complexity ограничения сложности запросов: MAX_QUERY_DEPTH, MAX_QUERY_ALIASES,
+	// MAX_QUERY_COST и размеры списков для модели стоимости QUERY_LIST_SIZES
+	// (например, "Query.getPosts=100,Query.getReplies=50")
+	Complexity complexity.Limits
+	Persisted  Persisted
+	// AdminToken токен административных операций (ADMIN_TOKEN)
+	AdminToken string
+	// AuthSecret подписывает токены пользователей (AUTH_SECRET); без него регистрация отключена
+	AuthSecret string
+	// GRPCAddr адрес gRPC-сервера (GRPC_ADDR, по умолчанию ":9090"); пустое значение отключает его
+	GRPCAddr string
+	// EmbedSecret подписывает CSRF-токены форм встраиваемого виджета (EMBED_CSRF_SECRET);
+	// без него секрет генерируется при запуске
+	EmbedSecret string
+	// MarkdownCacheSize число закэшированных результатов отрисовки Markdown (MARKDOWN_CACHE_SIZE)
+	MarkdownCacheSize int
+	// Spam настройки фильтров спама: SPAM_BLOCKED_WORDS, SPAM_PENDING_LINKS, SPAM_MAX_LINKS,
+	// SPAM_DUPLICATE_WINDOW, SPAM_PENDING_SCORE, SPAM_REJECT_SCORE и SPAM_MIN_TRAINING;
+	// нулевые значения отключают соответствующие проверки
+	Spam     spam.Options
+	Tenants  Tenants
+	Webhooks Webhooks
+	Outbox   Outbox
+	Cache    Cache
+}
+
+// Cache содержит настройки кэширования результатов чтения постов и комментариев
+type Cache struct {
+	// Backend хранилище кэша (CACHE_BACKEND): off | memory | postgres; по умолчанию кэш
+	// отключен, поскольку кэш в памяти сбрасывается только в своем процессе
+	Backend string
+	// Capacity число закэшированных результатов (CACHE_CAPACITY)
+	Capacity int
+	// TTL время жизни закэшированного результата (CACHE_TTL)
+	TTL time.Duration
+	// HTTPMaxAge время, в течение которого клиенты и прокси могут использовать ответ
+	// на анонимный GET-запрос без повторной проверки (HTTP_CACHE_MAX_AGE)
+	HTTPMaxAge time.Duration
+}
+
+// Tenants содержит настройки арендаторов
+type Tenants struct {
+	// File JSON-файл с арендаторами, их API-ключами, именами хостов и ограничениями (TENANTS_FILE)
+	File string
+	// Default арендатор запросов без арендатора (DEFAULT_TENANT); пустое значение запрещает такие запросы
+	Default string
+}
+
+// Outbox содержит настройки публикации доменных событий
+type Outbox struct {
+	// Sinks получатели событий (OUTBOX_SINKS): stdout | file | webhook | nats
+	Sinks []string
+	// File файл получателя file (OUTBOX_FILE)
+	File string
+	// NATSURL адрес NATS получателя nats (NATS_URL)
+	NATSURL string
+	// NATSPrefix префикс тем NATS (NATS_SUBJECT_PREFIX)
+	NATSPrefix string
+	// BatchSize число событий, публикуемых за один проход (OUTBOX_BATCH_SIZE)
+	BatchSize int
+	// PollInterval интервал опроса outbox (OUTBOX_POLL_INTERVAL)
+	PollInterval time.Duration
+	// SinkTimeout таймаут публикации в получатель (OUTBOX_SINK_TIMEOUT)
+	SinkTimeout time.Duration
+}
+
+// Webhooks содержит настройки доставки событий на webhook'и
+type Webhooks struct {
+	// Store хранилище webhook'ов (WEBHOOK_STORE): memory | postgres
+	Store string
+	// Options параметры доставки: WEBHOOK_MAX_ATTEMPTS, WEBHOOK_BASE_BACKOFF,
+	// WEBHOOK_MAX_BACKOFF и WEBHOOK_TIMEOUT
+	Options webhook.Options
+}
+
+// Persisted содержит настройки persisted queries
+type Persisted struct {
+	// Mode режим (PERSISTED_QUERIES_MODE): off | apq | allowlist
+	Mode persisted.Mode
+	// Store хранилище запросов (PERSISTED_QUERIES_STORE): memory | postgres
+	Store string
+	// Capacity число хранимых запросов (PERSISTED_QUERIES_CAPACITY)
+	Capacity int
+	// Manifest файл разрешенных запросов, обязательный в режиме allowlist (PERSISTED_QUERIES_MANIFEST)
+	Manifest string
+}
+
+// Logging содержит настройки журналирования
+type Logging struct {
+	// Level уровень журналирования (LOG_LEVEL)
+	Level slog.Level
+	// RedactFields поля, скрываемые в журнале (LOG_REDACT_FIELDS)
+	RedactFields []string
+	// SlowQueryThreshold длительность, начиная с которой запрос считается медленным (SLOW_QUERY_THRESHOLD)
+	SlowQueryThreshold time.Duration
+}
+
+// RateLimit содержит настройки ограничения частоты запросов
+type RateLimit struct {
+	// Backend хранилище счетчиков (RATE_LIMIT_BACKEND): memory | postgres
+	Backend string
+	// TrustProxy разрешает определять клиента по заголовкам прокси (RATE_LIMIT_TRUST_PROXY)
+	TrustProxy bool
+	// Policy лимиты RATE_LIMIT_{QUERY,MUTATION,POST}_{RATE,BURST}; нулевая скорость
+	// отключает соответствующий лимит
+	Policy ratelimit.Policy
+}
+
+// Postgres содержит параметры подключения к PostgreSQL: POSTGRES_HOST, POSTGRES_PORT,
+// POSTGRES_USER, POSTGRES_PASSWORD и POSTGRES_DATABASE
+type Postgres struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+}
+
+// DSN возвращает строку подключения к PostgreSQL
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		p.Host, p.Port, p.User, p.Password, p.Database)
+}
+
+// Load считывает конфигурацию из переменных окружения, указанных в описаниях полей Config,
+// и проверяет ее
+func Load() (*Config, error) {
+	cfg := &Config{
+		StorageType: os.Getenv("STORAGE_TYPE"),
+		Postgres: Postgres{
+			Host:     os.Getenv("POSTGRES_HOST"),
+			Port:     os.Getenv("POSTGRES_PORT"),
+			User:     os.Getenv("POSTGRES_USER"),
+			Password: os.Getenv("POSTGRES_PASSWORD"),
+			Database: os.Getenv("POSTGRES_DATABASE"),
+		},
+		Timeouts: storage.Timeouts{
+			Default:    DefaultStorageTimeout,
+			Operations: make(map[string]time.Duration),
+		},
+		Logging: Logging{
+			Level:        slog.LevelInfo,
+			RedactFields: listEnv("LOG_REDACT_FIELDS", []string{"content"}),
+		},
+		RateLimit: RateLimit{
+			Backend: stringEnv("RATE_LIMIT_BACKEND", "memory"),
+		},
+		Complexity: complexity.Limits{
+			ListSizes: make(map[string]int),
+		},
+		Persisted: Persisted{
+			Mode:     persisted.Mode(stringEnv("PERSISTED_QUERIES_MODE", string(persisted.ModeAPQ))),
+			Store:    stringEnv("PERSISTED_QUERIES_STORE", "memory"),
+			Manifest: os.Getenv("PERSISTED_QUERIES_MANIFEST"),
+		},
+		AdminToken:  os.Getenv("ADMIN_TOKEN"),
+		AuthSecret:  os.Getenv("AUTH_SECRET"),
+		GRPCAddr:    stringEnv("GRPC_ADDR", ":9090"),
+		EmbedSecret: os.Getenv("EMBED_CSRF_SECRET"),
+		Spam:        spam.DefaultOptions,
+		Tenants: Tenants{
+			File:    os.Getenv("TENANTS_FILE"),
+			Default: os.Getenv("DEFAULT_TENANT"),
+		},
+		Webhooks: Webhooks{
+			Store:   stringEnv("WEBHOOK_STORE", "memory"),
+			Options: webhook.DefaultOptions,
+		},
+		Outbox: Outbox{
+			Sinks:      listEnv("OUTBOX_SINKS", []string{"webhook"}),
+			File:       os.Getenv("OUTBOX_FILE"),
+			NATSURL:    os.Getenv("NATS_URL"),
+			NATSPrefix: stringEnv("NATS_SUBJECT_PREFIX", "comments."),
+		},
+		Cache: Cache{
+			Backend: stringEnv("CACHE_BACKEND", "off"),
+		},
+	}
+
+	var err error
+	if cfg.Timeouts.Default, err = durationEnv("STORAGE_TIMEOUT", DefaultStorageTimeout); err != nil {
+		return nil, err
+	}
+	for _, op := range storage.Operations {
+		key := "STORAGE_TIMEOUT_" + envName(op)
+		if _, ok := os.LookupEnv(key); !ok {
+			continue
+		}
+		if cfg.Timeouts.Operations[op], err = durationEnv(key, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err = cfg.Logging.Level.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+	}
+	if cfg.Logging.SlowQueryThreshold, err = durationEnv("SLOW_QUERY_THRESHOLD", DefaultSlowQueryThreshold); err != nil {
+		return nil, err
+	}
+
+	if cfg.RateLimit.TrustProxy, err = boolEnv("RATE_LIMIT_TRUST_PROXY", false); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Policy.Query, err = limitEnv("RATE_LIMIT_QUERY", ratelimit.Limit{Rate: 20, Burst: 40}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Policy.Mutation, err = limitEnv("RATE_LIMIT_MUTATION", ratelimit.Limit{Rate: 1, Burst: 5}); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit.Policy.Post, err = limitEnv("RATE_LIMIT_POST", ratelimit.Limit{Rate: 5, Burst: 20}); err != nil {
+		return nil, err
+	}
+
+	if cfg.Complexity.MaxDepth, err = intEnv("MAX_QUERY_DEPTH", 10); err != nil {
+		return nil, err
+	}
+	if cfg.Complexity.MaxAliases, err = intEnv("MAX_QUERY_ALIASES", 15); err != nil {
+		return nil, err
+	}
+	if cfg.Complexity.MaxCost, err = intEnv("MAX_QUERY_COST", 1000); err != nil {
+		return nil, err
+	}
+	for field, size := range complexity.DefaultListSizes {
+		cfg.Complexity.ListSizes[field] = size
+	}
+	for _, item := range listEnv("QUERY_LIST_SIZES", nil) {
+		field, value, _ := strings.Cut(item, "=")
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QUERY_LIST_SIZES entry %q: %w", item, err)
+		}
+		cfg.Complexity.ListSizes[strings.TrimSpace(field)] = size
+	}
+
+	if cfg.MarkdownCacheSize, err = intEnv("MARKDOWN_CACHE_SIZE", markdown.DefaultCacheSize); err != nil {
+		return nil, err
+	}
+
+	if _, ok := os.LookupEnv("DEFAULT_TENANT"); !ok {
+		cfg.Tenants.Default = tenant.DefaultID
+	}
+
+	spamOptions := &cfg.Spam
+	spamOptions.BlockedWords = listEnv("SPAM_BLOCKED_WORDS", nil)
+	if spamOptions.PendingLinks, err = intEnv("SPAM_PENDING_LINKS", spamOptions.PendingLinks); err != nil {
+		return nil, err
+	}
+	if spamOptions.MaxLinks, err = intEnv("SPAM_MAX_LINKS", spamOptions.MaxLinks); err != nil {
+		return nil, err
+	}
+	if spamOptions.DuplicateWindow, err = durationEnv("SPAM_DUPLICATE_WINDOW", spamOptions.DuplicateWindow); err != nil {
+		return nil, err
+	}
+	if spamOptions.PendingScore, err = floatEnv("SPAM_PENDING_SCORE", spamOptions.PendingScore); err != nil {
+		return nil, err
+	}
+	if spamOptions.RejectScore, err = floatEnv("SPAM_REJECT_SCORE", spamOptions.RejectScore); err != nil {
+		return nil, err
+	}
+	if spamOptions.MinTrainingSize, err = intEnv("SPAM_MIN_TRAINING", spamOptions.MinTrainingSize); err != nil {
+		return nil, err
+	}
+
+	if cfg.Persisted.Capacity, err = intEnv("PERSISTED_QUERIES_CAPACITY", 1000); err != nil {
+		return nil, err
+	}
+	switch cfg.Persisted.Mode {
+	case persisted.ModeOff, persisted.ModeAPQ:
+	case persisted.ModeAllowlist:
+		if cfg.Persisted.Manifest == "" {
+			return nil, fmt.Errorf("PERSISTED_QUERIES_MANIFEST is required in %s mode", persisted.ModeAllowlist)
+		}
+	default:
+		return nil, fmt.Errorf("invalid PERSISTED_QUERIES_MODE: %q", cfg.Persisted.Mode)
+	}
+
+	options := &cfg.Webhooks.Options
+	if options.MaxAttempts, err = intEnv("WEBHOOK_MAX_ATTEMPTS", options.MaxAttempts); err != nil {
+		return nil, err
+	}
+	if options.BaseBackoff, err = durationEnv("WEBHOOK_BASE_BACKOFF", options.BaseBackoff); err != nil {
+		return nil, err
+	}
+	if options.MaxBackoff, err = durationEnv("WEBHOOK_MAX_BACKOFF", options.MaxBackoff); err != nil {
+		return nil, err
+	}
+	if options.Timeout, err = durationEnv("WEBHOOK_TIMEOUT", options.Timeout); err != nil {
+		return nil, err
+	}
+
+	if cfg.Outbox.BatchSize, err = intEnv("OUTBOX_BATCH_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.Outbox.PollInterval, err = durationEnv("OUTBOX_POLL_INTERVAL", 500*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.Outbox.SinkTimeout, err = durationEnv("OUTBOX_SINK_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	for _, sink := range cfg.Outbox.Sinks {
+		switch {
+		case sink == "file" && cfg.Outbox.File == "":
+			return nil, errors.New("OUTBOX_FILE is required for file sink")
+		case sink == "nats" && cfg.Outbox.NATSURL == "":
+			return nil, errors.New("NATS_URL is required for nats sink")
+		}
+	}
+
+	if cfg.Cache.Capacity, err = intEnv("CACHE_CAPACITY", cache.DefaultCapacity); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.TTL, err = durationEnv("CACHE_TTL", cache.DefaultTTL); err != nil {
+		return nil, err
+	}
+	if cfg.Cache.HTTPMaxAge, err = durationEnv("HTTP_CACHE_MAX_AGE", 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func stringEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+func boolEnv(key string, def bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+func intEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func floatEnv(key string, def float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// limitEnv считывает лимит из переменных <prefix>_RATE и <prefix>_BURST
+func limitEnv(prefix string, def ratelimit.Limit) (ratelimit.Limit, error) {
+	var (
+		limit ratelimit.Limit
+		err   error
+	)
+	if limit.Rate, err = floatEnv(prefix+"_RATE", def.Rate); err != nil {
+		return limit, err
+	}
+	if limit.Burst, err = intEnv(prefix+"_BURST", def.Burst); err != nil {
+		return limit, err
+	}
+	return limit, nil
+}
+
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// listEnv разбирает список значений, разделенных запятыми.
+// Пустая строка означает пустой список.
+func listEnv(key string, def []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+var wordBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// envName переводит имя операции из CamelCase в UPPER_SNAKE_CASE
+func envName(op string) string {
+	return strings.ToUpper(wordBoundary.ReplaceAllString(op, "${1}_${2}"))
+}
@@ -0,0 +1,236 @@
+// Package dump выгружает посты, ветки комментариев и комментарии арендатора в NDJSON и
+// загружает выгрузку в любое хранилище storage.DataStore с сохранением ID, связей между
+// записями и времени создания.
+package dump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"io"
+	"time"
+)
+
+// Version версия формата выгрузки
+const Version = 1
+
+// exportPageSize размер страницы при обходе хранилища
+const exportPageSize = 500
+
+// Типы записей выгрузки
+const (
+	RecordHeader  = "header"
+	RecordUser    = "user"
+	RecordPost    = "post"
+	RecordComment = "comment"
+)
+
+// Record строка выгрузки. Поля, не относящиеся к типу записи, пусты; CreatedAt заголовка
+// содержит время выгрузки.
+type Record struct {
+	Type            string    `json:"type"`
+	Version         int       `json:"version,omitempty"`
+	ID              string    `json:"id,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	PostID          string    `json:"postID,omitempty"`
+	ParentCommentID string    `json:"parentCommentID,omitempty"`
+	AuthorID        string    `json:"authorID,omitempty"`
+	Title           string    `json:"title,omitempty"`
+	Content         string    `json:"content,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	AllowComments   *bool     `json:"allowComments,omitempty"`
+	Namespace       string    `json:"namespace,omitempty"`
+	ExternalID      string    `json:"externalID,omitempty"`
+	Status          string    `json:"status,omitempty"`
+}
+
+// Result число записей выгрузки или импорта
+type Result struct {
+	Users    int `json:"users"`
+	Posts    int `json:"posts"`
+	Comments int `json:"comments"`
+	// Existing записи, уже присутствующие в хранилище, в том числе загруженные до
+	// прерывания импорта
+	Existing int `json:"existing"`
+	// Renamed записи, получившие новый ID из-за конфликта
+	Renamed int `json:"renamed"`
+	// Skipped записи, пропущенные из-за конфликта, вместе с зависящими от них
+	Skipped int `json:"skipped"`
+	// Foreign записи, ID которых занят другим арендатором; они также учтены в Renamed
+	// или Skipped в зависимости от политики разрешения конфликтов
+	Foreign int `json:"foreign"`
+	// Orphaned комментарии, пост или родитель которых нет ни в выгрузке, ни в хранилище
+	Orphaned int `json:"orphaned"`
+}
+
+// Export выгружает в w записи арендатора ctx: посты и ветки комментариев от старых к новым,
+// затем комментарии любого статуса от старых к новым. Автор выгружается перед первой своей
+// записью.
+func Export(ctx context.Context, store storage.DataStore, w io.Writer) (*Result, error) {
+	writer, err := NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{store: store, writer: writer, users: make(map[string]bool)}
+
+	postFilter := storage.PostFilter{Limit: exportPageSize}
+	for {
+		posts, err := store.ListPosts(ctx, postFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			if err := e.author(ctx, post.AuthorID); err != nil {
+				return nil, err
+			}
+			if err := writer.Post(post); err != nil {
+				return nil, err
+			}
+		}
+		if len(posts) < postFilter.Limit {
+			break
+		}
+		postFilter.After = posts[len(posts)-1].ID
+	}
+
+	commentFilter := storage.CommentFilter{Limit: exportPageSize}
+	for {
+		comments, err := store.ListComments(ctx, commentFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			if err := e.author(ctx, comment.AuthorID); err != nil {
+				return nil, err
+			}
+			if err := writer.Comment(comment); err != nil {
+				return nil, err
+			}
+		}
+		if len(comments) < commentFilter.Limit {
+			break
+		}
+		commentFilter.After = comments[len(comments)-1].ID
+	}
+
+	return writer.Close()
+}
+
+type exporter struct {
+	store  storage.DataStore
+	writer *Writer
+	// users ID уже выгруженных авторов
+	users map[string]bool
+}
+
+// author выгружает пользователя id, если он еще не выгружен
+func (e *exporter) author(ctx context.Context, id string) error {
+	if id == "" || e.users[id] {
+		return nil
+	}
+	e.users[id] = true
+
+	user, err := e.store.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.writer.User(user)
+}
+
+// Writer записывает выгрузку в NDJSON. Ссылки между записями не проверяются: импорт
+// дожидается поста или родителя, записанных позже комментария.
+type Writer struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+	result   Result
+}
+
+// NewWriter записывает в w заголовок выгрузки
+func NewWriter(w io.Writer) (*Writer, error) {
+	buffered := bufio.NewWriter(w)
+	writer := &Writer{buffered: buffered, encoder: json.NewEncoder(buffered)}
+	if err := writer.encoder.Encode(&Record{Type: RecordHeader, Version: Version, CreatedAt: time.Now()}); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// User записывает пользователя
+func (w *Writer) User(user *types.User) error {
+	w.result.Users++
+	return w.encoder.Encode(&Record{Type: RecordUser, ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt})
+}
+
+// Post записывает пост или ветку комментариев внешнего ресурса
+func (w *Writer) Post(post *types.Post) error {
+	allowComments := post.AllowComments
+	w.result.Posts++
+	return w.encoder.Encode(&Record{
+		Type:          RecordPost,
+		ID:            post.ID,
+		AuthorID:      post.AuthorID,
+		Title:         post.Title,
+		Content:       post.Content,
+		CreatedAt:     post.CreatedAt,
+		AllowComments: &allowComments,
+		Namespace:     post.Namespace,
+		ExternalID:    post.ExternalID,
+	})
+}
+
+// Comment записывает комментарий
+func (w *Writer) Comment(comment *types.Comment) error {
+	w.result.Comments++
+	return w.encoder.Encode(&Record{
+		Type:            RecordComment,
+		ID:              comment.ID,
+		PostID:          comment.PostID,
+		ParentCommentID: comment.ParentCommentID,
+		AuthorID:        comment.AuthorID,
+		Content:         comment.Content,
+		CreatedAt:       comment.CreatedAt,
+		Status:          comment.Status,
+	})
+}
+
+// Close дописывает буферизованные записи и возвращает их число
+func (w *Writer) Close() (*Result, error) {
+	if err := w.buffered.Flush(); err != nil {
+		return nil, err
+	}
+	result := w.result
+	return &result, nil
+}
+
+// post возвращает пост, описанный записью
+func (r *Record) post() *types.Post {
+	return &types.Post{
+		ID:            r.ID,
+		AuthorID:      r.AuthorID,
+		Title:         r.Title,
+		Content:       r.Content,
+		CreatedAt:     r.CreatedAt,
+		AllowComments: r.AllowComments == nil || *r.AllowComments,
+		Namespace:     r.Namespace,
+		ExternalID:    r.ExternalID,
+	}
+}
+
+// comment возвращает комментарий, описанный записью
+func (r *Record) comment() *types.Comment {
+	status := r.Status
+	if status == "" {
+		status = types.CommentPublished
+	}
+	return &types.Comment{
+		ID:              r.ID,
+		PostID:          r.PostID,
+		ParentCommentID: r.ParentCommentID,
+		AuthorID:        r.AuthorID,
+		Content:         r.Content,
+		CreatedAt:       r.CreatedAt,
+		Status:          status,
+	}
+}
@@ -0,0 +1,385 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"io"
+	"strings"
+)
+
+// Политики разрешения конфликтов, когда ID записи выгрузки уже занят в хранилище другой записью
+const (
+	// ConflictSkip пропускает запись и зависящие от нее комментарии; у пропущенного автора
+	// записи импортируются анонимными
+	ConflictSkip = "SKIP"
+	// ConflictFail прерывает импорт с ошибкой ErrConflict
+	ConflictFail = "FAIL"
+	// ConflictRename сохраняет запись под новым ID и обновляет ссылки на нее
+	ConflictRename = "RENAME"
+)
+
+const (
+	// DefaultCheckpointEvery число строк между вызовами Importer.Checkpoint
+	DefaultCheckpointEvery = 1000
+	// DefaultMaxPending число комментариев, которые могут ожидать своего поста или родителя
+	DefaultMaxPending = 100000
+)
+
+// ErrConflict возвращается при конфликте ID с политикой ConflictFail
+var ErrConflict = errors.New("record already exists")
+
+// Progress состояние импорта, с которого его можно продолжить после прерывания
+type Progress struct {
+	// Offset смещение в выгрузке, все записи до которого обработаны
+	Offset int64 `json:"offset"`
+	// Renamed новые ID записей выгрузки
+	Renamed map[string]string `json:"renamed,omitempty"`
+	// Skipped ID пропущенных записей выгрузки
+	Skipped map[string]bool `json:"skipped,omitempty"`
+	Result  Result          `json:"result"`
+}
+
+// Importer загружает выгрузку в хранилище Store. Записи, уже присутствующие в хранилище
+// с тем же содержимым, не считаются конфликтом, поэтому импорт можно безопасно повторить.
+type Importer struct {
+	Store storage.DataStore
+	// OnConflict политика разрешения конфликтов; по умолчанию ConflictSkip
+	OnConflict string
+	// Checkpoint, если задан, получает прогресс импорта каждые CheckpointEvery строк,
+	// по завершении и при ошибке
+	Checkpoint      func(progress *Progress) error
+	CheckpointEvery int
+	MaxPending      int
+}
+
+// waiting комментарий, ожидающий своего поста или родителя
+type waiting struct {
+	record *Record
+	offset int64
+}
+
+type importer struct {
+	store           storage.DataStore
+	onConflict      string
+	checkpoint      func(progress *Progress) error
+	checkpointEvery int
+	maxPending      int
+	progress        *Progress
+	// offset смещение в выгрузке, до которого обработаны все строки, кроме ожидающих комментариев
+	offset int64
+	// waiting комментарии по ID записи выгрузки, которую они ожидают
+	waiting map[string][]waiting
+	pending int
+}
+
+// Import загружает выгрузку r, продолжая с прогресса progress (nil — с начала)
+func (i *Importer) Import(ctx context.Context, r io.Reader, progress *Progress) (*Result, error) {
+	im := &importer{
+		store:           i.Store,
+		onConflict:      i.OnConflict,
+		checkpoint:      i.Checkpoint,
+		checkpointEvery: i.CheckpointEvery,
+		maxPending:      i.MaxPending,
+		progress:        progress,
+		waiting:         make(map[string][]waiting),
+	}
+	switch im.onConflict {
+	case "":
+		im.onConflict = ConflictSkip
+	case ConflictSkip, ConflictFail, ConflictRename:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q", im.onConflict)
+	}
+	if im.checkpointEvery <= 0 {
+		im.checkpointEvery = DefaultCheckpointEvery
+	}
+	if im.maxPending <= 0 {
+		im.maxPending = DefaultMaxPending
+	}
+	if im.progress == nil {
+		im.progress = &Progress{}
+	}
+	if im.progress.Renamed == nil {
+		im.progress.Renamed = make(map[string]string)
+	}
+	if im.progress.Skipped == nil {
+		im.progress.Skipped = make(map[string]bool)
+	}
+
+	im.offset = im.progress.Offset
+	if _, err := io.CopyN(io.Discard, r, im.offset); err != nil {
+		return nil, fmt.Errorf("resuming at offset %d: %w", im.offset, err)
+	}
+	if err := im.run(ctx, bufio.NewReader(r)); err != nil {
+		// Прогресс сохраняется, чтобы импорт можно было продолжить после исправления причины
+		if checkpointErr := im.save(); checkpointErr != nil {
+			return nil, errors.Join(err, checkpointErr)
+		}
+		return nil, err
+	}
+	return &im.progress.Result, nil
+}
+
+func (im *importer) run(ctx context.Context, r *bufio.Reader) error {
+	for lines := 1; ; lines++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				var record Record
+				if err := json.Unmarshal(trimmed, &record); err != nil {
+					return fmt.Errorf("offset %d: invalid record: %w", im.offset, err)
+				}
+				if err := im.record(ctx, &record, im.offset); err != nil {
+					return fmt.Errorf("offset %d: %w", im.offset, err)
+				}
+			}
+			im.offset += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if lines%im.checkpointEvery == 0 {
+			if err := im.save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Оставшиеся комментарии ссылаются на записи, которых нет ни в выгрузке, ни в хранилище
+	im.progress.Result.Orphaned += im.pending
+	im.waiting = make(map[string][]waiting)
+	im.pending = 0
+	return im.save()
+}
+
+// save передает прогресс импорта в Checkpoint. Строки ожидающих комментариев считаются
+// необработанными.
+func (im *importer) save() error {
+	if im.checkpoint == nil {
+		return nil
+	}
+	im.progress.Offset = im.offset
+	for _, list := range im.waiting {
+		for _, w := range list {
+			im.progress.Offset = min(im.progress.Offset, w.offset)
+		}
+	}
+	return im.checkpoint(im.progress)
+}
+
+// record импортирует запись выгрузки, начинающуюся со смещения offset
+func (im *importer) record(ctx context.Context, record *Record, offset int64) error {
+	if record.Type == RecordHeader {
+		if record.Version > Version {
+			return fmt.Errorf("unsupported dump version %d", record.Version)
+		}
+		return nil
+	}
+	if record.ID == "" {
+		return fmt.Errorf("%s id is empty", record.Type)
+	}
+	// Запись пропущена до прерывания импорта
+	if im.progress.Skipped[record.ID] {
+		return im.release(ctx, record.ID)
+	}
+
+	switch record.Type {
+	case RecordUser:
+		return im.user(ctx, record)
+	case RecordPost:
+		return im.post(ctx, record)
+	case RecordComment:
+		return im.comment(ctx, record, offset)
+	}
+	return fmt.Errorf("unknown record type %q", record.Type)
+}
+
+func (im *importer) user(ctx context.Context, record *Record) error {
+	user := &types.User{ID: im.id(record.ID), Name: record.Name, CreatedAt: record.CreatedAt}
+	for {
+		err := im.store.ImportUser(ctx, user)
+		if !errors.Is(err, storage.ErrAlreadyExists) {
+			if err == nil {
+				im.progress.Result.Users++
+			}
+			return err
+		}
+
+		// Пользователь с тем же именем считается тем же пользователем
+		if existing, err := im.store.GetUserByName(ctx, user.Name); err == nil {
+			if existing.ID != record.ID {
+				im.progress.Renamed[record.ID] = existing.ID
+			}
+			im.progress.Result.Existing++
+			return nil
+		}
+		if retry, err := im.conflict(record, false); !retry {
+			return err
+		}
+		user.ID = im.id(record.ID)
+	}
+}
+
+func (im *importer) post(ctx context.Context, record *Record) error {
+	post := record.post()
+	post.ID = im.id(record.ID)
+	post.AuthorID = im.id(record.AuthorID)
+	for {
+		err := im.store.ImportPost(ctx, post)
+		if err == nil {
+			im.progress.Result.Posts++
+			return im.release(ctx, record.ID)
+		}
+		if !errors.Is(err, storage.ErrAlreadyExists) {
+			return err
+		}
+
+		existing, err := im.store.GetPostByID(ctx, post.ID)
+		if err == nil && existing.Title == post.Title && existing.Content == post.Content {
+			im.progress.Result.Existing++
+			return im.release(ctx, record.ID)
+		}
+		foreign := errors.Is(err, storage.ErrPostNotFound)
+		// Ветка внешнего ресурса может существовать под другим ID
+		if post.Namespace != "" {
+			if thread, err := im.store.GetThread(ctx, post.Namespace, post.ExternalID); err == nil {
+				im.progress.Renamed[record.ID] = thread.ID
+				im.progress.Result.Existing++
+				return im.release(ctx, record.ID)
+			}
+		}
+		retry, err := im.conflict(record, foreign)
+		if err != nil {
+			return err
+		}
+		if !retry {
+			return im.release(ctx, record.ID)
+		}
+		post.ID = im.id(record.ID)
+	}
+}
+
+func (im *importer) comment(ctx context.Context, record *Record, offset int64) error {
+	if im.progress.Skipped[record.PostID] || im.progress.Skipped[record.ParentCommentID] {
+		im.progress.Skipped[record.ID] = true
+		im.progress.Result.Skipped++
+		return im.release(ctx, record.ID)
+	}
+
+	comment := record.comment()
+	comment.ID = im.id(record.ID)
+	comment.PostID = im.id(record.PostID)
+	comment.ParentCommentID = im.id(record.ParentCommentID)
+	comment.AuthorID = im.id(record.AuthorID)
+	for {
+		err := im.store.ImportComment(ctx, comment)
+		switch {
+		case err == nil:
+			im.progress.Result.Comments++
+			return im.release(ctx, record.ID)
+		case errors.Is(err, storage.ErrPostNotFound):
+			return im.wait(record.PostID, record, offset)
+		case errors.Is(err, storage.ErrParentNotFound):
+			return im.wait(record.ParentCommentID, record, offset)
+		case !errors.Is(err, storage.ErrAlreadyExists):
+			return err
+		}
+
+		existing, err := im.store.GetCommentByID(ctx, comment.ID)
+		if err == nil && existing.PostID == comment.PostID && existing.ParentCommentID == comment.ParentCommentID && existing.Content == comment.Content {
+			im.progress.Result.Existing++
+			return im.release(ctx, record.ID)
+		}
+		retry, err := im.conflict(record, errors.Is(err, storage.ErrCommentNotFound))
+		if err != nil {
+			return err
+		}
+		if !retry {
+			return im.release(ctx, record.ID)
+		}
+		comment.ID = im.id(record.ID)
+	}
+}
+
+// conflict применяет политику разрешения конфликтов к записи; retry сообщает, что запись
+// получила новый ID и ее нужно сохранить повторно. foreign сообщает, что ID занят записью
+// другого арендатора: такие конфликты учитываются отдельно, поскольку хранилище арендатора
+// записи не содержит.
+func (im *importer) conflict(record *Record, foreign bool) (retry bool, err error) {
+	if foreign {
+		im.progress.Result.Foreign++
+	}
+	switch im.onConflict {
+	case ConflictFail:
+		if foreign {
+			return false, fmt.Errorf("%w: %s %s is taken by another tenant", ErrConflict, record.Type, record.ID)
+		}
+		return false, fmt.Errorf("%w: %s %s", ErrConflict, record.Type, record.ID)
+	case ConflictRename:
+		im.progress.Renamed[record.ID] = uuid.NewString()
+		im.progress.Result.Renamed++
+		return true, nil
+	}
+	im.progress.Skipped[record.ID] = true
+	im.progress.Result.Skipped++
+	return false, nil
+}
+
+// wait откладывает комментарий до импорта записи id
+func (im *importer) wait(id string, record *Record, offset int64) error {
+	if im.pending >= im.maxPending {
+		return fmt.Errorf("more than %d comments precede their posts or parents", im.maxPending)
+	}
+	im.waiting[id] = append(im.waiting[id], waiting{record: record, offset: offset})
+	im.pending++
+	return nil
+}
+
+// release импортирует комментарии, ожидавшие записи id
+func (im *importer) release(ctx context.Context, id string) error {
+	list := im.waiting[id]
+	delete(im.waiting, id)
+	im.pending -= len(list)
+	for _, w := range list {
+		if err := im.comment(ctx, w.record, w.offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// id возвращает ID, под которым сохранена запись выгрузки id. Пропущенный автор
+// заменяется анонимным.
+func (im *importer) id(id string) string {
+	if renamed, ok := im.progress.Renamed[id]; ok {
+		return renamed
+	}
+	if im.progress.Skipped[id] {
+		return ""
+	}
+	return id
+}
+
+// ParseConflictPolicy возвращает политику разрешения конфликтов по ее имени без учета регистра
+func ParseConflictPolicy(name string) (string, error) {
+	switch policy := strings.ToUpper(name); policy {
+	case ConflictSkip, ConflictFail, ConflictRename:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q", name)
+}
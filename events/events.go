@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"time"
+)
+
+// Типы доменных событий
+const (
+	PostCreated    = "post.created"
+	CommentCreated = "comment.created"
+)
+
+// Types перечисляет все типы событий
+var Types = []string{PostCreated, CommentCreated}
+
+// Event доменное событие
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// TenantID арендатор, в пространстве которого произошло событие
+	TenantID   string                 `json:"tenantID,omitempty"`
+	OccurredAt time.Time              `json:"occurredAt"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// Publisher публикует доменные события
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// New создает событие типа eventType с данными data
+func New(eventType string, data map[string]interface{}) Event {
+	return Event{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// NewPostCreated создает событие о публикации поста арендатора запроса ctx
+func NewPostCreated(ctx context.Context, post *types.Post) Event {
+	return withTenant(ctx, New(PostCreated, map[string]interface{}{
+		"id":            post.ID,
+		"authorID":      post.AuthorID,
+		"title":         post.Title,
+		"content":       post.Content,
+		"createdAt":     post.CreatedAt,
+		"allowComments": post.AllowComments,
+	}))
+}
+
+// NewCommentCreated создает событие о добавлении комментария арендатора запроса ctx
+func NewCommentCreated(ctx context.Context, comment *types.Comment) Event {
+	return withTenant(ctx, New(CommentCreated, map[string]interface{}{
+		"id":              comment.ID,
+		"postID":          comment.PostID,
+		"parentCommentID": comment.ParentCommentID,
+		"authorID":        comment.AuthorID,
+		"content":         comment.Content,
+		"createdAt":       comment.CreatedAt,
+	}))
+}
+
+func withTenant(ctx context.Context, event Event) Event {
+	event.TenantID = tenant.ID(ctx)
+	return event
+}
+
+// Known сообщает, является ли eventType известным типом события
+func Known(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSSink публикует события в NATS (или совместимый сервер) по текстовому протоколу.
+// Событие публикуется в subject "<prefix><тип события>", например "comments.comment.created".
+// После каждой публикации отправляется PING, и sink дожидается PONG, поэтому
+// успешный Publish означает, что сервер принял сообщение.
+type NATSSink struct {
+	addr    string
+	user    string
+	pass    string
+	prefix  string
+	timeout time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// NewNATSSink создает sink для сервера rawURL вида nats://[user:pass@]host:port
+func NewNATSSink(rawURL, subjectPrefix string, timeout time.Duration) (*NATSSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q", rawURL)
+	}
+
+	sink := &NATSSink{
+		addr:    u.Host,
+		prefix:  subjectPrefix,
+		timeout: timeout,
+	}
+	if u.Port() == "" {
+		sink.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		sink.user = u.User.Username()
+		sink.pass, _ = u.User.Password()
+	}
+	return sink, nil
+}
+
+func (s *NATSSink) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.publish(ctx, s.prefix+event.Type, payload); err != nil {
+		// Соединение могло оказаться в неопределенном состоянии, при следующей
+		// публикации оно будет установлено заново
+		s.close()
+		return err
+	}
+	return nil
+}
+
+func (s *NATSSink) publish(ctx context.Context, subject string, payload []byte) error {
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetDeadline(deadline)
+
+	if _, err := fmt.Fprintf(s.conn, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload); err != nil {
+		return err
+	}
+	return s.awaitPong()
+}
+
+func (s *NATSSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected NATS greeting: %q", strings.TrimSpace(line))
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "graphql-comments-outbox",
+		"lang":     "go",
+	}
+	if s.user != "" {
+		options["user"] = s.user
+		options["pass"] = s.pass
+	}
+	connect, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+	return s.awaitPong()
+}
+
+// awaitPong читает ответы сервера до получения PONG
+func (s *NATSSink) awaitPong() error {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("NATS error: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// Close закрывает соединение с сервером
+func (s *NATSSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.close()
+}
+
+func (s *NATSSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.reader = nil
+	return err
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterSink записывает события в w в формате JSON, по одному на строку
+type WriterSink struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewWriterSink создает sink, пишущий в w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewStdoutSink создает sink, пишущий в стандартный вывод
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// FileSink дописывает события в файл
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+// NewFileSink открывает (или создает) файл path для дописывания событий
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: NewWriterSink(file), file: file}, nil
+}
+
+// Publish реализует Publisher. Запись синхронизируется на диск, чтобы событие
+// не было отмечено опубликованным раньше, чем оно сохранено.
+func (s *FileSink) Publish(ctx context.Context, event Event) error {
+	if err := s.WriterSink.Publish(ctx, event); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close закрывает файл
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *WriterSink) Publish(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Fanout публикует каждое событие во все Publishers по очереди
+type Fanout []Publisher
+
+// Publish реализует Publisher. Ошибки всех получателей объединяются; получатели
+// должны быть идемпотентны, так как событие может быть опубликовано повторно.
+func (f Fanout) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
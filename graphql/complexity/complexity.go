@@ -0,0 +1,239 @@
+package complexity
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"strings"
+)
+
+// Limits задает ограничения на сложность запроса. Нулевое значение отключает ограничение.
+type Limits struct {
+	MaxDepth   int
+	MaxAliases int
+	MaxCost    int
+	// ListSizes задает предполагаемое число элементов для списочных полей.
+	// Ключом служит "Тип.поле" или просто имя поля.
+	ListSizes map[string]int
+}
+
+// DefaultListSizes размеры списков, используемые в модели стоимости по умолчанию
+var DefaultListSizes = map[string]int{
+	"Query.getPosts":    50,
+	"Query.getComments": 10,
+	"Query.getReplies":  20,
+	// Размеры ограничены storage.MaxMentioningComments и storage.MaxMentionsPerComment
+	"Query.commentsMentioning": 50,
+	"Comment.mentions":         10,
+	// Размер страницы уведомлений ограничен storage.MaxNotificationsPage
+	"NotificationConnection.edges": 50,
+	// Размеры страниц ограничены storage.MaxModerationPage и storage.MaxAuditLogPage
+	"ModerationConnection.edges": 50,
+	"AuditEntryConnection.edges": 100,
+}
+
+// Report результат статического анализа операции
+type Report struct {
+	Depth   int `json:"depth"`
+	Aliases int `json:"aliases"`
+	Cost    int `json:"cost"`
+	MaxCost int `json:"maxCost,omitempty"`
+}
+
+// Error возвращается, когда запрос превышает одно из ограничений
+type Error struct {
+	Code    string
+	Message string
+	Value   int
+	Limit   int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions реализует gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":  e.Code,
+		"value": e.Value,
+		"limit": e.Limit,
+	}
+}
+
+// Analyze вычисляет глубину, число алиасов и стоимость операции operationName
+// документа doc. Поля интроспекции не учитываются.
+func Analyze(schema *graphql.Schema, doc *ast.Document, operationName string, limits Limits) (*Report, error) {
+	a := &analyzer{
+		schema:    schema,
+		limits:    limits,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		visiting:  make(map[string]bool),
+	}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				if operation == nil {
+					operation = def
+				}
+			}
+		}
+	}
+	if operation == nil {
+		return &Report{MaxCost: limits.MaxCost}, nil
+	}
+
+	var root *graphql.Object
+	switch operation.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+
+	cost := a.selectionSet(operation.SelectionSet, root, 1)
+	report := &Report{
+		Depth:   a.depth,
+		Aliases: a.aliases,
+		Cost:    cost,
+		MaxCost: limits.MaxCost,
+	}
+
+	switch {
+	case limits.MaxDepth > 0 && report.Depth > limits.MaxDepth:
+		return report, &Error{
+			Code:    "QUERY_TOO_DEEP",
+			Message: fmt.Sprintf("query depth %d exceeds maximum of %d", report.Depth, limits.MaxDepth),
+			Value:   report.Depth,
+			Limit:   limits.MaxDepth,
+		}
+	case limits.MaxAliases > 0 && report.Aliases > limits.MaxAliases:
+		return report, &Error{
+			Code:    "TOO_MANY_ALIASES",
+			Message: fmt.Sprintf("query uses %d aliases, maximum is %d", report.Aliases, limits.MaxAliases),
+			Value:   report.Aliases,
+			Limit:   limits.MaxAliases,
+		}
+	case limits.MaxCost > 0 && report.Cost > limits.MaxCost:
+		return report, &Error{
+			Code:    "QUERY_TOO_COMPLEX",
+			Message: fmt.Sprintf("query cost %d exceeds budget of %d", report.Cost, limits.MaxCost),
+			Value:   report.Cost,
+			Limit:   limits.MaxCost,
+		}
+	}
+	return report, nil
+}
+
+type analyzer struct {
+	schema    *graphql.Schema
+	limits    Limits
+	fragments map[string]*ast.FragmentDefinition
+	visiting  map[string]bool
+	depth     int
+	aliases   int
+}
+
+// selectionSet возвращает стоимость набора полей типа parent, находящегося на глубине depth
+func (a *analyzer) selectionSet(set *ast.SelectionSet, parent graphql.Type, depth int) int {
+	if set == nil {
+		return 0
+	}
+
+	cost := 0
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost += a.field(selection, parent, depth)
+		case *ast.InlineFragment:
+			typ := parent
+			if selection.TypeCondition != nil {
+				if named := a.schema.Type(selection.TypeCondition.Name.Value); named != nil {
+					typ = named
+				}
+			}
+			cost += a.selectionSet(selection.SelectionSet, typ, depth)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := a.fragments[name]
+			// Циклы во фрагментах отклонит валидация, здесь достаточно не зациклиться
+			if !ok || a.visiting[name] {
+				continue
+			}
+			typ := parent
+			if fragment.TypeCondition != nil {
+				if named := a.schema.Type(fragment.TypeCondition.Name.Value); named != nil {
+					typ = named
+				}
+			}
+			a.visiting[name] = true
+			cost += a.selectionSet(fragment.SelectionSet, typ, depth)
+			a.visiting[name] = false
+		}
+	}
+	return cost
+}
+
+func (a *analyzer) field(field *ast.Field, parent graphql.Type, depth int) int {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0
+	}
+
+	if field.Alias != nil && field.Alias.Value != name {
+		a.aliases++
+	}
+	if depth > a.depth {
+		a.depth = depth
+	}
+
+	var (
+		fieldType  graphql.Type
+		parentName string
+	)
+	if object, ok := parent.(*graphql.Object); ok {
+		parentName = object.Name()
+		if def, ok := object.Fields()[name]; ok {
+			fieldType = def.Type
+		}
+	}
+
+	children := a.selectionSet(field.SelectionSet, unwrap(fieldType), depth+1)
+	return 1 + a.listSize(parentName, name)*children
+}
+
+// listSize возвращает множитель стоимости дочерних полей
+func (a *analyzer) listSize(parent, field string) int {
+	sizes := a.limits.ListSizes
+	if sizes == nil {
+		sizes = DefaultListSizes
+	}
+	if size, ok := sizes[parent+"."+field]; ok {
+		return size
+	}
+	if size, ok := sizes[field]; ok {
+		return size
+	}
+	return 1
+}
+
+// unwrap снимает с типа обертки NonNull и List
+func unwrap(typ graphql.Type) graphql.Type {
+	for {
+		switch t := typ.(type) {
+		case *graphql.NonNull:
+			typ = t.OfType
+		case *graphql.List:
+			typ = t.OfType
+		default:
+			return typ
+		}
+	}
+}
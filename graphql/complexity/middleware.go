@@ -0,0 +1,94 @@
+package complexity
+
+import (
+	"context"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"graphql-comments/graphql"
+	"net/http"
+)
+
+type reportKey struct{}
+
+// ReportFromContext возвращает результат анализа текущего запроса
+func ReportFromContext(ctx context.Context) (*Report, bool) {
+	report, ok := ctx.Value(reportKey{}).(*Report)
+	return report, ok
+}
+
+// Middleware анализирует GraphQL-запрос до его выполнения и отклоняет запросы,
+// превышающие limits. Результат анализа сохраняется в контексте запроса.
+//
+// Запрос без текста пропускается без анализа, поэтому Middleware должен стоять после
+// persisted.Middleware, который подставляет текст persisted query по хешу.
+func Middleware(schema *graphql.Schema, limits Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := gql.ReadRequest(r)
+			if err != nil {
+				gql.WriteError(w, http.StatusBadRequest, "unable to read request", nil)
+				return
+			}
+
+			doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+			if req.Query == "" || err != nil {
+				// Синтаксические ошибки сообщит основной обработчик
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			report, err := Analyze(schema, doc, req.OperationName, limits)
+			if err != nil {
+				complexityErr := err.(*Error)
+				extensions := complexityErr.Extensions()
+				extensions["complexity"] = report
+				gql.WriteError(w, http.StatusOK, complexityErr.Message, extensions)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), reportKey{}, report)))
+		})
+	}
+}
+
+// Extension добавляет результат анализа в поле extensions.complexity ответа
+type Extension struct{}
+
+var _ graphql.Extension = Extension{}
+
+func (Extension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return ctx
+}
+
+func (Extension) Name() string {
+	return "complexity"
+}
+
+func (Extension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (Extension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (Extension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (Extension) ResolveFieldDidStart(ctx context.Context, _ *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (Extension) HasResult() bool {
+	return true
+}
+
+func (Extension) GetResult(ctx context.Context) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	report, _ := ReportFromContext(ctx)
+	return report
+}
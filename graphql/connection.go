@@ -0,0 +1,35 @@
+package gql
+
+// connection страница списка в формате Relay Connection
+type connection[T any] struct {
+	Edges    []*edge[T]
+	PageInfo *pageInfo
+}
+
+type edge[T any] struct {
+	Cursor string
+	Node   T
+}
+
+type pageInfo struct {
+	EndCursor   *string
+	HasNextPage bool
+}
+
+// newConnection строит страницу из первых first элементов nodes. Хранилище должно вернуть
+// на один элемент больше, чтобы можно было узнать, есть ли следующая страница.
+func newConnection[T any](nodes []T, first int, cursor func(T) string) *connection[T] {
+	page := &connection[T]{
+		Edges:    make([]*edge[T], 0, first),
+		PageInfo: &pageInfo{HasNextPage: len(nodes) > first},
+	}
+	for idx, node := range nodes {
+		if idx == first {
+			break
+		}
+		c := cursor(node)
+		page.Edges = append(page.Edges, &edge[T]{Cursor: c, Node: node})
+		page.PageInfo.EndCursor = &c
+	}
+	return page
+}
@@ -0,0 +1,45 @@
+package gql
+
+import (
+	"context"
+	"graphql-comments/events"
+	"graphql-comments/logging"
+	"graphql-comments/pubsub"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"graphql-comments/webhook"
+)
+
+// Events получает события об успешно выполненных мутациях. Если не задан, события не публикуются.
+var Events events.Publisher
+
+// Webhooks управляет подписками на события
+var Webhooks *webhook.Dispatcher
+
+// publish публикует событие. Мутация к этому моменту уже выполнена, поэтому ошибка
+// публикации не возвращается клиенту, а только логируется.
+func publish(ctx context.Context, event events.Event) {
+	if Events == nil {
+		return
+	}
+	if err := Events.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx).Error("failed to publish event", "event_type", event.Type, "event_id", event.ID, "error", err)
+	}
+}
+
+// Comments рассылает опубликованные комментарии подписчикам ветки поста.
+// Подписчики получают только комментарии, опубликованные в этом же процессе.
+var Comments = pubsub.NewBroker(16)
+
+// CommentTopic возвращает тему комментариев поста postID арендатора запроса
+func CommentTopic(ctx context.Context, postID string) string {
+	return "comments:" + tenant.ID(ctx) + ":" + postID
+}
+
+// published публикует событие о новом комментарии, рассылает его подписчикам ветки и
+// уведомляет заинтересованных пользователей
+func published(ctx context.Context, comment *types.Comment) {
+	publish(ctx, events.NewCommentCreated(ctx, comment))
+	Comments.Publish(CommentTopic(ctx, comment.PostID), comment)
+	notify(ctx, comment, mention(ctx, comment))
+}
@@ -0,0 +1,135 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"graphql-comments/graphql"
+	"graphql-comments/tenant"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Vary заголовки запроса, от которых зависит ответ: токен пользователя и арендатор
+var Vary = strings.Join([]string{"Authorization", tenant.APIKeyHeader, tenant.IDHeader}, ", ")
+
+// Middleware добавляет к успешным ответам на анонимные GET-запросы с операцией query
+// заголовки ETag и Cache-Control (public, max-age=maxAge) и отвечает 304 Not Modified,
+// если клиент уже получил ответ с тем же ETag. Ответы на запросы с заголовком Authorization
+// помечаются как private, no-store; ответы с ошибками и мутации не кэшируются.
+func Middleware(maxAge time.Duration) func(http.Handler) http.Handler {
+	cacheControl := "public, no-cache"
+	if seconds := int(maxAge.Seconds()); seconds > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", seconds)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || !isQuery(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Vary", Vary)
+			if r.Header.Get("Authorization") != "" {
+				w.Header().Set("Cache-Control", "private, no-store")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recorder{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			for key, values := range rec.header {
+				w.Header()[key] = values
+			}
+			body := rec.body.Bytes()
+			if rec.status != http.StatusOK || hasErrors(body) {
+				w.WriteHeader(rec.status)
+				w.Write(body)
+				return
+			}
+
+			sum := sha256.Sum256(body)
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", cacheControl)
+			if matches(r.Header.Get("If-None-Match"), etag) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+		})
+	}
+}
+
+// isQuery сообщает, выполняет ли запрос операцию query
+func isQuery(r *http.Request) bool {
+	req, err := gql.ReadRequest(r)
+	if err != nil || req.Query == "" {
+		return false
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return false
+	}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		op, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName == "" || (op.Name != nil && op.Name.Value == req.OperationName) {
+			if operation != nil && req.OperationName == "" {
+				// Без operationName документ с несколькими операциями не выполняется
+				return false
+			}
+			operation = op
+		}
+	}
+	return operation != nil && operation.Operation == ast.OperationTypeQuery
+}
+
+func hasErrors(body []byte) bool {
+	var response struct {
+		Errors []json.RawMessage `json:"errors"`
+	}
+	return json.Unmarshal(body, &response) != nil || len(response.Errors) != 0
+}
+
+// matches сообщает, содержит ли значение заголовка If-None-Match тег etag
+func matches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder накапливает ответ, чтобы вычислить ETag до отправки заголовков
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
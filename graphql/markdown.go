@@ -0,0 +1,20 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"graphql-comments/markdown"
+	"graphql-comments/types"
+)
+
+// Markdown отрисовывает содержимое постов и комментариев в HTML
+var Markdown = markdown.NewRenderer(markdown.DefaultCacheSize)
+
+func contentHTMLResolver(params graphql.ResolveParams) (interface{}, error) {
+	switch source := params.Source.(type) {
+	case *types.Post:
+		return Markdown.Render(source.Content)
+	case *types.Comment:
+		return Markdown.Render(source.Content)
+	}
+	return nil, nil
+}
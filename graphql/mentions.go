@@ -0,0 +1,37 @@
+package gql
+
+import (
+	"context"
+	"graphql-comments/logging"
+	"graphql-comments/mentions"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+)
+
+// mention сохраняет упоминания пользователей в комментарии comment и возвращает ID
+// упомянутых пользователей. Несуществующие имена пропускаются и не учитываются
+// в storage.MaxMentionsPerComment, но разбирается не больше 2*storage.MaxMentionsPerComment
+// имен, чтобы комментарий не порождал неограниченное число запросов к хранилищу.
+// Комментарий к этому моменту уже сохранен, поэтому ошибки только логируются.
+func mention(ctx context.Context, comment *types.Comment) []string {
+	userIDs := make([]string, 0)
+	for _, name := range mentions.Parse(comment.Content, 2*storage.MaxMentionsPerComment, storage.MaxUserNameLength) {
+		if len(userIDs) == storage.MaxMentionsPerComment {
+			break
+		}
+		user, err := storage.DataBase.GetUserByName(ctx, name)
+		if err != nil {
+			continue
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	if len(userIDs) == 0 {
+		return userIDs
+	}
+
+	if err := storage.DataBase.AddMentions(ctx, comment.ID, userIDs); err != nil {
+		logging.FromContext(ctx).Error("failed to save mentions", "comment_id", comment.ID, "error", err)
+		return nil
+	}
+	return userIDs
+}
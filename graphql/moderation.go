@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/logging"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+)
+
+// AdminActor имя, под которым в журнал аудита записываются действия по токену администратора
+const AdminActor = "admin"
+
+// moderator возвращает имя модератора для журнала аудита
+func moderator(ctx context.Context) string {
+	if userID := auth.UserID(ctx); userID != "" {
+		return userID
+	}
+	return AdminActor
+}
+
+// moderate устанавливает комментарию статус status, обучает классификатор на решении
+// модератора и публикует комментарий, если он был скрыт до одобрения
+func moderate(ctx context.Context, id, status, reason string) (*types.Comment, error) {
+	if err := auth.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	current, err := storage.DataBase.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := current.Status
+	comment, err := storage.DataBase.ModerateComment(ctx, moderator(ctx), id, status, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	Classifiers.For(tenant.ID(ctx)).Train(comment.Content, status == types.CommentRejected)
+	if status == types.CommentPublished && previous != types.CommentPublished {
+		published(ctx, comment)
+	}
+	return comment, nil
+}
+
+// TrainClassifier обучает классификатор арендатора запроса на решениях модераторов
+// из его журнала аудита
+func TrainClassifier(ctx context.Context) error {
+	classifier := Classifiers.For(tenant.ID(ctx))
+	for _, action := range []string{types.AuditApprove, types.AuditReject} {
+		filter := storage.AuditFilter{Action: action, Limit: storage.MaxAuditLogPage}
+		for {
+			entries, err := storage.DataBase.GetAuditLog(ctx, filter)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				comment, err := storage.DataBase.GetCommentByID(ctx, entry.TargetID)
+				if err != nil {
+					logging.FromContext(ctx).Warn("failed to train spam classifier", "comment_id", entry.TargetID, "error", err)
+					continue
+				}
+				classifier.Train(comment.Content, action == types.AuditReject)
+			}
+			if len(entries) < filter.Limit {
+				break
+			}
+			filter.After = entries[len(entries)-1].ID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package gql
+
+import (
+	"context"
+	"graphql-comments/logging"
+	"graphql-comments/pubsub"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+)
+
+// Notifications рассылает созданные уведомления подписчикам notificationAdded.
+// Подписчики получают только уведомления, созданные в этом же процессе.
+var Notifications = pubsub.NewBroker(16)
+
+func notificationTopic(userID string) string {
+	return "notifications:" + userID
+}
+
+// notify уведомляет автора родительского комментария об ответе, автора поста о новом
+// комментарии и пользователей mentioned об упоминании. Каждый получатель получает не больше
+// одного уведомления. Комментарий к этому моменту уже сохранен, поэтому ошибки только логируются.
+func notify(ctx context.Context, comment *types.Comment, mentioned []string) {
+	notifications := make([]*types.Notification, 0, 2)
+	recipients := map[string]bool{comment.AuthorID: true}
+	add := func(userID, notificationType string) {
+		if userID == "" || recipients[userID] {
+			return
+		}
+		recipients[userID] = true
+		notifications = append(notifications, &types.Notification{
+			UserID:    userID,
+			Type:      notificationType,
+			ActorID:   comment.AuthorID,
+			PostID:    comment.PostID,
+			CommentID: comment.ID,
+		})
+	}
+
+	logger := logging.FromContext(ctx)
+	if comment.ParentCommentID != "" {
+		parent, err := storage.DataBase.GetCommentByID(ctx, comment.ParentCommentID)
+		if err != nil {
+			logger.Error("failed to create notifications", "comment_id", comment.ID, "error", err)
+			return
+		}
+		add(parent.AuthorID, types.NotificationReply)
+	}
+	post, err := storage.DataBase.GetPostByID(ctx, comment.PostID)
+	if err != nil {
+		logger.Error("failed to create notifications", "comment_id", comment.ID, "error", err)
+		return
+	}
+	add(post.AuthorID, types.NotificationPostComment)
+	for _, userID := range mentioned {
+		add(userID, types.NotificationMention)
+	}
+
+	if len(notifications) == 0 {
+		return
+	}
+	if err := storage.DataBase.AddNotifications(ctx, notifications); err != nil {
+		logger.Error("failed to create notifications", "comment_id", comment.ID, "error", err)
+		return
+	}
+	for _, notification := range notifications {
+		Notifications.Publish(notificationTopic(notification.UserID), notification)
+	}
+}
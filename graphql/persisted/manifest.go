@@ -0,0 +1,75 @@
+package persisted
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Manifest список заранее зарегистрированных операций в формате
+// Apollo persisted query manifest
+type Manifest struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	Operations []ManifestOperation `json:"operations"`
+
+	queries map[string]string
+}
+
+// ManifestOperation операция из манифеста. Если ID не указан, он вычисляется по Body.
+type ManifestOperation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+// LoadManifest читает манифест из файла path и проверяет хеши операций
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if err := manifest.index(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// NewManifest создает манифест из списка операций
+func NewManifest(operations ...ManifestOperation) (*Manifest, error) {
+	manifest := &Manifest{Version: 1, Operations: operations}
+	if err := manifest.index(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) index() error {
+	m.queries = make(map[string]string, len(m.Operations))
+	for i, op := range m.Operations {
+		hash := Hash(op.Body)
+		if op.ID != "" && op.ID != hash {
+			return fmt.Errorf("operation %d (%s): id does not match sha256 of body", i, op.Name)
+		}
+		m.queries[hash] = op.Body
+	}
+	return nil
+}
+
+// Get реализует Store
+func (m *Manifest) Get(_ context.Context, hash string) (string, bool, error) {
+	query, ok := m.queries[hash]
+	return query, ok, nil
+}
+
+// Put реализует Store. Манифест неизменяем, поэтому новые запросы не сохраняются.
+func (m *Manifest) Put(context.Context, string, string) error {
+	return nil
+}
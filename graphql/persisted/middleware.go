@@ -0,0 +1,151 @@
+package persisted
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"graphql-comments/graphql"
+	"graphql-comments/graphql/complexity"
+	"net/http"
+)
+
+// Mode режим работы persisted queries
+type Mode string
+
+const (
+	// ModeOff отключает persisted queries
+	ModeOff Mode = "off"
+	// ModeAPQ включает automatic persisted queries: клиент может передать только хеш
+	// запроса, а при промахе повторить запрос с полным текстом, который будет сохранен
+	ModeAPQ Mode = "apq"
+	// ModeAllowlist разрешает выполнять только операции из манифеста
+	ModeAllowlist Mode = "allowlist"
+)
+
+const supportedVersion = 1
+
+// MaxQueryLength максимальная длина запроса, сохраняемого в режиме ModeAPQ
+const MaxQueryLength = 16 << 10
+
+// Config настройки middleware. В режиме ModeAllowlist в качестве Store используется Manifest.
+type Config struct {
+	Mode  Mode
+	Store Store
+	// Schema и Limits проверяют запросы перед сохранением в режиме ModeAPQ: сохраняются
+	// только запросы, прошедшие валидацию и анализ сложности. Без Schema запросы не сохраняются.
+	Schema *graphql.Schema
+	Limits complexity.Limits
+}
+
+// Middleware подставляет текст запроса по хешу из extensions.persistedQuery
+// и, в режиме ModeAllowlist, отклоняет незарегистрированные операции
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg.Mode == ModeOff || cfg.Mode == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := gql.ReadRequest(r)
+			if err != nil {
+				gql.WriteError(w, http.StatusBadRequest, "unable to read request", nil)
+				return
+			}
+
+			version, hash, ok := persistedQuery(req.Extensions)
+			if !ok {
+				if cfg.Mode == ModeAllowlist && req.Query != "" {
+					if _, found, err := cfg.Store.Get(r.Context(), Hash(req.Query)); err != nil || !found {
+						writeNotInList(w)
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if version != supportedVersion {
+				gql.WriteError(w, http.StatusOK, "Unsupported persisted query version", map[string]interface{}{
+					"code": "PERSISTED_QUERY_NOT_SUPPORTED",
+				})
+				return
+			}
+
+			if req.Query != "" {
+				if Hash(req.Query) != hash {
+					gql.WriteError(w, http.StatusOK, "provided sha does not match query", map[string]interface{}{
+						"code": "PERSISTED_QUERY_HASH_MISMATCH",
+					})
+					return
+				}
+
+				if cfg.Mode == ModeAllowlist {
+					if _, found, err := cfg.Store.Get(r.Context(), hash); err != nil || !found {
+						writeNotInList(w)
+						return
+					}
+				} else if cfg.persistable(req) {
+					if err := cfg.Store.Put(r.Context(), hash, req.Query); err != nil {
+						gql.WriteError(w, http.StatusInternalServerError, "unable to persist query", nil)
+						return
+					}
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			query, found, err := cfg.Store.Get(r.Context(), hash)
+			switch {
+			case err != nil:
+				gql.WriteError(w, http.StatusInternalServerError, "unable to load persisted query", nil)
+				return
+			case !found && cfg.Mode == ModeAllowlist:
+				writeNotInList(w)
+				return
+			case !found:
+				gql.WriteError(w, http.StatusOK, "PersistedQueryNotFound", map[string]interface{}{
+					"code": "PERSISTED_QUERY_NOT_FOUND",
+				})
+				return
+			}
+
+			req.Query = query
+			next.ServeHTTP(w, gql.WithRequest(r, req))
+		})
+	}
+}
+
+// persistable сообщает, можно ли сохранить запрос req. Непрошедший проверку запрос
+// не сохраняется, а передается дальше, где получит соответствующую ошибку.
+func (cfg Config) persistable(req *gql.Request) bool {
+	if cfg.Schema == nil || len(req.Query) > MaxQueryLength {
+		return false
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return false
+	}
+	if result := graphql.ValidateDocument(cfg.Schema, doc, nil); !result.IsValid {
+		return false
+	}
+	_, err = complexity.Analyze(cfg.Schema, doc, req.OperationName, cfg.Limits)
+	return err == nil
+}
+
+func writeNotInList(w http.ResponseWriter) {
+	gql.WriteError(w, http.StatusOK, "operation is not in the list of persisted queries", map[string]interface{}{
+		"code": "PERSISTED_QUERY_NOT_IN_LIST",
+	})
+}
+
+// persistedQuery извлекает версию и хеш из extensions.persistedQuery
+func persistedQuery(extensions map[string]interface{}) (int, string, bool) {
+	pq, ok := extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return 0, "", false
+	}
+
+	hash, _ := pq["sha256Hash"].(string)
+	version, _ := pq["version"].(float64)
+	return int(version), hash, hash != ""
+}
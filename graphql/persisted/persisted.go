@@ -0,0 +1,116 @@
+package persisted
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// Store хранит тексты запросов по их sha256-хешу
+type Store interface {
+	Get(ctx context.Context, hash string) (string, bool, error)
+	Put(ctx context.Context, hash, query string) error
+}
+
+// Hash возвращает sha256-хеш запроса в шестнадцатеричном виде
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore хранит не более capacity последних использованных запросов в памяти
+type MemoryStore struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	mu       sync.Mutex
+}
+
+type memoryEntry struct {
+	hash  string
+	query string
+}
+
+// NewMemoryStore создает in-memory хранилище. Неположительная capacity снимает ограничение.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, hash string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[hash]
+	if !ok {
+		return "", false, nil
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryEntry).query, true, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, hash, query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[hash]; ok {
+		s.order.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[hash] = s.order.PushFront(&memoryEntry{hash: hash, query: query})
+	if s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).hash)
+	}
+	return nil
+}
+
+// PostgresStore хранит не более Capacity последних сохраненных запросов в таблице persisted_queries
+type PostgresStore struct {
+	DB       *sql.DB
+	Capacity int
+}
+
+// NewPostgresStore создает хранилище поверх PostgreSQL. Неположительная capacity снимает ограничение.
+func NewPostgresStore(db *sql.DB, capacity int) *PostgresStore {
+	return &PostgresStore{DB: db, Capacity: capacity}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, hash string) (string, bool, error) {
+	var query string
+	err := s.DB.QueryRowContext(ctx, "SELECT query FROM persisted_queries WHERE hash = $1", hash).Scan(&query)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return query, true, nil
+}
+
+func (s *PostgresStore) Put(ctx context.Context, hash, query string) error {
+	result, err := s.DB.ExecContext(ctx,
+		"INSERT INTO persisted_queries (hash, query) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING",
+		hash, query)
+	if err != nil {
+		return err
+	}
+	if added, err := result.RowsAffected(); err != nil || added == 0 || s.Capacity <= 0 {
+		return err
+	}
+
+	// Сверх Capacity удаляются самые старые запросы
+	_, err = s.DB.ExecContext(ctx,
+		"DELETE FROM persisted_queries WHERE hash IN (SELECT hash FROM persisted_queries ORDER BY created_at DESC, hash OFFSET $1)",
+		s.Capacity)
+	return err
+}
@@ -0,0 +1,160 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"graphql-comments/ratelimit"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"sync"
+)
+
+// RateLimiter ограничивает частоту запросов. Если не задан, ограничения не применяются.
+var RateLimiter ratelimit.Limiter
+
+// RateLimits задает лимиты для запросов, мутаций и комментариев к одному посту
+var RateLimits ratelimit.Policy
+
+// rateLimitedQuery расходует токен из bucket'а запросов клиента перед вызовом resolve.
+// В схеме с RateLimitExtension все корневые поля операции расходуют один токен,
+// иначе каждое поле расходует свой.
+func rateLimitedQuery(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		check := AllowQuery
+		if operation, ok := params.Context.Value(operationKey{}).(*operationLimit); ok {
+			check = operation.allow
+		}
+		if err := check(params.Context); err != nil {
+			return nil, err
+		}
+		return resolve(params)
+	}
+}
+
+type operationKey struct{}
+
+// operationLimit результат проверки лимита запросов для одной операции
+type operationLimit struct {
+	once sync.Once
+	err  error
+}
+
+func (o *operationLimit) allow(ctx context.Context) error {
+	o.once.Do(func() { o.err = AllowQuery(ctx) })
+	return o.err
+}
+
+// RateLimitExtension списывает токен запросов клиента один раз на операцию, сколько бы
+// корневых полей или алиасов она ни содержала. Мутации расходуют токен на каждое поле:
+// каждое из них выполняет отдельную запись.
+type RateLimitExtension struct{}
+
+var _ graphql.Extension = RateLimitExtension{}
+
+func (RateLimitExtension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return context.WithValue(ctx, operationKey{}, &operationLimit{})
+}
+
+func (RateLimitExtension) Name() string {
+	return "rateLimit"
+}
+
+func (RateLimitExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (RateLimitExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (RateLimitExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (RateLimitExtension) ResolveFieldDidStart(ctx context.Context, _ *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (RateLimitExtension) HasResult() bool {
+	return false
+}
+
+func (RateLimitExtension) GetResult(context.Context) interface{} {
+	return nil
+}
+
+// rateLimitedMutation расходует токен из bucket'а мутаций клиента и, если у мутации
+// есть аргумент postID или externalID, из bucket'а поста или ветки внешнего ресурса
+func rateLimitedMutation(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		if err := allow(params.Context, "mutation:"+ratelimit.Client(params.Context), RateLimits.Mutation); err != nil {
+			return nil, err
+		}
+		if postID, ok := params.Args["postID"].(string); ok && postID != "" {
+			if err := allowPost(params.Context, postID); err != nil {
+				return nil, err
+			}
+		}
+		if externalID, ok := params.Args["externalID"].(string); ok && externalID != "" {
+			namespace, _ := params.Args["namespace"].(string)
+			if err := allowThread(params.Context, namespace, externalID); err != nil {
+				return nil, err
+			}
+		}
+		return resolve(params)
+	}
+}
+
+// allowPost расходует токен из bucket'а поста postID арендатора запроса. Слишком длинные
+// и несуществующие ID отклоняются до списания, чтобы клиент не мог создавать bucket'ы
+// для произвольных ключей.
+func allowPost(ctx context.Context, postID string) error {
+	if RateLimiter == nil {
+		return nil
+	}
+	if len(postID) > storage.MaxIDLength {
+		return &InputError{Message: fmt.Sprintf("postID is too long (maximum %d chars)", storage.MaxIDLength)}
+	}
+	if _, err := storage.DataBase.GetPostByID(ctx, postID); err != nil {
+		return err
+	}
+	return allow(ctx, "post:"+tenant.ID(ctx)+":"+postID, RateLimits.Post)
+}
+
+// allowThread расходует токен из bucket'а ветки внешнего ресурса. Пространство имен и
+// внешний ID хешируются, чтобы ключ помещался в колонку rate_limits.key.
+func allowThread(ctx context.Context, namespace, externalID string) error {
+	if RateLimiter == nil {
+		return nil
+	}
+	if err := validateThread(namespace, externalID); err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(namespace + "\x00" + externalID))
+	return allow(ctx, "thread:"+tenant.ID(ctx)+":"+hex.EncodeToString(sum[:]), RateLimits.Post)
+}
+
+// AllowQuery расходует токен из bucket'а запросов клиента. Используется обработчиками,
+// работающими вне GraphQL.
+func AllowQuery(ctx context.Context) error {
+	return allow(ctx, "query:"+ratelimit.Client(ctx), RateLimits.Query)
+}
+
+func allow(ctx context.Context, key string, limit ratelimit.Limit) error {
+	if RateLimiter == nil {
+		return nil
+	}
+
+	result, err := RateLimiter.Allow(ctx, key, limit)
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return &ratelimit.Error{RetryAfter: result.RetryAfter}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package gql
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/graphql-go/handler"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Request GraphQL-запрос, извлеченный из HTTP-запроса
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ReadRequest разбирает HTTP-запрос по тем же правилам, что и handler.NewRequestOptions,
+// дополнительно извлекая поле extensions. Тело запроса восстанавливается, чтобы
+// его мог прочитать следующий обработчик.
+func ReadRequest(r *http.Request) (*Request, error) {
+	if req := requestFromValues(r.URL.Query()); req != nil {
+		return req, nil
+	}
+
+	if r.Method != http.MethodPost || r.Body == nil {
+		return &Request{}, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	switch contentType {
+	case handler.ContentTypeGraphQL:
+		return &Request{Query: string(body)}, nil
+	case handler.ContentTypeFormURLEncoded:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return &Request{}, nil
+		}
+		if req := requestFromValues(values); req != nil {
+			return req, nil
+		}
+		return &Request{}, nil
+	default:
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			// Как и handler, допускаем variables и extensions, переданные JSON-строкой
+			var compatible struct {
+				Query         string `json:"query"`
+				Variables     string `json:"variables"`
+				OperationName string `json:"operationName"`
+				Extensions    string `json:"extensions"`
+			}
+			json.Unmarshal(body, &compatible)
+			req.Query = compatible.Query
+			req.OperationName = compatible.OperationName
+			json.Unmarshal([]byte(compatible.Variables), &req.Variables)
+			json.Unmarshal([]byte(compatible.Extensions), &req.Extensions)
+		}
+		return &req, nil
+	}
+}
+
+// WithRequest возвращает копию HTTP-запроса r, содержащую GraphQL-запрос req.
+// Запросы, переданные в параметрах URL, переписываются там же, остальные — в теле в формате JSON.
+func WithRequest(r *http.Request, req *Request) *http.Request {
+	clone := r.Clone(r.Context())
+
+	values := r.URL.Query()
+	if values.Has("query") || values.Has("extensions") {
+		values.Set("query", req.Query)
+		clone.URL.RawQuery = values.Encode()
+		return clone
+	}
+
+	body, _ := json.Marshal(req)
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.Header.Set("Content-Type", handler.ContentTypeJSON)
+	return clone
+}
+
+func requestFromValues(values url.Values) *Request {
+	query := values.Get("query")
+	extensions := values.Get("extensions")
+	if query == "" && extensions == "" {
+		return nil
+	}
+
+	req := &Request{
+		Query:         query,
+		OperationName: values.Get("operationName"),
+	}
+	json.Unmarshal([]byte(values.Get("variables")), &req.Variables)
+	json.Unmarshal([]byte(extensions), &req.Extensions)
+	return req
+}
+
+// WriteError отправляет ответ в формате GraphQL, содержащий единственную ошибку
+func WriteError(w http.ResponseWriter, status int, message string, extensions map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	body := map[string]interface{}{
+		"data": nil,
+		"errors": []map[string]interface{}{{
+			"message":    message,
+			"extensions": extensions,
+		}},
+	}
+	json.NewEncoder(w).Encode(body)
+}
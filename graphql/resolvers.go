@@ -1,35 +1,59 @@
 package gql
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/graphql-go/graphql"
+	"graphql-comments/auth"
+	"graphql-comments/dump"
+	"graphql-comments/events"
+	"graphql-comments/ratelimit"
 	"graphql-comments/storage"
+	"graphql-comments/types"
+	"graphql-comments/webhook"
+	"strings"
 )
 
 func addPostResolver(params graphql.ResolveParams) (interface{}, error) {
 	title, _ := params.Args["title"].(string)
 	content, _ := params.Args["content"].(string)
 	allowComments, ok := params.Args["allowComments"].(bool)
+	if !ok {
+		allowComments = true
+	}
+	return addPost(params.Context, title, content, allowComments)
+}
+
+// AddPost добавляет пост так же, как мутация addPost, с учетом лимитов запросов клиента.
+// Используется обработчиками, работающими вне GraphQL.
+func AddPost(ctx context.Context, title, content string, allowComments bool) (*types.Post, error) {
+	if err := allow(ctx, "mutation:"+ratelimit.Client(ctx), RateLimits.Mutation); err != nil {
+		return nil, err
+	}
+	return addPost(ctx, title, content, allowComments)
+}
+
+// addPost проверяет пост с учетом ограничений арендатора и сохраняет его
+func addPost(ctx context.Context, title, content string, allowComments bool) (*types.Post, error) {
+	limits := storage.Limits(ctx)
 
 	switch {
 	case title == "":
-		return nil, errors.New("title is empty")
+		return nil, &InputError{Message: "title is empty"}
 	case content == "":
-		return nil, errors.New("content is empty")
-	case len(title) > storage.MaxPostTitleLength:
-		return nil, errors.New(fmt.Sprintf("title is too long (maximum %d chars)", storage.MaxPostTitleLength))
-	case len(content) > storage.MaxPostContentLength:
-		return nil, errors.New(fmt.Sprintf("content is too long (maximum %d chars)", storage.MaxPostContentLength))
+		return nil, &InputError{Message: "content is empty"}
+	case len(title) > limits.MaxPostTitleLength:
+		return nil, &InputError{Message: fmt.Sprintf("title is too long (maximum %d chars)", limits.MaxPostTitleLength)}
+	case len(content) > limits.MaxPostContentLength:
+		return nil, &InputError{Message: fmt.Sprintf("content is too long (maximum %d chars)", limits.MaxPostContentLength)}
 	}
 
-	if !ok {
-		allowComments = true
-	}
-	newPost, err := storage.DataBase.AddPost(title, content, allowComments)
+	newPost, err := storage.DataBase.AddPost(ctx, auth.UserID(ctx), title, content, allowComments)
 	if err != nil {
 		return nil, err
 	}
+	publish(ctx, events.NewPostCreated(ctx, newPost))
 	return newPost, nil
 }
 
@@ -38,24 +62,135 @@ func addCommentResolver(params graphql.ResolveParams) (interface{}, error) {
 	parentCommentID, _ := params.Args["parentCommentID"].(string)
 	content, _ := params.Args["content"].(string)
 
-	switch {
-	case postID == "":
+	if postID == "" {
 		return nil, errors.New("postID is empty")
+	}
+	if err := validateComment(params.Context, content); err != nil {
+		return nil, err
+	}
+	return addComment(params.Context, postID, parentCommentID, content)
+}
+
+func addCommentToExternalResolver(params graphql.ResolveParams) (interface{}, error) {
+	namespace, _ := params.Args["namespace"].(string)
+	externalID, _ := params.Args["externalID"].(string)
+	parentCommentID, _ := params.Args["parentCommentID"].(string)
+	content, _ := params.Args["content"].(string)
+
+	if err := validateThread(namespace, externalID); err != nil {
+		return nil, err
+	}
+	if err := validateComment(params.Context, content); err != nil {
+		return nil, err
+	}
+
+	// Ветка поста создается вместе с постом
+	if namespace == types.PostNamespace {
+		return addComment(params.Context, externalID, parentCommentID, content)
+	}
+
+	// Ветка внешнего ресурса создается при первом комментарии, но только после его проверки,
+	// чтобы отклоненный комментарий не оставлял пустую ветку
+	var threadID string
+	thread, err := storage.DataBase.GetThread(params.Context, namespace, externalID)
+	switch {
+	case err == nil:
+		threadID = thread.ID
+	case !errors.Is(err, storage.ErrThreadNotFound):
+		return nil, err
+	}
+
+	status, err := checkComment(params.Context, threadID, parentCommentID, content)
+	if err != nil {
+		return nil, err
+	}
+	if threadID == "" {
+		thread, err := storage.DataBase.AddThread(params.Context, namespace, externalID)
+		if err != nil {
+			return nil, err
+		}
+		threadID = thread.ID
+	}
+	return saveComment(params.Context, threadID, parentCommentID, content, status)
+}
+
+// InputError ошибка проверки входных данных
+type InputError struct {
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+// validateComment проверяет текст комментария с учетом ограничений арендатора
+func validateComment(ctx context.Context, content string) error {
+	maxLength := storage.Limits(ctx).MaxCommentLength
+
+	switch {
 	case content == "":
-		return nil, errors.New("content is empty")
-	case len(content) > storage.MaxCommentLength:
-		return nil, errors.New(fmt.Sprintf("content is too long (maximum %d chars)", storage.MaxCommentLength))
+		return &InputError{Message: "content is empty"}
+	case len(content) > maxLength:
+		return &InputError{Message: fmt.Sprintf("content is too long (maximum %d chars)", maxLength)}
+	}
+	return nil
+}
+
+// validateThread проверяет пространство имен и внешний ID ветки комментариев
+func validateThread(namespace, externalID string) error {
+	switch {
+	case namespace == "":
+		return &InputError{Message: "namespace is empty"}
+	case len(namespace) > storage.MaxNamespaceLength:
+		return &InputError{Message: fmt.Sprintf("namespace is too long (maximum %d chars)", storage.MaxNamespaceLength)}
+	case externalID == "":
+		return &InputError{Message: "externalID is empty"}
+	case len(externalID) > storage.MaxExternalIDLength:
+		return &InputError{Message: fmt.Sprintf("externalID is too long (maximum %d chars)", storage.MaxExternalIDLength)}
+	}
+	return nil
+}
+
+// AddComment добавляет комментарий так же, как мутация addComment, с учетом лимитов
+// запросов клиента. Используется обработчиками, работающими вне GraphQL.
+func AddComment(ctx context.Context, postID, parentCommentID, content string) (*types.Comment, error) {
+	if err := allow(ctx, "mutation:"+ratelimit.Client(ctx), RateLimits.Mutation); err != nil {
+		return nil, err
+	}
+	if err := allowPost(ctx, postID); err != nil {
+		return nil, err
+	}
+	if err := validateComment(ctx, content); err != nil {
+		return nil, err
+	}
+	return addComment(ctx, postID, parentCommentID, content)
+}
+
+// addComment проверяет комментарий фильтрами спама и сохраняет его в ветке postID
+func addComment(ctx context.Context, postID, parentCommentID, content string) (*types.Comment, error) {
+	status, err := checkComment(ctx, postID, parentCommentID, content)
+	if err != nil {
+		return nil, err
 	}
+	return saveComment(ctx, postID, parentCommentID, content, status)
+}
 
-	newComment, err := storage.DataBase.AddComment(postID, parentCommentID, content)
+// saveComment сохраняет комментарий, прошедший checkComment, со статусом status
+func saveComment(ctx context.Context, postID, parentCommentID, content, status string) (*types.Comment, error) {
+	newComment, err := storage.DataBase.AddComment(ctx, auth.UserID(ctx), postID, parentCommentID, content, status)
 	if err != nil {
 		return nil, err
 	}
+	commitComment(ctx, newComment)
+	// Комментарий на модерации не публикуется до одобрения
+	if newComment.Status == types.CommentPublished {
+		published(ctx, newComment)
+	}
 	return newComment, nil
 }
 
 func getPostsResolver(params graphql.ResolveParams) (interface{}, error) {
-	posts, err := storage.DataBase.GetPosts()
+	posts, err := storage.DataBase.GetPosts(params.Context)
 	if err != nil {
 		return nil, err
 	}
@@ -64,21 +199,77 @@ func getPostsResolver(params graphql.ResolveParams) (interface{}, error) {
 
 func getPostByIDResolver(params graphql.ResolveParams) (interface{}, error) {
 	id, _ := params.Args["id"].(string)
-	post, err := storage.DataBase.GetPostByID(id)
+	post, err := storage.DataBase.GetPostByID(params.Context, id)
 	if err != nil {
 		return nil, err
 	}
 	return post, nil
 }
 
+func threadByExternalIDResolver(params graphql.ResolveParams) (interface{}, error) {
+	namespace, _ := params.Args["namespace"].(string)
+	id, _ := params.Args["id"].(string)
+
+	if err := validateThread(namespace, id); err != nil {
+		return nil, err
+	}
+	if namespace == types.PostNamespace {
+		return storage.DataBase.GetPostByID(params.Context, id)
+	}
+
+	thread, err := storage.DataBase.GetThread(params.Context, namespace, id)
+	if errors.Is(err, storage.ErrThreadNotFound) {
+		// У ресурса еще нет комментариев
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+func threadNamespaceResolver(params graphql.ResolveParams) (interface{}, error) {
+	thread := params.Source.(*types.Post)
+	if thread.Namespace == "" {
+		return types.PostNamespace, nil
+	}
+	return thread.Namespace, nil
+}
+
+func threadExternalIDResolver(params graphql.ResolveParams) (interface{}, error) {
+	thread := params.Source.(*types.Post)
+	if thread.Namespace == "" {
+		return thread.ID, nil
+	}
+	return thread.ExternalID, nil
+}
+
+func threadPostResolver(params graphql.ResolveParams) (interface{}, error) {
+	thread := params.Source.(*types.Post)
+	if thread.Namespace != "" {
+		return nil, nil
+	}
+	return thread, nil
+}
+
+// optionalIDResolver возвращает null вместо пустого ID: у комментариев верхнего уровня нет
+// родителя, у анонимных постов и комментариев — автора
+func optionalIDResolver(params graphql.ResolveParams) (interface{}, error) {
+	value, err := graphql.DefaultResolveFn(params)
+	if id, ok := value.(string); ok && id == "" {
+		return nil, err
+	}
+	return value, err
+}
+
 func getCommentsResolver(params graphql.ResolveParams) (interface{}, error) {
 	postID, _ := params.Args["postID"].(string)
 	page, ok := params.Args["page"].(int)
-	if !ok {
-		page = 0
+	if !ok || page < 1 {
+		page = 1
 	}
 
-	comments, err := storage.DataBase.GetComments(postID, page)
+	comments, err := storage.DataBase.GetComments(params.Context, postID, page)
 	if err != nil {
 		return nil, err
 	}
@@ -87,16 +278,19 @@ func getCommentsResolver(params graphql.ResolveParams) (interface{}, error) {
 
 func getCommentByIDResolver(params graphql.ResolveParams) (interface{}, error) {
 	id, _ := params.Args["id"].(string)
-	comment, err := storage.DataBase.GetCommentByID(id)
+	comment, err := storage.DataBase.GetCommentByID(params.Context, id)
 	if err != nil {
 		return nil, err
 	}
+	if !Visible(params.Context, comment) {
+		return nil, storage.ErrCommentNotFound
+	}
 	return comment, nil
 }
 
 func getNumberOfCommentPagesResolver(params graphql.ResolveParams) (interface{}, error) {
 	postID, _ := params.Args["postID"].(string)
-	pages, err := storage.DataBase.GetNumberOfCommentPages(postID)
+	pages, err := storage.DataBase.GetNumberOfCommentPages(params.Context, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -105,9 +299,349 @@ func getNumberOfCommentPagesResolver(params graphql.ResolveParams) (interface{},
 
 func getRepliesResolver(params graphql.ResolveParams) (interface{}, error) {
 	commentID, _ := params.Args["commentID"].(string)
-	replies, err := storage.DataBase.GetReplies(commentID)
+	replies, err := storage.DataBase.GetReplies(params.Context, commentID)
 	if err != nil {
 		return nil, err
 	}
 	return replies, nil
 }
+
+func commentsMentioningResolver(params graphql.ResolveParams) (interface{}, error) {
+	userID, _ := params.Args["userID"].(string)
+	first, ok := params.Args["first"].(int)
+	if !ok || first <= 0 || first > storage.MaxMentioningComments {
+		first = storage.MaxMentioningComments
+	}
+
+	comments, err := storage.DataBase.GetCommentsMentioning(params.Context, userID, first)
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func commentMentionsResolver(params graphql.ResolveParams) (interface{}, error) {
+	comment, _ := params.Source.(*types.Comment)
+	if comment == nil {
+		return nil, nil
+	}
+	return storage.DataBase.GetMentionedUsers(params.Context, comment.ID)
+}
+
+func registerUserResolver(params graphql.ResolveParams) (interface{}, error) {
+	if TokenSecret == "" {
+		return nil, errors.New("user registration is disabled")
+	}
+
+	name, _ := params.Args["name"].(string)
+	if err := validateUserName(name); err != nil {
+		return nil, err
+	}
+
+	user, err := storage.DataBase.AddUser(params.Context, name)
+	if err != nil {
+		return nil, err
+	}
+	return &authPayload{User: user, Token: auth.IssueToken(TokenSecret, user.ID)}, nil
+}
+
+func notificationsResolver(params graphql.ResolveParams) (interface{}, error) {
+	userID, err := auth.RequireUser(params.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	after, _ := params.Args["after"].(string)
+	unreadOnly, _ := params.Args["unreadOnly"].(bool)
+	first, ok := params.Args["first"].(int)
+	if !ok || first <= 0 || first > storage.MaxNotificationsPage {
+		first = storage.MaxNotificationsPage
+	}
+
+	// Запрашиваем на одно уведомление больше, чтобы узнать, есть ли следующая страница
+	notifications, err := storage.DataBase.GetNotifications(params.Context, userID, storage.NotificationFilter{
+		After:      after,
+		Limit:      first + 1,
+		UnreadOnly: unreadOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newConnection(notifications, first, func(notification *types.Notification) string {
+		return notification.ID
+	}), nil
+}
+
+func unreadNotificationCountResolver(params graphql.ResolveParams) (interface{}, error) {
+	userID, err := auth.RequireUser(params.Context)
+	if err != nil {
+		return nil, err
+	}
+	return storage.DataBase.GetUnreadNotificationCount(params.Context, userID)
+}
+
+func markNotificationsReadResolver(params graphql.ResolveParams) (interface{}, error) {
+	userID, err := auth.RequireUser(params.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	if list, ok := params.Args["ids"].([]interface{}); ok {
+		for _, item := range list {
+			if id, ok := item.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > storage.MaxNotificationsPage {
+		return nil, errors.New(fmt.Sprintf("too many ids (maximum %d)", storage.MaxNotificationsPage))
+	}
+	return storage.DataBase.MarkNotificationsRead(params.Context, userID, ids)
+}
+
+// notificationAddedSubscriber подписывает пользователя на его новые уведомления до
+// завершения запроса
+func notificationAddedSubscriber(params graphql.ResolveParams) (interface{}, error) {
+	userID, err := auth.RequireUser(params.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, unsubscribe := Notifications.Subscribe(notificationTopic(userID))
+	go func() {
+		<-params.Context.Done()
+		unsubscribe()
+	}()
+	return ch, nil
+}
+
+func notificationReadResolver(params graphql.ResolveParams) (interface{}, error) {
+	notification, _ := params.Source.(*types.Notification)
+	return notification != nil && notification.ReadAt != nil, nil
+}
+
+func registerWebhookResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	url, _ := params.Args["url"].(string)
+	secret, _ := params.Args["secret"].(string)
+	eventTypes := make([]string, 0)
+	if list, ok := params.Args["events"].([]interface{}); ok {
+		for _, item := range list {
+			if eventType, ok := item.(string); ok {
+				eventTypes = append(eventTypes, eventType)
+			}
+		}
+	}
+
+	newWebhook, err := webhook.NewWebhook(url, eventTypes, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := Webhooks.Store.CreateWebhook(params.Context, newWebhook); err != nil {
+		return nil, err
+	}
+	return newWebhook, nil
+}
+
+func deleteWebhookResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	id, _ := params.Args["id"].(string)
+	if err := Webhooks.Store.DeleteWebhook(params.Context, id); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+func redeliverWebhookDeliveryResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	id, _ := params.Args["id"].(string)
+	delivery, err := Webhooks.Redeliver(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func webhooksResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := Webhooks.Store.ListWebhooks(params.Context)
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func webhookDeliveriesResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	webhookID, _ := params.Args["webhookID"].(string)
+	status, _ := params.Args["status"].(string)
+	first, ok := params.Args["first"].(int)
+	if !ok || first <= 0 || first > webhook.MaxDeliveriesPageSize {
+		first = webhook.MaxDeliveriesPageSize
+	}
+
+	if _, err := Webhooks.Store.GetWebhook(params.Context, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := Webhooks.Store.ListDeliveries(params.Context, webhook.DeliveryFilter{
+		WebhookID: webhookID,
+		Status:    status,
+		Limit:     first,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func deliveryPayloadResolver(params graphql.ResolveParams) (interface{}, error) {
+	delivery, _ := params.Source.(*webhook.Delivery)
+	if delivery == nil {
+		return nil, nil
+	}
+	return string(delivery.Payload), nil
+}
+
+func moderationQueueResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	queue, _ := params.Args["status"].(string)
+	after, _ := params.Args["after"].(string)
+	first, ok := params.Args["first"].(int)
+	if !ok || first <= 0 || first > storage.MaxModerationPage {
+		first = storage.MaxModerationPage
+	}
+
+	items, err := storage.DataBase.GetModerationQueue(params.Context, storage.ModerationFilter{
+		Queue: queue,
+		After: after,
+		Limit: first + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newConnection(items, first, func(item *types.ModerationItem) string {
+		return item.Comment.ID
+	}), nil
+}
+
+func auditLogResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	filter := storage.AuditFilter{}
+	if args, ok := params.Args["filter"].(map[string]interface{}); ok {
+		filter.Actor, _ = args["actor"].(string)
+		filter.Action, _ = args["action"].(string)
+		filter.TargetID, _ = args["targetID"].(string)
+	}
+	filter.After, _ = params.Args["after"].(string)
+	first, ok := params.Args["first"].(int)
+	if !ok || first <= 0 || first > storage.MaxAuditLogPage {
+		first = storage.MaxAuditLogPage
+	}
+	filter.Limit = first + 1
+
+	entries, err := storage.DataBase.GetAuditLog(params.Context, filter)
+	if err != nil {
+		return nil, err
+	}
+	return newConnection(entries, first, func(entry *types.AuditEntry) string {
+		return entry.ID
+	}), nil
+}
+
+func exportDumpResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	var data strings.Builder
+	if _, err := dump.Export(params.Context, storage.DataBase, &data); err != nil {
+		return nil, err
+	}
+	return data.String(), nil
+}
+
+func importDumpResolver(params graphql.ResolveParams) (interface{}, error) {
+	if err := auth.RequireAdmin(params.Context); err != nil {
+		return nil, err
+	}
+
+	data, _ := params.Args["data"].(string)
+	onConflict, _ := params.Args["onConflict"].(string)
+	importer := &dump.Importer{Store: storage.DataBase, OnConflict: onConflict}
+	return importer.Import(params.Context, strings.NewReader(data), nil)
+}
+
+func approveCommentResolver(params graphql.ResolveParams) (interface{}, error) {
+	id, _ := params.Args["id"].(string)
+	reason, _ := params.Args["reason"].(string)
+	if len(reason) > storage.MaxReportReasonLength {
+		return nil, errors.New(fmt.Sprintf("reason is too long (maximum %d chars)", storage.MaxReportReasonLength))
+	}
+	return moderate(params.Context, id, types.CommentPublished, reason)
+}
+
+func rejectCommentResolver(params graphql.ResolveParams) (interface{}, error) {
+	id, _ := params.Args["id"].(string)
+	reason, _ := params.Args["reason"].(string)
+	if len(reason) > storage.MaxReportReasonLength {
+		return nil, errors.New(fmt.Sprintf("reason is too long (maximum %d chars)", storage.MaxReportReasonLength))
+	}
+	return moderate(params.Context, id, types.CommentRejected, reason)
+}
+
+func reportCommentResolver(params graphql.ResolveParams) (interface{}, error) {
+	userID, err := auth.RequireUser(params.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := params.Args["id"].(string)
+	reason, _ := params.Args["reason"].(string)
+	switch {
+	case reason == "":
+		return nil, errors.New("reason is empty")
+	case len(reason) > storage.MaxReportReasonLength:
+		return nil, errors.New(fmt.Sprintf("reason is too long (maximum %d chars)", storage.MaxReportReasonLength))
+	}
+
+	comment, err := storage.DataBase.GetCommentByID(params.Context, id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case comment.Status != types.CommentPublished:
+		return nil, storage.ErrCommentNotFound
+	case comment.AuthorID == userID:
+		return nil, errors.New("cannot report own comment")
+	}
+
+	err = storage.DataBase.ReportComment(params.Context, &types.Report{CommentID: id, UserID: userID, Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	return true, nil
+}
@@ -1,149 +1,86 @@
 package gql
 
 import (
+	_ "embed"
+
 	"github.com/graphql-go/graphql"
 )
 
-// PostType определяет тип постов для GraphQL
-var PostType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Post",
-	Fields: graphql.Fields{
-		"id": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.ID),
-		},
-		"title": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.String),
-		},
-		"content": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.String),
-		},
-		"createdAt": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.String),
-		},
-		"comments": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.NewList(graphql.ID)),
-		},
-		"allowComments": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.Boolean),
-		},
-	},
-})
-
-// CommentType определяет тип комментариев для GraphQL
-var CommentType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Comment",
-	Fields: graphql.Fields{
-		"id": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.ID),
-		},
-		"postID": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.ID),
-		},
-		"parentCommentID": &graphql.Field{
-			Type: graphql.ID,
-		},
-		"content": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.String),
-		},
-		"createdAt": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.String),
-		},
-		"replies": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.NewList(graphql.ID)),
-		},
-	},
-})
+// schemaSource описание схемы GraphQL, по которому строятся типы
+//
+//go:embed schema.graphql
+var schemaSource string
+
+// resolvers связывает поля schema.graphql с резолверами. Поля, которых здесь нет,
+// разрешаются по одноименным полям значений
+var resolvers = Resolvers{
+	"Post.authorID":    optionalIDResolver,
+	"Post.contentHTML": contentHTMLResolver,
+
+	"Thread.namespace":  threadNamespaceResolver,
+	"Thread.externalID": threadExternalIDResolver,
+	"Thread.post":       threadPostResolver,
+
+	"Comment.parentCommentID": optionalIDResolver,
+	"Comment.authorID":        optionalIDResolver,
+	"Comment.contentHTML":     contentHTMLResolver,
+	"Comment.mentions":        commentMentionsResolver,
+
+	"Notification.actorID": optionalIDResolver,
+	"Notification.read":    notificationReadResolver,
+
+	"WebhookDelivery.payload": deliveryPayloadResolver,
+
+	"Query.getPosts":                rateLimitedQuery(getPostsResolver),
+	"Query.getPostByID":             rateLimitedQuery(getPostByIDResolver),
+	"Query.threadByExternalID":      rateLimitedQuery(threadByExternalIDResolver),
+	"Query.getComments":             rateLimitedQuery(getCommentsResolver),
+	"Query.getCommentByID":          rateLimitedQuery(getCommentByIDResolver),
+	"Query.getNumberOfCommentPages": rateLimitedQuery(getNumberOfCommentPagesResolver),
+	"Query.getReplies":              rateLimitedQuery(getRepliesResolver),
+	"Query.commentsMentioning":      rateLimitedQuery(commentsMentioningResolver),
+	"Query.notifications":           rateLimitedQuery(notificationsResolver),
+	"Query.unreadNotificationCount": rateLimitedQuery(unreadNotificationCountResolver),
+	"Query.webhooks":                rateLimitedQuery(webhooksResolver),
+	"Query.webhookDeliveries":       rateLimitedQuery(webhookDeliveriesResolver),
+	"Query.moderationQueue":         rateLimitedQuery(moderationQueueResolver),
+	"Query.auditLog":                rateLimitedQuery(auditLogResolver),
+	"Query.exportDump":              rateLimitedQuery(exportDumpResolver),
+
+	"Mutation.addPost":                  rateLimitedMutation(addPostResolver),
+	"Mutation.addComment":               rateLimitedMutation(addCommentResolver),
+	"Mutation.addCommentToExternal":     rateLimitedMutation(addCommentToExternalResolver),
+	"Mutation.registerUser":             rateLimitedMutation(registerUserResolver),
+	"Mutation.markNotificationsRead":    rateLimitedMutation(markNotificationsReadResolver),
+	"Mutation.registerWebhook":          rateLimitedMutation(registerWebhookResolver),
+	"Mutation.deleteWebhook":            rateLimitedMutation(deleteWebhookResolver),
+	"Mutation.redeliverWebhookDelivery": rateLimitedMutation(redeliverWebhookDeliveryResolver),
+	"Mutation.approveComment":           rateLimitedMutation(approveCommentResolver),
+	"Mutation.rejectComment":            rateLimitedMutation(rejectCommentResolver),
+	"Mutation.reportComment":            rateLimitedMutation(reportCommentResolver),
+	"Mutation.importDump":               rateLimitedMutation(importDumpResolver),
+
+	"Subscription.notificationAdded": rateLimitedQuery(notificationAddedSubscriber),
+}
+
+// Schema конфигурация схемы GraphQL, построенная по schema.graphql, с расширением
+// RateLimitExtension. Сервер не запускается, если описание схемы и резолверы расходятся
+var Schema = mustBuildSchema()
 
 // QueryType определяет типы запросов для GraphQL
-var QueryType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Query",
-	Fields: graphql.Fields{
-		"getPosts": &graphql.Field{
-			Name:    "getPosts",
-			Type:    graphql.NewList(PostType),
-			Resolve: getPostsResolver,
-		},
-		"getPostByID": &graphql.Field{
-			Type: PostType,
-			Args: graphql.FieldConfigArgument{
-				"id": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-			},
-			Resolve: getPostByIDResolver,
-		},
-		"getComments": &graphql.Field{
-			Type: graphql.NewList(CommentType),
-			Args: graphql.FieldConfigArgument{
-				"postID": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-				"paginationSize": &graphql.ArgumentConfig{
-					Type: graphql.Int,
-				},
-			},
-			Resolve: getCommentsResolver,
-		},
-		"getCommentByID": &graphql.Field{
-			Type: CommentType,
-			Args: graphql.FieldConfigArgument{
-				"id": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-			},
-			Resolve: getCommentByIDResolver,
-		},
-		"getNumberOfCommentPages": &graphql.Field{
-			Type: graphql.NewNonNull(graphql.Int),
-			Args: graphql.FieldConfigArgument{
-				"postID": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-			},
-			Resolve: getNumberOfCommentPagesResolver,
-		},
-		"getReplies": &graphql.Field{
-			Type: graphql.NewList(CommentType),
-			Args: graphql.FieldConfigArgument{
-				"commentID": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-			},
-			Resolve: getRepliesResolver,
-		},
-	},
-})
+var QueryType = Schema.Query
 
 // MutationType определяет мутаций для GraphQL
-var MutationType = graphql.NewObject(graphql.ObjectConfig{
-	Name: "Mutation",
-	Fields: graphql.Fields{
-		"addPost": &graphql.Field{
-			Type: PostType,
-			Args: graphql.FieldConfigArgument{
-				"title": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.String),
-				},
-				"content": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.String),
-				},
-			},
-			Resolve: addPostResolver,
-		},
-		"addComment": &graphql.Field{
-			Type: CommentType,
-			Args: graphql.FieldConfigArgument{
-				"postId": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.ID),
-				},
-				"parentCommentId": &graphql.ArgumentConfig{
-					Type: graphql.ID,
-				},
-				"content": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.String),
-				},
-			},
-			Resolve: addCommentResolver,
-		},
-	},
-})
+var MutationType = Schema.Mutation
+
+// SubscriptionType определяет подписки для GraphQL
+var SubscriptionType = Schema.Subscription
+
+func mustBuildSchema() graphql.SchemaConfig {
+	config, err := BuildSchema(schemaSource, resolvers)
+	if err != nil {
+		panic("graphql schema: " + err.Error())
+	}
+	config.Extensions = []graphql.Extension{RateLimitExtension{}}
+	return config
+}
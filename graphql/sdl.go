@@ -0,0 +1,293 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Resolvers связывает поля схемы с резолверами по имени "Тип.поле". Для полей типа
+// подписок резолвер создает поток событий (graphql.Field.Subscribe), а значением поля
+// становится событие.
+type Resolvers map[string]graphql.FieldResolveFn
+
+// BuildSchema строит конфигурацию схемы по SDL source. Поля без резолвера разрешаются по
+// одноименному полю или ключу значения родителя, поэтому резолвер обязателен только для полей
+// корневых типов. Возвращает ошибку, если у поля корневого типа нет резолвера, резолвер не
+// соответствует ни одному полю или SDL ссылается на неизвестный тип.
+func BuildSchema(source string, resolvers Resolvers) (graphql.SchemaConfig, error) {
+	document, err := parser.Parse(parser.ParseParams{Source: source})
+	if err != nil {
+		return graphql.SchemaConfig{}, err
+	}
+
+	b := &schemaBuilder{
+		resolvers: resolvers,
+		bound:     make(map[string]bool),
+		types: map[string]graphql.Type{
+			"ID":      graphql.ID,
+			"String":  graphql.String,
+			"Int":     graphql.Int,
+			"Float":   graphql.Float,
+			"Boolean": graphql.Boolean,
+		},
+		roots: map[string]string{"query": "Query", "mutation": "Mutation", "subscription": "Subscription"},
+	}
+	b.declare(document)
+	if len(b.errs) == 0 {
+		b.check()
+	}
+	if len(b.errs) != 0 {
+		return graphql.SchemaConfig{}, errors.Join(b.errs...)
+	}
+
+	config := graphql.SchemaConfig{}
+	config.Query, _ = b.types[b.roots["query"]].(*graphql.Object)
+	config.Mutation, _ = b.types[b.roots["mutation"]].(*graphql.Object)
+	config.Subscription, _ = b.types[b.roots["subscription"]].(*graphql.Object)
+	if config.Query == nil {
+		return graphql.SchemaConfig{}, fmt.Errorf("query type %s is not defined", b.roots["query"])
+	}
+	for _, name := range b.names {
+		config.Types = append(config.Types, b.types[name])
+	}
+	return config, nil
+}
+
+type schemaBuilder struct {
+	resolvers Resolvers
+	// bound имена резолверов, связанных с полями
+	bound map[string]bool
+	types map[string]graphql.Type
+	// names имена типов SDL в порядке объявления
+	names   []string
+	objects []*ast.ObjectDefinition
+	inputs  []*ast.InputObjectDefinition
+	// roots имена корневых типов операций
+	roots map[string]string
+	errs  []error
+}
+
+// declare создает типы SDL; поля объектов и входных типов заполняются при построении схемы,
+// поэтому типы могут ссылаться друг на друга
+func (b *schemaBuilder) declare(document *ast.Document) {
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.SchemaDefinition:
+			for _, operation := range definition.OperationTypes {
+				b.roots[operation.Operation] = operation.Type.Name.Value
+			}
+		case *ast.EnumDefinition:
+			values := graphql.EnumValueConfigMap{}
+			for _, value := range definition.Values {
+				values[value.Name.Value] = &graphql.EnumValueConfig{Value: value.Name.Value, Description: description(value.Description)}
+			}
+			b.add(definition.Name.Value, graphql.NewEnum(graphql.EnumConfig{
+				Name:        definition.Name.Value,
+				Values:      values,
+				Description: description(definition.Description),
+			}))
+		case *ast.ObjectDefinition:
+			b.objects = append(b.objects, definition)
+			b.add(definition.Name.Value, graphql.NewObject(graphql.ObjectConfig{
+				Name:        definition.Name.Value,
+				Fields:      graphql.FieldsThunk(func() graphql.Fields { return b.fields(definition) }),
+				Description: description(definition.Description),
+			}))
+		case *ast.InputObjectDefinition:
+			b.inputs = append(b.inputs, definition)
+			b.add(definition.Name.Value, graphql.NewInputObject(graphql.InputObjectConfig{
+				Name:        definition.Name.Value,
+				Fields:      graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap { return b.inputFields(definition) }),
+				Description: description(definition.Description),
+			}))
+		default:
+			b.errs = append(b.errs, fmt.Errorf("unsupported definition %s", definition.GetKind()))
+		}
+	}
+}
+
+func (b *schemaBuilder) add(name string, t graphql.Type) {
+	if _, ok := b.types[name]; ok {
+		b.errs = append(b.errs, fmt.Errorf("type %s is defined more than once", name))
+		return
+	}
+	b.types[name] = t
+	b.names = append(b.names, name)
+}
+
+// check проверяет ссылки на типы, значения по умолчанию и связывание резолверов до
+// построения полей, которое не может вернуть ошибку
+func (b *schemaBuilder) check() {
+	roots := make(map[string]bool)
+	for _, name := range b.roots {
+		roots[name] = true
+	}
+
+	for _, object := range b.objects {
+		for _, field := range object.Fields {
+			name := object.Name.Value + "." + field.Name.Value
+			if !graphql.IsOutputType(b.typeOf(field.Type)) {
+				b.errs = append(b.errs, fmt.Errorf("field %s: %s is not an output type", name, typeName(field.Type)))
+			}
+			for _, arg := range field.Arguments {
+				b.checkInput("argument "+name+"("+arg.Name.Value+")", arg)
+			}
+			if _, ok := b.resolvers[name]; ok {
+				b.bound[name] = true
+			} else if roots[object.Name.Value] {
+				b.errs = append(b.errs, fmt.Errorf("field %s has no resolver", name))
+			}
+		}
+	}
+	for _, input := range b.inputs {
+		for _, field := range input.Fields {
+			b.checkInput("input field "+input.Name.Value+"."+field.Name.Value, field)
+		}
+	}
+
+	var unbound []string
+	for name := range b.resolvers {
+		if !b.bound[name] {
+			unbound = append(unbound, name)
+		}
+	}
+	slices.Sort(unbound)
+	for _, name := range unbound {
+		b.errs = append(b.errs, fmt.Errorf("resolver %s is not bound to any field", name))
+	}
+}
+
+func (b *schemaBuilder) checkInput(name string, value *ast.InputValueDefinition) {
+	if !graphql.IsInputType(b.typeOf(value.Type)) {
+		b.errs = append(b.errs, fmt.Errorf("%s: %s is not an input type", name, typeName(value.Type)))
+		return
+	}
+	if value.DefaultValue != nil {
+		if _, err := defaultValue(value.DefaultValue); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+func (b *schemaBuilder) fields(object *ast.ObjectDefinition) graphql.Fields {
+	subscription := object.Name.Value == b.roots["subscription"]
+	fields := graphql.Fields{}
+	for _, definition := range object.Fields {
+		field := &graphql.Field{
+			Name:        definition.Name.Value,
+			Type:        b.typeOf(definition.Type).(graphql.Output),
+			Args:        graphql.FieldConfigArgument{},
+			Description: description(definition.Description),
+		}
+		for _, arg := range definition.Arguments {
+			field.Args[arg.Name.Value] = &graphql.ArgumentConfig{
+				Type:         b.typeOf(arg.Type).(graphql.Input),
+				DefaultValue: b.defaultValue(arg.DefaultValue),
+				Description:  description(arg.Description),
+			}
+		}
+
+		resolve := b.resolvers[object.Name.Value+"."+definition.Name.Value]
+		if subscription {
+			field.Subscribe = resolve
+			field.Resolve = func(params graphql.ResolveParams) (interface{}, error) {
+				return params.Source, nil
+			}
+		} else {
+			field.Resolve = resolve
+		}
+		fields[definition.Name.Value] = field
+	}
+	return fields
+}
+
+func (b *schemaBuilder) inputFields(input *ast.InputObjectDefinition) graphql.InputObjectConfigFieldMap {
+	fields := graphql.InputObjectConfigFieldMap{}
+	for _, definition := range input.Fields {
+		fields[definition.Name.Value] = &graphql.InputObjectFieldConfig{
+			Type:         b.typeOf(definition.Type).(graphql.Input),
+			DefaultValue: b.defaultValue(definition.DefaultValue),
+			Description:  description(definition.Description),
+		}
+	}
+	return fields
+}
+
+// typeOf возвращает тип по ссылке SDL или nil для неизвестного типа
+func (b *schemaBuilder) typeOf(t ast.Type) graphql.Type {
+	switch t := t.(type) {
+	case *ast.NonNull:
+		if inner := b.typeOf(t.Type); inner != nil {
+			return graphql.NewNonNull(inner)
+		}
+	case *ast.List:
+		if inner := b.typeOf(t.Type); inner != nil {
+			return graphql.NewList(inner)
+		}
+	case *ast.Named:
+		return b.types[t.Name.Value]
+	}
+	return nil
+}
+
+// defaultValue возвращает значение по умолчанию, уже проверенное в check
+func (b *schemaBuilder) defaultValue(value ast.Value) interface{} {
+	if value == nil {
+		return nil
+	}
+	result, _ := defaultValue(value)
+	return result
+}
+
+// defaultValue преобразует литерал SDL в значение Go
+func defaultValue(value ast.Value) (interface{}, error) {
+	switch value := value.(type) {
+	case *ast.StringValue:
+		return value.Value, nil
+	case *ast.EnumValue:
+		return value.Value, nil
+	case *ast.BooleanValue:
+		return value.Value, nil
+	case *ast.IntValue:
+		return strconv.Atoi(value.Value)
+	case *ast.FloatValue:
+		return strconv.ParseFloat(value.Value, 64)
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(value.Values))
+		for _, item := range value.Values {
+			v, err := defaultValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unsupported default value %s", value.GetKind())
+}
+
+// typeName возвращает имя типа, на который ссылается t
+func typeName(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.NonNull:
+		return typeName(t.Type)
+	case *ast.List:
+		return typeName(t.Type)
+	case *ast.Named:
+		return t.Name.Value
+	}
+	return ""
+}
+
+func description(value *ast.StringValue) string {
+	if value == nil {
+		return ""
+	}
+	return value.Value
+}
@@ -0,0 +1,66 @@
+package gql
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/ratelimit"
+	"graphql-comments/spam"
+	"graphql-comments/types"
+)
+
+// ContentFilter проверяет новые комментарии перед сохранением; nil отключает проверку
+var ContentFilter spam.Filter
+
+// Classifiers классификаторы спама арендаторов, обучаемые на решениях их модераторов
+var Classifiers = spam.NewClassifiers()
+
+// checkComment прогоняет комментарий через ContentFilter и возвращает статус,
+// с которым его следует сохранить
+func checkComment(ctx context.Context, postID, parentCommentID, content string) (string, error) {
+	if ContentFilter == nil {
+		return types.CommentPublished, nil
+	}
+
+	decision, err := ContentFilter.Check(ctx, candidate(ctx, postID, parentCommentID, content))
+	if err != nil {
+		return "", err
+	}
+
+	switch decision.Verdict {
+	case spam.Reject:
+		return "", &spam.RejectedError{Decision: decision}
+	case spam.Pending:
+		return types.CommentPending, nil
+	}
+	return types.CommentPublished, nil
+}
+
+// commitComment сообщает ContentFilter о сохранении комментария, прошедшего checkComment
+func commitComment(ctx context.Context, comment *types.Comment) {
+	if committer, ok := ContentFilter.(spam.Committer); ok {
+		committer.Commit(ctx, candidate(ctx, comment.PostID, comment.ParentCommentID, comment.Content))
+	}
+}
+
+func candidate(ctx context.Context, postID, parentCommentID, content string) *spam.Candidate {
+	// Анонимные авторы различаются по ключу клиента
+	author := auth.UserID(ctx)
+	if author == "" {
+		author = "client:" + ratelimit.Client(ctx)
+	}
+	return &spam.Candidate{
+		Author:          author,
+		PostID:          postID,
+		ParentCommentID: parentCommentID,
+		Content:         content,
+	}
+}
+
+// Visible сообщает, виден ли комментарий текущему пользователю: комментарии на модерации
+// видны только автору и администраторам
+func Visible(ctx context.Context, comment *types.Comment) bool {
+	if comment.Status == types.CommentPublished || auth.IsAdmin(ctx) {
+		return true
+	}
+	return comment.AuthorID != "" && comment.AuthorID == auth.UserID(ctx)
+}
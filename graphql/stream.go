@@ -0,0 +1,65 @@
+package gql
+
+import (
+	"encoding/json"
+	"github.com/graphql-go/graphql"
+	"net/http"
+	"time"
+)
+
+// StreamKeepAlive интервал, с которым в простаивающий поток отправляются комментарии,
+// чтобы прокси не закрывали соединение
+var StreamKeepAlive = 15 * time.Second
+
+// StreamHandler выполняет подписки GraphQL и передает результаты в формате Server-Sent Events:
+// каждый результат отправляется событием "next", завершение подписки — событием "complete".
+// Подписка действует, пока клиент не закроет соединение.
+func StreamHandler(schema *graphql.Schema) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := ReadRequest(r)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "unable to read request", nil)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			WriteError(w, http.StatusInternalServerError, "streaming is not supported", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		results := graphql.Subscribe(graphql.Params{
+			Schema:         *schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        r.Context(),
+		})
+
+		keepAlive := time.NewTicker(StreamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case result, ok := <-results:
+				if !ok {
+					w.Write([]byte("event: complete\ndata:\n\n"))
+					flusher.Flush()
+					return
+				}
+				data, _ := json.Marshal(result)
+				w.Write([]byte("event: next\ndata: "))
+				w.Write(data)
+				w.Write([]byte("\n\n"))
+				flusher.Flush()
+			case <-keepAlive.C:
+				w.Write([]byte(": keep-alive\n\n"))
+				flusher.Flush()
+			}
+		}
+	})
+}
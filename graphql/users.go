@@ -0,0 +1,32 @@
+package gql
+
+import (
+	"fmt"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"regexp"
+)
+
+// TokenSecret подписывает токены, выдаваемые при регистрации пользователей.
+// Если не задан, регистрация отключена.
+var TokenSecret string
+
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// authPayload результат регистрации пользователя
+type authPayload struct {
+	User  *types.User
+	Token string
+}
+
+func validateUserName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("name is empty")
+	case len(name) > storage.MaxUserNameLength:
+		return fmt.Errorf("name is too long (maximum %d chars)", storage.MaxUserNameLength)
+	case !userNamePattern.MatchString(name):
+		return fmt.Errorf("name may contain only latin letters, digits and underscores")
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package importers
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"graphql-comments/types"
+	"io"
+	"strings"
+	"time"
+)
+
+type disqusAuthor struct {
+	Name     string `xml:"name"`
+	Username string `xml:"username"`
+}
+
+// name возвращает отображаемое имя автора или имя учетной записи Disqus
+func (a *disqusAuthor) name() string {
+	return cmp.Or(strings.TrimSpace(a.Name), a.Username)
+}
+
+// disqusRef ссылка на ветку или комментарий по внутреннему ID Disqus (атрибут dsq:id)
+type disqusRef struct {
+	ID string `xml:"http://disqus.com/disqus-internals id,attr"`
+}
+
+type disqusThread struct {
+	ID         string       `xml:"http://disqus.com/disqus-internals id,attr"`
+	Identifier string       `xml:"id"`
+	Link       string       `xml:"link"`
+	Title      string       `xml:"title"`
+	Message    string       `xml:"message"`
+	CreatedAt  string       `xml:"createdAt"`
+	Author     disqusAuthor `xml:"author"`
+	IsClosed   bool         `xml:"isClosed"`
+	IsDeleted  bool         `xml:"isDeleted"`
+}
+
+type disqusPost struct {
+	ID        string `xml:"http://disqus.com/disqus-internals id,attr"`
+	Message   string `xml:"message"`
+	CreatedAt string `xml:"createdAt"`
+	IsDeleted bool   `xml:"isDeleted"`
+	IsSpam    bool   `xml:"isSpam"`
+	// IsApproved присутствует не во всех выгрузках; отсутствие означает одобренный комментарий
+	IsApproved string       `xml:"isApproved"`
+	Author     disqusAuthor `xml:"author"`
+	Thread     disqusRef    `xml:"thread"`
+	Parent     disqusRef    `xml:"parent"`
+}
+
+// ParseDisqus разбирает XML выгрузку Disqus: ветки (thread) становятся постами или ветками
+// внешних ресурсов, комментарии (post) — комментариями с исходными авторами, временем и
+// вложенностью. Удаленные и отмеченные как спам комментарии пропускаются, неодобренные
+// загружаются на модерацию.
+func ParseDisqus(r io.Reader, options Options) (*Data, error) {
+	b := newBuilder("disqus", options)
+	err := decode(r, 1, func(decoder *xml.Decoder, start xml.StartElement) error {
+		switch start.Name.Local {
+		case "thread":
+			var thread disqusThread
+			if err := decoder.DecodeElement(&thread, &start); err != nil {
+				return err
+			}
+			return b.disqusThread(&thread)
+		case "post":
+			var post disqusPost
+			if err := decoder.DecodeElement(&post, &start); err != nil {
+				return err
+			}
+			return b.disqusPost(&post)
+		}
+		return decoder.Skip()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing Disqus export: %w", err)
+	}
+	return b.build(), nil
+}
+
+func (b *builder) disqusThread(thread *disqusThread) error {
+	if thread.ID == "" {
+		return errors.New("thread without dsq:id")
+	}
+	createdAt, err := time.Parse(time.RFC3339, strings.TrimSpace(thread.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("thread %s: %w", thread.ID, err)
+	}
+	skip := ""
+	if thread.IsDeleted {
+		skip = SkipDeleted
+	}
+	externalID := cmp.Or(strings.TrimSpace(thread.Identifier), strings.TrimSpace(thread.Link))
+	b.page(thread.ID, thread.Title, cmp.Or(markdown(thread.Message), thread.Link), thread.Author.name(), externalID, createdAt, !thread.IsClosed, skip)
+	return nil
+}
+
+func (b *builder) disqusPost(post *disqusPost) error {
+	if post.ID == "" {
+		return errors.New("post without dsq:id")
+	}
+	createdAt, err := time.Parse(time.RFC3339, strings.TrimSpace(post.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("post %s: %w", post.ID, err)
+	}
+
+	status := types.CommentPublished
+	if strings.TrimSpace(post.IsApproved) == "false" {
+		status = types.CommentPending
+	}
+	skip := ""
+	switch {
+	case post.IsDeleted:
+		skip = SkipDeleted
+	case post.IsSpam:
+		skip = SkipSpam
+	}
+	b.comment(post.ID, post.Thread.ID, post.Parent.ID, post.Author.name(), markdown(post.Message), createdAt, status, skip)
+	return nil
+}
+
+// decode вызывает handle для каждого элемента на глубине depth (корневой элемент на глубине
+// 0). handle должен прочитать элемент целиком через DecodeElement или Skip.
+func decode(r io.Reader, depth int, handle func(decoder *xml.Decoder, start xml.StartElement) error) error {
+	decoder := xml.NewDecoder(r)
+	level := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			if level != 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if level == depth {
+				if err := handle(decoder, token); err != nil {
+					return err
+				}
+				continue
+			}
+			level++
+		case xml.EndElement:
+			level--
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package importers
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var extraNewlines = regexp.MustCompile(`\n{3,}`)
+
+// markdown преобразует HTML комментария исходной системы в Markdown, который отображает
+// сервер: абзацы и переводы строк сохраняются, ссылки и выделение переводятся в разметку
+// Markdown, остальные теги отбрасываются
+func markdown(source string) string {
+	var b strings.Builder
+	// links адреса открытых ссылок; пустой адрес у ссылки без href
+	var links []string
+
+	tokenizer := html.NewTokenizer(strings.NewReader(source))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			text := extraNewlines.ReplaceAllString(b.String(), "\n\n")
+			return strings.TrimSpace(text)
+		case html.TextToken:
+			b.Write(tokenizer.Text())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "p", "div", "blockquote", "ul", "ol":
+				b.WriteString("\n\n")
+			case "li":
+				b.WriteString("\n- ")
+			case "br":
+				b.WriteString("\n")
+			case "b", "strong":
+				b.WriteString("**")
+			case "i", "em":
+				b.WriteString("*")
+			case "code":
+				b.WriteString("`")
+			case "a":
+				href := ""
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					if string(key) == "href" {
+						href = string(value)
+					}
+				}
+				links = append(links, href)
+				if href != "" {
+					b.WriteString("[")
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "p", "div", "blockquote", "ul", "ol":
+				b.WriteString("\n\n")
+			case "b", "strong":
+				b.WriteString("**")
+			case "i", "em":
+				b.WriteString("*")
+			case "code":
+				b.WriteString("`")
+			case "a":
+				if len(links) == 0 {
+					continue
+				}
+				href := links[len(links)-1]
+				links = links[:len(links)-1]
+				if href != "" {
+					b.WriteString("](" + href + ")")
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package importers переносит комментарии из выгрузок Disqus (XML) и WordPress (WXR) в любое
+// хранилище storage.DataStore. Выгрузка разбирается целиком в Data, после чего по ней можно
+// построить отчет пробного запуска или загрузить ее через формат пакета dump. ID записей
+// выводятся из ID исходной системы, поэтому повторный импорт той же выгрузки не создает
+// дубликатов.
+package importers
+
+import (
+	"cmp"
+	"context"
+	"graphql-comments/dump"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Причины пропуска элементов выгрузки
+const (
+	// SkipDeleted удаленные страницы и комментарии
+	SkipDeleted = "deleted"
+	// SkipSpam комментарии, отмеченные как спам
+	SkipSpam = "spam"
+	// SkipPingback уведомления о ссылках WordPress (pingback и trackback)
+	SkipPingback = "pingback"
+	// SkipUnpublished черновики, вложения и другие неопубликованные записи WordPress
+	SkipUnpublished = "unpublished"
+	// SkipEmpty страницы без комментариев
+	SkipEmpty = "empty"
+)
+
+// Options настройки разбора выгрузки
+type Options struct {
+	// Namespace, если задан, превращает страницы выгрузки в ветки комментариев внешних
+	// ресурсов этого пространства имен; внешним ID служит идентификатор Disqus или адрес
+	// страницы. Иначе страницы загружаются постами.
+	Namespace string
+}
+
+// Data разобранная выгрузка. Ответы на пропущенные комментарии прикреплены к ближайшему
+// сохраненному предку, комментарии без страницы или родителя отброшены.
+type Data struct {
+	Users    []*types.User
+	Posts    []*types.Post
+	Comments []*types.Comment
+	// Skipped число пропущенных страниц и комментариев по причинам пропуска
+	Skipped map[string]int
+	// Orphaned комментарии, страница или родитель которых отсутствуют в выгрузке
+	Orphaned int
+	// Reparented ответы на пропущенные комментарии
+	Reparented int
+}
+
+// Report итог разбора и загрузки выгрузки
+type Report struct {
+	Users      int            `json:"users"`
+	Posts      int            `json:"posts"`
+	Comments   int            `json:"comments"`
+	Skipped    map[string]int `json:"skipped"`
+	Orphaned   int            `json:"orphaned"`
+	Reparented int            `json:"reparented"`
+	// Result итог загрузки в хранилище; nil при пробном запуске
+	Result *dump.Result `json:"result,omitempty"`
+}
+
+// Report возвращает отчет о разобранной выгрузке без загрузки в хранилище
+func (d *Data) Report() *Report {
+	return &Report{
+		Users:      len(d.Users),
+		Posts:      len(d.Posts),
+		Comments:   len(d.Comments),
+		Skipped:    d.Skipped,
+		Orphaned:   d.Orphaned,
+		Reparented: d.Reparented,
+	}
+}
+
+// Import загружает выгрузку в хранилище importer.Store с политикой конфликтов importer
+func (d *Data) Import(ctx context.Context, importer *dump.Importer) (*Report, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(d.write(writer))
+	}()
+
+	result, err := importer.Import(ctx, reader, nil)
+	// Останавливает запись, если импорт прерван раньше
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return nil, err
+	}
+	report := d.Report()
+	report.Result = result
+	return report, nil
+}
+
+// write записывает выгрузку в формате пакета dump: пользователи, посты, затем комментарии
+// от старых к новым
+func (d *Data) write(w io.Writer) error {
+	writer, err := dump.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, user := range d.Users {
+		if err := writer.User(user); err != nil {
+			return err
+		}
+	}
+	for _, post := range d.Posts {
+		if err := writer.Post(post); err != nil {
+			return err
+		}
+	}
+	for _, comment := range d.Comments {
+		if err := writer.Comment(comment); err != nil {
+			return err
+		}
+	}
+	_, err = writer.Close()
+	return err
+}
+
+// page страница исходной системы
+type page struct {
+	post   *types.Post
+	author string
+	// skip причина пропуска страницы
+	skip     string
+	comments int
+}
+
+// entry комментарий исходной системы
+type entry struct {
+	comment *types.Comment
+	author  string
+	page    string
+	parent  string
+	// skip причина пропуска комментария
+	skip string
+	// target ID сохраняемого комментария, к которому прикрепляются ответы на этот; lost
+	// сообщает, что ответы на этот комментарий некуда прикрепить
+	target                         string
+	resolved, visiting, kept, lost bool
+}
+
+// builder собирает Data из страниц и комментариев исходной системы source
+type builder struct {
+	source  string
+	options Options
+	pages   map[string]*page
+	entries map[string]*entry
+	// order порядок комментариев в выгрузке
+	order []*entry
+	users map[string]*types.User
+	data  *Data
+}
+
+func newBuilder(source string, options Options) *builder {
+	return &builder{
+		source:  source,
+		options: options,
+		pages:   make(map[string]*page),
+		entries: make(map[string]*entry),
+		users:   make(map[string]*types.User),
+		data:    &Data{Skipped: make(map[string]int)},
+	}
+}
+
+// id возвращает ID записи kind с ID id исходной системы
+func (b *builder) id(kind, id string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(b.source+":"+kind+":"+id)).String()
+}
+
+// page добавляет страницу с ID id исходной системы; externalID используется в режиме
+// веток внешних ресурсов, skip — причина пропуска страницы
+func (b *builder) page(id, title, content, author, externalID string, createdAt time.Time, allowComments bool, skip string) {
+	post := &types.Post{
+		ID:            b.id("page", id),
+		CreatedAt:     createdAt,
+		Comments:      []string{},
+		AllowComments: allowComments,
+	}
+	if b.options.Namespace != "" {
+		post.Namespace = b.options.Namespace
+		post.ExternalID = externalID
+	} else {
+		post.Title = truncate(cmp.Or(strings.TrimSpace(title), externalID), storage.MaxPostTitleLength)
+		post.Content = content
+	}
+	b.pages[id] = &page{post: post, author: author, skip: skip}
+}
+
+// comment добавляет комментарий с ID id исходной системы к странице pageID; parentID пуст
+// для комментариев верхнего уровня
+func (b *builder) comment(id, pageID, parentID, author, content string, createdAt time.Time, status, skip string) {
+	e := &entry{
+		comment: &types.Comment{
+			ID:        b.id("comment", id),
+			Content:   content,
+			CreatedAt: createdAt,
+			Status:    status,
+		},
+		author: author,
+		page:   pageID,
+		parent: parentID,
+		skip:   skip,
+	}
+	b.entries[id] = e
+	b.order = append(b.order, e)
+}
+
+// build связывает комментарии со страницами и родителями и возвращает Data
+func (b *builder) build() *Data {
+	for _, e := range b.order {
+		b.resolve(e)
+		if !e.kept {
+			continue
+		}
+		p := b.pages[e.page]
+		p.comments++
+		e.comment.PostID = p.post.ID
+		e.comment.AuthorID = b.user(e.author, e.comment.CreatedAt)
+		b.data.Comments = append(b.data.Comments, e.comment)
+	}
+
+	for _, p := range b.pages {
+		switch {
+		case p.skip != "":
+			b.data.Skipped[p.skip]++
+		case p.comments == 0:
+			b.data.Skipped[SkipEmpty]++
+		default:
+			if p.post.Namespace == "" {
+				p.post.AuthorID = b.user(p.author, p.post.CreatedAt)
+			}
+			b.data.Posts = append(b.data.Posts, p.post)
+		}
+	}
+
+	for _, user := range b.users {
+		b.data.Users = append(b.data.Users, user)
+	}
+	slices.SortFunc(b.data.Users, func(a, b *types.User) int { return cmp.Compare(a.Name, b.Name) })
+	slices.SortFunc(b.data.Posts, func(a, b *types.Post) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	slices.SortStableFunc(b.data.Comments, func(a, b *types.Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return b.data
+}
+
+// resolve решает, сохраняется ли комментарий, и определяет его родителя среди сохраняемых
+func (b *builder) resolve(e *entry) {
+	if e.resolved {
+		return
+	}
+	e.visiting = true
+	e.target, e.lost = b.parent(e)
+	e.visiting = false
+	e.resolved = true
+
+	switch p := b.pages[e.page]; {
+	case e.skip != "":
+		b.data.Skipped[e.skip]++
+	case p != nil && p.skip != "":
+		b.data.Skipped[p.skip]++
+	case e.lost:
+		b.data.Orphaned++
+	default:
+		if e.parent != "" && e.target != b.id("comment", e.parent) {
+			b.data.Reparented++
+		}
+		e.comment.ParentCommentID = e.target
+		e.kept = true
+		e.target = e.comment.ID
+	}
+}
+
+// parent возвращает ID сохраняемого комментария, к которому прикрепляется e, или lost, если
+// страница e или цепочка ее родителей потеряна
+func (b *builder) parent(e *entry) (target string, lost bool) {
+	if p, ok := b.pages[e.page]; !ok || p.skip != "" {
+		return "", true
+	}
+	if e.parent == "" {
+		return "", false
+	}
+	ancestor, ok := b.entries[e.parent]
+	// Цикл в ссылках на родителей
+	if !ok || ancestor.visiting {
+		return "", true
+	}
+	b.resolve(ancestor)
+	return ancestor.target, ancestor.lost
+}
+
+var invalidUserName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// user возвращает ID пользователя с именем name, приведенным к допустимому виду, или пустую
+// строку для анонимного автора. Авторы, имена которых совпадают без учета регистра,
+// считаются одним пользователем.
+func (b *builder) user(name string, createdAt time.Time) string {
+	name = strings.Trim(invalidUserName.ReplaceAllString(strings.TrimSpace(name), "_"), "_")
+	name = truncate(name, storage.MaxUserNameLength)
+	if name == "" {
+		return ""
+	}
+
+	key := strings.ToLower(name)
+	user, ok := b.users[key]
+	if !ok {
+		user = &types.User{ID: b.id("user", key), Name: name, CreatedAt: createdAt}
+		b.users[key] = user
+	}
+	if createdAt.Before(user.CreatedAt) {
+		user.CreatedAt = createdAt
+	}
+	return user.ID
+}
+
+// truncate сокращает s до n символов
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+package importers
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"graphql-comments/types"
+	"io"
+	"strings"
+	"time"
+)
+
+// wordpressTime формат дат выгрузки WordPress
+const wordpressTime = "2006-01-02 15:04:05"
+
+type wordpressItem struct {
+	Title         string             `xml:"title"`
+	Link          string             `xml:"link"`
+	Creator       string             `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Content       string             `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID        string             `xml:"post_id"`
+	PostDate      string             `xml:"post_date"`
+	PostDateGMT   string             `xml:"post_date_gmt"`
+	CommentStatus string             `xml:"comment_status"`
+	Status        string             `xml:"status"`
+	PostType      string             `xml:"post_type"`
+	Comments      []wordpressComment `xml:"comment"`
+}
+
+type wordpressComment struct {
+	ID      string `xml:"comment_id"`
+	Author  string `xml:"comment_author"`
+	Date    string `xml:"comment_date"`
+	DateGMT string `xml:"comment_date_gmt"`
+	Content string `xml:"comment_content"`
+	// Approved 1 — опубликован, 0 — ожидает модерации, spam или trash — удален
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"`
+	Parent   string `xml:"comment_parent"`
+}
+
+// ParseWordPress разбирает выгрузку WordPress в формате WXR: опубликованные записи и
+// страницы становятся постами или ветками внешних ресурсов с адресом страницы в качестве
+// внешнего ID, их комментарии — комментариями с исходными авторами, временем и
+// вложенностью. Спам, удаленные комментарии, pingback и trackback пропускаются,
+// неодобренные комментарии загружаются на модерацию.
+func ParseWordPress(r io.Reader, options Options) (*Data, error) {
+	b := newBuilder("wordpress", options)
+	// Записи лежат в rss/channel/item
+	err := decode(r, 2, func(decoder *xml.Decoder, start xml.StartElement) error {
+		if start.Name.Local != "item" {
+			return decoder.Skip()
+		}
+		var item wordpressItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		return b.wordpressItem(&item)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing WordPress export: %w", err)
+	}
+	return b.build(), nil
+}
+
+func (b *builder) wordpressItem(item *wordpressItem) error {
+	id := strings.TrimSpace(item.PostID)
+	if id == "" {
+		return errors.New("item without wp:post_id")
+	}
+	createdAt, err := wordpressDate(item.PostDateGMT, item.PostDate)
+	if err != nil {
+		return fmt.Errorf("item %s: %w", id, err)
+	}
+
+	skip := ""
+	switch {
+	case item.Status == "trash":
+		skip = SkipDeleted
+	case item.Status != "publish" || (item.PostType != "post" && item.PostType != "page"):
+		skip = SkipUnpublished
+	}
+	b.page(id, item.Title, markdown(item.Content), item.Creator, strings.TrimSpace(item.Link), createdAt, item.CommentStatus != "closed", skip)
+
+	for _, comment := range item.Comments {
+		if err := b.wordpressComment(id, &comment); err != nil {
+			return fmt.Errorf("item %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (b *builder) wordpressComment(pageID string, comment *wordpressComment) error {
+	id := strings.TrimSpace(comment.ID)
+	if id == "" {
+		return errors.New("comment without wp:comment_id")
+	}
+	createdAt, err := wordpressDate(comment.DateGMT, comment.Date)
+	if err != nil {
+		return fmt.Errorf("comment %s: %w", id, err)
+	}
+
+	status := types.CommentPublished
+	skip := ""
+	switch {
+	case comment.Type == "pingback" || comment.Type == "trackback":
+		skip = SkipPingback
+	case comment.Approved == "spam":
+		skip = SkipSpam
+	case comment.Approved == "trash":
+		skip = SkipDeleted
+	case comment.Approved == "0":
+		status = types.CommentPending
+	}
+	parent := strings.TrimSpace(comment.Parent)
+	if parent == "0" {
+		parent = ""
+	}
+	b.comment(id, pageID, parent, comment.Author, markdown(comment.Content), createdAt, status, skip)
+	return nil
+}
+
+// wordpressDate разбирает время в UTC, а если оно не заполнено — местное время сайта
+func wordpressDate(gmt, local string) (time.Time, error) {
+	value := strings.TrimSpace(gmt)
+	if value == "" || strings.HasPrefix(value, "0000-00-00") {
+		value = strings.TrimSpace(local)
+	}
+	return time.Parse(wordpressTime, cmp.Or(value, "missing date"))
+}
@@ -0,0 +1,507 @@
+// Package loadtest воспроизводит против работающего сервера смесь запросов и мутаций GraphQL
+// с заданной конкурентностью и считает процентили задержек и долю ошибок по каждому
+// сценарию. ID постов и комментариев для запросов берутся из ответов сервера, поэтому
+// сервер должен содержать хотя бы один пост (см. пакет seed).
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Сценарии нагрузки
+const (
+	// ScenarioPosts список постов
+	ScenarioPosts = "posts"
+	// ScenarioPost пост по ID
+	ScenarioPost = "post"
+	// ScenarioComments комментарии поста
+	ScenarioComments = "comments"
+	// ScenarioReplies ответы на комментарий
+	ScenarioReplies = "replies"
+	// ScenarioPages число страниц комментариев поста
+	ScenarioPages = "pages"
+	// ScenarioAddComment добавление комментария или ответа
+	ScenarioAddComment = "addComment"
+)
+
+const (
+	// DefaultConcurrency число одновременных клиентов по умолчанию
+	DefaultConcurrency = 10
+	// DefaultTimeout время ожидания ответа на один запрос по умолчанию
+	DefaultTimeout = 30 * time.Second
+	// maxKnownComments число ID комментариев, запоминаемых для запросов ответов
+	maxKnownComments = 10000
+	// maxErrorMessages число различных сообщений об ошибках в отчете
+	maxErrorMessages = 20
+)
+
+// DefaultMix относительные веса сценариев по умолчанию: преобладает чтение комментариев
+var DefaultMix = map[string]int{
+	ScenarioPosts:      1,
+	ScenarioPost:       3,
+	ScenarioComments:   5,
+	ScenarioReplies:    2,
+	ScenarioPages:      1,
+	ScenarioAddComment: 1,
+}
+
+// Config параметры нагрузки
+type Config struct {
+	// URL адрес GraphQL сервера, например http://localhost:8084/graphql
+	URL         string
+	Concurrency int
+	// Duration длительность нагрузки; 0 — до выполнения Requests запросов
+	Duration time.Duration
+	// Requests общее число запросов; 0 — без ограничения до истечения Duration
+	Requests int
+	// Mix относительные веса сценариев; по умолчанию DefaultMix
+	Mix map[string]int
+	// Header добавляется к каждому запросу, например для авторизации или выбора арендатора
+	Header http.Header
+	Client *http.Client
+	// Seed делает выбор сценариев и ID воспроизводимым
+	Seed uint64
+}
+
+// Stats задержки и ошибки сценария; задержки в наносекундах
+type Stats struct {
+	Scenario  string        `json:"scenario"`
+	Requests  int           `json:"requests"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"errorRate"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P95       time.Duration `json:"p95"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// Report итог нагрузки
+type Report struct {
+	Elapsed time.Duration `json:"elapsed"`
+	// Throughput число запросов в секунду
+	Throughput float64 `json:"throughput"`
+	Total      Stats   `json:"total"`
+	Scenarios  []Stats `json:"scenarios"`
+	// ErrorMessages число ошибок по сообщениям, не более maxErrorMessages различных
+	ErrorMessages map[string]int `json:"errorMessages,omitempty"`
+}
+
+// scenario формирует запрос и обрабатывает данные успешного ответа
+type scenario func(w *worker) (query string, variables map[string]interface{}, handle func(data json.RawMessage))
+
+var scenarios = map[string]scenario{
+	ScenarioPosts:      posts,
+	ScenarioPost:       post,
+	ScenarioComments:   comments,
+	ScenarioReplies:    replies,
+	ScenarioPages:      pages,
+	ScenarioAddComment: addComment,
+}
+
+// Validate проверяет параметры нагрузки
+func (c *Config) Validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("URL is empty")
+	case c.Concurrency < 0 || c.Requests < 0 || c.Duration < 0:
+		return errors.New("concurrency, requests and duration must not be negative")
+	case c.Duration == 0 && c.Requests == 0:
+		return errors.New("either duration or requests is required")
+	}
+	total := 0
+	for name, weight := range c.Mix {
+		if _, ok := scenarios[name]; !ok {
+			return fmt.Errorf("unknown scenario %q", name)
+		}
+		if weight < 0 {
+			return fmt.Errorf("negative weight of scenario %q", name)
+		}
+		total += weight
+	}
+	if c.Mix != nil && total == 0 {
+		return errors.New("scenario weights sum to zero")
+	}
+	return nil
+}
+
+// Run выполняет нагрузку и возвращает отчет. Запросы, прерванные отменой ctx или истечением
+// Duration, в отчет не попадают.
+func Run(ctx context.Context, config Config) (*Report, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Concurrency == 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.Mix == nil {
+		config.Mix = DefaultMix
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	r := &runner{config: config}
+	for name, weight := range config.Mix {
+		if weight > 0 {
+			r.names = append(r.names, name)
+		}
+	}
+	slices.Sort(r.names)
+	for _, name := range r.names {
+		r.weights = append(r.weights, config.Mix[name])
+	}
+
+	// Начальный список постов, без которого сценариям не на что ссылаться
+	discovery := r.worker(0)
+	query, variables, handle := posts(discovery)
+	data, err := discovery.do(ctx, query, variables)
+	if err != nil {
+		return nil, fmt.Errorf("listing posts: %w", err)
+	}
+	handle(data)
+	if len(r.posts) == 0 {
+		return nil, errors.New("server has no posts; seed it first")
+	}
+
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+
+	workers := make([]*worker, config.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		workers[i] = r.worker(uint64(i) + 1)
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(ctx)
+		}(workers[i])
+	}
+	wg.Wait()
+	return r.report(workers, time.Since(start)), nil
+}
+
+type runner struct {
+	config  Config
+	names   []string
+	weights []int
+	issued  atomic.Int64
+
+	mu       sync.Mutex
+	posts    []string
+	comments []node
+}
+
+func (r *runner) worker(stream uint64) *worker {
+	return &worker{
+		runner:    r,
+		random:    rand.New(rand.NewPCG(r.config.Seed, stream)),
+		durations: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+		messages:  make(map[string]int),
+	}
+}
+
+// worker клиент нагрузки; собирает результаты без блокировок и отдает их в отчет по окончании
+type worker struct {
+	runner    *runner
+	random    *rand.Rand
+	durations map[string][]time.Duration
+	errors    map[string]int
+	messages  map[string]int
+}
+
+func (w *worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if limit := w.runner.config.Requests; limit > 0 && w.runner.issued.Add(1) > int64(limit) {
+			return
+		}
+
+		name := w.scenario()
+		query, variables, handle := scenarios[name](w)
+		start := time.Now()
+		data, err := w.do(ctx, query, variables)
+		elapsed := time.Since(start)
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+
+		w.durations[name] = append(w.durations[name], elapsed)
+		if err != nil {
+			w.errors[name]++
+			w.messages[err.Error()]++
+			continue
+		}
+		handle(data)
+	}
+}
+
+// scenario выбирает сценарий по весам
+func (w *worker) scenario() string {
+	total := 0
+	for _, weight := range w.runner.weights {
+		total += weight
+	}
+	value := w.random.IntN(total)
+	for i, weight := range w.runner.weights {
+		if value < weight {
+			return w.runner.names[i]
+		}
+		value -= weight
+	}
+	return w.runner.names[len(w.runner.names)-1]
+}
+
+// do выполняет запрос GraphQL и возвращает поле data ответа. Ответ с ошибками GraphQL
+// считается ошибкой.
+func (w *worker) do(ctx context.Context, query string, variables map[string]interface{}) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.runner.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range w.runner.config.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.runner.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+	return result.Data, nil
+}
+
+// post возвращает ID случайного известного поста
+func (w *worker) post() string {
+	w.runner.mu.Lock()
+	defer w.runner.mu.Unlock()
+	return w.runner.posts[w.random.IntN(len(w.runner.posts))]
+}
+
+// comment возвращает случайный известный комментарий или пустой node
+func (w *worker) comment() node {
+	w.runner.mu.Lock()
+	defer w.runner.mu.Unlock()
+	if len(w.runner.comments) == 0 {
+		return node{}
+	}
+	return w.runner.comments[w.random.IntN(len(w.runner.comments))]
+}
+
+// remember запоминает комментарии из ответов; при переполнении заменяет случайные
+func (w *worker) remember(comments ...node) {
+	w.runner.mu.Lock()
+	defer w.runner.mu.Unlock()
+	for _, comment := range comments {
+		if len(w.runner.comments) < maxKnownComments {
+			w.runner.comments = append(w.runner.comments, comment)
+		} else {
+			w.runner.comments[w.random.IntN(maxKnownComments)] = comment
+		}
+	}
+}
+
+// node запись из ответа; PostID заполняется только для комментариев
+type node struct {
+	ID     string `json:"id"`
+	PostID string `json:"postID"`
+}
+
+func posts(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	return `query { getPosts { id } }`, nil, func(data json.RawMessage) {
+		var result struct {
+			Posts []node `json:"getPosts"`
+		}
+		if json.Unmarshal(data, &result) != nil || len(result.Posts) == 0 {
+			return
+		}
+		ids := make([]string, 0, len(result.Posts))
+		for _, post := range result.Posts {
+			ids = append(ids, post.ID)
+		}
+		w.runner.mu.Lock()
+		w.runner.posts = ids
+		w.runner.mu.Unlock()
+	}
+}
+
+func post(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	postID := w.post()
+	return `query($id: ID!) { getPostByID(id: $id) { id title contentHTML comments allowComments } }`,
+		map[string]interface{}{"id": postID}, func(data json.RawMessage) {
+			var result struct {
+				Post *struct {
+					Comments []string `json:"comments"`
+				} `json:"getPostByID"`
+			}
+			if json.Unmarshal(data, &result) == nil && result.Post != nil {
+				for _, id := range result.Post.Comments {
+					w.remember(node{ID: id, PostID: postID})
+				}
+			}
+		}
+}
+
+func comments(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	return `query($postID: ID!) { getComments(postID: $postID) { id postID authorID contentHTML createdAt replies } }`,
+		map[string]interface{}{"postID": w.post()}, func(data json.RawMessage) {
+			var result struct {
+				Comments []node `json:"getComments"`
+			}
+			if json.Unmarshal(data, &result) != nil {
+				return
+			}
+			for _, comment := range result.Comments {
+				w.remember(comment)
+			}
+		}
+}
+
+func replies(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	comment := w.comment()
+	// Пока не известно ни одного комментария, читается пост со списком его комментариев
+	if comment.ID == "" {
+		return post(w)
+	}
+	return `query($id: ID!) { getReplies(commentID: $id) { id postID contentHTML createdAt replies } }`,
+		map[string]interface{}{"id": comment.ID}, func(data json.RawMessage) {
+			var result struct {
+				Replies []node `json:"getReplies"`
+			}
+			if json.Unmarshal(data, &result) != nil {
+				return
+			}
+			for _, reply := range result.Replies {
+				w.remember(reply)
+			}
+		}
+}
+
+func pages(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	return `query($postID: ID!) { getNumberOfCommentPages(postID: $postID) }`,
+		map[string]interface{}{"postID": w.post()}, func(json.RawMessage) {}
+}
+
+func addComment(w *worker) (string, map[string]interface{}, func(json.RawMessage)) {
+	variables := map[string]interface{}{
+		"postID":  w.post(),
+		"content": fmt.Sprintf("Load test comment %d", w.random.Uint32()),
+	}
+	// Половина новых комментариев отвечает на известный комментарий в его посте
+	if parent := w.comment(); parent.ID != "" && w.random.IntN(2) == 0 {
+		variables["postID"] = parent.PostID
+		variables["parentID"] = parent.ID
+		return `mutation($postID: ID!, $parentID: ID!, $content: String!) {
+			addComment(postID: $postID, parentCommentID: $parentID, content: $content) { id postID }
+		}`, variables, handleAdded(w)
+	}
+	return `mutation($postID: ID!, $content: String!) { addComment(postID: $postID, content: $content) { id postID } }`,
+		variables, handleAdded(w)
+}
+
+func handleAdded(w *worker) func(json.RawMessage) {
+	return func(data json.RawMessage) {
+		var result struct {
+			Comment node `json:"addComment"`
+		}
+		if json.Unmarshal(data, &result) == nil && result.Comment.ID != "" {
+			w.remember(result.Comment)
+		}
+	}
+}
+
+// report объединяет результаты клиентов
+func (r *runner) report(workers []*worker, elapsed time.Duration) *Report {
+	report := &Report{Elapsed: elapsed, Scenarios: make([]Stats, 0, len(r.names))}
+	var all []time.Duration
+	allErrors := 0
+	messages := make(map[string]int)
+	for _, name := range r.names {
+		var durations []time.Duration
+		errorCount := 0
+		for _, w := range workers {
+			durations = append(durations, w.durations[name]...)
+			errorCount += w.errors[name]
+		}
+		if len(durations) == 0 {
+			continue
+		}
+		all = append(all, durations...)
+		allErrors += errorCount
+		report.Scenarios = append(report.Scenarios, stats(name, durations, errorCount))
+	}
+	for _, w := range workers {
+		for message, count := range w.messages {
+			if _, ok := messages[message]; ok || len(messages) < maxErrorMessages {
+				messages[message] += count
+			}
+		}
+	}
+	if len(messages) > 0 {
+		report.ErrorMessages = messages
+	}
+
+	report.Total = stats("total", all, allErrors)
+	if elapsed > 0 {
+		report.Throughput = float64(len(all)) / elapsed.Seconds()
+	}
+	return report
+}
+
+// stats считает процентили задержек методом ближайшего ранга
+func stats(name string, durations []time.Duration, errorCount int) Stats {
+	result := Stats{Scenario: name, Requests: len(durations), Errors: errorCount}
+	if len(durations) == 0 {
+		return result
+	}
+	slices.Sort(durations)
+	percentile := func(p float64) time.Duration {
+		rank := int(math.Ceil(p / 100 * float64(len(durations))))
+		return durations[max(rank, 1)-1]
+	}
+	result.ErrorRate = float64(errorCount) / float64(len(durations))
+	result.P50 = percentile(50)
+	result.P90 = percentile(90)
+	result.P95 = percentile(95)
+	result.P99 = percentile(99)
+	result.Max = durations[len(durations)-1]
+	return result
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/handler"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// GraphQLResultCallback возвращает функцию для handler.Config, которая логирует каждый
+// выполненный GraphQL-запрос. Значения переменных с именами из redactFields заменяются
+// на "[REDACTED]" на любом уровне вложенности.
+func GraphQLResultCallback(redactFields []string) handler.ResultCallbackFn {
+	fields := make(map[string]struct{}, len(redactFields))
+	for _, field := range redactFields {
+		fields[strings.ToLower(field)] = struct{}{}
+	}
+
+	return func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+		attrs := []slog.Attr{
+			slog.String("operation", operationName(params)),
+			slog.Any("variables", Redact(params.VariableValues, fields)),
+			slog.Int("result_size", len(responseBody)),
+		}
+		if start, ok := StartTime(ctx); ok {
+			attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+		}
+
+		level := slog.LevelInfo
+		if result.HasErrors() {
+			level = slog.LevelWarn
+			errs := make([]string, 0, len(result.Errors))
+			for _, err := range result.Errors {
+				errs = append(errs, err.Message)
+			}
+			attrs = append(attrs, slog.Any("errors", errs))
+		}
+
+		FromContext(ctx).LogAttrs(ctx, level, "graphql request", attrs...)
+	}
+}
+
+// Redact возвращает копию value, в которой значения полей из fields скрыты
+func Redact(value interface{}, fields map[string]struct{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if _, ok := fields[strings.ToLower(key)]; ok {
+				out[key] = redacted
+				continue
+			}
+			out[key] = Redact(val, fields)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = Redact(val, fields)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// operationName возвращает имя операции, а для анонимных операций — имя первого
+// поля верхнего уровня
+func operationName(params *graphql.Params) string {
+	if params.OperationName != "" {
+		return params.OperationName
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: params.RequestString})
+	if err != nil {
+		return ""
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if op.Name != nil {
+			return op.Name.Value
+		}
+		if op.SelectionSet != nil && len(op.SelectionSet.Selections) > 0 {
+			if field, ok := op.SelectionSet.Selections[0].(*ast.Field); ok {
+				return field.Name.Value
+			}
+		}
+	}
+	return ""
+}
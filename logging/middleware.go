@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+type startTimeKey struct{}
+
+// RequestID возвращает идентификатор текущего запроса
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID сохраняет идентификатор запроса в контексте
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// StartTime возвращает время начала обработки запроса
+func StartTime(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(startTimeKey{}).(time.Time)
+	return start, ok
+}
+
+// Middleware присваивает каждому запросу идентификатор (используя заголовок X-Request-ID,
+// если клиент его передал) и кладет в контекст логгер с этим идентификатором
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := WithRequestID(r.Context(), id)
+			ctx = context.WithValue(ctx, startTimeKey{}, time.Now())
+			ctx = WithLogger(ctx, logger.With(slog.String("request_id", id)))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
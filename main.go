@@ -1,65 +1,353 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/handler"
+	"graphql-comments/auth"
+	"graphql-comments/config"
+	"graphql-comments/events"
 	"graphql-comments/graphql"
+	"graphql-comments/graphql/complexity"
+	"graphql-comments/graphql/httpcache"
+	"graphql-comments/graphql/persisted"
+	"graphql-comments/logging"
+	"graphql-comments/markdown"
+	"graphql-comments/outbox"
+	"graphql-comments/ratelimit"
+	"graphql-comments/rest"
+	"graphql-comments/rpc"
+	"graphql-comments/spam"
 	"graphql-comments/storage"
+	"graphql-comments/storage/cache"
 	"graphql-comments/storage/in-memory"
 	"graphql-comments/storage/postgres"
-	"log"
+	"graphql-comments/storage/slowlog"
+	"graphql-comments/tenant"
+	"graphql-comments/webhook"
+	"graphql-comments/widget"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"time"
 )
 
 func main() {
-	flag := os.Getenv("STORAGE_TYPE")
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+
+	logger := logging.New(os.Stdout, cfg.Logging.Level)
+	slog.SetDefault(logger)
 
-	switch flag {
+	switch cfg.StorageType {
 	case "in-memory":
-		log.Println("Using in-memory storage")
+		logger.Info("Using in-memory storage")
 		storage.DataBase = inMemory.NewInMemoryStore()
 	case "postgres":
-		log.Println("Using PostgreSQL storage")
+		logger.Info("Using PostgreSQL storage")
 
-		host := os.Getenv("POSTGRES_HOST")
-		port := os.Getenv("POSTGRES_PORT")
-		user := os.Getenv("POSTGRES_USER")
-		password := os.Getenv("POSTGRES_PASSWORD")
-		dbname := os.Getenv("POSTGRES_DATABASE")
-
-		psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			host, port, user, password, dbname)
-
-		var err error
-		storage.DataBase, err = postgres.NewPostgresDataStore(psqlInfo)
+		ctx, cancel := cfg.Timeouts.Context(context.Background(), "")
+		storage.DataBase, err = postgres.NewPostgresDataStore(ctx, cfg.Postgres.DSN(), cfg.Timeouts)
+		cancel()
 		if err != nil {
-			log.Println("Error connecting to PostgreSQL: ", err)
+			logger.Error("Error connecting to PostgreSQL", "error", err)
 			return
 		}
 
-		log.Println("Successfully connected to PostgreSQL!")
+		logger.Info("Successfully connected to PostgreSQL!")
 	default:
-		log.Fatalf("Unknown storage type: \"%s\"\n", flag)
+		logger.Error("Unknown storage type", "storage_type", cfg.StorageType)
+		os.Exit(1)
 	}
+	rateLimiter, err := newRateLimiter(cfg, logger)
+	if err != nil {
+		logger.Error("Error initializing rate limiter", "error", err)
+		os.Exit(1)
+	}
+	gql.RateLimiter = rateLimiter
+	gql.RateLimits = cfg.RateLimit.Policy
 
-	var schema, _ = graphql.NewSchema(graphql.SchemaConfig{
-		Query:    gql.QueryType,
-		Mutation: gql.MutationType,
-		Types:    []graphql.Type{gql.PostType, gql.CommentType},
-	})
+	persistedStore, err := newPersistedStore(cfg)
+	if err != nil {
+		logger.Error("Error initializing persisted queries", "error", err)
+		os.Exit(1)
+	}
+
+	webhookStore, err := newWebhookStore(cfg)
+	if err != nil {
+		logger.Error("Error initializing webhooks", "error", err)
+		os.Exit(1)
+	}
+	dispatcher := webhook.NewDispatcher(webhookStore, cfg.Webhooks.Options)
+	go dispatcher.Run(logging.WithLogger(context.Background(), logger))
+	gql.Webhooks = dispatcher
+	gql.TokenSecret = cfg.AuthSecret
+	gql.Markdown = markdown.NewRenderer(cfg.MarkdownCacheSize)
+	gql.ContentFilter = spam.NewChain(cfg.Spam, gql.Classifiers)
+
+	publisher, err := newEventPublisher(cfg, dispatcher)
+	if err != nil {
+		logger.Error("Error initializing event sinks", "error", err)
+		os.Exit(1)
+	}
+	if store, ok := storage.DataBase.(*postgres.DataStorePostgres); ok {
+		// PostgreSQL записывает события в outbox в одной транзакции с изменениями,
+		// откуда их публикует relay
+		relay := outbox.NewRelay(store.DB, publisher, cfg.Outbox.BatchSize, cfg.Outbox.PollInterval)
+		go relay.Run(logging.WithLogger(context.Background(), logger))
+	} else {
+		gql.Events = publisher
+	}
+
+	cacheBackend, err := newCacheBackend(cfg, logger)
+	if err != nil {
+		logger.Error("Error initializing cache", "error", err)
+		os.Exit(1)
+	}
+	storage.DataBase = slowlog.NewSlowLogStore(storage.DataBase, cfg.Logging.SlowQueryThreshold)
+	if cacheBackend != nil {
+		storage.DataBase = cache.NewCacheStore(storage.DataBase, cacheBackend, cfg.Cache.TTL)
+	}
+
+	tenants, err := newTenantRegistry(cfg)
+	if err != nil {
+		logger.Error("Error loading tenants", "error", err)
+		os.Exit(1)
+	}
+
+	for _, t := range tenants.Tenants() {
+		ctx := tenant.WithTenant(logging.WithLogger(context.Background(), logger), t)
+		if err := gql.TrainClassifier(ctx); err != nil {
+			logger.Error("failed to train spam classifier", "tenant", t.ID, "error", err)
+		}
+	}
+
+	schemaConfig := gql.Schema
+	schemaConfig.Extensions = append(schemaConfig.Extensions, complexity.Extension{})
+	schema, err := graphql.NewSchema(schemaConfig)
+	if err != nil {
+		logger.Error("Error building GraphQL schema", "error", err)
+		os.Exit(1)
+	}
 
 	graphqlHandler := handler.New(&handler.Config{
-		Schema:     &schema,
-		Playground: true,
+		Schema:           &schema,
+		Playground:       true,
+		ResultCallbackFn: logging.GraphQLResultCallback(cfg.Logging.RedactFields),
 	})
 
-	http.Handle("/graphql", graphqlHandler)
+	middleware := func(next http.Handler) http.Handler {
+		return logging.Middleware(logger)(
+			tenant.Middleware(tenants)(
+				auth.Middleware(cfg.AdminToken, cfg.AuthSecret)(
+					ratelimit.ClientMiddleware(cfg.RateLimit.TrustProxy)(
+						// complexity.Middleware анализирует текст, подставленный persisted.Middleware
+						persisted.Middleware(persisted.Config{Mode: cfg.Persisted.Mode, Store: persistedStore, Schema: &schema, Limits: cfg.Complexity})(
+							complexity.Middleware(&schema, cfg.Complexity)(next),
+						),
+					),
+				),
+			),
+		)
+	}
+	http.Handle("/graphql", middleware(httpcache.Middleware(cfg.Cache.HTTPMaxAge)(graphqlHandler)))
+	// Подписки передаются клиенту в формате Server-Sent Events
+	http.Handle("/graphql/stream", middleware(gql.StreamHandler(&schema)))
+
+	http.Handle(rest.Prefix, logging.Middleware(logger)(
+		tenant.Middleware(tenants)(
+			auth.Middleware(cfg.AdminToken, cfg.AuthSecret)(
+				ratelimit.ClientMiddleware(cfg.RateLimit.TrustProxy)(rest.NewHandler()),
+			),
+		),
+	))
 
-	log.Println("Server is running at http://localhost:8084/graphql")
-	err := http.ListenAndServe(":8084", nil)
+	embedHandler, err := widget.NewHandler(cfg.EmbedSecret)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("Error initializing embed widget", "error", err)
+		os.Exit(1)
+	}
+	// Формы виджета отправляются анонимно, поэтому из цепочки исключены авторизация и
+	// обработка GraphQL-запросов
+	http.Handle(widget.Prefix, logging.Middleware(logger)(
+		tenant.Middleware(tenants)(
+			ratelimit.ClientMiddleware(cfg.RateLimit.TrustProxy)(embedHandler),
+		),
+	))
+
+	if cfg.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			logger.Error("Error starting gRPC server", "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			if err := rpc.NewServer(tenants, cfg.AdminToken, cfg.AuthSecret).Serve(listener); err != nil {
+				logger.Error("gRPC server stopped", "error", err)
+			}
+		}()
+		logger.Info("gRPC server is running", "addr", cfg.GRPCAddr)
+	}
+
+	logger.Info("Server is running at http://localhost:8084/graphql")
+	err = http.ListenAndServe(":8084", nil)
+	if err != nil {
+		logger.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// newTenantRegistry создает реестр арендаторов согласно настройкам
+func newTenantRegistry(cfg *config.Config) (*tenant.Registry, error) {
+	var tenants []*tenant.Tenant
+	if cfg.Tenants.File != "" {
+		var err error
+		if tenants, err = tenant.Load(cfg.Tenants.File); err != nil {
+			return nil, err
+		}
+	}
+	return tenant.NewRegistry(tenants, cfg.Tenants.Default)
+}
+
+// newRateLimiter создает limiter согласно настройкам. Для postgres используется
+// подключение хранилища, если оно тоже работает с PostgreSQL.
+func newRateLimiter(cfg *config.Config, logger *slog.Logger) (ratelimit.Limiter, error) {
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		return ratelimit.NewMemoryLimiter(), nil
+	case "postgres":
+		db, err := postgresDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		limiter := ratelimit.NewPostgresLimiter(db)
+		go func() {
+			for range time.Tick(time.Hour) {
+				if err := limiter.Prune(context.Background(), time.Hour); err != nil {
+					logger.Error("Error pruning rate limits", "error", err)
+				}
+			}
+		}()
+		return limiter, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend: %q", cfg.RateLimit.Backend)
+	}
+}
+
+// newCacheBackend создает хранилище кэша результатов чтения согласно настройкам.
+// Для off возвращает nil: чтения выполняются без кэша.
+func newCacheBackend(cfg *config.Config, logger *slog.Logger) (cache.Backend, error) {
+	switch cfg.Cache.Backend {
+	case "off":
+		return nil, nil
+	case "memory":
+		return cache.NewMemoryBackend(cfg.Cache.Capacity), nil
+	case "postgres":
+		db, err := postgresDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		backend := cache.NewPostgresBackend(db)
+		go func() {
+			for range time.Tick(time.Hour) {
+				if err := backend.Prune(context.Background()); err != nil {
+					logger.Error("Error pruning cache", "error", err)
+				}
+			}
+		}()
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend: %q", cfg.Cache.Backend)
+	}
+}
+
+// newPersistedStore создает хранилище persisted queries. В режиме allowlist
+// операции берутся только из манифеста.
+func newPersistedStore(cfg *config.Config) (persisted.Store, error) {
+	if cfg.Persisted.Mode == persisted.ModeAllowlist {
+		return persisted.LoadManifest(cfg.Persisted.Manifest)
+	}
+
+	switch cfg.Persisted.Store {
+	case "memory":
+		return persisted.NewMemoryStore(cfg.Persisted.Capacity), nil
+	case "postgres":
+		db, err := postgresDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return persisted.NewPostgresStore(db, cfg.Persisted.Capacity), nil
+	default:
+		return nil, fmt.Errorf("unknown persisted queries store: %q", cfg.Persisted.Store)
+	}
+}
+
+// newEventPublisher создает получателей доменных событий согласно OUTBOX_SINKS
+func newEventPublisher(cfg *config.Config, dispatcher *webhook.Dispatcher) (events.Publisher, error) {
+	sinks := make(events.Fanout, 0, len(cfg.Outbox.Sinks))
+	for _, name := range cfg.Outbox.Sinks {
+		switch name {
+		case "stdout":
+			sinks = append(sinks, events.NewStdoutSink())
+		case "file":
+			sink, err := events.NewFileSink(cfg.Outbox.File)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "webhook":
+			sinks = append(sinks, dispatcher)
+		case "nats":
+			sink, err := events.NewNATSSink(cfg.Outbox.NATSURL, cfg.Outbox.NATSPrefix, cfg.Outbox.SinkTimeout)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown event sink: %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// newWebhookStore создает хранилище подписок и очереди доставок
+func newWebhookStore(cfg *config.Config) (webhook.Store, error) {
+	switch cfg.Webhooks.Store {
+	case "memory":
+		return webhook.NewMemoryStore(), nil
+	case "postgres":
+		db, err := postgresDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return webhook.NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown webhook store: %q", cfg.Webhooks.Store)
+	}
+}
+
+var sharedDB *sql.DB
+
+// postgresDB возвращает подключение хранилища, если оно работает с PostgreSQL,
+// или открывает (однократно) новое по тем же параметрам
+func postgresDB(cfg *config.Config) (*sql.DB, error) {
+	if store, ok := storage.DataBase.(*postgres.DataStorePostgres); ok {
+		return store.DB, nil
+	}
+	if sharedDB == nil {
+		db, err := sql.Open("postgres", cfg.Postgres.DSN())
+		if err != nil {
+			return nil, err
+		}
+		sharedDB = db
 	}
+	return sharedDB, nil
 }
@@ -0,0 +1,143 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"regexp"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	// LinkRel значение атрибута rel, добавляемое ко всем ссылкам пользовательского контента
+	LinkRel = "nofollow ugc"
+	// DefaultCacheSize число результатов, кэшируемых по умолчанию
+	DefaultCacheSize = 10000
+)
+
+// Renderer преобразует Markdown (CommonMark и автоссылки) в HTML. Исходный HTML
+// не выводится, результат дополнительно проходит через allowlist-санитайзер.
+// Результаты кэшируются по хэшу содержимого.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+	capacity int
+	entries  map[[sha256.Size]byte]*list.Element
+	order    *list.List
+	mu       sync.Mutex
+}
+
+type cacheEntry struct {
+	hash [sha256.Size]byte
+	html string
+}
+
+// NewRenderer создает Renderer, кэширующий не более capacity последних результатов.
+// Неположительная capacity отключает кэш.
+func NewRenderer(capacity int) *Renderer {
+	return &Renderer{
+		markdown: goldmark.New(
+			goldmark.WithExtensions(extension.Linkify),
+			goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(linkRel{}, 100))),
+		),
+		policy:   Policy(),
+		capacity: capacity,
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Policy возвращает allowlist элементов и атрибутов, допустимых в отрисованном HTML
+func Policy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements("p", "br", "hr", "em", "strong", "blockquote", "pre", "code",
+		"ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	policy.AllowAttrs("href").OnElements("a")
+	policy.AllowAttrs("rel").Matching(regexp.MustCompile(`^` + LinkRel + `$`)).OnElements("a")
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.RequireParseableURLs(true)
+	return policy
+}
+
+// Render возвращает безопасный HTML для content
+func (r *Renderer) Render(content string) (string, error) {
+	hash := sha256.Sum256([]byte(content))
+	if html, ok := r.get(hash); ok {
+		return html, nil
+	}
+
+	var buf bytes.Buffer
+	if err := r.markdown.Convert([]byte(content), &buf); err != nil {
+		return "", err
+	}
+	html := r.policy.Sanitize(buf.String())
+
+	r.put(hash, html)
+	return html, nil
+}
+
+func (r *Renderer) get(hash [sha256.Size]byte) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[hash]
+	if !ok {
+		return "", false
+	}
+	r.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).html, true
+}
+
+func (r *Renderer) put(hash [sha256.Size]byte, html string) {
+	if r.capacity <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[hash]; ok {
+		r.order.MoveToFront(element)
+		return
+	}
+
+	r.entries[hash] = r.order.PushFront(&cacheEntry{hash: hash, html: html})
+	if r.order.Len() > r.capacity {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).hash)
+	}
+}
+
+// Len возвращает число закэшированных результатов
+func (r *Renderer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.order.Len()
+}
+
+// linkRel добавляет атрибут rel ко всем ссылкам документа
+type linkRel struct{}
+
+func (linkRel) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node.(type) {
+		case *ast.Link, *ast.AutoLink:
+			node.SetAttributeString("rel", []byte(LinkRel))
+		}
+		return ast.WalkContinue, nil
+	})
+}
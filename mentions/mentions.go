@@ -0,0 +1,107 @@
+package mentions
+
+import (
+	"strings"
+)
+
+// Parse возвращает имена пользователей, упомянутых в content в виде "@name", в порядке
+// первого упоминания. Повторные упоминания, упоминания внутри блоков и фрагментов кода,
+// а также имена длиннее maxNameLength не учитываются. Возвращается не более limit имен; limit <= 0 не ограничивает их число.
+func Parse(content string, limit, maxNameLength int) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, text := range prose(content) {
+		for idx := 0; idx < len(text) && (limit <= 0 || len(names) < limit); idx++ {
+			if text[idx] != '@' || (idx > 0 && (isNameChar(text[idx-1]) || text[idx-1] == '@')) {
+				continue
+			}
+
+			end := idx + 1
+			for end < len(text) && isNameChar(text[end]) {
+				end++
+			}
+			name := text[idx+1 : end]
+			idx = end - 1
+
+			key := strings.ToLower(name)
+			if name == "" || len(name) > maxNameLength || seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// prose разбивает content на фрагменты текста, исключая блоки кода (```, ~~~) и
+// фрагменты кода в обратных кавычках
+func prose(content string) []string {
+	parts := make([]string, 0)
+	var (
+		text  strings.Builder
+		fence string
+	)
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		text.WriteString(line)
+	}
+
+	rest := text.String()
+	for {
+		start := strings.IndexByte(rest, '`')
+		if start < 0 {
+			return append(parts, rest)
+		}
+		parts = append(parts, rest[:start])
+
+		// Фрагмент кода закрывается последовательностью обратных кавычек той же длины
+		n := start
+		for n < len(rest) && rest[n] == '`' {
+			n++
+		}
+		ticks := rest[start:n]
+		end := closingTicks(rest[n:], len(ticks))
+		if end < 0 {
+			// Незакрытые кавычки считаются обычным текстом
+			parts = append(parts, ticks)
+			rest = rest[n:]
+			continue
+		}
+		rest = rest[n+end+len(ticks):]
+	}
+}
+
+// closingTicks возвращает позицию последовательности из ровно count обратных кавычек
+func closingTicks(text string, count int) int {
+	for idx := 0; idx < len(text); {
+		if text[idx] != '`' {
+			idx++
+			continue
+		}
+		end := idx
+		for end < len(text) && text[end] == '`' {
+			end++
+		}
+		if end-idx == count {
+			return idx
+		}
+		idx = end
+	}
+	return -1
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
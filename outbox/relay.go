@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"graphql-comments/events"
+	"graphql-comments/logging"
+	"time"
+)
+
+// relayLockID ключ advisory lock'а, гарантирующего, что события публикует
+// только один relay, и порядок публикации совпадает с порядком записи
+const relayLockID = 7_302_031_512
+
+// Relay публикует события из таблицы outbox в порядке их записи
+type Relay struct {
+	DB           *sql.DB
+	Publisher    events.Publisher
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// NewRelay создает Relay
+func NewRelay(db *sql.DB, publisher events.Publisher, batchSize int, pollInterval time.Duration) *Relay {
+	return &Relay{
+		DB:           db,
+		Publisher:    publisher,
+		BatchSize:    batchSize,
+		PollInterval: pollInterval,
+	}
+}
+
+// Run публикует события, пока не будет отменен ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.Process(ctx)
+			if err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Error("outbox relay failed", "error", err)
+			}
+			// Если пачка заполнена целиком, вероятно, есть еще события
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process публикует очередную пачку событий и возвращает число опубликованных.
+// События упорядочены по seq, то есть по порядку записи в outbox.
+// Публикация останавливается на первом событии, которое не удалось опубликовать,
+// чтобы не нарушить порядок; оно будет опубликовано повторно при следующем вызове.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", relayLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		// События уже публикует другая реплика
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT seq, payload FROM outbox WHERE published_at IS NULL ORDER BY seq LIMIT $1", r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type entry struct {
+		seq   int64
+		event events.Event
+	}
+	entries := make([]entry, 0, r.BatchSize)
+	for rows.Next() {
+		var (
+			e       entry
+			payload []byte
+		)
+		if err := rows.Scan(&e.seq, &payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(payload, &e.event); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for _, e := range entries {
+		if publishErr = r.Publisher.Publish(ctx, e.event); publishErr != nil {
+			break
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at = $2 WHERE seq = $1", e.seq, time.Now()); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
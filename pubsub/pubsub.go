@@ -0,0 +1,59 @@
+package pubsub
+
+import "sync"
+
+// Broker рассылает сообщения подписчикам тем в пределах одного процесса
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan interface{}]struct{}
+	buffer      int
+}
+
+// NewBroker создает брокер, у каждого подписчика которого буфер на buffer сообщений
+func NewBroker(buffer int) *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan interface{}]struct{}),
+		buffer:      buffer,
+	}
+}
+
+// Subscribe подписывается на тему topic. Возвращает канал сообщений и функцию отмены
+// подписки, которая закрывает канал.
+func (b *Broker) Subscribe(topic string) (chan interface{}, func()) {
+	ch := make(chan interface{}, b.buffer)
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan interface{}]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers[topic], ch)
+			if len(b.subscribers[topic]) == 0 {
+				delete(b.subscribers, topic)
+			}
+			close(ch)
+		})
+	}
+}
+
+// Publish отправляет message всем подписчикам темы topic. Подписчики, не успевающие
+// читать сообщения, пропускают их, чтобы не блокировать отправителя.
+func (b *Broker) Publish(topic string, message interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- message:
+		default:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientKey struct{}
+
+// WithClient сохраняет идентификатор клиента в контексте
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// Client возвращает идентификатор клиента, от имени которого выполняется запрос:
+// ID аутентифицированного пользователя или, для анонимных запросов, сохраненный
+// в контексте идентификатор (IP-адрес)
+func Client(ctx context.Context) string {
+	if userID := auth.UserID(ctx); userID != "" {
+		return "user:" + userID
+	}
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// ClientMiddleware определяет IP-адрес клиента. Если trustProxy установлен,
+// используется первый адрес из заголовка X-Forwarded-For.
+func ClientMiddleware(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithClient(r.Context(), "ip:"+clientIP(r, trustProxy))))
+		})
+	}
+}
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Minute
+
+// MemoryLimiter хранит bucket'ы в памяти процесса
+type MemoryLimiter struct {
+	buckets   map[string]*memoryBucket
+	lastPrune time.Time
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+type memoryBucket struct {
+	bucket
+	limit Limit
+}
+
+// NewMemoryLimiter создает in-process limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if limit.Disabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: newBucket(limit, now)}
+		l.buckets[key] = b
+	}
+	b.limit = limit
+
+	return b.take(limit, now), nil
+}
+
+// prune удаляет полностью восстановившиеся bucket'ы, чтобы память не росла
+// с каждым новым клиентом
+func (l *MemoryLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		if b.full(b.limit, now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresLimiter хранит bucket'ы в таблице rate_limits, благодаря чему
+// лимиты разделяются между всеми репликами приложения
+type PostgresLimiter struct {
+	DB *sql.DB
+}
+
+// NewPostgresLimiter создает limiter поверх PostgreSQL
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{DB: db}
+}
+
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Disabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	tx, err := l.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Burst), now,
+	); err != nil {
+		return Result{}, err
+	}
+
+	var b bucket
+	if err := tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key,
+	).Scan(&b.tokens, &b.updatedAt); err != nil {
+		return Result{}, err
+	}
+
+	result := b.take(limit, now)
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1",
+		key, b.tokens, b.updatedAt,
+	); err != nil {
+		return Result{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// Prune удаляет bucket'ы, не использовавшиеся дольше idle
+func (l *PostgresLimiter) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := l.DB.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", time.Now().Add(-idle))
+	return err
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limit описывает параметры token bucket: скорость пополнения (токенов в секунду)
+// и максимальный запас токенов. Лимит с неположительной скоростью отключен.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Disabled сообщает, отключен ли лимит
+func (l Limit) Disabled() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Policy задает лимиты для разных классов запросов
+type Policy struct {
+	Query    Limit
+	Mutation Limit
+	Post     Limit
+}
+
+// Result результат проверки лимита
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter проверяет и расходует токены из bucket'а с ключом key
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Error возвращается, когда лимит запросов исчерпан
+type Error struct {
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// Extensions реализует gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":       "RATE_LIMITED",
+		"retryAfter": int(math.Ceil(e.RetryAfter.Seconds())),
+	}
+}
+
+// bucket состояние token bucket'а
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func newBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Burst), updatedAt: now}
+}
+
+// take пополняет bucket на момент now и пытается забрать из него один токен
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	if elapsed := now.Sub(b.updatedAt).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	}
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return Result{Allowed: true, Remaining: int(b.tokens)}
+	}
+
+	wait := (1 - b.tokens) / limit.Rate
+	return Result{RetryAfter: time.Duration(wait * float64(time.Second))}
+}
+
+// full сообщает, восстановился ли bucket полностью к моменту now
+func (b *bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.updatedAt).Seconds()*limit.Rate >= float64(limit.Burst)
+}
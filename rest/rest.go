@@ -0,0 +1,367 @@
+package rest
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"graphql-comments/graphql"
+	"graphql-comments/logging"
+	"graphql-comments/ratelimit"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Prefix путь, по которому обслуживается REST API
+const Prefix = "/api/v1/"
+
+// maxBodySize ограничивает размер тела запроса
+const maxBodySize = 64 << 10
+
+//go:embed openapi.json
+var openAPI []byte
+
+// Handler REST API поверх storage.DataStore для клиентов, не работающих с GraphQL.
+// Проверки и лимиты совпадают с одноименными операциями GraphQL.
+type Handler struct {
+	mux *http.ServeMux
+}
+
+// NewHandler создает обработчик REST API
+func NewHandler() *Handler {
+	h := &Handler{mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET "+Prefix+"openapi.json", h.openAPI)
+	h.mux.HandleFunc("GET "+Prefix+"posts", h.getPosts)
+	h.mux.HandleFunc("GET "+Prefix+"posts/{id}", h.getPost)
+	h.mux.HandleFunc("GET "+Prefix+"posts/{id}/comments", h.getComments)
+	h.mux.HandleFunc("POST "+Prefix+"posts/{id}/comments", h.addComment)
+	h.mux.HandleFunc("GET "+Prefix+"comments/{id}/replies", h.getReplies)
+	h.mux.HandleFunc(Prefix, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, &Error{Status: http.StatusNotFound, Code: "NOT_FOUND", Message: "route not found"})
+	})
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Post представление поста в REST API
+type Post struct {
+	ID            string    `json:"id"`
+	AuthorID      string    `json:"authorID,omitempty"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	ContentHTML   string    `json:"contentHTML"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Comments      []string  `json:"comments"`
+	AllowComments bool      `json:"allowComments"`
+}
+
+// Comment представление комментария в REST API
+type Comment struct {
+	ID              string    `json:"id"`
+	PostID          string    `json:"postID"`
+	ParentCommentID string    `json:"parentCommentID,omitempty"`
+	AuthorID        string    `json:"authorID,omitempty"`
+	Content         string    `json:"content"`
+	ContentHTML     string    `json:"contentHTML"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Replies         []string  `json:"replies"`
+	Status          string    `json:"status"`
+}
+
+// CommentPage страница комментариев верхнего уровня поста
+type CommentPage struct {
+	Comments []*Comment `json:"comments"`
+	// NextCursor передается в параметре cursor для получения следующей страницы
+	NextCursor *string `json:"nextCursor"`
+}
+
+// NewComment тело запроса на добавление комментария
+type NewComment struct {
+	ParentCommentID string `json:"parentCommentID"`
+	Content         string `json:"content"`
+}
+
+func (h *Handler) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+func (h *Handler) getPosts(w http.ResponseWriter, r *http.Request) {
+	if err := gql.AllowQuery(r.Context()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	posts, err := storage.DataBase.GetPosts(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result := make([]*Post, 0, len(posts))
+	for _, post := range posts {
+		p, err := newPost(post)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		result = append(result, p)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"posts": result})
+}
+
+func (h *Handler) getPost(w http.ResponseWriter, r *http.Request) {
+	if err := gql.AllowQuery(r.Context()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	post, err := storage.DataBase.GetPostByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := newPost(post)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) getComments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := gql.AllowQuery(ctx); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	page := 1
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		if page, err = strconv.Atoi(cursor); err != nil || page < 1 {
+			writeError(w, r, &Error{Status: http.StatusBadRequest, Code: "BAD_REQUEST", Message: "invalid cursor"})
+			return
+		}
+	}
+
+	post, err := storage.DataBase.GetPostByID(ctx, r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	comments, err := storage.DataBase.GetComments(ctx, post.ID, page)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result := &CommentPage{}
+	if result.Comments, err = newComments(comments); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	// Курсор — номер следующей страницы; клиенты должны считать его непрозрачным
+	pages, err := storage.DataBase.GetNumberOfCommentPages(ctx, post.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if page < pages {
+		next := strconv.Itoa(page + 1)
+		result.NextCursor = &next
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) addComment(w http.ResponseWriter, r *http.Request) {
+	var input NewComment
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		writeError(w, r, &Error{Status: http.StatusBadRequest, Code: "BAD_REQUEST", Message: "invalid request body: " + err.Error()})
+		return
+	}
+
+	comment, err := gql.AddComment(r.Context(), r.PathValue("id"), input.ParentCommentID, input.Content)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := newComment(comment)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, result)
+}
+
+func (h *Handler) getReplies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := gql.AllowQuery(ctx); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	comment, err := storage.DataBase.GetCommentByID(ctx, r.PathValue("id"))
+	if err == nil && !gql.Visible(ctx, comment) {
+		err = storage.ErrCommentNotFound
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	replies, err := storage.DataBase.GetReplies(ctx, comment.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	result, err := newComments(replies)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"replies": result})
+}
+
+func newPost(post *types.Post) (*Post, error) {
+	html, err := gql.Markdown.Render(post.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &Post{
+		ID:            post.ID,
+		AuthorID:      post.AuthorID,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentHTML:   html,
+		CreatedAt:     post.CreatedAt,
+		Comments:      nonNil(post.Comments),
+		AllowComments: post.AllowComments,
+	}, nil
+}
+
+func newComment(comment *types.Comment) (*Comment, error) {
+	html, err := gql.Markdown.Render(comment.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &Comment{
+		ID:              comment.ID,
+		PostID:          comment.PostID,
+		ParentCommentID: comment.ParentCommentID,
+		AuthorID:        comment.AuthorID,
+		Content:         comment.Content,
+		ContentHTML:     html,
+		CreatedAt:       comment.CreatedAt,
+		Replies:         nonNil(comment.Replies),
+		Status:          comment.Status,
+	}, nil
+}
+
+func newComments(comments []*types.Comment) ([]*Comment, error) {
+	result := make([]*Comment, 0, len(comments))
+	for _, comment := range comments {
+		c, err := newComment(comment)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// Error ошибка REST API; в ответе передается как {"error": {"code": ..., "message": ...}}
+type Error struct {
+	Status  int                    `json:"-"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// extendedError ошибка с кодом, передаваемым в GraphQL в extensions
+type extendedError interface {
+	error
+	Extensions() map[string]interface{}
+}
+
+// statuses HTTP-статусы ошибок с кодами GraphQL
+var statuses = map[string]int{
+	"FORBIDDEN":        http.StatusForbidden,
+	"UNAUTHENTICATED":  http.StatusUnauthorized,
+	"RATE_LIMITED":     http.StatusTooManyRequests,
+	"COMMENT_REJECTED": http.StatusUnprocessableEntity,
+}
+
+// apiError приводит ошибку хранилища или проверки к ошибке REST API
+func apiError(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var extended extendedError
+	if errors.As(err, &extended) {
+		details := extended.Extensions()
+		code, _ := details["code"].(string)
+		delete(details, "code")
+		status, ok := statuses[code]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		if len(details) == 0 {
+			details = nil
+		}
+		return &Error{Status: status, Code: code, Message: err.Error(), Details: details}
+	}
+
+	var input *gql.InputError
+	switch {
+	case errors.As(err, &input):
+		return &Error{Status: http.StatusUnprocessableEntity, Code: "INVALID_INPUT", Message: err.Error()}
+	case errors.Is(err, storage.ErrCommentsNotAllowed):
+		return &Error{Status: http.StatusForbidden, Code: "COMMENTS_CLOSED", Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Status: http.StatusGatewayTimeout, Code: "TIMEOUT", Message: "request timed out"}
+	case errors.Is(err, storage.ErrPostNotFound), errors.Is(err, storage.ErrCommentNotFound), errors.Is(err, storage.ErrParentNotFound):
+		return &Error{Status: http.StatusNotFound, Code: "NOT_FOUND", Message: err.Error()}
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: "INTERNAL", Message: "internal error"}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := apiError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("REST request failed", "path", r.URL.Path, "error", err)
+	}
+
+	var limited *ratelimit.Error
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	}
+	writeJSON(w, apiErr.Status, map[string]interface{}{"error": apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/ratelimit"
+	"graphql-comments/tenant"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// interceptor определяет арендатора, пользователя и клиента вызова по метаданным
+type interceptor struct {
+	tenants    *tenant.Registry
+	adminToken string
+	userSecret string
+}
+
+func (i *interceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := i.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *interceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+func (i *interceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	// Проверка состояния и reflection доступны без арендатора
+	if strings.HasPrefix(method, "/grpc.health.v1.Health/") || strings.HasPrefix(method, "/grpc.reflection.") {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	t, err := i.tenants.Lookup(first(md, tenant.APIKeyHeader), first(md, tenant.IDHeader), first(md, ":authority"))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	ctx = tenant.WithTenant(ctx, t)
+	ctx = auth.Authenticate(ctx, first(md, "authorization"), i.adminToken, i.userSecret)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		client := p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+		ctx = ratelimit.WithClient(ctx, client)
+	}
+	return ctx, nil
+}
+
+// first возвращает первое значение метаданных key
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream подменяет контекст потока
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
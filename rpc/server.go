@@ -0,0 +1,267 @@
+// Package rpc реализует gRPC-сервис CommentService для внутренних сервисов
+package rpc
+
+//go:generate protoc -I proto --go_out=.. --go_opt=module=graphql-comments --go-grpc_out=.. --go-grpc_opt=module=graphql-comments comments/v1/comments.proto
+
+import (
+	"context"
+	"errors"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/ratelimit"
+	"graphql-comments/rpc/commentspb"
+	"graphql-comments/spam"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server реализует commentspb.CommentServiceServer поверх storage.DataStore
+type Server struct {
+	commentspb.UnimplementedCommentServiceServer
+}
+
+// NewServer создает gRPC-сервер с сервисом CommentService, проверкой состояния и
+// reflection. Арендатор и пользователь каждого вызова определяются так же, как для HTTP.
+func NewServer(tenants *tenant.Registry, adminToken, userSecret string) *grpc.Server {
+	interceptor := &interceptor{tenants: tenants, adminToken: adminToken, userSecret: userSecret}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptor.unary),
+		grpc.ChainStreamInterceptor(interceptor.stream),
+	)
+	commentspb.RegisterCommentServiceServer(server, &Server{})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(commentspb.CommentService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+	return server
+}
+
+func (s *Server) AddPost(ctx context.Context, req *commentspb.AddPostRequest) (*commentspb.Post, error) {
+	allowComments := true
+	if req.AllowComments != nil {
+		allowComments = *req.AllowComments
+	}
+	post, err := gql.AddPost(ctx, req.Title, req.Content, allowComments)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newPost(post), nil
+}
+
+func (s *Server) AddComment(ctx context.Context, req *commentspb.AddCommentRequest) (*commentspb.Comment, error) {
+	if req.PostId == "" {
+		return nil, status.Error(codes.InvalidArgument, "post_id is empty")
+	}
+	comment, err := gql.AddComment(ctx, req.PostId, req.ParentCommentId, req.Content)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newComment(comment), nil
+}
+
+func (s *Server) GetPosts(ctx context.Context, _ *commentspb.GetPostsRequest) (*commentspb.GetPostsResponse, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	posts, err := storage.DataBase.GetPosts(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &commentspb.GetPostsResponse{Posts: make([]*commentspb.Post, 0, len(posts))}
+	for _, post := range posts {
+		resp.Posts = append(resp.Posts, newPost(post))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetPost(ctx context.Context, req *commentspb.GetPostRequest) (*commentspb.Post, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	post, err := storage.DataBase.GetPostByID(ctx, req.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newPost(post), nil
+}
+
+func (s *Server) GetComments(ctx context.Context, req *commentspb.GetCommentsRequest) (*commentspb.GetCommentsResponse, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	if req.Page < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page is negative")
+	}
+
+	post, err := storage.DataBase.GetPostByID(ctx, req.PostId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	comments, err := storage.DataBase.GetComments(ctx, post.ID, max(int(req.Page), 1))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	pages, err := storage.DataBase.GetNumberOfCommentPages(ctx, post.ID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &commentspb.GetCommentsResponse{
+		Comments: newComments(comments),
+		Pages:    int32(pages),
+	}, nil
+}
+
+func (s *Server) GetComment(ctx context.Context, req *commentspb.GetCommentRequest) (*commentspb.Comment, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	comment, err := storage.DataBase.GetCommentByID(ctx, req.Id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !gql.Visible(ctx, comment) {
+		return nil, statusError(storage.ErrCommentNotFound)
+	}
+	return newComment(comment), nil
+}
+
+func (s *Server) GetReplies(ctx context.Context, req *commentspb.GetRepliesRequest) (*commentspb.GetRepliesResponse, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	replies, err := storage.DataBase.GetReplies(ctx, req.CommentId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &commentspb.GetRepliesResponse{Replies: newComments(replies)}, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *commentspb.GetUserRequest) (*commentspb.User, error) {
+	if err := gql.AllowQuery(ctx); err != nil {
+		return nil, statusError(err)
+	}
+
+	var user *types.User
+	var err error
+	switch key := req.Key.(type) {
+	case *commentspb.GetUserRequest_Id:
+		user, err = storage.DataBase.GetUserByID(ctx, key.Id)
+	case *commentspb.GetUserRequest_Name:
+		user, err = storage.DataBase.GetUserByName(ctx, key.Name)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "id or name is required")
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &commentspb.User{Id: user.ID, Name: user.Name, CreatedAt: timestamppb.New(user.CreatedAt)}, nil
+}
+
+func (s *Server) WatchPost(req *commentspb.WatchPostRequest, stream grpc.ServerStreamingServer[commentspb.Comment]) error {
+	ctx := stream.Context()
+	if err := gql.AllowQuery(ctx); err != nil {
+		return statusError(err)
+	}
+	post, err := storage.DataBase.GetPostByID(ctx, req.PostId)
+	if err != nil {
+		return statusError(err)
+	}
+
+	ch, unsubscribe := gql.Comments.Subscribe(gql.CommentTopic(ctx, post.ID))
+	defer unsubscribe()
+	// Заголовки отправляются сразу, чтобы клиент знал, что подписка оформлена
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message := <-ch:
+			if err := stream.Send(newComment(message.(*types.Comment))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newPost(post *types.Post) *commentspb.Post {
+	return &commentspb.Post{
+		Id:            post.ID,
+		AuthorId:      post.AuthorID,
+		Title:         post.Title,
+		Content:       post.Content,
+		CreatedAt:     timestamppb.New(post.CreatedAt),
+		Comments:      post.Comments,
+		AllowComments: post.AllowComments,
+	}
+}
+
+// statuses статусы модерации комментариев в protobuf
+var statuses = map[string]commentspb.CommentStatus{
+	types.CommentPublished: commentspb.CommentStatus_COMMENT_STATUS_PUBLISHED,
+	types.CommentPending:   commentspb.CommentStatus_COMMENT_STATUS_PENDING,
+	types.CommentRejected:  commentspb.CommentStatus_COMMENT_STATUS_REJECTED,
+}
+
+func newComment(comment *types.Comment) *commentspb.Comment {
+	return &commentspb.Comment{
+		Id:              comment.ID,
+		PostId:          comment.PostID,
+		ParentCommentId: comment.ParentCommentID,
+		AuthorId:        comment.AuthorID,
+		Content:         comment.Content,
+		CreatedAt:       timestamppb.New(comment.CreatedAt),
+		Replies:         comment.Replies,
+		Status:          statuses[comment.Status],
+	}
+}
+
+func newComments(comments []*types.Comment) []*commentspb.Comment {
+	result := make([]*commentspb.Comment, 0, len(comments))
+	for _, comment := range comments {
+		result = append(result, newComment(comment))
+	}
+	return result
+}
+
+// statusError приводит ошибку хранилища или проверки к статусу gRPC
+func statusError(err error) error {
+	var (
+		input    *gql.InputError
+		limited  *ratelimit.Error
+		rejected *spam.RejectedError
+	)
+	switch {
+	case errors.As(err, &input), errors.As(err, &rejected):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &limited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrCommentsNotAllowed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, storage.ErrPostNotFound), errors.Is(err, storage.ErrCommentNotFound), errors.Is(err, storage.ErrParentNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,207 @@
+// Package seed заполняет хранилище синтетическими пользователями, постами и деревьями
+// комментариев через интерфейс storage.DataStore, чтобы проверять пагинацию и
+// производительность на данных реалистичного объема.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"math/rand/v2"
+	"strings"
+)
+
+// Config параметры генерации
+type Config struct {
+	Posts int
+	// Users число авторов комментариев; 0 — все комментарии анонимные
+	Users int
+	// MinComments и MaxComments границы равномерно распределенного числа комментариев поста
+	MinComments int
+	MaxComments int
+	// DepthWeights относительные веса глубины комментария: DepthWeights[0] — комментарий
+	// верхнего уровня, DepthWeights[1] — ответ на него и так далее
+	DepthWeights []float64
+	// MinContentLength и MaxContentLength границы длины текста комментария в символах
+	MinContentLength int
+	MaxContentLength int
+	// Seed делает генерацию воспроизводимой
+	Seed uint64
+}
+
+// DefaultConfig параметры генерации по умолчанию
+var DefaultConfig = Config{
+	Posts:            10,
+	Users:            20,
+	MinComments:      0,
+	MaxComments:      50,
+	DepthWeights:     []float64{60, 25, 10, 5},
+	MinContentLength: 20,
+	MaxContentLength: 400,
+}
+
+// Result число созданных записей
+type Result struct {
+	Users    int `json:"users"`
+	Posts    int `json:"posts"`
+	Comments int `json:"comments"`
+	// Depths число комментариев по глубине вложенности
+	Depths []int `json:"depths"`
+}
+
+// words словарь текста постов и комментариев
+var words = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod
+	tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud
+	exercitation ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure in
+	reprehenderit voluptate velit esse cillum fugiat nulla pariatur excepteur sint occaecat
+	cupidatat non proident sunt culpa qui officia deserunt mollit anim id est laborum`)
+
+// Validate проверяет параметры генерации
+func (c *Config) Validate() error {
+	switch {
+	case c.Posts < 0 || c.Users < 0:
+		return errors.New("posts and users must not be negative")
+	case c.MinComments < 0 || c.MaxComments < c.MinComments:
+		return errors.New("invalid comment count range")
+	case c.MinContentLength < 1 || c.MaxContentLength < c.MinContentLength:
+		return errors.New("invalid content length range")
+	case c.MaxContentLength > storage.MaxCommentLength:
+		return fmt.Errorf("content length exceeds %d chars", storage.MaxCommentLength)
+	case len(c.DepthWeights) == 0:
+		return errors.New("depth weights are empty")
+	}
+	total := 0.0
+	for _, weight := range c.DepthWeights {
+		if weight < 0 {
+			return errors.New("depth weights must not be negative")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("depth weights sum to zero")
+	}
+	return nil
+}
+
+// Generate создает в store пользователей, посты и комментарии согласно config. Пользователи,
+// созданные предыдущим запуском с тем же Seed, используются повторно.
+func Generate(ctx context.Context, store storage.DataStore, config Config) (*Result, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	g := &generator{
+		store:  store,
+		config: config,
+		random: rand.New(rand.NewPCG(config.Seed, config.Seed^0x9e3779b97f4a7c15)),
+		result: &Result{Depths: make([]int, len(config.DepthWeights))},
+	}
+
+	for i := 0; i < config.Users; i++ {
+		if err := g.user(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < config.Posts; i++ {
+		if err := g.post(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	return g.result, nil
+}
+
+type generator struct {
+	store  storage.DataStore
+	config Config
+	random *rand.Rand
+	users  []string
+	result *Result
+}
+
+func (g *generator) user(ctx context.Context, i int) error {
+	name := fmt.Sprintf("seed%d_%d", g.config.Seed, i)
+	user, err := g.store.AddUser(ctx, name)
+	if err != nil {
+		existing, lookupErr := g.store.GetUserByName(ctx, name)
+		if lookupErr != nil {
+			return fmt.Errorf("adding user %s: %w", name, err)
+		}
+		user = existing
+	} else {
+		g.result.Users++
+	}
+	g.users = append(g.users, user.ID)
+	return nil
+}
+
+func (g *generator) post(ctx context.Context, i int) error {
+	title := fmt.Sprintf("Post %d: %s", i+1, g.text(20, 60))
+	post, err := g.store.AddPost(ctx, g.author(), title, g.text(200, 1000), true)
+	if err != nil {
+		return err
+	}
+	g.result.Posts++
+
+	// byDepth ID комментариев поста по глубине, из них выбираются родители ответов
+	byDepth := make([][]string, len(g.config.DepthWeights))
+	count := g.config.MinComments + g.random.IntN(g.config.MaxComments-g.config.MinComments+1)
+	for j := 0; j < count; j++ {
+		depth := g.depth()
+		// Ответ требует родителя на предыдущем уровне
+		for depth > 0 && len(byDepth[depth-1]) == 0 {
+			depth--
+		}
+		parentID := ""
+		if depth > 0 {
+			parents := byDepth[depth-1]
+			parentID = parents[g.random.IntN(len(parents))]
+		}
+
+		comment, err := g.store.AddComment(ctx, g.author(), post.ID, parentID, g.text(g.config.MinContentLength, g.config.MaxContentLength), types.CommentPublished)
+		if err != nil {
+			return err
+		}
+		byDepth[depth] = append(byDepth[depth], comment.ID)
+		g.result.Comments++
+		g.result.Depths[depth]++
+	}
+	return nil
+}
+
+// depth выбирает глубину комментария по весам DepthWeights
+func (g *generator) depth() int {
+	total := 0.0
+	for _, weight := range g.config.DepthWeights {
+		total += weight
+	}
+	value := g.random.Float64() * total
+	for depth, weight := range g.config.DepthWeights {
+		if value < weight {
+			return depth
+		}
+		value -= weight
+	}
+	return len(g.config.DepthWeights) - 1
+}
+
+// author возвращает ID случайного автора или пустую строку, если авторов нет
+func (g *generator) author() string {
+	if len(g.users) == 0 {
+		return ""
+	}
+	return g.users[g.random.IntN(len(g.users))]
+}
+
+// text возвращает текст из словаря длиной от min до max символов
+func (g *generator) text(min, max int) string {
+	length := min + g.random.IntN(max-min+1)
+	var b strings.Builder
+	for b.Len() < length {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(words[g.random.IntN(len(words))])
+	}
+	return b.String()[:length]
+}
@@ -0,0 +1,114 @@
+package spam
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Classifier наивный байесовский классификатор спама, обучаемый на решениях модераторов
+type Classifier struct {
+	spam     map[string]int
+	ham      map[string]int
+	spamDocs int
+	hamDocs  int
+	spamSize int
+	hamSize  int
+	mu       sync.RWMutex
+}
+
+// NewClassifier создает необученный классификатор
+func NewClassifier() *Classifier {
+	return &Classifier{
+		spam: make(map[string]int),
+		ham:  make(map[string]int),
+	}
+}
+
+// Classifiers хранит отдельный классификатор каждого арендатора, чтобы решения
+// модераторов одного арендатора не влияли на проверку комментариев другого
+type Classifiers struct {
+	classifiers map[string]*Classifier
+	mu          sync.Mutex
+}
+
+// NewClassifiers создает пустой набор классификаторов
+func NewClassifiers() *Classifiers {
+	return &Classifiers{classifiers: make(map[string]*Classifier)}
+}
+
+// For возвращает классификатор арендатора tenantID, создавая его при первом обращении
+func (c *Classifiers) For(tenantID string) *Classifier {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	classifier, ok := c.classifiers[tenantID]
+	if !ok {
+		classifier = NewClassifier()
+		c.classifiers[tenantID] = classifier
+	}
+	return classifier
+}
+
+// Train учитывает content как спам (spam = true) или как нормальный комментарий
+func (c *Classifier) Train(content string, spam bool) {
+	tokens := Tokenize(content)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts, size := c.ham, &c.hamSize
+	if spam {
+		counts, size = c.spam, &c.spamSize
+		c.spamDocs++
+	} else {
+		c.hamDocs++
+	}
+	for _, token := range tokens {
+		counts[token]++
+	}
+	*size += len(tokens)
+}
+
+// Trained возвращает число изученных примеров спама и нормальных комментариев
+func (c *Classifier) Trained() (spamDocs, hamDocs int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.spamDocs, c.hamDocs
+}
+
+// Score возвращает оценку вероятности того, что content является спамом
+func (c *Classifier) Score(content string) float64 {
+	tokens := Tokenize(content)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.spamDocs == 0 || c.hamDocs == 0 {
+		return 0.5
+	}
+
+	vocabulary := len(c.spam)
+	for token := range c.ham {
+		if _, ok := c.spam[token]; !ok {
+			vocabulary++
+		}
+	}
+
+	// Логарифмы вероятностей со сглаживанием Лапласа
+	spamLog := math.Log(float64(c.spamDocs) / float64(c.spamDocs+c.hamDocs))
+	hamLog := math.Log(float64(c.hamDocs) / float64(c.spamDocs+c.hamDocs))
+	for _, token := range tokens {
+		spamLog += math.Log(float64(c.spam[token]+1) / float64(c.spamSize+vocabulary))
+		hamLog += math.Log(float64(c.ham[token]+1) / float64(c.hamSize+vocabulary))
+	}
+	return 1 / (1 + math.Exp(hamLog-spamLog))
+}
+
+// Tokenize разбивает текст на слова в нижнем регистре
+func Tokenize(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
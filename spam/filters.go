@@ -0,0 +1,152 @@
+package spam
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"graphql-comments/tenant"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockedWords отклоняет комментарии, содержащие слова из списка (без учета регистра)
+type BlockedWords struct {
+	words map[string]struct{}
+}
+
+// NewBlockedWords создает фильтр по списку слов
+func NewBlockedWords(words []string) *BlockedWords {
+	filter := &BlockedWords{words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		filter.words[strings.ToLower(word)] = struct{}{}
+	}
+	return filter
+}
+
+func (f *BlockedWords) Name() string {
+	return "blocked_words"
+}
+
+func (f *BlockedWords) Check(_ context.Context, candidate *Candidate) (Decision, error) {
+	for _, word := range Tokenize(candidate.Content) {
+		if _, ok := f.words[word]; ok {
+			return Decision{Verdict: Reject, Filter: f.Name(), Reason: "content contains a blocked word"}, nil
+		}
+	}
+	return Decision{Verdict: Allow}, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)`)
+
+// LinkLimit ограничивает число ссылок в комментарии. Комментарии, содержащие не меньше
+// Pending ссылок, отправляются на модерацию, не меньше Max — отклоняются.
+type LinkLimit struct {
+	Pending int
+	Max     int
+}
+
+func (f *LinkLimit) Name() string {
+	return "link_limit"
+}
+
+func (f *LinkLimit) Check(_ context.Context, candidate *Candidate) (Decision, error) {
+	links := len(linkPattern.FindAllStringIndex(candidate.Content, -1))
+	switch {
+	case f.Max > 0 && links >= f.Max:
+		return Decision{Verdict: Reject, Filter: f.Name(), Reason: fmt.Sprintf("too many links (%d)", links)}, nil
+	case f.Pending > 0 && links >= f.Pending:
+		return Decision{Verdict: Pending, Filter: f.Name(), Reason: fmt.Sprintf("too many links (%d)", links)}, nil
+	}
+	return Decision{Verdict: Allow}, nil
+}
+
+// Duplicates отклоняет повторную отправку одного и того же текста одним автором
+// к одному посту в пределах окна Window. Текст запоминается в Commit, то есть только
+// после сохранения комментария, поэтому несохраненный комментарий можно отправить снова.
+type Duplicates struct {
+	Window time.Duration
+	seen   map[[sha256.Size]byte]time.Time
+	// swept время последней очистки seen от записей старше Window
+	swept time.Time
+	mu    sync.Mutex
+}
+
+// NewDuplicates создает фильтр повторов с окном window
+func NewDuplicates(window time.Duration) *Duplicates {
+	return &Duplicates{Window: window, seen: make(map[[sha256.Size]byte]time.Time), swept: time.Now()}
+}
+
+func (f *Duplicates) Name() string {
+	return "duplicates"
+}
+
+func (f *Duplicates) Check(_ context.Context, candidate *Candidate) (Decision, error) {
+	key := duplicateKey(candidate)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if at, ok := f.seen[key]; ok && time.Since(at) < f.Window {
+		return Decision{Verdict: Reject, Filter: f.Name(), Reason: "duplicate comment"}, nil
+	}
+	return Decision{Verdict: Allow}, nil
+}
+
+// Commit запоминает сохраненный комментарий. Устаревшие записи удаляются не чаще
+// одного раза за Window, чтобы каждый комментарий не перебирал все записи.
+func (f *Duplicates) Commit(_ context.Context, candidate *Candidate) {
+	key := duplicateKey(candidate)
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if now.Sub(f.swept) >= f.Window {
+		for k, at := range f.seen {
+			if now.Sub(at) >= f.Window {
+				delete(f.seen, k)
+			}
+		}
+		f.swept = now
+	}
+	f.seen[key] = now
+}
+
+func duplicateKey(candidate *Candidate) [sha256.Size]byte {
+	// Текст нормализуется, чтобы повтор не маскировался регистром и пробелами
+	normalized := strings.Join(strings.Fields(strings.ToLower(candidate.Content)), " ")
+	return sha256.Sum256([]byte(candidate.Author + "\x00" + candidate.PostID + "\x00" + normalized))
+}
+
+// BayesFilter оценивает вероятность спама классификатором арендатора запроса, пока тот
+// обучен хотя бы на MinTraining примерах каждого вида
+type BayesFilter struct {
+	Classifiers  *Classifiers
+	PendingScore float64
+	RejectScore  float64
+	MinTraining  int
+}
+
+func (f *BayesFilter) Name() string {
+	return "bayes"
+}
+
+func (f *BayesFilter) Check(ctx context.Context, candidate *Candidate) (Decision, error) {
+	classifier := f.Classifiers.For(tenant.ID(ctx))
+	spamDocs, hamDocs := classifier.Trained()
+	if spamDocs < f.MinTraining || hamDocs < f.MinTraining {
+		return Decision{Verdict: Allow}, nil
+	}
+
+	score := classifier.Score(candidate.Content)
+	reason := fmt.Sprintf("spam score %.2f", score)
+	switch {
+	case f.RejectScore > 0 && score >= f.RejectScore:
+		return Decision{Verdict: Reject, Filter: f.Name(), Reason: reason}, nil
+	case f.PendingScore > 0 && score >= f.PendingScore:
+		return Decision{Verdict: Pending, Filter: f.Name(), Reason: reason}, nil
+	}
+	return Decision{Verdict: Allow}, nil
+}
@@ -0,0 +1,161 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Verdict решение фильтра о комментарии
+type Verdict int
+
+const (
+	// Allow пропускает комментарий
+	Allow Verdict = iota
+	// Pending отправляет комментарий на модерацию
+	Pending
+	// Reject отклоняет комментарий
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Pending:
+		return "PENDING"
+	case Reject:
+		return "REJECT"
+	default:
+		return "ALLOW"
+	}
+}
+
+// Candidate комментарий, проверяемый перед сохранением. Author идентифицирует автора:
+// ID пользователя или, для анонимных клиентов, ключ клиента.
+type Candidate struct {
+	Author          string
+	PostID          string
+	ParentCommentID string
+	Content         string
+}
+
+// Decision результат проверки комментария
+type Decision struct {
+	Verdict Verdict
+	Filter  string
+	Reason  string
+}
+
+// Filter проверяет комментарий перед сохранением
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, candidate *Candidate) (Decision, error)
+}
+
+// Committer фильтр, запоминающий принятые комментарии. Commit вызывается только после
+// того, как комментарий, прошедший проверку, успешно сохранен.
+type Committer interface {
+	Commit(ctx context.Context, candidate *Candidate)
+}
+
+// Chain последовательно применяет фильтры. Первый отклонивший комментарий фильтр
+// прерывает проверку; если ни один фильтр не отклонил комментарий, но хотя бы один
+// отправил его на модерацию, возвращается решение этого фильтра.
+type Chain []Filter
+
+func (c Chain) Name() string {
+	return "chain"
+}
+
+func (c Chain) Check(ctx context.Context, candidate *Candidate) (Decision, error) {
+	result := Decision{Verdict: Allow}
+	for _, filter := range c {
+		decision, err := filter.Check(ctx, candidate)
+		if err != nil {
+			return Decision{}, fmt.Errorf("%s filter: %w", filter.Name(), err)
+		}
+		switch decision.Verdict {
+		case Reject:
+			return decision, nil
+		case Pending:
+			if result.Verdict == Allow {
+				result = decision
+			}
+		}
+	}
+	return result, nil
+}
+
+// Commit передает сохраненный комментарий фильтрам цепочки, реализующим Committer
+func (c Chain) Commit(ctx context.Context, candidate *Candidate) {
+	for _, filter := range c {
+		if committer, ok := filter.(Committer); ok {
+			committer.Commit(ctx, candidate)
+		}
+	}
+}
+
+// RejectedError возвращается клиенту, если комментарий отклонен фильтром
+type RejectedError struct {
+	Decision Decision
+}
+
+func (e *RejectedError) Error() string {
+	return "comment rejected: " + e.Decision.Reason
+}
+
+// Extensions реализует gqlerrors.ExtendedError
+func (e *RejectedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":   "COMMENT_REJECTED",
+		"filter": e.Decision.Filter,
+	}
+}
+
+// Options настройки стандартной цепочки фильтров. Нулевые значения отключают
+// соответствующие проверки.
+type Options struct {
+	BlockedWords []string
+	// PendingLinks и MaxLinks задают число ссылок, начиная с которого комментарий
+	// отправляется на модерацию и отклоняется соответственно
+	PendingLinks    int
+	MaxLinks        int
+	DuplicateWindow time.Duration
+	// PendingScore и RejectScore задают пороги вероятности спама по оценке классификатора
+	PendingScore float64
+	RejectScore  float64
+	// MinTrainingSize число решений модераторов каждого вида, после которого включается классификатор
+	MinTrainingSize int
+}
+
+// DefaultOptions настройки по умолчанию
+var DefaultOptions = Options{
+	PendingLinks:    3,
+	MaxLinks:        10,
+	DuplicateWindow: 10 * time.Minute,
+	PendingScore:    0.7,
+	RejectScore:     0.98,
+	MinTrainingSize: 10,
+}
+
+// NewChain создает цепочку из стандартных фильтров согласно options
+func NewChain(options Options, classifiers *Classifiers) Chain {
+	chain := make(Chain, 0, 4)
+	if len(options.BlockedWords) > 0 {
+		chain = append(chain, NewBlockedWords(options.BlockedWords))
+	}
+	if options.PendingLinks > 0 || options.MaxLinks > 0 {
+		chain = append(chain, &LinkLimit{Pending: options.PendingLinks, Max: options.MaxLinks})
+	}
+	if options.DuplicateWindow > 0 {
+		chain = append(chain, NewDuplicates(options.DuplicateWindow))
+	}
+	if classifiers != nil {
+		chain = append(chain, &BayesFilter{
+			Classifiers:  classifiers,
+			PendingScore: options.PendingScore,
+			RejectScore:  options.RejectScore,
+			MinTraining:  options.MinTrainingSize,
+		})
+	}
+	return chain
+}
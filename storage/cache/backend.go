@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Backend хранит закодированные результаты чтения по ключу не дольше заданного ttl
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// MemoryBackend хранит не более capacity последних использованных записей в памяти процесса
+type MemoryBackend struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	mu       sync.Mutex
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryBackend создает in-process кэш. Неположительная capacity снимает ограничение.
+func NewMemoryBackend(capacity int) *MemoryBackend {
+	return &MemoryBackend{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	element, ok := b.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		b.order.Remove(element)
+		delete(b.entries, key)
+		return nil, false, nil
+	}
+	b.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+func (b *MemoryBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+	if element, ok := b.entries[key]; ok {
+		element.Value = entry
+		b.order.MoveToFront(element)
+		return nil
+	}
+
+	b.entries[key] = b.order.PushFront(entry)
+	if b.capacity > 0 && b.order.Len() > b.capacity {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(ctx context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := b.entries[key]; ok {
+			b.order.Remove(element)
+			delete(b.entries, key)
+		}
+	}
+	return nil
+}
+
+// Len возвращает число записей в кэше, включая устаревшие
+func (b *MemoryBackend) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.order.Len()
+}
+
+// PostgresBackend хранит записи в таблице cache_entries, благодаря чему кэш и его
+// инвалидация разделяются между всеми репликами приложения
+type PostgresBackend struct {
+	DB *sql.DB
+}
+
+// NewPostgresBackend создает кэш поверх PostgreSQL
+func NewPostgresBackend(db *sql.DB) *PostgresBackend {
+	return &PostgresBackend{DB: db}
+}
+
+func (b *PostgresBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	err := b.DB.QueryRowContext(ctx,
+		"SELECT value FROM cache_entries WHERE key = $1 AND expires_at > $2", key, time.Now(),
+	).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (b *PostgresBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := b.DB.ExecContext(ctx,
+		"INSERT INTO cache_entries (key, value, expires_at) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at",
+		key, value, time.Now().Add(ttl))
+	return err
+}
+
+func (b *PostgresBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := b.DB.ExecContext(ctx, "DELETE FROM cache_entries WHERE key = ANY($1)", pq.Array(keys))
+	return err
+}
+
+// Prune удаляет устаревшие записи
+func (b *PostgresBackend) Prune(ctx context.Context) error {
+	_, err := b.DB.ExecContext(ctx, "DELETE FROM cache_entries WHERE expires_at <= $1", time.Now())
+	return err
+}
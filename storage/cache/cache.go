@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"graphql-comments/logging"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultCapacity число записей in-process кэша по умолчанию
+	DefaultCapacity = 10000
+	// DefaultTTL время жизни записи по умолчанию
+	DefaultTTL = 30 * time.Second
+)
+
+// DataStoreCache оборачивает DataStore и кэширует списки постов, пост, страницы
+// комментариев поста, число страниц и ответы на комментарий. Записи инвалидируются
+// изменениями, прошедшими через обертку; изменения в обход нее (например, из commentsctl)
+// и результаты чтений, завершившихся одновременно с изменением, видны не дольше TTL.
+type DataStoreCache struct {
+	Next    storage.DataStore
+	Backend Backend
+	TTL     time.Duration
+}
+
+// NewCacheStore создает обертку над next, хранящую результаты в backend не дольше ttl
+func NewCacheStore(next storage.DataStore, backend Backend, ttl time.Duration) *DataStoreCache {
+	return &DataStoreCache{Next: next, Backend: backend, TTL: ttl}
+}
+
+// Ключи записей начинаются с ID арендатора: размер страницы и данные у арендаторов свои
+func postsKey(ctx context.Context) string {
+	return tenant.ID(ctx) + ":posts"
+}
+
+func postKey(ctx context.Context, postID string) string {
+	return tenant.ID(ctx) + ":post:" + postID
+}
+
+func commentsKey(ctx context.Context, postID string, page int) string {
+	return tenant.ID(ctx) + ":comments:" + postID + ":" + strconv.Itoa(page)
+}
+
+func pagesKey(ctx context.Context, postID string) string {
+	return tenant.ID(ctx) + ":pages:" + postID
+}
+
+func repliesKey(ctx context.Context, commentID string) string {
+	return tenant.ID(ctx) + ":replies:" + commentID
+}
+
+// cached возвращает значение по ключу key, загружая его через load при промахе.
+// Ошибки кэша не прерывают чтение; ошибки load не кэшируются.
+func cached[T any](ctx context.Context, store *DataStoreCache, key string, load func() (T, error)) (T, error) {
+	data, ok, err := store.Backend.Get(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Warn("cache read failed", "key", key, "error", err)
+	} else if ok {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		if err := store.Backend.Set(ctx, key, data, store.TTL); err != nil {
+			logging.FromContext(ctx).Warn("cache write failed", "key", key, "error", err)
+		}
+	}
+	return value, nil
+}
+
+func (store *DataStoreCache) invalidate(ctx context.Context, keys ...string) {
+	if err := store.Backend.Delete(ctx, keys...); err != nil {
+		logging.FromContext(ctx).Error("cache invalidation failed", "keys", keys, "error", err)
+	}
+}
+
+// pages возвращает число страниц комментариев поста без учета кэша
+func (store *DataStoreCache) pages(ctx context.Context, postID string) int {
+	pages, _ := store.Next.GetNumberOfCommentPages(ctx, postID)
+	return pages
+}
+
+// invalidatePost удаляет пост, список постов, число страниц и первые pages страниц
+// комментариев поста
+func (store *DataStoreCache) invalidatePost(ctx context.Context, postID string, pages int) {
+	keys := []string{postsKey(ctx), postKey(ctx, postID), pagesKey(ctx, postID)}
+	for page := 1; page <= pages; page++ {
+		keys = append(keys, commentsKey(ctx, postID, page))
+	}
+	store.invalidate(ctx, keys...)
+}
+
+// invalidateParent удаляет ответы на комментарий parentCommentID и ответы на его родителя,
+// в которые входит список ответов parentCommentID
+func (store *DataStoreCache) invalidateParent(ctx context.Context, parentCommentID string) {
+	if parentCommentID == "" {
+		return
+	}
+	keys := []string{repliesKey(ctx, parentCommentID)}
+	if parent, err := store.Next.GetCommentByID(ctx, parentCommentID); err == nil && parent.ParentCommentID != "" {
+		keys = append(keys, repliesKey(ctx, parent.ParentCommentID))
+	}
+	store.invalidate(ctx, keys...)
+}
+
+// invalidateComment удаляет записи, в которые входит комментарий comment или его ID.
+// before — число страниц комментариев поста до изменения.
+func (store *DataStoreCache) invalidateComment(ctx context.Context, comment *types.Comment, before int) {
+	store.invalidatePost(ctx, comment.PostID, max(before, store.pages(ctx, comment.PostID)))
+	store.invalidateParent(ctx, comment.ParentCommentID)
+}
+
+func (store *DataStoreCache) AddPost(ctx context.Context, authorID, title, content string, allowComments bool) (*types.Post, error) {
+	post, err := store.Next.AddPost(ctx, authorID, title, content, allowComments)
+	if err == nil {
+		store.invalidate(ctx, postsKey(ctx))
+	}
+	return post, err
+}
+
+func (store *DataStoreCache) AddComment(ctx context.Context, authorID, postID, parentCommentID, content, status string) (*types.Comment, error) {
+	comment, err := store.Next.AddComment(ctx, authorID, postID, parentCommentID, content, status)
+	if err == nil {
+		store.invalidateComment(ctx, comment, 0)
+	}
+	return comment, err
+}
+
+func (store *DataStoreCache) GetPosts(ctx context.Context) ([]*types.Post, error) {
+	return cached(ctx, store, postsKey(ctx), func() ([]*types.Post, error) {
+		return store.Next.GetPosts(ctx)
+	})
+}
+
+func (store *DataStoreCache) GetPostByID(ctx context.Context, id string) (*types.Post, error) {
+	return cached(ctx, store, postKey(ctx, id), func() (*types.Post, error) {
+		return store.Next.GetPostByID(ctx, id)
+	})
+}
+
+func (store *DataStoreCache) GetComments(ctx context.Context, postID string, page int) ([]*types.Comment, error) {
+	return cached(ctx, store, commentsKey(ctx, postID, page), func() ([]*types.Comment, error) {
+		return store.Next.GetComments(ctx, postID, page)
+	})
+}
+
+func (store *DataStoreCache) GetCommentByID(ctx context.Context, id string) (*types.Comment, error) {
+	return store.Next.GetCommentByID(ctx, id)
+}
+
+func (store *DataStoreCache) GetNumberOfCommentPages(ctx context.Context, postID string) (int, error) {
+	return cached(ctx, store, pagesKey(ctx, postID), func() (int, error) {
+		return store.Next.GetNumberOfCommentPages(ctx, postID)
+	})
+}
+
+func (store *DataStoreCache) GetReplies(ctx context.Context, commentID string) ([]*types.Comment, error) {
+	return cached(ctx, store, repliesKey(ctx, commentID), func() ([]*types.Comment, error) {
+		return store.Next.GetReplies(ctx, commentID)
+	})
+}
+
+func (store *DataStoreCache) AddUser(ctx context.Context, name string) (*types.User, error) {
+	return store.Next.AddUser(ctx, name)
+}
+
+func (store *DataStoreCache) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	return store.Next.GetUserByID(ctx, id)
+}
+
+func (store *DataStoreCache) GetUserByName(ctx context.Context, name string) (*types.User, error) {
+	return store.Next.GetUserByName(ctx, name)
+}
+
+func (store *DataStoreCache) AddNotifications(ctx context.Context, notifications []*types.Notification) error {
+	return store.Next.AddNotifications(ctx, notifications)
+}
+
+func (store *DataStoreCache) GetNotifications(ctx context.Context, userID string, filter storage.NotificationFilter) ([]*types.Notification, error) {
+	return store.Next.GetNotifications(ctx, userID, filter)
+}
+
+func (store *DataStoreCache) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	return store.Next.MarkNotificationsRead(ctx, userID, ids)
+}
+
+func (store *DataStoreCache) GetUnreadNotificationCount(ctx context.Context, userID string) (int, error) {
+	return store.Next.GetUnreadNotificationCount(ctx, userID)
+}
+
+func (store *DataStoreCache) AddMentions(ctx context.Context, commentID string, userIDs []string) error {
+	return store.Next.AddMentions(ctx, commentID, userIDs)
+}
+
+func (store *DataStoreCache) GetMentionedUsers(ctx context.Context, commentID string) ([]*types.User, error) {
+	return store.Next.GetMentionedUsers(ctx, commentID)
+}
+
+func (store *DataStoreCache) GetCommentsMentioning(ctx context.Context, userID string, limit int) ([]*types.Comment, error) {
+	return store.Next.GetCommentsMentioning(ctx, userID, limit)
+}
+
+// ModerateComment инвалидирует записи комментария: решение модератора меняет его статус и
+// может добавить его в списки комментариев поста и ответов или убрать из них
+func (store *DataStoreCache) ModerateComment(ctx context.Context, actor, commentID, status, reason string) (*types.Comment, error) {
+	var before int
+	if comment, err := store.Next.GetCommentByID(ctx, commentID); err == nil {
+		before = store.pages(ctx, comment.PostID)
+	}
+	comment, err := store.Next.ModerateComment(ctx, actor, commentID, status, reason)
+	if err == nil {
+		store.invalidateComment(ctx, comment, before)
+	}
+	return comment, err
+}
+
+func (store *DataStoreCache) ReportComment(ctx context.Context, report *types.Report) error {
+	return store.Next.ReportComment(ctx, report)
+}
+
+func (store *DataStoreCache) GetModerationQueue(ctx context.Context, filter storage.ModerationFilter) ([]*types.ModerationItem, error) {
+	return store.Next.GetModerationQueue(ctx, filter)
+}
+
+func (store *DataStoreCache) GetAuditLog(ctx context.Context, filter storage.AuditFilter) ([]*types.AuditEntry, error) {
+	return store.Next.GetAuditLog(ctx, filter)
+}
+
+func (store *DataStoreCache) GetThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	return store.Next.GetThread(ctx, namespace, externalID)
+}
+
+func (store *DataStoreCache) AddThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	return store.Next.AddThread(ctx, namespace, externalID)
+}
+
+func (store *DataStoreCache) SetAllowComments(ctx context.Context, actor, postID string, allow bool) (*types.Post, error) {
+	post, err := store.Next.SetAllowComments(ctx, actor, postID, allow)
+	if err == nil {
+		store.invalidate(ctx, postsKey(ctx), postKey(ctx, postID))
+	}
+	return post, err
+}
+
+// DeleteComment инвалидирует записи комментария и ответы на удаляемые вместе с ним комментарии
+func (store *DataStoreCache) DeleteComment(ctx context.Context, actor, commentID, reason string) (int, error) {
+	comment, err := store.Next.GetCommentByID(ctx, commentID)
+	if err != nil {
+		return store.Next.DeleteComment(ctx, actor, commentID, reason)
+	}
+	before := store.pages(ctx, comment.PostID)
+	var subtree []string
+	for queue := []string{commentID}; len(queue) > 0; queue = queue[1:] {
+		subtree = append(subtree, repliesKey(ctx, queue[0]))
+		if replies, err := store.Next.GetReplies(ctx, queue[0]); err == nil {
+			for _, reply := range replies {
+				queue = append(queue, reply.ID)
+			}
+		}
+	}
+
+	deleted, err := store.Next.DeleteComment(ctx, actor, commentID, reason)
+	if err == nil {
+		store.invalidateComment(ctx, comment, before)
+		store.invalidate(ctx, subtree...)
+	}
+	return deleted, err
+}
+
+func (store *DataStoreCache) ListComments(ctx context.Context, filter storage.CommentFilter) ([]*types.Comment, error) {
+	return store.Next.ListComments(ctx, filter)
+}
+
+func (store *DataStoreCache) GetStats(ctx context.Context) (*types.Stats, error) {
+	return store.Next.GetStats(ctx)
+}
+
+func (store *DataStoreCache) ListPosts(ctx context.Context, filter storage.PostFilter) ([]*types.Post, error) {
+	return store.Next.ListPosts(ctx, filter)
+}
+
+func (store *DataStoreCache) ImportUser(ctx context.Context, user *types.User) error {
+	return store.Next.ImportUser(ctx, user)
+}
+
+func (store *DataStoreCache) ImportPost(ctx context.Context, post *types.Post) error {
+	err := store.Next.ImportPost(ctx, post)
+	if err == nil {
+		store.invalidate(ctx, postsKey(ctx), postKey(ctx, post.ID))
+	}
+	return err
+}
+
+func (store *DataStoreCache) ImportComment(ctx context.Context, comment *types.Comment) error {
+	err := store.Next.ImportComment(ctx, comment)
+	if err == nil {
+		store.invalidateComment(ctx, comment, 0)
+	}
+	return err
+}
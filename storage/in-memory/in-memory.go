@@ -1,31 +1,65 @@
 package inMemory
 
 import (
+	"context"
 	"errors"
+	"github.com/google/uuid"
 	"graphql-comments/storage"
+	"graphql-comments/tenant"
 	"graphql-comments/types"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
 // DataStoreInMemory структура для хранения постов и комментариев в памяти
 type DataStoreInMemory struct {
+	// Tenants хранит посты и комментарии каждого арендатора
+	Tenants map[string]*Namespace
+	Users   map[string]*types.User
+	// Notifications хранит уведомления каждого пользователя в порядке создания
+	Notifications map[string][]*types.Notification
+	// Mentions хранит ID пользователей, упомянутых в комментарии
+	Mentions map[string][]string
+	// Reports хранит нерассмотренные жалобы на комментарий
+	Reports map[string][]*types.Report
+	// AuditLog журнал аудита в порядке записи
+	AuditLog   []*types.AuditEntry
+	userNames  map[string]*types.User
+	mentioning map[string][]string
+	mu         sync.RWMutex
+}
+
+// Namespace посты и комментарии одного арендатора
+type Namespace struct {
 	Posts    map[string]*types.Post
 	Comments map[string]*types.Comment
-	mu       sync.Mutex
+	// Threads хранит ID веток комментариев внешних ресурсов по пространству имен и внешнему ID
+	Threads map[string]map[string]string
 }
 
 // NewInMemoryStore создает новый in-memory store
 func NewInMemoryStore() *DataStoreInMemory {
 	return &DataStoreInMemory{
-		Posts:    make(map[string]*types.Post),
-		Comments: make(map[string]*types.Comment),
+		Tenants:       make(map[string]*Namespace),
+		Users:         make(map[string]*types.User),
+		Notifications: make(map[string][]*types.Notification),
+		Mentions:      make(map[string][]string),
+		Reports:       make(map[string][]*types.Report),
+		userNames:     make(map[string]*types.User),
+		mentioning:    make(map[string][]string),
 	}
 }
 
-func (store *DataStoreInMemory) AddPost(title, content string, allowComments bool) (*types.Post, error) {
+func (store *DataStoreInMemory) AddPost(ctx context.Context, authorID, title, content string, allowComments bool) (*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	post := &types.Post{
-		ID:            storage.GenerateNewPostUUID(),
+		ID:            storage.GenerateNewPostUUID(ctx),
+		AuthorID:      authorID,
 		Title:         title,
 		Content:       content,
 		CreatedAt:     time.Now(),
@@ -34,77 +68,126 @@ func (store *DataStoreInMemory) AddPost(title, content string, allowComments boo
 	}
 
 	store.mu.Lock()
-	store.Posts[post.ID] = post
+	store.ownNamespace(ctx).Posts[post.ID] = post
 	store.mu.Unlock()
 
 	return post, nil
 }
 
-func (store *DataStoreInMemory) AddComment(postID, parentCommentID string, content string) (*types.Comment, error) {
-	post, ok := store.Posts[postID]
-	if !ok {
-		return nil, errors.New("post not found")
-	}
-
-	if !store.Posts[postID].AllowComments {
-		return nil, errors.New("comments are not allowed for this post")
+func (store *DataStoreInMemory) AddComment(ctx context.Context, authorID, postID, parentCommentID, content, status string) (*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	comment := &types.Comment{
-		ID:              storage.GenerateNewCommentUUID(),
+		ID:              storage.GenerateNewCommentUUID(ctx),
 		PostID:          postID,
 		ParentCommentID: parentCommentID,
+		AuthorID:        authorID,
 		Content:         content,
 		CreatedAt:       time.Now(),
 		Replies:         []string{},
+		Status:          status,
 	}
 
 	store.mu.Lock()
-	store.Comments[comment.ID] = comment
-	store.mu.Unlock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
 
-	if parentCommentID == "" {
+	post, ok := ns.Posts[postID]
+	if !ok {
+		return nil, storage.ErrPostNotFound
+	}
+
+	if !post.AllowComments {
+		return nil, storage.ErrCommentsNotAllowed
+	}
+
+	var parentComment *types.Comment
+	if parentCommentID != "" {
+		if parentComment, ok = ns.Comments[parentCommentID]; !ok || parentComment.PostID != postID {
+			return nil, storage.ErrParentNotFound
+		}
+	}
+	ns.Comments[comment.ID] = comment
+
+	// Комментарии на модерации не попадают в списки комментариев поста и ответов
+	if status != types.CommentPublished {
+		return comment, nil
+	}
+	if parentComment == nil {
 		// Добавление комментария к посту
 		post.Comments = append(post.Comments, comment.ID)
 	} else {
 		// Добавление вложенного комментария
-		if parentComment, ok := store.Comments[parentCommentID]; ok {
-			parentComment.Replies = append(parentComment.Replies, comment.ID)
-		} else {
-			return nil, errors.New("parent comment not found")
-		}
+		parentComment.Replies = append(parentComment.Replies, comment.ID)
 	}
+
 	return comment, nil
 }
 
-func (store *DataStoreInMemory) GetPosts() ([]*types.Post, error) {
+func (store *DataStoreInMemory) GetPosts(ctx context.Context) ([]*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
 	posts := make([]*types.Post, 0)
 
-	for _, post := range store.Posts {
+	for _, post := range ns.Posts {
+		// Ветки комментариев внешних ресурсов не являются постами
+		if post.Namespace != "" {
+			continue
+		}
 		posts = append(posts, post)
 	}
+	slices.SortFunc(posts, comparePosts)
 
 	return posts, nil
 }
 
-func (store *DataStoreInMemory) GetPostByID(id string) (*types.Post, error) {
-	if post, ok := store.Posts[id]; ok {
+func (store *DataStoreInMemory) GetPostByID(ctx context.Context, id string) (*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	if post, ok := ns.Posts[id]; ok {
 		return post, nil
 	}
-	return nil, errors.New("post not found")
+	return nil, storage.ErrPostNotFound
 }
 
-func (store *DataStoreInMemory) GetComments(postID string, page int) ([]*types.Comment, error) {
-	post, err := store.GetPostByID(postID)
-	if err != nil {
+func (store *DataStoreInMemory) GetComments(ctx context.Context, postID string, page int) ([]*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+	pageSize := storage.Limits(ctx).CommentsPageSize
+
+	post, ok := ns.Posts[postID]
+	if !ok {
+		return nil, storage.ErrPostNotFound
+	}
+
 	comments := make([]*types.Comment, 0)
-	for idx := (page - 1) * storage.CommentsPageSize; idx < len(post.Comments) && idx < page*storage.CommentsPageSize; idx++ {
-		comment, err := store.GetCommentByID(post.Comments[idx])
-		if err != nil {
-			return nil, err
+	for idx := (page - 1) * pageSize; idx < len(post.Comments) && idx < page*pageSize; idx++ {
+		comment, ok := ns.Comments[post.Comments[idx]]
+		if !ok {
+			return nil, storage.ErrCommentNotFound
 		}
 
 		comments = append(comments, comment)
@@ -113,31 +196,60 @@ func (store *DataStoreInMemory) GetComments(postID string, page int) ([]*types.C
 	return comments, nil
 }
 
-func (store *DataStoreInMemory) GetCommentByID(id string) (*types.Comment, error) {
-	if comment, ok := store.Comments[id]; ok {
+func (store *DataStoreInMemory) GetCommentByID(ctx context.Context, id string) (*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	if comment, ok := ns.Comments[id]; ok {
 		return comment, nil
 	}
-	return nil, errors.New("comment not found")
+	return nil, storage.ErrCommentNotFound
 }
 
-func (store *DataStoreInMemory) GetNumberOfCommentPages(postID string) (int, error) {
-	if _, ok := store.Posts[postID]; !ok {
-		return 0, errors.New("post not found")
+func (store *DataStoreInMemory) GetNumberOfCommentPages(ctx context.Context, postID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
-	return len(store.Posts[postID].Comments) / storage.CommentsPageSize, nil
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	post, ok := ns.Posts[postID]
+	if !ok {
+		return 0, storage.ErrPostNotFound
+	}
+	pageSize := storage.Limits(ctx).CommentsPageSize
+	return (len(post.Comments) + pageSize - 1) / pageSize, nil
 }
 
-func (store *DataStoreInMemory) GetReplies(commentID string) ([]*types.Comment, error) {
-	comment, err := store.GetCommentByID(commentID)
-	if err != nil {
+func (store *DataStoreInMemory) GetReplies(ctx context.Context, commentID string) ([]*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
 
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	comment, ok := ns.Comments[commentID]
+	if !ok {
+		return nil, storage.ErrCommentNotFound
+	}
+
 	replies := make([]*types.Comment, 0)
 	for _, replyID := range comment.Replies {
-		reply, err := store.GetCommentByID(replyID)
-		if err != nil {
-			return nil, err
+		reply, ok := ns.Comments[replyID]
+		if !ok {
+			return nil, storage.ErrCommentNotFound
 		}
 
 		replies = append(replies, reply)
@@ -145,3 +257,735 @@ func (store *DataStoreInMemory) GetReplies(commentID string) ([]*types.Comment,
 
 	return replies, nil
 }
+
+func (store *DataStoreInMemory) AddUser(ctx context.Context, name string) (*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	user := &types.User{
+		ID:        storage.GenerateNewUserUUID(ctx),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Имена пользователей уникальны без учета регистра
+	key := strings.ToLower(name)
+	if _, ok := store.userNames[key]; ok {
+		return nil, errors.New("user name is already taken")
+	}
+	store.Users[user.ID] = user
+	store.userNames[key] = user
+
+	return user, nil
+}
+
+func (store *DataStoreInMemory) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if user, ok := store.Users[id]; ok {
+		return user, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func (store *DataStoreInMemory) GetUserByName(ctx context.Context, name string) (*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if user, ok := store.userNames[strings.ToLower(name)]; ok {
+		return user, nil
+	}
+	return nil, errors.New("user not found")
+}
+
+func (store *DataStoreInMemory) AddNotifications(ctx context.Context, notifications []*types.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, notification := range notifications {
+		if notification.ID == "" {
+			notification.ID = uuid.NewString()
+		}
+		if notification.CreatedAt.IsZero() {
+			notification.CreatedAt = time.Now()
+		}
+		store.Notifications[notification.UserID] = append(store.Notifications[notification.UserID], notification)
+	}
+	return nil
+}
+
+func (store *DataStoreInMemory) GetNotifications(ctx context.Context, userID string, filter storage.NotificationFilter) ([]*types.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	inbox := store.Notifications[userID]
+	end := len(inbox)
+	if filter.After != "" {
+		end = -1
+		for idx, notification := range inbox {
+			if notification.ID == filter.After {
+				end = idx
+				break
+			}
+		}
+		if end == -1 {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	notifications := make([]*types.Notification, 0)
+	for idx := end - 1; idx >= 0 && (filter.Limit <= 0 || len(notifications) < filter.Limit); idx-- {
+		if filter.UnreadOnly && inbox[idx].ReadAt != nil {
+			continue
+		}
+		notifications = append(notifications, inbox[idx])
+	}
+	return notifications, nil
+}
+
+func (store *DataStoreInMemory) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	unread := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		unread[id] = struct{}{}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	marked := 0
+	for _, notification := range store.Notifications[userID] {
+		if _, ok := unread[notification.ID]; ok && notification.ReadAt == nil {
+			notification.ReadAt = &now
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (store *DataStoreInMemory) GetUnreadNotificationCount(ctx context.Context, userID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	count := 0
+	for _, notification := range store.Notifications[userID] {
+		if notification.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (store *DataStoreInMemory) AddMentions(ctx context.Context, commentID string, userIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
+
+	if _, ok := ns.Comments[commentID]; !ok {
+		return storage.ErrCommentNotFound
+	}
+	for _, userID := range userIDs {
+		if _, ok := store.Users[userID]; !ok {
+			return errors.New("user not found")
+		}
+	}
+
+	for _, userID := range userIDs {
+		if slices.Contains(store.Mentions[commentID], userID) {
+			continue
+		}
+		store.Mentions[commentID] = append(store.Mentions[commentID], userID)
+		store.mentioning[userID] = append(store.mentioning[userID], commentID)
+	}
+	return nil
+}
+
+func (store *DataStoreInMemory) GetMentionedUsers(ctx context.Context, commentID string) ([]*types.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	users := make([]*types.User, 0)
+	for _, userID := range store.Mentions[commentID] {
+		if user, ok := store.Users[userID]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (store *DataStoreInMemory) GetCommentsMentioning(ctx context.Context, userID string, limit int) ([]*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	comments := make([]*types.Comment, 0)
+	mentioning := store.mentioning[userID]
+	for idx := len(mentioning) - 1; idx >= 0 && (limit <= 0 || len(comments) < limit); idx-- {
+		if comment, ok := ns.Comments[mentioning[idx]]; ok && comment.Status == types.CommentPublished {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (store *DataStoreInMemory) ModerateComment(ctx context.Context, actor, commentID, status, reason string) (*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var action string
+	switch status {
+	case types.CommentPublished:
+		action = types.AuditApprove
+	case types.CommentRejected:
+		action = types.AuditReject
+	default:
+		return nil, errors.New("invalid comment status")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
+
+	comment, ok := ns.Comments[commentID]
+	if !ok {
+		return nil, storage.ErrCommentNotFound
+	}
+
+	// Ссылки на комментарий в списках поста и родительского комментария
+	// есть только у опубликованных комментариев
+	var list *[]string
+	if comment.ParentCommentID == "" {
+		list = &ns.Posts[comment.PostID].Comments
+	} else {
+		list = &ns.Comments[comment.ParentCommentID].Replies
+	}
+	switch {
+	case status == types.CommentPublished && comment.Status != types.CommentPublished:
+		*list = append(*list, comment.ID)
+	case status != types.CommentPublished && comment.Status == types.CommentPublished:
+		*list = slices.DeleteFunc(*list, func(id string) bool { return id == comment.ID })
+	}
+	comment.Status = status
+
+	delete(store.Reports, commentID)
+	store.audit(ctx, actor, action, commentID, reason)
+	return comment, nil
+}
+
+func (store *DataStoreInMemory) ReportComment(ctx context.Context, report *types.Report) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
+
+	if _, ok := ns.Comments[report.CommentID]; !ok {
+		return storage.ErrCommentNotFound
+	}
+	for _, existing := range store.Reports[report.CommentID] {
+		if existing.UserID == report.UserID {
+			return nil
+		}
+	}
+
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	store.Reports[report.CommentID] = append(store.Reports[report.CommentID], report)
+	store.audit(ctx, report.UserID, types.AuditReport, report.CommentID, report.Reason)
+	return nil
+}
+
+func (store *DataStoreInMemory) GetModerationQueue(ctx context.Context, filter storage.ModerationFilter) ([]*types.ModerationItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	var match func(comment *types.Comment) bool
+	switch filter.Queue {
+	case types.QueuePending:
+		match = func(comment *types.Comment) bool { return comment.Status == types.CommentPending }
+	case types.QueueFlagged:
+		match = func(comment *types.Comment) bool {
+			return comment.Status == types.CommentPublished && len(store.Reports[comment.ID]) > 0
+		}
+	default:
+		return nil, errors.New("invalid moderation queue")
+	}
+
+	var after *types.Comment
+	if filter.After != "" {
+		var ok bool
+		if after, ok = ns.Comments[filter.After]; !ok {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	comments := make([]*types.Comment, 0)
+	for _, comment := range ns.Comments {
+		if match(comment) && (after == nil || compareComments(comment, after) > 0) {
+			comments = append(comments, comment)
+		}
+	}
+	slices.SortFunc(comments, compareComments)
+	if filter.Limit > 0 && len(comments) > filter.Limit {
+		comments = comments[:filter.Limit]
+	}
+
+	items := make([]*types.ModerationItem, 0, len(comments))
+	for _, comment := range comments {
+		item := &types.ModerationItem{Comment: comment, Reasons: []string{}}
+		for _, report := range store.Reports[comment.ID] {
+			item.Reports++
+			item.Reasons = append(item.Reasons, report.Reason)
+			if item.LastReportedAt == nil || report.CreatedAt.After(*item.LastReportedAt) {
+				createdAt := report.CreatedAt
+				item.LastReportedAt = &createdAt
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (store *DataStoreInMemory) GetAuditLog(ctx context.Context, filter storage.AuditFilter) ([]*types.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	end := len(store.AuditLog)
+	if filter.After != "" {
+		end = slices.IndexFunc(store.AuditLog, func(entry *types.AuditEntry) bool { return entry.ID == filter.After })
+		if end == -1 {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	entries := make([]*types.AuditEntry, 0)
+	for idx := end - 1; idx >= 0 && (filter.Limit <= 0 || len(entries) < filter.Limit); idx-- {
+		entry := store.AuditLog[idx]
+		if entry.TenantID != tenant.ID(ctx) ||
+			(filter.Actor != "" && entry.Actor != filter.Actor) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.TargetID != "" && entry.TargetID != filter.TargetID) {
+			continue
+		}
+		// Записи журнала возвращаются копиями, чтобы их нельзя было изменить
+		copied := *entry
+		entries = append(entries, &copied)
+	}
+	return entries, nil
+}
+
+// audit добавляет запись в журнал аудита; вызывается под блокировкой store.mu
+func (store *DataStoreInMemory) audit(ctx context.Context, actor, action, targetID, reason string) {
+	store.AuditLog = append(store.AuditLog, &types.AuditEntry{
+		ID:        uuid.NewString(),
+		TenantID:  tenant.ID(ctx),
+		Actor:     actor,
+		Action:    action,
+		TargetID:  targetID,
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	})
+}
+
+// compareComments упорядочивает комментарии по времени создания и ID
+func compareComments(a, b *types.Comment) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// emptyNamespace пространство арендатора, у которого еще нет постов
+var emptyNamespace = &Namespace{}
+
+// namespace возвращает пространство арендатора запроса; вызывается под блокировкой store.mu
+func (store *DataStoreInMemory) namespace(ctx context.Context) *Namespace {
+	if ns, ok := store.Tenants[tenant.ID(ctx)]; ok {
+		return ns
+	}
+	return emptyNamespace
+}
+
+// ownNamespace возвращает пространство арендатора запроса, создавая его при необходимости;
+// вызывается под блокировкой store.mu на запись
+func (store *DataStoreInMemory) ownNamespace(ctx context.Context) *Namespace {
+	ns, ok := store.Tenants[tenant.ID(ctx)]
+	if !ok {
+		ns = &Namespace{
+			Posts:    make(map[string]*types.Post),
+			Comments: make(map[string]*types.Comment),
+			Threads:  make(map[string]map[string]string),
+		}
+		store.Tenants[tenant.ID(ctx)] = ns
+	}
+	return ns
+}
+
+func (store *DataStoreInMemory) GetThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+	id, ok := ns.Threads[namespace][externalID]
+	if !ok {
+		return nil, storage.ErrThreadNotFound
+	}
+	return ns.Posts[id], nil
+}
+
+func (store *DataStoreInMemory) AddThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	thread := &types.Post{
+		ID:            storage.GenerateNewPostUUID(ctx),
+		CreatedAt:     time.Now(),
+		Comments:      []string{},
+		AllowComments: true,
+		Namespace:     namespace,
+		ExternalID:    externalID,
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.ownNamespace(ctx)
+	// Ветка могла быть создана параллельным запросом
+	if id, ok := ns.Threads[namespace][externalID]; ok {
+		return ns.Posts[id], nil
+	}
+	if ns.Threads[namespace] == nil {
+		ns.Threads[namespace] = make(map[string]string)
+	}
+	ns.Threads[namespace][externalID] = thread.ID
+	ns.Posts[thread.ID] = thread
+
+	return thread, nil
+}
+
+func (store *DataStoreInMemory) SetAllowComments(ctx context.Context, actor, postID string, allow bool) (*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	post, ok := store.namespace(ctx).Posts[postID]
+	if !ok {
+		return nil, storage.ErrPostNotFound
+	}
+	post.AllowComments = allow
+
+	action := types.AuditLock
+	if allow {
+		action = types.AuditUnlock
+	}
+	store.audit(ctx, actor, action, postID, "")
+	return post, nil
+}
+
+func (store *DataStoreInMemory) DeleteComment(ctx context.Context, actor, commentID, reason string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
+
+	comment, ok := ns.Comments[commentID]
+	if !ok {
+		return 0, storage.ErrCommentNotFound
+	}
+
+	// Ответы любого статуса удаляются вместе с комментарием
+	deleted := map[string]bool{commentID: true}
+	for found := true; found; {
+		found = false
+		for _, reply := range ns.Comments {
+			if !deleted[reply.ID] && deleted[reply.ParentCommentID] {
+				deleted[reply.ID] = true
+				found = true
+			}
+		}
+	}
+
+	if comment.ParentCommentID == "" {
+		post := ns.Posts[comment.PostID]
+		post.Comments = slices.DeleteFunc(post.Comments, func(id string) bool { return id == commentID })
+	} else {
+		parent := ns.Comments[comment.ParentCommentID]
+		parent.Replies = slices.DeleteFunc(parent.Replies, func(id string) bool { return id == commentID })
+	}
+	for id := range deleted {
+		for _, userID := range store.Mentions[id] {
+			store.mentioning[userID] = slices.DeleteFunc(store.mentioning[userID], func(id string) bool { return deleted[id] })
+		}
+		delete(store.Mentions, id)
+		delete(store.Reports, id)
+		delete(ns.Comments, id)
+	}
+	for userID, notifications := range store.Notifications {
+		store.Notifications[userID] = slices.DeleteFunc(notifications, func(notification *types.Notification) bool {
+			return deleted[notification.CommentID]
+		})
+	}
+
+	store.audit(ctx, actor, types.AuditDelete, commentID, reason)
+	return len(deleted), nil
+}
+
+func (store *DataStoreInMemory) ListComments(ctx context.Context, filter storage.CommentFilter) ([]*types.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	var after *types.Comment
+	if filter.After != "" {
+		var ok bool
+		if after, ok = ns.Comments[filter.After]; !ok {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	comments := make([]*types.Comment, 0)
+	for _, comment := range ns.Comments {
+		if (filter.PostID == "" || comment.PostID == filter.PostID) && (after == nil || compareComments(comment, after) > 0) {
+			comments = append(comments, comment)
+		}
+	}
+	slices.SortFunc(comments, compareComments)
+	if filter.Limit > 0 && len(comments) > filter.Limit {
+		comments = comments[:filter.Limit]
+	}
+	return comments, nil
+}
+
+func (store *DataStoreInMemory) GetStats(ctx context.Context) (*types.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	stats := &types.Stats{Users: len(store.Users)}
+	for _, post := range ns.Posts {
+		if post.Namespace == "" {
+			stats.Posts++
+		} else {
+			stats.Threads++
+		}
+	}
+	for _, comment := range ns.Comments {
+		switch comment.Status {
+		case types.CommentPublished:
+			stats.Published++
+		case types.CommentPending:
+			stats.Pending++
+		case types.CommentRejected:
+			stats.Rejected++
+		}
+		stats.Reports += len(store.Reports[comment.ID])
+	}
+	return stats, nil
+}
+
+func (store *DataStoreInMemory) ListPosts(ctx context.Context, filter storage.PostFilter) ([]*types.Post, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ns := store.namespace(ctx)
+
+	var after *types.Post
+	if filter.After != "" {
+		var ok bool
+		if after, ok = ns.Posts[filter.After]; !ok {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	posts := make([]*types.Post, 0)
+	for _, post := range ns.Posts {
+		if after == nil || comparePosts(post, after) > 0 {
+			copied := *post
+			copied.Comments = []string{}
+			posts = append(posts, &copied)
+		}
+	}
+	slices.SortFunc(posts, comparePosts)
+	if filter.Limit > 0 && len(posts) > filter.Limit {
+		posts = posts[:filter.Limit]
+	}
+	return posts, nil
+}
+
+func (store *DataStoreInMemory) ImportUser(ctx context.Context, user *types.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := strings.ToLower(user.Name)
+	if _, ok := store.Users[user.ID]; ok {
+		return storage.ErrAlreadyExists
+	}
+	if _, ok := store.userNames[key]; ok {
+		return storage.ErrAlreadyExists
+	}
+	copied := *user
+	store.Users[user.ID] = &copied
+	store.userNames[key] = &copied
+	return nil
+}
+
+func (store *DataStoreInMemory) ImportPost(ctx context.Context, post *types.Post) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.ownNamespace(ctx)
+	if _, ok := ns.Posts[post.ID]; ok {
+		return storage.ErrAlreadyExists
+	}
+	if _, ok := ns.Threads[post.Namespace][post.ExternalID]; ok && post.Namespace != "" {
+		return storage.ErrAlreadyExists
+	}
+
+	copied := *post
+	copied.Comments = []string{}
+	ns.Posts[post.ID] = &copied
+	if post.Namespace != "" {
+		if ns.Threads[post.Namespace] == nil {
+			ns.Threads[post.Namespace] = make(map[string]string)
+		}
+		ns.Threads[post.Namespace][post.ExternalID] = post.ID
+	}
+	return nil
+}
+
+func (store *DataStoreInMemory) ImportComment(ctx context.Context, comment *types.Comment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	ns := store.namespace(ctx)
+	post, ok := ns.Posts[comment.PostID]
+	if !ok {
+		return storage.ErrPostNotFound
+	}
+	var parent *types.Comment
+	if comment.ParentCommentID != "" {
+		if parent, ok = ns.Comments[comment.ParentCommentID]; !ok {
+			return storage.ErrParentNotFound
+		}
+	}
+	if _, ok := ns.Comments[comment.ID]; ok {
+		return storage.ErrAlreadyExists
+	}
+
+	copied := *comment
+	copied.Replies = []string{}
+	ns.Comments[comment.ID] = &copied
+	switch {
+	case comment.Status != types.CommentPublished:
+	case parent == nil:
+		post.Comments = append(post.Comments, comment.ID)
+	default:
+		parent.Replies = append(parent.Replies, comment.ID)
+	}
+	return nil
+}
+
+// comparePosts упорядочивает посты по времени создания и ID
+func comparePosts(a, b *types.Post) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
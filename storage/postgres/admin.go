@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"github.com/lib/pq"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strconv"
+	"time"
+)
+
+//go:embed init.sql
+var schema string
+
+// Migrate применяет к базе db схему init.sql. Скрипт идемпотентен, поэтому его можно
+// выполнять при каждом обновлении.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, schema)
+	return err
+}
+
+func (store *DataStorePostgres) SetAllowComments(ctx context.Context, actor, postID string, allow bool) (*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpSetAllowComments)
+	defer cancel()
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE posts SET allow_comments = $1 WHERE id = $2 AND tenant_id = $3", allow, postID, tenant.ID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, storage.ErrPostNotFound
+	}
+
+	action := types.AuditLock
+	if allow {
+		action = types.AuditUnlock
+	}
+	if err := insertAuditEntry(ctx, tx, actor, action, postID, ""); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return store.GetPostByID(ctx, postID)
+}
+
+func (store *DataStorePostgres) DeleteComment(ctx context.Context, actor, commentID, reason string) (int, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpDeleteComment)
+	defer cancel()
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Ответы любого статуса удаляются вместе с комментарием
+	rows, err := tx.QueryContext(ctx,
+		"WITH RECURSIVE subtree AS (SELECT id FROM comments WHERE id = $1 AND tenant_id = $2 "+
+			"UNION ALL SELECT comments.id FROM comments JOIN subtree ON comments.parent_comment_id = subtree.id) SELECT id FROM subtree",
+		commentID, tenant.ID(ctx))
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, storage.ErrCommentNotFound
+	}
+
+	for _, query := range []string{
+		"DELETE FROM notifications WHERE comment_id = ANY($1)",
+		"DELETE FROM comment_mentions WHERE comment_id = ANY($1)",
+		"DELETE FROM comment_reports WHERE comment_id = ANY($1)",
+		"DELETE FROM comments WHERE id = ANY($1)",
+	} {
+		if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+			return 0, err
+		}
+	}
+	if err := insertAuditEntry(ctx, tx, actor, types.AuditDelete, commentID, reason); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (store *DataStorePostgres) ListComments(ctx context.Context, filter storage.CommentFilter) ([]*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpListComments)
+	defer cancel()
+
+	comments := make([]*types.Comment, 0)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		query := "SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE tenant_id = $1"
+		args := []interface{}{tenant.ID(ctx)}
+		if filter.PostID != "" {
+			args = append(args, filter.PostID)
+			query += " AND post_id = $" + strconv.Itoa(len(args))
+		}
+		if filter.After != "" {
+			var createdAt time.Time
+			err := tx.QueryRowContext(ctx, "SELECT created_at FROM comments WHERE id = $1 AND tenant_id = $2", filter.After, tenant.ID(ctx)).Scan(&createdAt)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errors.New("invalid cursor")
+				}
+				return err
+			}
+			args = append(args, createdAt, filter.After)
+			query += " AND (created_at, id) > ($" + strconv.Itoa(len(args)-1) + ", $" + strconv.Itoa(len(args)) + ")"
+		}
+		query += " ORDER BY created_at, id"
+		if filter.Limit > 0 {
+			args = append(args, filter.Limit)
+			query += " LIMIT $" + strconv.Itoa(len(args))
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			comment := &types.Comment{Replies: []string{}}
+			var parentCommentID, authorID sql.NullString
+			if err := rows.Scan(&comment.ID, &comment.PostID, &parentCommentID, &authorID, &comment.Content, &comment.CreatedAt, &comment.Status); err != nil {
+				return err
+			}
+			comment.ParentCommentID = parentCommentID.String
+			comment.AuthorID = authorID.String
+			comments = append(comments, comment)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (store *DataStorePostgres) GetStats(ctx context.Context) (*types.Stats, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetStats)
+	defer cancel()
+
+	stats := &types.Stats{}
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx,
+			"SELECT "+
+				"(SELECT COUNT(*) FROM posts WHERE tenant_id = $1 AND namespace IS NULL), "+
+				"(SELECT COUNT(*) FROM posts WHERE tenant_id = $1 AND namespace IS NOT NULL), "+
+				"(SELECT COUNT(*) FROM comments WHERE tenant_id = $1 AND status = 'PUBLISHED'), "+
+				"(SELECT COUNT(*) FROM comments WHERE tenant_id = $1 AND status = 'PENDING'), "+
+				"(SELECT COUNT(*) FROM comments WHERE tenant_id = $1 AND status = 'REJECTED'), "+
+				"(SELECT COUNT(*) FROM comment_reports JOIN comments ON comments.id = comment_reports.comment_id WHERE comments.tenant_id = $1), "+
+				"(SELECT COUNT(*) FROM users)",
+			tenant.ID(ctx)).Scan(&stats.Posts, &stats.Threads, &stats.Published, &stats.Pending, &stats.Rejected, &stats.Reports, &stats.Users)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strconv"
+	"time"
+)
+
+func (store *DataStorePostgres) ListPosts(ctx context.Context, filter storage.PostFilter) ([]*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpListPosts)
+	defer cancel()
+
+	posts := make([]*types.Post, 0)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		query := "SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE tenant_id = $1"
+		args := []interface{}{tenant.ID(ctx)}
+		if filter.After != "" {
+			var createdAt time.Time
+			err := tx.QueryRowContext(ctx, "SELECT created_at FROM posts WHERE id = $1 AND tenant_id = $2", filter.After, tenant.ID(ctx)).Scan(&createdAt)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errors.New("invalid cursor")
+				}
+				return err
+			}
+			args = append(args, createdAt, filter.After)
+			query += " AND (created_at, id) > ($2, $3)"
+		}
+		query += " ORDER BY created_at, id"
+		if filter.Limit > 0 {
+			args = append(args, filter.Limit)
+			query += " LIMIT $" + strconv.Itoa(len(args))
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			post := &types.Post{Comments: []string{}}
+			var authorID, namespace, externalID sql.NullString
+			if err := rows.Scan(&post.ID, &authorID, &post.Title, &post.Content, &post.CreatedAt, &post.AllowComments, &namespace, &externalID); err != nil {
+				return err
+			}
+			post.AuthorID = authorID.String
+			post.Namespace = namespace.String
+			post.ExternalID = externalID.String
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (store *DataStorePostgres) ImportUser(ctx context.Context, user *types.User) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpImportUser)
+	defer cancel()
+
+	// Конфликт возможен как по ID, так и по имени пользователя
+	result, err := store.DB.ExecContext(ctx, "INSERT INTO users (id, name, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		user.ID, user.Name, user.CreatedAt)
+	return inserted(result, err)
+}
+
+func (store *DataStorePostgres) ImportPost(ctx context.Context, post *types.Post) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpImportPost)
+	defer cancel()
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO posts (id, tenant_id, author_id, title, content, created_at, allow_comments, namespace, external_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING",
+		post.ID, tenant.ID(ctx), nullable(post.AuthorID), post.Title, post.Content, post.CreatedAt, post.AllowComments, nullable(post.Namespace), nullable(post.ExternalID))
+	if err := inserted(result, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *DataStorePostgres) ImportComment(ctx context.Context, comment *types.Comment) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpImportComment)
+	defer cancel()
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Внешние ключи не учитывают арендатора, поэтому пост и родитель проверяются явно
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND tenant_id = $2)", comment.PostID, tenant.ID(ctx)).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrPostNotFound
+	}
+	if comment.ParentCommentID != "" {
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)", comment.ParentCommentID, tenant.ID(ctx)).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrParentNotFound
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING",
+		comment.ID, tenant.ID(ctx), comment.PostID, nullable(comment.ParentCommentID), nullable(comment.AuthorID), comment.Content, comment.CreatedAt, comment.Status)
+	if err := inserted(result, err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inserted возвращает storage.ErrAlreadyExists, если INSERT ... ON CONFLICT DO NOTHING
+// не добавил строку
+func inserted(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return storage.ErrAlreadyExists
+	}
+	return nil
+}
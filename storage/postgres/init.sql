@@ -15,3 +15,157 @@ CREATE TABLE IF NOT EXISTS Comments (
     FOREIGN KEY (post_id) REFERENCES Posts(id),
     FOREIGN KEY (parent_comment_id) REFERENCES Comments(id)
 );
+
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(128) PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_name ON users (LOWER(name));
+
+ALTER TABLE Posts ADD COLUMN IF NOT EXISTS author_id VARCHAR(128) REFERENCES users(id);
+ALTER TABLE Comments ADD COLUMN IF NOT EXISTS author_id VARCHAR(128) REFERENCES users(id);
+ALTER TABLE Comments ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'PUBLISHED';
+
+ALTER TABLE Posts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE Comments ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS posts_tenant ON Posts (tenant_id);
+
+-- Ветки комментариев внешних ресурсов; у постов, созданных через addPost, поля пусты
+ALTER TABLE Posts ADD COLUMN IF NOT EXISTS namespace VARCHAR(64);
+ALTER TABLE Posts ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS posts_thread ON Posts (tenant_id, namespace, external_id);
+CREATE INDEX IF NOT EXISTS comments_tenant_post ON Comments (tenant_id, post_id);
+
+-- Запросы приложения явно ограничены tenant_id и выполняются в транзакциях, задающих
+-- app.tenant_id. Политики дополнительно изолируют арендаторов: без app.tenant_id
+-- строки не видны и не могут быть изменены.
+ALTER TABLE Posts ENABLE ROW LEVEL SECURITY;
+ALTER TABLE Posts FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON Posts;
+CREATE POLICY tenant_isolation ON Posts
+    USING (tenant_id = current_setting('app.tenant_id'));
+
+ALTER TABLE Comments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE Comments FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON Comments;
+CREATE POLICY tenant_isolation ON Comments
+    USING (tenant_id = current_setting('app.tenant_id'));
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id VARCHAR(128) PRIMARY KEY,
+    user_id VARCHAR(128) NOT NULL REFERENCES users(id),
+    type VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(128) REFERENCES users(id),
+    post_id VARCHAR(128) NOT NULL REFERENCES Posts(id),
+    comment_id VARCHAR(128) NOT NULL REFERENCES Comments(id),
+    created_at TIMESTAMPTZ NOT NULL,
+    read_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notifications_inbox ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS notifications_unread ON notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id VARCHAR(128) NOT NULL REFERENCES Comments(id),
+    user_id VARCHAR(128) NOT NULL REFERENCES users(id),
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS comment_mentions_user ON comment_mentions (user_id);
+
+CREATE TABLE IF NOT EXISTS comment_reports (
+    comment_id VARCHAR(128) NOT NULL REFERENCES Comments(id),
+    user_id VARCHAR(128) NOT NULL REFERENCES users(id),
+    reason VARCHAR(500) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS comments_pending ON Comments (created_at, id) WHERE status = 'PENDING';
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id VARCHAR(128) PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    actor VARCHAR(128) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id VARCHAR(128) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_tenant ON audit_log (tenant_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_log_target ON audit_log (target_id);
+
+-- Журнал аудита доступен только для добавления записей
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable();
+
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS cache_entries (
+    key VARCHAR(512) PRIMARY KEY,
+    value BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS persisted_queries (
+    hash CHAR(64) PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id VARCHAR(128) PRIMARY KEY,
+    url TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id VARCHAR(128) PRIMARY KEY,
+    webhook_id VARCHAR(128) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id VARCHAR(128) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    response_status INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (webhook_id, event_id)
+);
+
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS webhooks_tenant ON webhooks (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_log ON webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    seq BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(128) NOT NULL UNIQUE,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished ON outbox (seq) WHERE published_at IS NULL;
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+)
+
+func (store *DataStorePostgres) AddMentions(ctx context.Context, commentID string, userIDs []string) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddMentions)
+	defer cancel()
+
+	_, err := store.DB.ExecContext(ctx,
+		"INSERT INTO comment_mentions (comment_id, user_id) SELECT $1, UNNEST($2::VARCHAR[]) ON CONFLICT DO NOTHING",
+		commentID, pq.Array(userIDs))
+	return err
+}
+
+func (store *DataStorePostgres) GetMentionedUsers(ctx context.Context, commentID string) ([]*types.User, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetMentionedUsers)
+	defer cancel()
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT users.id, users.name, users.created_at FROM comment_mentions JOIN users ON users.id = comment_mentions.user_id "+
+			"WHERE comment_mentions.comment_id = $1 ORDER BY users.name", commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*types.User, 0)
+	for rows.Next() {
+		user := &types.User{}
+		if err := rows.Scan(&user.ID, &user.Name, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (store *DataStorePostgres) GetCommentsMentioning(ctx context.Context, userID string, limit int) ([]*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetCommentsMentioning)
+	defer cancel()
+
+	comments := make([]*types.Comment, 0)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			"SELECT comments.id, comments.post_id, comments.parent_comment_id, comments.author_id, comments.content, comments.created_at "+
+				"FROM comment_mentions JOIN comments ON comments.id = comment_mentions.comment_id "+
+				"WHERE comment_mentions.user_id = $1 AND comments.tenant_id = $2 AND comments.status = 'PUBLISHED' "+
+				"ORDER BY comments.created_at DESC LIMIT $3", userID, tenant.ID(ctx), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			comment := &types.Comment{}
+			var parentCommentID, authorID sql.NullString
+			if err := rows.Scan(&comment.ID, &comment.PostID, &parentCommentID, &authorID, &comment.Content, &comment.CreatedAt); err != nil {
+				return err
+			}
+			comment.ParentCommentID = parentCommentID.String
+			comment.AuthorID = authorID.String
+			comment.Status = types.CommentPublished
+			comments = append(comments, comment)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
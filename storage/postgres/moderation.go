@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"graphql-comments/events"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strconv"
+	"time"
+)
+
+const auditColumns = "id, tenant_id, actor, action, target_id, reason, created_at"
+
+func (store *DataStorePostgres) ModerateComment(ctx context.Context, actor, commentID, status, reason string) (*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpModerateComment)
+	defer cancel()
+
+	action, err := auditAction(status)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	comment := &types.Comment{}
+	var parentCommentID, authorID sql.NullString
+	err = tx.QueryRowContext(ctx,
+		"SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2 FOR UPDATE", commentID, tenant.ID(ctx)).Scan(
+		&comment.ID, &comment.PostID, &parentCommentID, &authorID, &comment.Content, &comment.CreatedAt, &comment.Status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	comment.ParentCommentID = parentCommentID.String
+	comment.AuthorID = authorID.String
+	previous := comment.Status
+
+	if _, err := tx.ExecContext(ctx, "UPDATE comments SET status = $1 WHERE id = $2", status, commentID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM comment_reports WHERE comment_id = $1", commentID); err != nil {
+		return nil, err
+	}
+	if err := insertAuditEntry(ctx, tx, actor, action, commentID, reason); err != nil {
+		return nil, err
+	}
+
+	// Одобренный комментарий публикуется так же, как добавленный без модерации
+	if status == types.CommentPublished && previous != types.CommentPublished {
+		comment.Status = status
+		if err := insertEvent(ctx, tx, events.NewCommentCreated(ctx, comment)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return store.GetCommentByID(ctx, commentID)
+}
+
+func (store *DataStorePostgres) ReportComment(ctx context.Context, report *types.Report) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpReportComment)
+	defer cancel()
+
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)", report.CommentID, tenant.ID(ctx)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrCommentNotFound
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO comment_reports (comment_id, user_id, reason, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING",
+		report.CommentID, report.UserID, report.Reason, report.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if added, err := result.RowsAffected(); err != nil || added == 0 {
+		return err
+	}
+	if err := insertAuditEntry(ctx, tx, report.UserID, types.AuditReport, report.CommentID, report.Reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *DataStorePostgres) GetModerationQueue(ctx context.Context, filter storage.ModerationFilter) ([]*types.ModerationItem, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetModerationQueue)
+	defer cancel()
+
+	items := make([]*types.ModerationItem, 0)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		query := "SELECT comments.id, comments.post_id, comments.parent_comment_id, comments.author_id, comments.content, comments.created_at, comments.status, " +
+			"COUNT(comment_reports.user_id), COALESCE(ARRAY_AGG(comment_reports.reason) FILTER (WHERE comment_reports.user_id IS NOT NULL), '{}'), MAX(comment_reports.created_at) " +
+			"FROM comments LEFT JOIN comment_reports ON comment_reports.comment_id = comments.id WHERE comments.tenant_id = $1 AND comments.status = $2"
+		args := []interface{}{tenant.ID(ctx)}
+		switch filter.Queue {
+		case types.QueuePending:
+			args = append(args, types.CommentPending)
+		case types.QueueFlagged:
+			args = append(args, types.CommentPublished)
+		default:
+			return errors.New("invalid moderation queue")
+		}
+		if filter.After != "" {
+			var createdAt time.Time
+			err := tx.QueryRowContext(ctx, "SELECT created_at FROM comments WHERE id = $1 AND tenant_id = $2", filter.After, tenant.ID(ctx)).Scan(&createdAt)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return errors.New("invalid cursor")
+				}
+				return err
+			}
+			args = append(args, createdAt, filter.After)
+			query += " AND (comments.created_at, comments.id) > ($3, $4)"
+		}
+		query += " GROUP BY comments.id"
+		if filter.Queue == types.QueueFlagged {
+			query += " HAVING COUNT(comment_reports.user_id) > 0"
+		}
+		query += " ORDER BY comments.created_at, comments.id"
+		if filter.Limit > 0 {
+			args = append(args, filter.Limit)
+			query += " LIMIT $" + strconv.Itoa(len(args))
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			item := &types.ModerationItem{Comment: &types.Comment{}}
+			var parentCommentID, authorID sql.NullString
+			var lastReportedAt sql.NullTime
+			if err := rows.Scan(&item.Comment.ID, &item.Comment.PostID, &parentCommentID, &authorID, &item.Comment.Content,
+				&item.Comment.CreatedAt, &item.Comment.Status, &item.Reports, pq.Array(&item.Reasons), &lastReportedAt); err != nil {
+				return err
+			}
+			item.Comment.ParentCommentID = parentCommentID.String
+			item.Comment.AuthorID = authorID.String
+			if lastReportedAt.Valid {
+				item.LastReportedAt = &lastReportedAt.Time
+			}
+			items = append(items, item)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (store *DataStorePostgres) GetAuditLog(ctx context.Context, filter storage.AuditFilter) ([]*types.AuditEntry, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetAuditLog)
+	defer cancel()
+
+	query := "SELECT " + auditColumns + " FROM audit_log WHERE tenant_id = $1"
+	args := []interface{}{tenant.ID(ctx)}
+	conditions := []struct{ column, value string }{
+		{"actor", filter.Actor},
+		{"action", filter.Action},
+		{"target_id", filter.TargetID},
+	}
+	for _, condition := range conditions {
+		if condition.value != "" {
+			args = append(args, condition.value)
+			query += " AND " + condition.column + " = $" + strconv.Itoa(len(args))
+		}
+	}
+	if filter.After != "" {
+		var createdAt time.Time
+		err := store.DB.QueryRowContext(ctx, "SELECT created_at FROM audit_log WHERE id = $1 AND tenant_id = $2", filter.After, tenant.ID(ctx)).Scan(&createdAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, errors.New("invalid cursor")
+			}
+			return nil, err
+		}
+		args = append(args, createdAt, filter.After)
+		query += " AND (created_at, id) < ($" + strconv.Itoa(len(args)-1) + ", $" + strconv.Itoa(len(args)) + ")"
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := store.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*types.AuditEntry, 0)
+	for rows.Next() {
+		entry := &types.AuditEntry{}
+		if err := rows.Scan(&entry.ID, &entry.TenantID, &entry.Actor, &entry.Action, &entry.TargetID, &entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// insertAuditEntry записывает действие в журнал аудита в рамках транзакции tx
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, actor, action, targetID, reason string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO audit_log ("+auditColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		uuid.NewString(), tenant.ID(ctx), actor, action, targetID, reason, time.Now())
+	return err
+}
+
+// auditAction возвращает действие модератора, устанавливающее статус status
+func auditAction(status string) (string, error) {
+	switch status {
+	case types.CommentPublished:
+		return types.AuditApprove, nil
+	case types.CommentRejected:
+		return types.AuditReject, nil
+	}
+	return "", errors.New("invalid comment status")
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"strconv"
+	"time"
+)
+
+const notificationColumns = "id, user_id, type, actor_id, post_id, comment_id, created_at, read_at"
+
+func (store *DataStorePostgres) AddNotifications(ctx context.Context, notifications []*types.Notification) error {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddNotifications)
+	defer cancel()
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, n := range notifications {
+		if n.ID == "" {
+			n.ID = uuid.NewString()
+		}
+		if n.CreatedAt.IsZero() {
+			n.CreatedAt = time.Now()
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO notifications ("+notificationColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			n.ID, n.UserID, n.Type, nullable(n.ActorID), n.PostID, n.CommentID, n.CreatedAt, n.ReadAt,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (store *DataStorePostgres) GetNotifications(ctx context.Context, userID string, filter storage.NotificationFilter) ([]*types.Notification, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetNotifications)
+	defer cancel()
+
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = $1"
+	args := []interface{}{userID}
+	if filter.After != "" {
+		var createdAt time.Time
+		err := store.DB.QueryRowContext(ctx, "SELECT created_at FROM notifications WHERE id = $1 AND user_id = $2",
+			filter.After, userID).Scan(&createdAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, errors.New("invalid cursor")
+			}
+			return nil, err
+		}
+		args = append(args, createdAt, filter.After)
+		query += " AND (created_at, id) < ($2, $3)"
+	}
+	if filter.UnreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := store.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]*types.Notification, 0)
+	for rows.Next() {
+		n := &types.Notification{}
+		var actorID sql.NullString
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &actorID, &n.PostID, &n.CommentID, &n.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		n.ActorID = actorID.String
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (store *DataStorePostgres) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpMarkNotificationsRead)
+	defer cancel()
+
+	result, err := store.DB.ExecContext(ctx,
+		"UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND id = ANY($3) AND read_at IS NULL",
+		time.Now(), userID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(marked), nil
+}
+
+func (store *DataStorePostgres) GetUnreadNotificationCount(ctx context.Context, userID string) (int, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetUnreadNotificationCount)
+	defer cancel()
+
+	var count int
+	err := store.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
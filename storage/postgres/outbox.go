@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"graphql-comments/events"
+)
+
+// insertEvent записывает событие в таблицу outbox в рамках транзакции tx.
+// Событие будет опубликовано relay'ем только если транзакция будет зафиксирована.
+func insertEvent(ctx context.Context, tx *sql.Tx, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)",
+		event.ID, event.Type, payload, event.OccurredAt)
+	return err
+}
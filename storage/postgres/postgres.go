@@ -1,35 +1,43 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	_ "github.com/lib/pq"
+	"graphql-comments/events"
 	"graphql-comments/storage"
+	"graphql-comments/tenant"
 	"graphql-comments/types"
 	"time"
 )
 
 type DataStorePostgres struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts storage.Timeouts
 }
 
-func NewPostgresDataStore(dbURL string) (*DataStorePostgres, error) {
+func NewPostgresDataStore(ctx context.Context, dbURL string, timeouts storage.Timeouts) (*DataStorePostgres, error) {
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DataStorePostgres{DB: db}, nil
+	return &DataStorePostgres{DB: db, Timeouts: timeouts}, nil
 }
 
-func (store *DataStorePostgres) AddPost(title, content string, allowComments bool) (*types.Post, error) {
+func (store *DataStorePostgres) AddPost(ctx context.Context, authorID, title, content string, allowComments bool) (*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddPost)
+	defer cancel()
+
 	post := &types.Post{
-		ID:            storage.GenerateNewPostUUID(),
+		ID:            storage.GenerateNewPostUUID(ctx),
+		AuthorID:      authorID,
 		Title:         title,
 		Content:       content,
 		CreatedAt:     time.Now(),
@@ -37,160 +45,251 @@ func (store *DataStorePostgres) AddPost(title, content string, allowComments boo
 		AllowComments: allowComments,
 	}
 
-	_, err := store.DB.Exec("INSERT INTO posts (id, title, content, created_at) VALUES ($1, $2, $3, $4)",
-		post.ID, post.Title, post.Content, post.CreatedAt)
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO posts (id, tenant_id, author_id, title, content, created_at, allow_comments) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		post.ID, tenant.ID(ctx), nullable(post.AuthorID), post.Title, post.Content, post.CreatedAt, post.AllowComments)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := insertEvent(ctx, tx, events.NewPostCreated(ctx, post)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return post, nil
 }
 
-func (store *DataStorePostgres) AddComment(postID, parentCommentID, content string) (*types.Comment, error) {
-	post, err := store.GetPostByID(postID)
+func (store *DataStorePostgres) AddComment(ctx context.Context, authorID, postID, parentCommentID, content, status string) (*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddComment)
+	defer cancel()
+
+	post, err := store.GetPostByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
 	if !post.AllowComments {
-		return nil, errors.New("comments are not allowed for this post")
+		return nil, storage.ErrCommentsNotAllowed
 	}
 	comment := &types.Comment{
-		ID:              storage.GenerateNewCommentUUID(),
+		ID:              storage.GenerateNewCommentUUID(ctx),
 		PostID:          postID,
 		ParentCommentID: parentCommentID,
+		AuthorID:        authorID,
 		Content:         content,
 		CreatedAt:       time.Now(),
+		Status:          status,
+	}
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
 	if comment.ParentCommentID == "" {
-		if _, err := store.DB.Exec("INSERT INTO comments (id, post_id, parent_comment_id, content, created_at) VALUES ($1, $2, NULL, $3, $4)",
-			comment.ID, comment.PostID, comment.Content, comment.CreatedAt,
+		if _, err := tx.ExecContext(ctx, "INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, NULL, $4, $5, $6, $7)",
+			comment.ID, tenant.ID(ctx), comment.PostID, nullable(comment.AuthorID), comment.Content, comment.CreatedAt, comment.Status,
 		); err != nil {
 			return nil, err
 		}
 	} else {
-		if _, err := store.DB.Exec("INSERT INTO comments (id, post_id, parent_comment_id, content, created_at) VALUES ($1, $2, $3, $4, $5)",
-			comment.ID, comment.PostID, comment.ParentCommentID, comment.Content, comment.CreatedAt,
+		// Внешние ключи не учитывают арендатора, поэтому родитель проверяется явно
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2 AND post_id = $3)",
+			comment.ParentCommentID, tenant.ID(ctx), comment.PostID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, storage.ErrParentNotFound
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			comment.ID, tenant.ID(ctx), comment.PostID, comment.ParentCommentID, nullable(comment.AuthorID), comment.Content, comment.CreatedAt, comment.Status,
 		); err != nil {
 			return nil, err
 		}
 	}
 
-	return comment, nil
-}
+	// Событие о комментарии на модерации публикуется только после его одобрения
+	if comment.Status == types.CommentPublished {
+		if err := insertEvent(ctx, tx, events.NewCommentCreated(ctx, comment)); err != nil {
+			return nil, err
+		}
+	}
 
-func (store *DataStorePostgres) GetPosts() ([]*types.Post, error) {
-	rows, err := store.DB.Query("SELECT id, title, content, created_at, allow_comments FROM posts")
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return comment, nil
+}
+
+func (store *DataStorePostgres) GetPosts(ctx context.Context) ([]*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetPosts)
+	defer cancel()
 
 	posts := make([]*types.Post, 0)
-	for rows.Next() {
-		post := &types.Post{}
-		err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CreatedAt, &post.AllowComments)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id, author_id, title, content, created_at, allow_comments FROM posts WHERE tenant_id = $1 AND namespace IS NULL", tenant.ID(ctx))
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		commentRows, err := store.DB.Query("SELECT id FROM comments WHERE post_id = $1", post.ID)
-		if err != nil {
-			return nil, err
+		defer rows.Close()
+
+		for rows.Next() {
+			post := &types.Post{}
+			var authorID sql.NullString
+			err := rows.Scan(&post.ID, &authorID, &post.Title, &post.Content, &post.CreatedAt, &post.AllowComments)
+			if err != nil {
+				return err
+			}
+			post.AuthorID = authorID.String
+			posts = append(posts, post)
+		}
+		if err := rows.Err(); err != nil {
+			return err
 		}
+		// Соединение транзакции не выполняет новый запрос, пока не прочитан предыдущий
+		rows.Close()
 
-		for commentRows.Next() {
-			var commentID string
-			if err := commentRows.Scan(&commentID); err != nil {
-				return nil, err
+		for _, post := range posts {
+			if post.Comments, err = postComments(ctx, tx, post.ID); err != nil {
+				return err
 			}
-			post.Comments = append(post.Comments, commentID)
 		}
-
-		posts = append(posts, post)
-	}
-	if err = rows.Err(); err != nil {
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return posts, nil
 }
 
-func (store *DataStorePostgres) GetPostByID(id string) (*types.Post, error) {
+func (store *DataStorePostgres) GetPostByID(ctx context.Context, id string) (*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetPostByID)
+	defer cancel()
+
+	var post *types.Post
+	err := store.read(ctx, func(tx *sql.Tx) (err error) {
+		post, err = getPostByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func getPostByID(ctx context.Context, tx *sql.Tx, id string) (*types.Post, error) {
 	post := &types.Post{}
-	err := store.DB.QueryRow("SELECT id, title, content, created_at, allow_comments FROM posts WHERE id = $1", id).Scan(
+	var authorID, namespace, externalID sql.NullString
+	err := tx.QueryRowContext(ctx, "SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx)).Scan(
 		&post.ID,
+		&authorID,
 		&post.Title,
 		&post.Content,
 		&post.CreatedAt,
 		&post.AllowComments,
+		&namespace,
+		&externalID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("post not found")
+			return nil, storage.ErrPostNotFound
 		}
 		return nil, err
 	}
+	post.AuthorID = authorID.String
+	post.Namespace = namespace.String
+	post.ExternalID = externalID.String
 
-	commentRows, err := store.DB.Query("SELECT id FROM comments WHERE post_id = $1", post.ID)
-	if err != nil {
+	if post.Comments, err = postComments(ctx, tx, post.ID); err != nil {
 		return nil, err
 	}
-
-	for commentRows.Next() {
-		var commentID string
-		if err := commentRows.Scan(&commentID); err != nil {
-			return nil, err
-		}
-		post.Comments = append(post.Comments, commentID)
-	}
-
 	return post, nil
 }
 
-func (store *DataStorePostgres) GetComments(postID string, page int) ([]*types.Comment, error) {
-	rows, err := store.DB.Query("SELECT id, post_id, parent_comment_id, content, created_at FROM comments WHERE post_id = $1", postID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// postComments возвращает ID опубликованных комментариев верхнего уровня поста postID
+func postComments(ctx context.Context, tx *sql.Tx, postID string) ([]string, error) {
+	return queryIDs(ctx, tx, "SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'", postID, tenant.ID(ctx))
+}
+
+func (store *DataStorePostgres) GetComments(ctx context.Context, postID string, page int) ([]*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetComments)
+	defer cancel()
 
+	pageSize := storage.Limits(ctx).CommentsPageSize
 	comments := make([]*types.Comment, 0)
-	cnt := 0
-	for rows.Next() && cnt < storage.CommentsPageSize*(page-1) {
-		cnt++
-		if err := rows.Scan(); err != nil {
-			return nil, err
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id, post_id, parent_comment_id, author_id, content, created_at FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED' ORDER BY created_at, id", postID, tenant.ID(ctx))
+		if err != nil {
+			return err
 		}
-	}
+		defer rows.Close()
 
-	for rows.Next() && cnt < storage.CommentsPageSize*page {
-		comment := &types.Comment{}
-		err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentCommentID, &comment.Content, &comment.CreatedAt)
-		if err != nil {
-			return nil, err
+		cnt := 0
+		for cnt < pageSize*(page-1) && rows.Next() {
+			cnt++
 		}
-		comments = append(comments, comment)
-	}
-	if err = rows.Err(); err != nil {
+
+		for cnt < pageSize*page && rows.Next() {
+			cnt++
+			comment := &types.Comment{}
+			var parentCommentID, authorID sql.NullString
+			err := rows.Scan(&comment.ID, &comment.PostID, &parentCommentID, &authorID, &comment.Content, &comment.CreatedAt)
+			if err != nil {
+				return err
+			}
+			comment.ParentCommentID = parentCommentID.String
+			comment.AuthorID = authorID.String
+			comment.Status = types.CommentPublished
+			comments = append(comments, comment)
+		}
+		return rows.Err()
+	})
+	if err != nil {
 		return nil, err
 	}
 	return comments, nil
 }
 
-func (store *DataStorePostgres) GetCommentByID(id string) (*types.Comment, error) {
+func (store *DataStorePostgres) GetCommentByID(ctx context.Context, id string) (*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetCommentByID)
+	defer cancel()
+
+	var comment *types.Comment
+	err := store.read(ctx, func(tx *sql.Tx) (err error) {
+		comment, err = getCommentByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func getCommentByID(ctx context.Context, tx *sql.Tx, id string) (*types.Comment, error) {
 	comment := &types.Comment{}
-	var tmp sql.NullString
-	err := store.DB.QueryRow(
-		"SELECT id, post_id, parent_comment_id, content, created_at FROM comments WHERE id = $1", id).Scan(
+	var tmp, authorID sql.NullString
+	err := tx.QueryRowContext(ctx,
+		"SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx)).Scan(
 		&comment.ID,
 		&comment.PostID,
 		&tmp,
+		&authorID,
 		&comment.Content,
 		&comment.CreatedAt,
+		&comment.Status,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("comment not found")
+			return nil, storage.ErrCommentNotFound
 		}
 		return nil, err
 	}
@@ -198,54 +297,111 @@ func (store *DataStorePostgres) GetCommentByID(id string) (*types.Comment, error
 	if tmp.Valid {
 		comment.ParentCommentID = tmp.String
 	}
+	comment.AuthorID = authorID.String
 
-	repliesRows, err := store.DB.Query("SELECT id FROM comments WHERE parent_comment_id = $1", comment.ID)
+	comment.Replies, err = queryIDs(ctx, tx, "SELECT id FROM comments WHERE parent_comment_id = $1 AND tenant_id = $2 AND status = 'PUBLISHED'", comment.ID, tenant.ID(ctx))
 	if err != nil {
 		return nil, err
 	}
-
-	for repliesRows.Next() {
-		var replyID string
-		if err := repliesRows.Scan(&replyID); err != nil {
-			return nil, err
-		}
-		comment.Replies = append(comment.Replies, replyID)
-	}
-
 	return comment, nil
 }
 
-func (store *DataStorePostgres) GetNumberOfCommentPages(postID string) (int, error) {
+func (store *DataStorePostgres) GetNumberOfCommentPages(ctx context.Context, postID string) (int, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetNumberOfCommentPages)
+	defer cancel()
+
 	var count int
-	err := store.DB.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = $1", postID).Scan(&count)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'", postID, tenant.ID(ctx)).Scan(&count)
+	})
 	if err != nil {
 		return 0, err
 	}
-	return count/storage.CommentsPageSize + 1, nil
+	pageSize := storage.Limits(ctx).CommentsPageSize
+	return (count + pageSize - 1) / pageSize, nil
+}
+
+func (store *DataStorePostgres) GetReplies(ctx context.Context, commentID string) ([]*types.Comment, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetReplies)
+	defer cancel()
+
+	comments := make([]*types.Comment, 0)
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		replyIDs, err := queryIDs(ctx, tx, "SELECT id FROM comments WHERE parent_comment_id = $1 AND tenant_id = $2 AND status = 'PUBLISHED' ORDER BY created_at, id", commentID, tenant.ID(ctx))
+		if err != nil {
+			return err
+		}
+
+		for _, replyID := range replyIDs {
+			comment, err := getCommentByID(ctx, tx, replyID)
+			if err != nil {
+				return err
+			}
+			comments = append(comments, comment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
 }
 
-func (store *DataStorePostgres) GetReplies(commentID string) ([]*types.Comment, error) {
-	rows, err := store.DB.Query("SELECT id FROM comments WHERE parent_comment_id = $1", commentID)
+// queryIDs выполняет запрос, возвращающий столбец идентификаторов
+func queryIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	comments := make([]*types.Comment, 0)
+	var ids []string
 	for rows.Next() {
-		var replyID string
-		if err := rows.Scan(&replyID); err != nil {
+		var id string
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
 
-		comment, err := store.GetCommentByID(commentID)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, comment)
+// read выполняет запросы fn в транзакции арендатора запроса, чтобы политики
+// row-level security применялись и к чтению
+func (store *DataStorePostgres) read(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// beginTx начинает транзакцию от имени арендатора запроса, чтобы к ней применялись
+// политики row-level security
+func (store *DataStorePostgres) beginTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
 	}
-	if err = rows.Err(); err != nil {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant.ID(ctx)); err != nil {
+		tx.Rollback()
 		return nil, err
 	}
-	return comments, nil
+	return tx, nil
+}
+
+// nullable возвращает NULL для пустой строки
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
 }
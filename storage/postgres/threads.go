@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"graphql-comments/storage"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"time"
+)
+
+func (store *DataStorePostgres) GetThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetThread)
+	defer cancel()
+
+	var thread *types.Post
+	err := store.read(ctx, func(tx *sql.Tx) error {
+		var id string
+		err := tx.QueryRowContext(ctx, "SELECT id FROM posts WHERE tenant_id = $1 AND namespace = $2 AND external_id = $3",
+			tenant.ID(ctx), namespace, externalID).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrThreadNotFound
+			}
+			return err
+		}
+
+		thread, err = getPostByID(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+func (store *DataStorePostgres) AddThread(ctx context.Context, namespace, externalID string) (*types.Post, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddThread)
+	defer cancel()
+
+	tx, err := store.beginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Ветку могли создать параллельно; в этом случае используется существующая
+	_, err = tx.ExecContext(ctx, "INSERT INTO posts (id, tenant_id, title, content, created_at, allow_comments, namespace, external_id) VALUES ($1, $2, '', '', $3, TRUE, $4, $5) ON CONFLICT (tenant_id, namespace, external_id) DO NOTHING",
+		storage.GenerateNewPostUUID(ctx), tenant.ID(ctx), time.Now(), namespace, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return store.GetThread(ctx, namespace, externalID)
+}
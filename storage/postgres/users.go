@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"time"
+)
+
+func (store *DataStorePostgres) AddUser(ctx context.Context, name string) (*types.User, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpAddUser)
+	defer cancel()
+
+	user := &types.User{
+		ID:        storage.GenerateNewUserUUID(ctx),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := store.DB.ExecContext(ctx, "INSERT INTO users (id, name, created_at) VALUES ($1, $2, $3)",
+		user.ID, user.Name, user.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, errors.New("user name is already taken")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (store *DataStorePostgres) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetUserByID)
+	defer cancel()
+
+	return store.getUser(ctx, "SELECT id, name, created_at FROM users WHERE id = $1", id)
+}
+
+func (store *DataStorePostgres) GetUserByName(ctx context.Context, name string) (*types.User, error) {
+	ctx, cancel := store.Timeouts.Context(ctx, storage.OpGetUserByName)
+	defer cancel()
+
+	return store.getUser(ctx, "SELECT id, name, created_at FROM users WHERE LOWER(name) = LOWER($1)", name)
+}
+
+func (store *DataStorePostgres) getUser(ctx context.Context, query string, arg string) (*types.User, error) {
+	user := &types.User{}
+	err := store.DB.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Name, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
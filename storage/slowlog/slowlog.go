@@ -0,0 +1,198 @@
+package slowlog
+
+import (
+	"context"
+	"graphql-comments/logging"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"log/slog"
+	"time"
+)
+
+// DataStoreSlowLog оборачивает DataStore и логирует вызовы, выполнявшиеся дольше Threshold
+type DataStoreSlowLog struct {
+	Next      storage.DataStore
+	Threshold time.Duration
+}
+
+// NewSlowLogStore создает обертку над next с порогом threshold
+func NewSlowLogStore(next storage.DataStore, threshold time.Duration) *DataStoreSlowLog {
+	return &DataStoreSlowLog{Next: next, Threshold: threshold}
+}
+
+func (store *DataStoreSlowLog) observe(ctx context.Context, op string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	if elapsed < store.Threshold {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", op),
+		slog.Duration("duration", elapsed),
+		slog.Duration("threshold", store.Threshold),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "slow storage call", attrs...)
+}
+
+func (store *DataStoreSlowLog) AddPost(ctx context.Context, authorID, title, content string, allowComments bool) (post *types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddPost, start, err) }(time.Now())
+	return store.Next.AddPost(ctx, authorID, title, content, allowComments)
+}
+
+func (store *DataStoreSlowLog) AddComment(ctx context.Context, authorID, postID, parentCommentID, content, status string) (comment *types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddComment, start, err) }(time.Now())
+	return store.Next.AddComment(ctx, authorID, postID, parentCommentID, content, status)
+}
+
+func (store *DataStoreSlowLog) GetPosts(ctx context.Context) (posts []*types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetPosts, start, err) }(time.Now())
+	return store.Next.GetPosts(ctx)
+}
+
+func (store *DataStoreSlowLog) GetPostByID(ctx context.Context, id string) (post *types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetPostByID, start, err) }(time.Now())
+	return store.Next.GetPostByID(ctx, id)
+}
+
+func (store *DataStoreSlowLog) GetComments(ctx context.Context, postID string, page int) (comments []*types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetComments, start, err) }(time.Now())
+	return store.Next.GetComments(ctx, postID, page)
+}
+
+func (store *DataStoreSlowLog) GetCommentByID(ctx context.Context, id string) (comment *types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetCommentByID, start, err) }(time.Now())
+	return store.Next.GetCommentByID(ctx, id)
+}
+
+func (store *DataStoreSlowLog) GetNumberOfCommentPages(ctx context.Context, postID string) (pages int, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetNumberOfCommentPages, start, err) }(time.Now())
+	return store.Next.GetNumberOfCommentPages(ctx, postID)
+}
+
+func (store *DataStoreSlowLog) GetReplies(ctx context.Context, commentID string) (replies []*types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetReplies, start, err) }(time.Now())
+	return store.Next.GetReplies(ctx, commentID)
+}
+
+func (store *DataStoreSlowLog) AddUser(ctx context.Context, name string) (user *types.User, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddUser, start, err) }(time.Now())
+	return store.Next.AddUser(ctx, name)
+}
+
+func (store *DataStoreSlowLog) GetUserByID(ctx context.Context, id string) (user *types.User, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetUserByID, start, err) }(time.Now())
+	return store.Next.GetUserByID(ctx, id)
+}
+
+func (store *DataStoreSlowLog) GetUserByName(ctx context.Context, name string) (user *types.User, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetUserByName, start, err) }(time.Now())
+	return store.Next.GetUserByName(ctx, name)
+}
+
+func (store *DataStoreSlowLog) AddNotifications(ctx context.Context, notifications []*types.Notification) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddNotifications, start, err) }(time.Now())
+	return store.Next.AddNotifications(ctx, notifications)
+}
+
+func (store *DataStoreSlowLog) GetNotifications(ctx context.Context, userID string, filter storage.NotificationFilter) (notifications []*types.Notification, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetNotifications, start, err) }(time.Now())
+	return store.Next.GetNotifications(ctx, userID, filter)
+}
+
+func (store *DataStoreSlowLog) MarkNotificationsRead(ctx context.Context, userID string, ids []string) (marked int, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpMarkNotificationsRead, start, err) }(time.Now())
+	return store.Next.MarkNotificationsRead(ctx, userID, ids)
+}
+
+func (store *DataStoreSlowLog) GetUnreadNotificationCount(ctx context.Context, userID string) (count int, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetUnreadNotificationCount, start, err) }(time.Now())
+	return store.Next.GetUnreadNotificationCount(ctx, userID)
+}
+
+func (store *DataStoreSlowLog) AddMentions(ctx context.Context, commentID string, userIDs []string) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddMentions, start, err) }(time.Now())
+	return store.Next.AddMentions(ctx, commentID, userIDs)
+}
+
+func (store *DataStoreSlowLog) GetMentionedUsers(ctx context.Context, commentID string) (users []*types.User, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetMentionedUsers, start, err) }(time.Now())
+	return store.Next.GetMentionedUsers(ctx, commentID)
+}
+
+func (store *DataStoreSlowLog) GetCommentsMentioning(ctx context.Context, userID string, limit int) (comments []*types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetCommentsMentioning, start, err) }(time.Now())
+	return store.Next.GetCommentsMentioning(ctx, userID, limit)
+}
+
+func (store *DataStoreSlowLog) ModerateComment(ctx context.Context, actor, commentID, status, reason string) (comment *types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpModerateComment, start, err) }(time.Now())
+	return store.Next.ModerateComment(ctx, actor, commentID, status, reason)
+}
+
+func (store *DataStoreSlowLog) ReportComment(ctx context.Context, report *types.Report) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpReportComment, start, err) }(time.Now())
+	return store.Next.ReportComment(ctx, report)
+}
+
+func (store *DataStoreSlowLog) GetModerationQueue(ctx context.Context, filter storage.ModerationFilter) (items []*types.ModerationItem, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetModerationQueue, start, err) }(time.Now())
+	return store.Next.GetModerationQueue(ctx, filter)
+}
+
+func (store *DataStoreSlowLog) GetAuditLog(ctx context.Context, filter storage.AuditFilter) (entries []*types.AuditEntry, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetAuditLog, start, err) }(time.Now())
+	return store.Next.GetAuditLog(ctx, filter)
+}
+
+func (store *DataStoreSlowLog) GetThread(ctx context.Context, namespace, externalID string) (thread *types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetThread, start, err) }(time.Now())
+	return store.Next.GetThread(ctx, namespace, externalID)
+}
+
+func (store *DataStoreSlowLog) AddThread(ctx context.Context, namespace, externalID string) (thread *types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpAddThread, start, err) }(time.Now())
+	return store.Next.AddThread(ctx, namespace, externalID)
+}
+
+func (store *DataStoreSlowLog) SetAllowComments(ctx context.Context, actor, postID string, allow bool) (post *types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpSetAllowComments, start, err) }(time.Now())
+	return store.Next.SetAllowComments(ctx, actor, postID, allow)
+}
+
+func (store *DataStoreSlowLog) DeleteComment(ctx context.Context, actor, commentID, reason string) (deleted int, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpDeleteComment, start, err) }(time.Now())
+	return store.Next.DeleteComment(ctx, actor, commentID, reason)
+}
+
+func (store *DataStoreSlowLog) ListComments(ctx context.Context, filter storage.CommentFilter) (comments []*types.Comment, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpListComments, start, err) }(time.Now())
+	return store.Next.ListComments(ctx, filter)
+}
+
+func (store *DataStoreSlowLog) GetStats(ctx context.Context) (stats *types.Stats, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpGetStats, start, err) }(time.Now())
+	return store.Next.GetStats(ctx)
+}
+
+func (store *DataStoreSlowLog) ListPosts(ctx context.Context, filter storage.PostFilter) (posts []*types.Post, err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpListPosts, start, err) }(time.Now())
+	return store.Next.ListPosts(ctx, filter)
+}
+
+func (store *DataStoreSlowLog) ImportUser(ctx context.Context, user *types.User) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpImportUser, start, err) }(time.Now())
+	return store.Next.ImportUser(ctx, user)
+}
+
+func (store *DataStoreSlowLog) ImportPost(ctx context.Context, post *types.Post) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpImportPost, start, err) }(time.Now())
+	return store.Next.ImportPost(ctx, post)
+}
+
+func (store *DataStoreSlowLog) ImportComment(ctx context.Context, comment *types.Comment) (err error) {
+	defer func(start time.Time) { store.observe(ctx, storage.OpImportComment, start, err) }(time.Now())
+	return store.Next.ImportComment(ctx, comment)
+}
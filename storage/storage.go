@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"github.com/google/uuid"
+	"graphql-comments/tenant"
 	"graphql-comments/types"
 )
 
@@ -10,34 +13,174 @@ const (
 	MaxPostTitleLength   = 100
 	MaxPostContentLength = 10000
 	CommentsPageSize     = 10
+	MaxUserNameLength    = 32
+	MaxNotificationsPage = 50
+	// MaxMentionsPerComment ограничивает число пользователей, упоминаемых в одном комментарии
+	MaxMentionsPerComment = 10
+	MaxMentioningComments = 50
+	MaxModerationPage     = 50
+	MaxAuditLogPage       = 100
+	MaxReportReasonLength = 500
+	MaxNamespaceLength    = 64
+	MaxExternalIDLength   = 255
+	// MaxIDLength ограничивает длину ID постов и комментариев (VARCHAR(128) в схеме)
+	MaxIDLength = 128
 )
 
+var (
+	// ErrThreadNotFound возвращается, если у внешнего ресурса еще нет ветки комментариев
+	ErrThreadNotFound = errors.New("thread not found")
+	// ErrCommentsNotAllowed возвращается при попытке прокомментировать пост с закрытыми комментариями
+	ErrCommentsNotAllowed = errors.New("comments are not allowed for this post")
+	// ErrPostNotFound возвращается, если пост арендатора запроса не найден
+	ErrPostNotFound = errors.New("post not found")
+	// ErrCommentNotFound возвращается, если комментарий арендатора запроса не найден
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrParentNotFound возвращается при добавлении ответа на несуществующий комментарий
+	ErrParentNotFound = errors.New("parent comment not found")
+	// ErrAlreadyExists возвращается при импорте записи, ID (или имя пользователя, или внешний
+	// ресурс ветки) которой уже занят
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// Limits возвращает ограничения арендатора запроса с учетом значений по умолчанию
+func Limits(ctx context.Context) tenant.Limits {
+	limits := tenant.FromContext(ctx).Limits
+	if limits.MaxCommentLength <= 0 {
+		limits.MaxCommentLength = MaxCommentLength
+	}
+	if limits.MaxPostTitleLength <= 0 {
+		limits.MaxPostTitleLength = MaxPostTitleLength
+	}
+	if limits.MaxPostContentLength <= 0 {
+		limits.MaxPostContentLength = MaxPostContentLength
+	}
+	if limits.CommentsPageSize <= 0 {
+		limits.CommentsPageSize = CommentsPageSize
+	}
+	return limits
+}
+
+// NotificationFilter задает выборку уведомлений пользователя. Уведомления возвращаются
+// от новых к старым, начиная со следующего за уведомлением After.
+type NotificationFilter struct {
+	After      string
+	Limit      int
+	UnreadOnly bool
+}
+
+// ModerationFilter задает выборку очереди модерации Queue (types.QueuePending или
+// types.QueueFlagged). Комментарии возвращаются от старых к новым, начиная со следующего
+// за комментарием After.
+type ModerationFilter struct {
+	Queue string
+	After string
+	Limit int
+}
+
+// AuditFilter задает выборку журнала аудита; пустые поля не ограничивают выборку.
+// Записи возвращаются от новых к старым, начиная со следующей за записью After.
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	TargetID string
+	After    string
+	Limit    int
+}
+
+// PostFilter задает выборку постов для администрирования: в нее входят ветки комментариев
+// внешних ресурсов. Посты возвращаются от старых к новым, начиная со следующего за постом After.
+type PostFilter struct {
+	After string
+	Limit int
+}
+
+// CommentFilter задает выборку комментариев для администрирования: в нее входят ответы и
+// комментарии любого статуса. Пустой PostID не ограничивает выборку постом.
+// Комментарии возвращаются от старых к новым, начиная со следующего за комментарием After.
+type CommentFilter struct {
+	PostID string
+	After  string
+	Limit  int
+}
+
 type DataStore interface {
-	AddPost(title, content string, allowComments bool) (*types.Post, error)
-	AddComment(postID, parentCommentID, content string) (*types.Comment, error)
-	GetPosts() ([]*types.Post, error)
-	GetPostByID(id string) (*types.Post, error)
-	GetComments(postID string, page int) ([]*types.Comment, error)
-	GetCommentByID(id string) (*types.Comment, error)
-	GetNumberOfCommentPages(postID string) (int, error)
-	GetReplies(commentID string) ([]*types.Comment, error)
+	AddPost(ctx context.Context, authorID, title, content string, allowComments bool) (*types.Post, error)
+	AddComment(ctx context.Context, authorID, postID, parentCommentID, content, status string) (*types.Comment, error)
+	GetPosts(ctx context.Context) ([]*types.Post, error)
+	GetPostByID(ctx context.Context, id string) (*types.Post, error)
+	GetComments(ctx context.Context, postID string, page int) ([]*types.Comment, error)
+	GetCommentByID(ctx context.Context, id string) (*types.Comment, error)
+	GetNumberOfCommentPages(ctx context.Context, postID string) (int, error)
+	GetReplies(ctx context.Context, commentID string) ([]*types.Comment, error)
+	AddUser(ctx context.Context, name string) (*types.User, error)
+	GetUserByID(ctx context.Context, id string) (*types.User, error)
+	GetUserByName(ctx context.Context, name string) (*types.User, error)
+	AddNotifications(ctx context.Context, notifications []*types.Notification) error
+	GetNotifications(ctx context.Context, userID string, filter NotificationFilter) ([]*types.Notification, error)
+	MarkNotificationsRead(ctx context.Context, userID string, ids []string) (int, error)
+	GetUnreadNotificationCount(ctx context.Context, userID string) (int, error)
+	AddMentions(ctx context.Context, commentID string, userIDs []string) error
+	GetMentionedUsers(ctx context.Context, commentID string) ([]*types.User, error)
+	GetCommentsMentioning(ctx context.Context, userID string, limit int) ([]*types.Comment, error)
+	// ModerateComment устанавливает статус комментария, закрывает жалобы на него и
+	// записывает решение модератора actor в журнал аудита
+	ModerateComment(ctx context.Context, actor, commentID, status, reason string) (*types.Comment, error)
+	// ReportComment сохраняет жалобу и записывает ее в журнал аудита. Повторная жалоба
+	// пользователя на комментарий учитывается, только если предыдущая уже рассмотрена.
+	// Возвращает ErrCommentNotFound, если комментарий арендатора запроса не найден.
+	ReportComment(ctx context.Context, report *types.Report) error
+	GetModerationQueue(ctx context.Context, filter ModerationFilter) ([]*types.ModerationItem, error)
+	GetAuditLog(ctx context.Context, filter AuditFilter) ([]*types.AuditEntry, error)
+	// GetThread возвращает ветку комментариев внешнего ресурса externalID из пространства
+	// имен namespace или ErrThreadNotFound
+	GetThread(ctx context.Context, namespace, externalID string) (*types.Post, error)
+	// AddThread возвращает ветку комментариев внешнего ресурса, создавая ее при первом обращении
+	AddThread(ctx context.Context, namespace, externalID string) (*types.Post, error)
+	// SetAllowComments открывает или закрывает комментарии к посту и записывает действие
+	// actor в журнал аудита
+	SetAllowComments(ctx context.Context, actor, postID string, allow bool) (*types.Post, error)
+	// DeleteComment удаляет комментарий вместе с ответами, упоминаниями, жалобами и
+	// уведомлениями о них и записывает действие actor в журнал аудита. Возвращает число
+	// удаленных комментариев.
+	DeleteComment(ctx context.Context, actor, commentID, reason string) (int, error)
+	ListComments(ctx context.Context, filter CommentFilter) ([]*types.Comment, error)
+	GetStats(ctx context.Context) (*types.Stats, error)
+	// ListPosts возвращает посты без списков комментариев
+	ListPosts(ctx context.Context, filter PostFilter) ([]*types.Post, error)
+	// ImportUser, ImportPost и ImportComment сохраняют записи с заданными ID и временем
+	// создания без проверок и доменных событий. Занятый ID возвращает ErrAlreadyExists.
+	ImportUser(ctx context.Context, user *types.User) error
+	ImportPost(ctx context.Context, post *types.Post) error
+	// ImportComment возвращает ErrPostNotFound или ErrParentNotFound, если пост или
+	// родительский комментарий еще не импортирован. Закрытые комментарии не учитываются.
+	ImportComment(ctx context.Context, comment *types.Comment) error
 }
 
 var DataBase DataStore
 
-func GenerateNewPostUUID() string {
+func GenerateNewPostUUID(ctx context.Context) string {
+	for {
+		newUUID := uuid.New()
+		if _, err := DataBase.GetPostByID(ctx, newUUID.String()); err != nil {
+			return newUUID.String()
+		}
+	}
+}
+
+func GenerateNewCommentUUID(ctx context.Context) string {
 	for {
 		newUUID := uuid.New()
-		if _, err := DataBase.GetPostByID(newUUID.String()); err != nil {
+		if _, err := DataBase.GetCommentByID(ctx, newUUID.String()); err != nil {
 			return newUUID.String()
 		}
 	}
 }
 
-func GenerateNewCommentUUID() string {
+func GenerateNewUserUUID(ctx context.Context) string {
 	for {
 		newUUID := uuid.New()
-		if _, err := DataBase.GetCommentByID(newUUID.String()); err != nil {
+		if _, err := DataBase.GetUserByID(ctx, newUUID.String()); err != nil {
 			return newUUID.String()
 		}
 	}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Имена операций хранилища, используемые для настройки таймаутов и логирования
+const (
+	OpAddPost                    = "AddPost"
+	OpAddComment                 = "AddComment"
+	OpGetPosts                   = "GetPosts"
+	OpGetPostByID                = "GetPostByID"
+	OpGetComments                = "GetComments"
+	OpGetCommentByID             = "GetCommentByID"
+	OpGetNumberOfCommentPages    = "GetNumberOfCommentPages"
+	OpGetReplies                 = "GetReplies"
+	OpAddUser                    = "AddUser"
+	OpGetUserByID                = "GetUserByID"
+	OpGetUserByName              = "GetUserByName"
+	OpAddNotifications           = "AddNotifications"
+	OpGetNotifications           = "GetNotifications"
+	OpMarkNotificationsRead      = "MarkNotificationsRead"
+	OpGetUnreadNotificationCount = "GetUnreadNotificationCount"
+	OpAddMentions                = "AddMentions"
+	OpGetMentionedUsers          = "GetMentionedUsers"
+	OpGetCommentsMentioning      = "GetCommentsMentioning"
+	OpModerateComment            = "ModerateComment"
+	OpReportComment              = "ReportComment"
+	OpGetModerationQueue         = "GetModerationQueue"
+	OpGetAuditLog                = "GetAuditLog"
+	OpGetThread                  = "GetThread"
+	OpAddThread                  = "AddThread"
+	OpSetAllowComments           = "SetAllowComments"
+	OpDeleteComment              = "DeleteComment"
+	OpListComments               = "ListComments"
+	OpGetStats                   = "GetStats"
+	OpListPosts                  = "ListPosts"
+	OpImportUser                 = "ImportUser"
+	OpImportPost                 = "ImportPost"
+	OpImportComment              = "ImportComment"
+)
+
+// Operations перечисляет все операции интерфейса DataStore
+var Operations = []string{
+	OpAddPost,
+	OpAddComment,
+	OpGetPosts,
+	OpGetPostByID,
+	OpGetComments,
+	OpGetCommentByID,
+	OpGetNumberOfCommentPages,
+	OpGetReplies,
+	OpAddUser,
+	OpGetUserByID,
+	OpGetUserByName,
+	OpAddNotifications,
+	OpGetNotifications,
+	OpMarkNotificationsRead,
+	OpGetUnreadNotificationCount,
+	OpAddMentions,
+	OpGetMentionedUsers,
+	OpGetCommentsMentioning,
+	OpModerateComment,
+	OpReportComment,
+	OpGetModerationQueue,
+	OpGetAuditLog,
+	OpGetThread,
+	OpAddThread,
+	OpSetAllowComments,
+	OpDeleteComment,
+	OpListComments,
+	OpGetStats,
+	OpListPosts,
+	OpImportUser,
+	OpImportPost,
+	OpImportComment,
+}
+
+// Timeouts задает ограничения по времени для операций хранилища.
+// Нулевое значение означает отсутствие ограничения.
+type Timeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+// For возвращает таймаут для операции op
+func (t Timeouts) For(op string) time.Duration {
+	if timeout, ok := t.Operations[op]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// Context возвращает контекст, ограниченный таймаутом операции op
+func (t Timeouts) Context(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	timeout := t.For(op)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
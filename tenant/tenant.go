@@ -0,0 +1,200 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultID идентификатор арендатора, к которому относятся запросы без явного арендатора
+const DefaultID = "default"
+
+// Заголовки, по которым определяется арендатор
+const (
+	APIKeyHeader = "X-API-Key"
+	IDHeader     = "X-Tenant-ID"
+)
+
+// Limits ограничения арендатора; нулевые значения означают ограничения по умолчанию
+type Limits struct {
+	MaxCommentLength     int `json:"maxCommentLength"`
+	MaxPostTitleLength   int `json:"maxPostTitleLength"`
+	MaxPostContentLength int `json:"maxPostContentLength"`
+	CommentsPageSize     int `json:"commentsPageSize"`
+}
+
+// Tenant арендатор — отдельное пространство постов и комментариев
+type Tenant struct {
+	ID      string   `json:"id"`
+	APIKeys []string `json:"apiKeys"`
+	Hosts   []string `json:"hosts"`
+	Limits  Limits   `json:"limits"`
+}
+
+var defaultTenant = &Tenant{ID: DefaultID}
+
+type ctxKey struct{}
+
+// WithTenant возвращает контекст запроса арендатора t
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext возвращает арендатора запроса или арендатора по умолчанию
+func FromContext(ctx context.Context) *Tenant {
+	if t, ok := ctx.Value(ctxKey{}).(*Tenant); ok {
+		return t
+	}
+	return defaultTenant
+}
+
+// ID возвращает идентификатор арендатора запроса
+func ID(ctx context.Context) string {
+	return FromContext(ctx).ID
+}
+
+// ErrUnknownTenant возвращается, если запрос указывает на неизвестного арендатора
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Registry определяет арендатора запроса по API-ключу или имени хоста
+type Registry struct {
+	tenants map[string]*Tenant
+	keys    map[string]*Tenant
+	hosts   map[string]*Tenant
+	// fallback арендатор запросов, не указывающих арендатора; nil запрещает такие запросы
+	fallback *Tenant
+}
+
+// NewRegistry создает реестр арендаторов. Запросы без арендатора относятся к арендатору
+// fallbackID; пустой fallbackID запрещает такие запросы. Арендатор DefaultID существует
+// всегда, даже если не описан в tenants.
+func NewRegistry(tenants []*Tenant, fallbackID string) (*Registry, error) {
+	r := &Registry{
+		tenants: map[string]*Tenant{DefaultID: defaultTenant},
+		keys:    make(map[string]*Tenant),
+		hosts:   make(map[string]*Tenant),
+	}
+	for _, t := range tenants {
+		if t.ID == "" {
+			return nil, errors.New("tenant id is empty")
+		}
+		if existing, ok := r.tenants[t.ID]; ok && existing != defaultTenant {
+			return nil, fmt.Errorf("duplicate tenant %q", t.ID)
+		}
+		r.tenants[t.ID] = t
+		for _, key := range t.APIKeys {
+			if _, ok := r.keys[key]; ok {
+				return nil, fmt.Errorf("duplicate API key for tenant %q", t.ID)
+			}
+			r.keys[key] = t
+		}
+		for _, host := range t.Hosts {
+			host = strings.ToLower(host)
+			if _, ok := r.hosts[host]; ok {
+				return nil, fmt.Errorf("duplicate host %q", host)
+			}
+			r.hosts[host] = t
+		}
+	}
+	if fallbackID != "" {
+		fallback, ok := r.tenants[fallbackID]
+		if !ok {
+			return nil, fmt.Errorf("unknown default tenant %q", fallbackID)
+		}
+		r.fallback = fallback
+	}
+	return r, nil
+}
+
+// ByID возвращает арендатора id без проверки API-ключа, а для пустого id — арендатора
+// запросов без арендатора. Используется инструментами оператора, которым доступны все арендаторы.
+func (r *Registry) ByID(id string) (*Tenant, error) {
+	if id == "" && r.fallback != nil {
+		return r.fallback, nil
+	}
+	if t, ok := r.tenants[id]; ok {
+		return t, nil
+	}
+	return nil, ErrUnknownTenant
+}
+
+// Tenants возвращает всех арендаторов реестра
+func (r *Registry) Tenants() []*Tenant {
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+// Load считывает описание арендаторов из JSON-файла path
+func Load(path string) ([]*Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []*Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("invalid tenants file: %w", err)
+	}
+	return tenants, nil
+}
+
+// Resolve определяет арендатора HTTP-запроса по API-ключу или, без него, по имени хоста
+func (r *Registry) Resolve(req *http.Request) (*Tenant, error) {
+	return r.Lookup(req.Header.Get(APIKeyHeader), req.Header.Get(IDHeader), req.Host)
+}
+
+// Lookup определяет арендатора по API-ключу apiKey или, без него, по имени хоста host
+// (возможно, с портом). Идентификатор id не дает доступа к арендатору: он лишь уточняет
+// запрос и должен совпадать с арендатором API-ключа. Пустые значения не учитываются.
+func (r *Registry) Lookup(apiKey, id, host string) (*Tenant, error) {
+	if apiKey != "" {
+		t, ok := r.keys[apiKey]
+		if !ok || (id != "" && id != t.ID) {
+			return nil, ErrUnknownTenant
+		}
+		return t, nil
+	}
+	if id != "" {
+		return nil, ErrUnknownTenant
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if t, ok := r.hosts[strings.ToLower(host)]; ok {
+		return t, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, ErrUnknownTenant
+}
+
+// Middleware добавляет в контекст запроса арендатора, определенного реестром r
+func Middleware(r *Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			t, err := r.Resolve(req)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"data": nil,
+					"errors": []map[string]interface{}{{
+						"message":    err.Error(),
+						"extensions": map[string]interface{}{"code": "UNKNOWN_TENANT"},
+					}},
+				})
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(WithTenant(req.Context(), t)))
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package bench_test
+
+import (
+	"context"
+	"fmt"
+	"graphql-comments/auth"
+	gql "graphql-comments/graphql"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// Бенчмарки запускаются командой
+//
+//	go test ./tests/bench -run '^$' -bench . -benchtime 1000x
+//
+// Хранилище PostgreSQL измеряется, если переменная BENCH_POSTGRES_DSN содержит строку
+// подключения к базе с примененной схемой init.sql. Данные каждого набора создаются в
+// отдельном арендаторе. С флагом -short измеряются наборы не больше 1000 комментариев.
+
+// sizes число комментариев поста в наборах данных
+var sizes = []int{10, 1_000, 100_000, 1_000_000}
+
+const (
+	// shapeWide половина комментариев находится на верхнем уровне, остальные отвечают на первый
+	shapeWide = "wide"
+	// shapeDeep каждый комментарий отвечает на предыдущий
+	shapeDeep = "deep"
+
+	benchUsers    = 100
+	benchContent  = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris."
+	benchPageSize = 50
+)
+
+type backend struct {
+	name string
+	open func(b *testing.B) storage.DataStore
+}
+
+var (
+	postgresOnce  sync.Once
+	postgresStore *postgres.DataStorePostgres
+	postgresErr   error
+)
+
+// backends возвращает измеряемые хранилища
+func backends() []backend {
+	list := []backend{{"InMemory", func(b *testing.B) storage.DataStore { return inMemory.NewInMemoryStore() }}}
+	dsn := os.Getenv("BENCH_POSTGRES_DSN")
+	if dsn == "" {
+		return list
+	}
+	return append(list, backend{"Postgres", func(b *testing.B) storage.DataStore {
+		postgresOnce.Do(func() {
+			postgresStore, postgresErr = postgres.NewPostgresDataStore(context.Background(), dsn, storage.Timeouts{})
+		})
+		if postgresErr != nil {
+			b.Fatalf("Unexpected error: %v", postgresErr)
+		}
+		return postgresStore
+	}})
+}
+
+// fixture набор данных: пост post с деревом комментариев заданной формы и размера, пост
+// scratch для мутаций, пользователи, уведомления, упоминания и жалобы
+type fixture struct {
+	ctx     context.Context
+	store   storage.DataStore
+	prefix  string
+	users   []*types.User
+	post    *types.Post
+	scratch *types.Post
+	// root первый комментарий верхнего уровня, leaf последний комментарий, middle
+	// комментарий из середины порядка создания
+	root, leaf, middle string
+	// scratchRoot комментарий поста scratch, на который отвечают мутации
+	scratchRoot   string
+	pages         int
+	pending       []string
+	notifications []string
+	created       time.Time
+	// serial делает уникальными имена, созданные повторными запусками бенчмарка
+	serial int
+}
+
+// newFixture создает набор данных в отдельном арендаторе хранилища store
+func newFixture(b *testing.B, store storage.DataStore, shape string, size int) *fixture {
+	b.Helper()
+	storage.DataBase = store
+	prefix := "bench" + strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+	f := &fixture{
+		ctx:     tenant.WithTenant(context.Background(), &tenant.Tenant{ID: prefix}),
+		store:   store,
+		prefix:  prefix,
+		created: time.Now().Add(-time.Hour),
+	}
+	check := func(err error) {
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	for i := 0; i < benchUsers; i++ {
+		user := &types.User{ID: uuid.NewString(), Name: fmt.Sprintf("%s_%d", prefix, i), CreatedAt: f.created}
+		check(store.ImportUser(f.ctx, user))
+		f.users = append(f.users, user)
+	}
+	f.post = f.importPost(b, "Post")
+	f.scratch = f.importPost(b, "Scratch")
+	f.scratchRoot = f.importComment(b, f.scratch.ID, "", types.CommentPublished, 0)
+
+	var notifications []*types.Notification
+	previous := ""
+	for i := 0; i < size; i++ {
+		parentID := ""
+		switch {
+		case shape == shapeDeep:
+			parentID = previous
+		case i%2 == 1:
+			parentID = f.root
+		}
+		id := f.importComment(b, f.post.ID, parentID, types.CommentPublished, i)
+		if i == 0 {
+			f.root = id
+		}
+		if i == size/2 {
+			f.middle = id
+		}
+		previous = id
+
+		notifications = append(notifications, &types.Notification{
+			ID:        uuid.NewString(),
+			UserID:    f.users[i%benchUsers].ID,
+			Type:      types.NotificationReply,
+			ActorID:   f.users[(i+1)%benchUsers].ID,
+			PostID:    f.post.ID,
+			CommentID: id,
+			CreatedAt: f.created.Add(time.Duration(i) * time.Millisecond),
+		})
+		if len(notifications) == 1000 || i == size-1 {
+			check(store.AddNotifications(f.ctx, notifications))
+			for _, notification := range notifications {
+				f.notifications = append(f.notifications, notification.ID)
+			}
+			notifications = nil
+		}
+		if (size-1-i)%10 == 0 {
+			check(store.AddMentions(f.ctx, id, []string{f.users[0].ID}))
+		}
+		// На каждые 100 опубликованных комментариев приходится комментарий на модерации с жалобой
+		if i%100 == 0 {
+			pending := f.importComment(b, f.post.ID, "", types.CommentPending, i)
+			check(store.ReportComment(f.ctx, &types.Report{CommentID: pending, UserID: f.users[0].ID, Reason: "spam", CreatedAt: f.created}))
+			f.pending = append(f.pending, pending)
+		}
+	}
+	f.leaf = previous
+
+	_, err := store.AddThread(f.ctx, "bench", "page")
+	check(err)
+	f.pages, err = store.GetNumberOfCommentPages(f.ctx, f.post.ID)
+	check(err)
+	return f
+}
+
+// name возвращает новое имя пользователя набора
+func (f *fixture) name() string {
+	f.serial++
+	return fmt.Sprintf("%s_n%d", f.prefix, f.serial)
+}
+
+func (f *fixture) importPost(b *testing.B, title string) *types.Post {
+	b.Helper()
+	post := &types.Post{ID: uuid.NewString(), AuthorID: f.users[0].ID, Title: title, Content: benchContent, CreatedAt: f.created, Comments: []string{}, AllowComments: true}
+	if err := f.store.ImportPost(f.ctx, post); err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	return post
+}
+
+func (f *fixture) importComment(b *testing.B, postID, parentID, status string, i int) string {
+	b.Helper()
+	comment := &types.Comment{
+		ID:              uuid.NewString(),
+		PostID:          postID,
+		ParentCommentID: parentID,
+		AuthorID:        f.users[i%benchUsers].ID,
+		Content:         benchContent,
+		CreatedAt:       f.created.Add(time.Duration(i) * time.Millisecond),
+		Status:          status,
+	}
+	if err := f.store.ImportComment(f.ctx, comment); err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	return comment.ID
+}
+
+// datasets вызывает run для каждого хранилища, формы и размера дерева комментариев
+func datasets(b *testing.B, run func(b *testing.B, f *fixture)) {
+	for _, backend := range backends() {
+		b.Run(backend.name, func(b *testing.B) {
+			for _, shape := range []string{shapeWide, shapeDeep} {
+				for _, size := range sizes {
+					if testing.Short() && size > 1_000 {
+						continue
+					}
+					b.Run(shape+"/"+strconv.Itoa(size), func(b *testing.B) {
+						run(b, newFixture(b, backend.open(b), shape, size))
+					})
+				}
+			}
+		})
+	}
+}
+
+// loop выполняет op b.N раз и останавливает измерение при первой ошибке
+func loop(b *testing.B, op func(i int) error) {
+	b.Helper()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := op(i); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+// ignore отбрасывает результат метода хранилища
+func ignore[T any](_ T, err error) error {
+	return err
+}
+
+func BenchmarkDataStore(b *testing.B) {
+	datasets(b, func(b *testing.B, f *fixture) {
+		ctx, store := f.ctx, f.store
+		actor := f.users[0].ID
+
+		// Чтение выполняется до мутаций, чтобы измерять исходный набор данных
+		reads := []struct {
+			name string
+			op   func(i int) error
+		}{
+			{"GetPosts", func(int) error { return ignore(store.GetPosts(ctx)) }},
+			{"GetPostByID", func(int) error { return ignore(store.GetPostByID(ctx, f.post.ID)) }},
+			{"GetComments/First", func(int) error { return ignore(store.GetComments(ctx, f.post.ID, 1)) }},
+			{"GetComments/Last", func(int) error { return ignore(store.GetComments(ctx, f.post.ID, max(f.pages, 1))) }},
+			{"GetCommentByID", func(int) error { return ignore(store.GetCommentByID(ctx, f.leaf)) }},
+			{"GetNumberOfCommentPages", func(int) error { return ignore(store.GetNumberOfCommentPages(ctx, f.post.ID)) }},
+			{"GetReplies", func(int) error { return ignore(store.GetReplies(ctx, f.root)) }},
+			{"GetUserByID", func(i int) error { return ignore(store.GetUserByID(ctx, f.users[i%benchUsers].ID)) }},
+			{"GetUserByName", func(i int) error { return ignore(store.GetUserByName(ctx, f.users[i%benchUsers].Name)) }},
+			{"GetNotifications", func(i int) error {
+				return ignore(store.GetNotifications(ctx, f.users[i%benchUsers].ID, storage.NotificationFilter{Limit: benchPageSize}))
+			}},
+			{"GetUnreadNotificationCount", func(i int) error { return ignore(store.GetUnreadNotificationCount(ctx, f.users[i%benchUsers].ID)) }},
+			{"GetMentionedUsers", func(int) error { return ignore(store.GetMentionedUsers(ctx, f.leaf)) }},
+			{"GetCommentsMentioning", func(int) error { return ignore(store.GetCommentsMentioning(ctx, actor, benchPageSize)) }},
+			{"GetModerationQueue/Pending", func(int) error {
+				return ignore(store.GetModerationQueue(ctx, storage.ModerationFilter{Queue: types.QueuePending, Limit: benchPageSize}))
+			}},
+			{"GetModerationQueue/Flagged", func(int) error {
+				return ignore(store.GetModerationQueue(ctx, storage.ModerationFilter{Queue: types.QueueFlagged, Limit: benchPageSize}))
+			}},
+			{"GetAuditLog", func(int) error { return ignore(store.GetAuditLog(ctx, storage.AuditFilter{Limit: benchPageSize})) }},
+			{"GetThread", func(int) error { return ignore(store.GetThread(ctx, "bench", "page")) }},
+			{"ListComments/First", func(int) error {
+				return ignore(store.ListComments(ctx, storage.CommentFilter{PostID: f.post.ID, Limit: benchPageSize}))
+			}},
+			{"ListComments/Middle", func(int) error {
+				return ignore(store.ListComments(ctx, storage.CommentFilter{PostID: f.post.ID, After: f.middle, Limit: benchPageSize}))
+			}},
+			{"GetStats", func(int) error { return ignore(store.GetStats(ctx)) }},
+			{"ListPosts", func(int) error { return ignore(store.ListPosts(ctx, storage.PostFilter{Limit: benchPageSize})) }},
+		}
+		for _, read := range reads {
+			b.Run(read.name, func(b *testing.B) { loop(b, read.op) })
+		}
+
+		// Мутации добавляют записи в пост scratch и не меняют дерево комментариев поста post
+		b.Run("AddPost", func(b *testing.B) {
+			loop(b, func(int) error { return ignore(store.AddPost(ctx, actor, "Title", benchContent, true)) })
+		})
+		b.Run("AddComment", func(b *testing.B) {
+			loop(b, func(int) error {
+				return ignore(store.AddComment(ctx, actor, f.scratch.ID, "", benchContent, types.CommentPublished))
+			})
+		})
+		b.Run("AddComment/Reply", func(b *testing.B) {
+			loop(b, func(int) error {
+				return ignore(store.AddComment(ctx, actor, f.scratch.ID, f.scratchRoot, benchContent, types.CommentPublished))
+			})
+		})
+		b.Run("AddUser", func(b *testing.B) {
+			loop(b, func(int) error { return ignore(store.AddUser(ctx, f.name())) })
+		})
+		b.Run("AddNotifications", func(b *testing.B) {
+			loop(b, func(i int) error {
+				return store.AddNotifications(ctx, []*types.Notification{{
+					UserID: f.users[i%benchUsers].ID, Type: types.NotificationReply, ActorID: actor, PostID: f.scratch.ID, CommentID: f.scratchRoot,
+				}})
+			})
+		})
+		b.Run("MarkNotificationsRead", func(b *testing.B) {
+			loop(b, func(i int) error {
+				n := i % len(f.notifications)
+				return ignore(store.MarkNotificationsRead(ctx, f.users[n%benchUsers].ID, []string{f.notifications[n]}))
+			})
+		})
+		b.Run("AddMentions", func(b *testing.B) {
+			loop(b, func(i int) error { return store.AddMentions(ctx, f.scratchRoot, []string{f.users[i%benchUsers].ID}) })
+		})
+		b.Run("ReportComment", func(b *testing.B) {
+			loop(b, func(i int) error {
+				return store.ReportComment(ctx, &types.Report{CommentID: f.leaf, UserID: f.users[i%benchUsers].ID, Reason: "spam", CreatedAt: time.Now()})
+			})
+		})
+		b.Run("ModerateComment", func(b *testing.B) {
+			loop(b, func(i int) error {
+				return ignore(store.ModerateComment(ctx, actor, f.pending[i%len(f.pending)], types.CommentRejected, "spam"))
+			})
+		})
+		b.Run("AddThread", func(b *testing.B) {
+			loop(b, func(i int) error { return ignore(store.AddThread(ctx, "bench", "page"+strconv.Itoa(i))) })
+		})
+		b.Run("SetAllowComments", func(b *testing.B) {
+			loop(b, func(int) error { return ignore(store.SetAllowComments(ctx, actor, f.scratch.ID, true)) })
+		})
+		b.Run("DeleteComment", func(b *testing.B) {
+			// Удаляется новый ответ на последний комментарий, поэтому дерево поста не меняется
+			loop(b, func(i int) error {
+				b.StopTimer()
+				id := f.importComment(b, f.post.ID, f.leaf, types.CommentPublished, i)
+				b.StartTimer()
+				return ignore(store.DeleteComment(ctx, actor, id, "bench"))
+			})
+		})
+		b.Run("ImportUser", func(b *testing.B) {
+			loop(b, func(int) error {
+				return store.ImportUser(ctx, &types.User{ID: uuid.NewString(), Name: f.name(), CreatedAt: f.created})
+			})
+		})
+		b.Run("ImportPost", func(b *testing.B) {
+			loop(b, func(int) error {
+				return store.ImportPost(ctx, &types.Post{ID: uuid.NewString(), Title: "Title", Content: benchContent, CreatedAt: f.created, AllowComments: true})
+			})
+		})
+		b.Run("ImportComment", func(b *testing.B) {
+			loop(b, func(int) error {
+				return store.ImportComment(ctx, &types.Comment{
+					ID: uuid.NewString(), PostID: f.scratch.ID, Content: benchContent, CreatedAt: f.created, Status: types.CommentPublished,
+				})
+			})
+		})
+	})
+}
+
+func BenchmarkGraphQL(b *testing.B) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+
+	datasets(b, func(b *testing.B, f *fixture) {
+		user := auth.WithUser(f.ctx, f.users[0].ID)
+		admin := auth.WithAdmin(f.ctx)
+
+		documents := []struct {
+			name      string
+			ctx       context.Context
+			query     string
+			variables map[string]interface{}
+		}{
+			{"getPosts", f.ctx, `{ getPosts { id title createdAt allowComments } }`, nil},
+			{"getPostByID", f.ctx, `query($id: ID!) { getPostByID(id: $id) { id title contentHTML comments } }`,
+				map[string]interface{}{"id": f.post.ID}},
+			{"getComments/First", f.ctx, `query($id: ID!) { getComments(postID: $id) { id authorID contentHTML createdAt replies } }`,
+				map[string]interface{}{"id": f.post.ID}},
+			{"getComments/Last", f.ctx, `query($id: ID!, $page: Int) { getComments(postID: $id, page: $page) { id authorID contentHTML createdAt replies } }`,
+				map[string]interface{}{"id": f.post.ID, "page": max(f.pages, 1)}},
+			{"getCommentByID", f.ctx, `query($id: ID!) { getCommentByID(id: $id) { id content contentHTML replies mentions { id name } } }`,
+				map[string]interface{}{"id": f.leaf}},
+			{"getReplies", f.ctx, `query($id: ID!) { getReplies(commentID: $id) { id authorID content createdAt replies } }`,
+				map[string]interface{}{"id": f.root}},
+			{"getNumberOfCommentPages", f.ctx, `query($id: ID!) { getNumberOfCommentPages(postID: $id) }`,
+				map[string]interface{}{"id": f.post.ID}},
+			{"threadByExternalID", f.ctx, `{ threadByExternalID(namespace: "bench", id: "page") { id comments } }`, nil},
+			{"commentsMentioning", f.ctx, `query($id: ID!) { commentsMentioning(userID: $id) { id content } }`,
+				map[string]interface{}{"id": f.users[0].ID}},
+			{"notifications", user, `{ notifications(first: 20) { edges { cursor node { id type commentID } } pageInfo { hasNextPage } } unreadNotificationCount }`, nil},
+			{"moderationQueue", admin, `{ moderationQueue(status: FLAGGED, first: 20) { edges { node { comment { id content } reports reasons } } } }`, nil},
+			{"auditLog", admin, `{ auditLog(first: 20) { edges { node { id actor action targetID } } } }`, nil},
+			{"addComment", user, `mutation($postID: ID!, $content: String!) { addComment(postID: $postID, content: $content) { id createdAt } }`,
+				map[string]interface{}{"postID": f.scratch.ID, "content": benchContent}},
+			{"addComment/Reply", user, `mutation($postID: ID!, $parentID: ID!, $content: String!) { addComment(postID: $postID, parentCommentID: $parentID, content: $content) { id } }`,
+				map[string]interface{}{"postID": f.scratch.ID, "parentID": f.scratchRoot, "content": benchContent}},
+		}
+		for _, document := range documents {
+			b.Run(document.name, func(b *testing.B) {
+				loop(b, func(int) error {
+					result := graphql.Do(graphql.Params{
+						Schema:         schema,
+						RequestString:  document.query,
+						VariableValues: document.variables,
+						Context:        document.ctx,
+					})
+					if result.HasErrors() {
+						return result.Errors[0]
+					}
+					return nil
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"graphql-comments/storage"
+	"graphql-comments/storage/cache"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// countingStore подсчитывает кэшируемые чтения, дошедшие до хранилища
+type countingStore struct {
+	storage.DataStore
+	calls map[string]int
+}
+
+func (s *countingStore) GetPosts(ctx context.Context) ([]*types.Post, error) {
+	s.calls["GetPosts"]++
+	return s.DataStore.GetPosts(ctx)
+}
+
+func (s *countingStore) GetPostByID(ctx context.Context, id string) (*types.Post, error) {
+	s.calls["GetPostByID"]++
+	return s.DataStore.GetPostByID(ctx, id)
+}
+
+func (s *countingStore) GetComments(ctx context.Context, postID string, page int) ([]*types.Comment, error) {
+	s.calls["GetComments"]++
+	return s.DataStore.GetComments(ctx, postID, page)
+}
+
+func (s *countingStore) GetReplies(ctx context.Context, commentID string) ([]*types.Comment, error) {
+	s.calls["GetReplies"]++
+	return s.DataStore.GetReplies(ctx, commentID)
+}
+
+func newStore(t *testing.T) (*cache.DataStoreCache, *countingStore) {
+	t.Helper()
+	memory := inMemory.NewInMemoryStore()
+	storage.DataBase = memory
+	counting := &countingStore{DataStore: memory, calls: make(map[string]int)}
+	return cache.NewCacheStore(counting, cache.NewMemoryBackend(100), time.Minute), counting
+}
+
+func ids(comments []*types.Comment) []string {
+	result := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		result = append(result, comment.ID)
+	}
+	return result
+}
+
+func TestCacheStore(t *testing.T) {
+	ctx := context.Background()
+	store, counting := newStore(t)
+
+	post, err := store.AddPost(ctx, "", "title", "content", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	root, err := store.AddComment(ctx, "", post.ID, "", "root", types.CommentPublished)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reply, err := store.AddComment(ctx, "", post.ID, root.ID, "reply", types.CommentPublished)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Hits", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			store.GetPosts(ctx)
+			store.GetPostByID(ctx, post.ID)
+			store.GetComments(ctx, post.ID, 1)
+			store.GetReplies(ctx, root.ID)
+		}
+		for _, op := range []string{"GetPosts", "GetPostByID", "GetComments", "GetReplies"} {
+			if counting.calls[op] != 1 {
+				t.Errorf("Expected 1 call of %s, got %d", op, counting.calls[op])
+			}
+		}
+
+		comments, err := store.GetComments(ctx, post.ID, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(comments) != 1 || comments[0].ID != root.ID || len(comments[0].Replies) != 1 || !comments[0].CreatedAt.Equal(root.CreatedAt) {
+			t.Errorf("Unexpected comments %+v", comments)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := store.GetPostByID(ctx, "missing"); !errors.Is(err, storage.ErrPostNotFound) {
+				t.Errorf("Expected ErrPostNotFound, got %v", err)
+			}
+		}
+		if counting.calls["GetPostByID"] != 3 {
+			t.Errorf("Errors must not be cached, got %d calls", counting.calls["GetPostByID"])
+		}
+	})
+
+	t.Run("AddComment", func(t *testing.T) {
+		nested, err := store.AddComment(ctx, "", post.ID, reply.ID, "nested", types.CommentPublished)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		replies, _ := store.GetReplies(ctx, root.ID)
+		if len(replies) != 1 || len(replies[0].Replies) != 1 || replies[0].Replies[0] != nested.ID {
+			t.Errorf("Expected replies of the grandparent to be invalidated, got %+v", replies)
+		}
+		if replies, _ := store.GetReplies(ctx, reply.ID); len(replies) != 1 {
+			t.Errorf("Unexpected replies %+v", replies)
+		}
+
+		for i := 0; i < storage.CommentsPageSize; i++ {
+			if _, err := store.AddComment(ctx, "", post.ID, "", fmt.Sprint(i), types.CommentPublished); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if pages, _ := store.GetNumberOfCommentPages(ctx, post.ID); pages != 2 {
+			t.Errorf("Expected 2 pages, got %d", pages)
+		}
+		if comments, _ := store.GetComments(ctx, post.ID, 2); len(comments) != 1 {
+			t.Errorf("Unexpected second page %v", ids(comments))
+		}
+		if post, _ := store.GetPostByID(ctx, post.ID); len(post.Comments) != storage.CommentsPageSize+1 {
+			t.Errorf("Unexpected post comments %v", post.Comments)
+		}
+		if posts, _ := store.GetPosts(ctx); len(posts) != 1 || len(posts[0].Comments) != storage.CommentsPageSize+1 {
+			t.Errorf("Unexpected posts %+v", posts)
+		}
+	})
+
+	t.Run("AddPost", func(t *testing.T) {
+		if _, err := store.AddPost(ctx, "", "second", "content", true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if posts, _ := store.GetPosts(ctx); len(posts) != 2 {
+			t.Errorf("Expected 2 posts, got %d", len(posts))
+		}
+	})
+
+	t.Run("SetAllowComments", func(t *testing.T) {
+		if _, err := store.SetAllowComments(ctx, "admin", post.ID, false); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if post, _ := store.GetPostByID(ctx, post.ID); post.AllowComments {
+			t.Errorf("Expected comments to be closed")
+		}
+	})
+
+	t.Run("DeleteComment", func(t *testing.T) {
+		if _, err := store.DeleteComment(ctx, "admin", reply.ID, "spam"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replies, _ := store.GetReplies(ctx, root.ID); len(replies) != 0 {
+			t.Errorf("Expected no replies, got %v", ids(replies))
+		}
+		if _, err := store.GetReplies(ctx, reply.ID); err == nil {
+			t.Errorf("Expected error for replies of a deleted comment")
+		}
+		if comments, _ := store.GetComments(ctx, post.ID, 1); len(comments[0].Replies) != 0 {
+			t.Errorf("Unexpected replies of the root %v", comments[0].Replies)
+		}
+	})
+}
+
+func TestModerateComment(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t)
+
+	post, _ := store.AddPost(ctx, "", "title", "content", true)
+	pending, err := store.AddComment(ctx, "", post.ID, "", "pending", types.CommentPending)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages, _ := store.GetNumberOfCommentPages(ctx, post.ID); pages != 0 {
+		t.Errorf("Expected no pages, got %d", pages)
+	}
+
+	if _, err := store.ModerateComment(ctx, "admin", pending.ID, types.CommentPublished, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pages, _ := store.GetNumberOfCommentPages(ctx, post.ID); pages != 1 {
+		t.Errorf("Expected 1 page, got %d", pages)
+	}
+	if comments, _ := store.GetComments(ctx, post.ID, 1); len(comments) != 1 || comments[0].Status != types.CommentPublished {
+		t.Errorf("Unexpected comments %+v", comments)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	store, _ := newStore(t)
+	first := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "first"})
+	second := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "second"})
+
+	if _, err := store.AddPost(first, "", "title", "content", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if posts, _ := store.GetPosts(first); len(posts) != 1 {
+		t.Errorf("Expected 1 post, got %d", len(posts))
+	}
+	if posts, _ := store.GetPosts(second); len(posts) != 0 {
+		t.Errorf("Expected no posts of another tenant, got %d", len(posts))
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := cache.NewMemoryBackend(2)
+
+	backend.Set(ctx, "a", []byte("1"), time.Minute)
+	backend.Set(ctx, "b", []byte("2"), time.Minute)
+	backend.Get(ctx, "a")
+	backend.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := backend.Get(ctx, "b"); ok {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if value, ok, _ := backend.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Errorf("Unexpected entry %q", value)
+	}
+
+	backend.Delete(ctx, "a", "missing")
+	if _, ok, _ := backend.Get(ctx, "a"); ok || backend.Len() != 1 {
+		t.Errorf("Expected entry to be deleted")
+	}
+
+	backend.Set(ctx, "expired", []byte("4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := backend.Get(ctx, "expired"); ok {
+		t.Errorf("Expected entry to expire")
+	}
+}
+
+func TestPostgresBackend(t *testing.T) {
+	ctx := context.Background()
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	backend := cache.NewPostgresBackend(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT value FROM cache_entries WHERE key = $1 AND expires_at > $2")).
+		WithArgs("key", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("value")))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT value FROM cache_entries WHERE key = $1 AND expires_at > $2")).
+		WithArgs("missing", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO cache_entries (key, value, expires_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO UPDATE")).
+		WithArgs("key", []byte("value"), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cache_entries WHERE key = ANY($1)")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM cache_entries WHERE expires_at <= $1")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if value, ok, err := backend.Get(ctx, "key"); err != nil || !ok || string(value) != "value" {
+		t.Errorf("Unexpected result %q, %v, %v", value, ok, err)
+	}
+	if _, ok, err := backend.Get(ctx, "missing"); err != nil || ok {
+		t.Errorf("Unexpected result %v, %v", ok, err)
+	}
+	if err := backend.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := backend.Delete(ctx, "key", "other"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := backend.Delete(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := backend.Prune(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
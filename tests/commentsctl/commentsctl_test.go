@@ -0,0 +1,280 @@
+package commentsctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"graphql-comments/commentsctl"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/sqltest"
+	"graphql-comments/types"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newCLI(t *testing.T, store storage.DataStore) (*commentsctl.CLI, *bytes.Buffer) {
+	t.Helper()
+
+	registry, err := tenant.NewRegistry([]*tenant.Tenant{{ID: "acme"}}, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	return &commentsctl.CLI{Store: store, Tenants: registry, Stdout: stdout}, stdout
+}
+
+func run(t *testing.T, cli *commentsctl.CLI, stdout *bytes.Buffer, result interface{}, args ...string) {
+	t.Helper()
+
+	stdout.Reset()
+	if err := cli.Run(context.Background(), args); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != nil {
+		if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+			t.Fatalf("Invalid JSON output %q: %v", stdout.String(), err)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+	cli, stdout := newCLI(t, store)
+	ctx := context.Background()
+
+	post, _ := store.AddPost(ctx, "", "Post", "Content", true)
+	root, _ := store.AddComment(ctx, "", post.ID, "", "hello", types.CommentPublished)
+	spam, _ := store.AddComment(ctx, "", post.ID, root.ID, "cheap CASINO bonus", types.CommentPublished)
+	store.AddComment(ctx, "", post.ID, spam.ID, "thanks for the link", types.CommentPublished)
+	store.AddComment(ctx, "", post.ID, spam.ID, "more casino", types.CommentPublished)
+	pending, _ := store.AddComment(ctx, "", post.ID, "", "casino pending", types.CommentPending)
+	store.ReportComment(ctx, &types.Report{CommentID: root.ID, UserID: "user", Reason: "rude"})
+
+	t.Run("Posts", func(t *testing.T) {
+		var posts []map[string]interface{}
+		run(t, cli, stdout, &posts, "-o", "json", "posts")
+		if len(posts) != 1 || posts[0]["id"] != post.ID || posts[0]["comments"] != float64(1) {
+			t.Errorf("Unexpected posts: %v", posts)
+		}
+
+		run(t, cli, stdout, nil, "posts")
+		if !strings.HasPrefix(stdout.String(), "ID") || !strings.Contains(stdout.String(), post.ID) {
+			t.Errorf("Unexpected table: %q", stdout.String())
+		}
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		var result struct {
+			ID   string `json:"id"`
+			Tree []struct {
+				ID      string `json:"id"`
+				Status  string `json:"status"`
+				Replies []struct {
+					ID      string            `json:"id"`
+					Replies []json.RawMessage `json:"replies"`
+				} `json:"replies"`
+			} `json:"tree"`
+		}
+		run(t, cli, stdout, &result, "-o", "json", "post", post.ID)
+		if len(result.Tree) != 2 || result.Tree[0].ID != root.ID || result.Tree[1].Status != types.CommentPending {
+			t.Fatalf("Unexpected tree: %+v", result.Tree)
+		}
+		if replies := result.Tree[0].Replies; len(replies) != 1 || replies[0].ID != spam.ID || len(replies[0].Replies) != 2 {
+			t.Errorf("Unexpected replies: %+v", replies)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		var stats map[string]int
+		run(t, cli, stdout, &stats, "-o", "json", "stats")
+		if stats["posts"] != 1 || stats["published"] != 4 || stats["pending"] != 1 || stats["reports"] != 1 {
+			t.Errorf("Unexpected stats: %v", stats)
+		}
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		run(t, cli, stdout, nil, "lock", post.ID)
+		if got, _ := store.GetPostByID(ctx, post.ID); got.AllowComments {
+			t.Errorf("Expected comments to be locked")
+		}
+		run(t, cli, stdout, nil, "-actor", "ops", "unlock", post.ID)
+		if got, _ := store.GetPostByID(ctx, post.ID); !got.AllowComments {
+			t.Errorf("Expected comments to be unlocked")
+		}
+
+		entries, _ := store.GetAuditLog(ctx, storage.AuditFilter{TargetID: post.ID})
+		if len(entries) != 2 || entries[0].Action != types.AuditUnlock || entries[0].Actor != "ops" || entries[1].Action != types.AuditLock {
+			t.Errorf("Unexpected audit log: %v", entries)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		var result struct {
+			Matched []struct {
+				ID string `json:"id"`
+			} `json:"matched"`
+			Deleted int  `json:"deleted"`
+			DryRun  bool `json:"dryRun"`
+		}
+		run(t, cli, stdout, &result, "-o", "json", "purge", "-pattern", "(?i)casino", "-dry-run")
+		if len(result.Matched) != 3 || result.Deleted != 0 || !result.DryRun {
+			t.Errorf("Unexpected dry run: %+v", result)
+		}
+		if _, err := store.GetCommentByID(ctx, spam.ID); err != nil {
+			t.Errorf("Dry run deleted a comment: %v", err)
+		}
+
+		run(t, cli, stdout, &result, "-o", "json", "purge", "-pattern", "(?i)casino", "-status", types.CommentPublished)
+		// Ответы удаляются вместе с родителем, поэтому удалено три комментария из двух совпавших
+		if len(result.Matched) != 2 || result.Deleted != 3 {
+			t.Errorf("Unexpected purge: %+v", result)
+		}
+		if _, err := store.GetCommentByID(ctx, spam.ID); err == nil {
+			t.Errorf("Expected spam comment to be deleted")
+		}
+		if _, err := store.GetCommentByID(ctx, pending.ID); err != nil {
+			t.Errorf("Pending comment should not be deleted: %v", err)
+		}
+		if got, _ := store.GetCommentByID(ctx, root.ID); len(got.Replies) != 0 {
+			t.Errorf("Expected reply to be unlinked, got %v", got.Replies)
+		}
+
+		entries, _ := store.GetAuditLog(ctx, storage.AuditFilter{Action: types.AuditDelete})
+		if len(entries) != 1 || entries[0].TargetID != spam.ID || entries[0].Actor != commentsctl.DefaultActor || entries[0].Reason != "spam" {
+			t.Errorf("Unexpected audit log: %v", entries)
+		}
+	})
+
+	t.Run("Tenant", func(t *testing.T) {
+		var posts []map[string]interface{}
+		run(t, cli, stdout, &posts, "-o", "json", "-tenant", "acme", "posts")
+		if len(posts) != 0 {
+			t.Errorf("Expected no posts for another tenant, got %v", posts)
+		}
+
+		if err := cli.Run(ctx, []string{"-tenant", "unknown", "posts"}); !errors.Is(err, tenant.ErrUnknownTenant) {
+			t.Errorf("Expected unknown tenant error, got %v", err)
+		}
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"unknown"},
+			{"-o", "yaml", "posts"},
+			{"post"},
+			{"purge"},
+			{"purge", "-pattern", "("},
+			{"purge", "-pattern", "x", "-status", "DELETED"},
+		} {
+			if err := cli.Run(ctx, args); !errors.Is(err, commentsctl.ErrUsage) {
+				t.Errorf("Expected usage error for %v, got %v", args, err)
+			}
+		}
+
+		if err := cli.Run(ctx, []string{"migrate"}); err == nil {
+			t.Errorf("Expected error for storage without migrations")
+		}
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := &postgres.DataStorePostgres{DB: db}
+	storage.DataBase = store
+	ctx := context.Background()
+
+	t.Run("DeleteComment", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("WITH RECURSIVE subtree")).WithArgs("comment-id", tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("comment-id").AddRow("reply-id"))
+		for _, table := range []string{"notifications", "comment_mentions", "comment_reports"} {
+			mock.ExpectExec(regexp.QuoteMeta("DELETE FROM " + table + " WHERE comment_id = ANY($1)")).
+				WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comments WHERE id = ANY($1)")).
+			WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, "ops", types.AuditDelete, "comment-id", "spam", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		deleted, err := store.DeleteComment(ctx, "ops", "comment-id", "spam")
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("Expected 2 deleted comments, got %d", deleted)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("SetAllowCommentsNotFound", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE posts SET allow_comments = $1 WHERE id = $2 AND tenant_id = $3")).
+			WithArgs(false, "missing", tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		if _, err := store.SetAllowComments(ctx, "ops", "missing", false); err == nil || err.Error() != "post not found" {
+			t.Errorf("Expected post not found, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ListComments", func(t *testing.T) {
+		createdAt := time.Now()
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT created_at FROM comments WHERE id = $1 AND tenant_id = $2")).
+			WithArgs("after-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE tenant_id = $1 AND post_id = $2 AND (created_at, id) > ($3, $4) ORDER BY created_at, id LIMIT $5")).
+			WithArgs(tenant.DefaultID, "post-id", createdAt, "after-id", 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at", "status"}).
+				AddRow("comment-id", "post-id", nil, nil, "hello", createdAt, types.CommentPending))
+		mock.ExpectCommit()
+
+		comments, err := store.ListComments(ctx, storage.CommentFilter{PostID: "post-id", After: "after-id", Limit: 10})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(comments) != 1 || comments[0].Status != types.CommentPending {
+			t.Errorf("Unexpected comments: %v", comments)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS Posts")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		if err := postgres.Migrate(ctx, db); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package complexity_test
+
+import (
+	"encoding/json"
+	"graphql-comments/graphql"
+	"graphql-comments/graphql/complexity"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/handler"
+)
+
+func newSchema(t *testing.T) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:      gql.QueryType,
+		Mutation:   gql.MutationType,
+		Extensions: []graphql.Extension{complexity.Extension{}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &schema
+}
+
+func analyze(t *testing.T, schema *graphql.Schema, query string, limits complexity.Limits) (*complexity.Report, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	return complexity.Analyze(schema, doc, "", limits)
+}
+
+func TestAnalyze(t *testing.T) {
+	schema := newSchema(t)
+
+	t.Run("ListFieldsMultiplyChildCost", func(t *testing.T) {
+		report, err := analyze(t, schema, `{ getPosts { id title } }`, complexity.Limits{})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if report.Cost != 1+50*2 {
+			t.Errorf("Expected cost 101, got %v", report.Cost)
+		}
+
+		if report.Depth != 2 {
+			t.Errorf("Expected depth 2, got %v", report.Depth)
+		}
+	})
+
+	t.Run("FragmentsAndAliases", func(t *testing.T) {
+		report, err := analyze(t, schema, `
+			query {
+				a: getComments(postID: "1") { ...fields }
+				b: getPostByID(id: "1") { id }
+			}
+			fragment fields on Comment { id content }
+		`, complexity.Limits{})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if report.Cost != 1+10*2+1+1 {
+			t.Errorf("Expected cost 23, got %v", report.Cost)
+		}
+
+		if report.Aliases != 2 {
+			t.Errorf("Expected 2 aliases, got %v", report.Aliases)
+		}
+	})
+
+	t.Run("IntrospectionIsIgnored", func(t *testing.T) {
+		report, err := analyze(t, schema, `{ __schema { types { fields { type { ofType { name } } } } } }`, complexity.Limits{MaxDepth: 1})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if report.Cost != 0 {
+			t.Errorf("Expected cost 0, got %v", report.Cost)
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		cases := []struct {
+			limits complexity.Limits
+			code   string
+		}{
+			{complexity.Limits{MaxDepth: 1}, "QUERY_TOO_DEEP"},
+			{complexity.Limits{MaxAliases: 1}, "TOO_MANY_ALIASES"},
+			{complexity.Limits{MaxCost: 100}, "QUERY_TOO_COMPLEX"},
+		}
+
+		for _, c := range cases {
+			_, err := analyze(t, schema, `{ a: getPosts { id } b: getPosts { id } }`, c.limits)
+			complexityErr, ok := err.(*complexity.Error)
+			if !ok {
+				t.Errorf("Expected %s error, got %v", c.code, err)
+				continue
+			}
+
+			if complexityErr.Code != c.code {
+				t.Errorf("Expected %s error, got %s", c.code, complexityErr.Code)
+			}
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	schema := newSchema(t)
+	server := complexity.Middleware(schema, complexity.Limits{MaxCost: 100})(handler.New(&handler.Config{Schema: schema}))
+
+	do := func(query string) map[string]interface{} {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Response is not JSON: %v", err)
+		}
+		return response
+	}
+
+	t.Run("ReportsCost", func(t *testing.T) {
+		response := do(`{ getPostByID(id: "1") { id } getNumberOfCommentPages(postID: "1") }`)
+
+		extensions, _ := response["extensions"].(map[string]interface{})
+		report, _ := extensions["complexity"].(map[string]interface{})
+		if report["cost"] != float64(3) {
+			t.Errorf("Expected cost 3 in extensions, got %v", response["extensions"])
+		}
+	})
+
+	t.Run("RejectsExpensiveQuery", func(t *testing.T) {
+		response := do(`{ getPosts { id title content } }`)
+
+		errs, _ := response["errors"].([]interface{})
+		if len(errs) != 1 {
+			t.Fatalf("Expected 1 error, got %v", response)
+		}
+
+		extensions := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})
+		if extensions["code"] != "QUERY_TOO_COMPLEX" {
+			t.Errorf("Expected QUERY_TOO_COMPLEX, got %v", extensions["code"])
+		}
+
+		if extensions["value"] != float64(151) {
+			t.Errorf("Expected cost 151, got %v", extensions["value"])
+		}
+	})
+}
@@ -0,0 +1,513 @@
+package dump_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"graphql-comments/auth"
+	"graphql-comments/commentsctl"
+	"graphql-comments/dump"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/sqltest"
+	"graphql-comments/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+// fixture заполняет хранилище постами, веткой внешнего ресурса и деревом комментариев
+func fixture(t *testing.T, store storage.DataStore) {
+	t.Helper()
+
+	// In-memory хранилище проверяет уникальность новых ID через storage.DataBase
+	storage.DataBase = store
+	ctx := context.Background()
+	alice, err := store.AddUser(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bob, _ := store.AddUser(ctx, "bob")
+	post, _ := store.AddPost(ctx, alice.ID, "Post", "Content", true)
+	store.AddPost(ctx, "", "Locked", "No comments", false)
+	thread, _ := store.AddThread(ctx, "blog", "article-1")
+
+	root, _ := store.AddComment(ctx, bob.ID, post.ID, "", "root", types.CommentPublished)
+	reply, _ := store.AddComment(ctx, alice.ID, post.ID, root.ID, "reply", types.CommentPublished)
+	store.AddComment(ctx, "", post.ID, reply.ID, "nested", types.CommentPublished)
+	store.AddComment(ctx, bob.ID, post.ID, "", "pending", types.CommentPending)
+	store.AddComment(ctx, bob.ID, thread.ID, "", "on thread", types.CommentPublished)
+}
+
+// export возвращает выгрузку без заголовка, который содержит время выгрузки
+func export(t *testing.T, store storage.DataStore) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := dump.Export(context.Background(), store, &buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, records, _ := strings.Cut(buf.String(), "\n")
+	return records
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := inMemory.NewInMemoryStore()
+	fixture(t, source)
+
+	var buf bytes.Buffer
+	exported, err := dump.Export(ctx, source, &buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *exported != (dump.Result{Users: 2, Posts: 3, Comments: 5}) {
+		t.Errorf("Unexpected export result: %+v", exported)
+	}
+	if !strings.HasPrefix(buf.String(), `{"type":"header","version":1`) {
+		t.Errorf("Expected header first, got %q", buf.String())
+	}
+
+	target := inMemory.NewInMemoryStore()
+	result, err := (&dump.Importer{Store: target}).Import(ctx, bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *result != (dump.Result{Users: 2, Posts: 3, Comments: 5}) {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	// Выгрузка нового хранилища совпадает с исходной: ID, связи, время и статусы сохранены
+	if got, want := export(t, target), export(t, source); got != want {
+		t.Errorf("Round trip mismatch:\n%s\nwant:\n%s", got, want)
+	}
+
+	posts, _ := target.GetPosts(ctx)
+	for _, post := range posts {
+		if post.Title == "Locked" && post.AllowComments {
+			t.Errorf("Expected locked post to stay locked")
+		}
+	}
+	if _, err := target.GetThread(ctx, "blog", "article-1"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	t.Run("Repeat", func(t *testing.T) {
+		result, err := (&dump.Importer{Store: target, OnConflict: dump.ConflictFail}).Import(ctx, bytes.NewReader(buf.Bytes()), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *result != (dump.Result{Existing: 10}) {
+			t.Errorf("Unexpected import result: %+v", result)
+		}
+	})
+}
+
+func TestOutOfOrder(t *testing.T) {
+	data := `{"type":"comment","id":"c2","postID":"p1","parentCommentID":"c1","content":"reply","createdAt":"2024-01-01T00:00:02Z"}
+{"type":"comment","id":"c1","postID":"p1","content":"root","createdAt":"2024-01-01T00:00:01Z"}
+{"type":"post","id":"p1","title":"Post","content":"Content","createdAt":"2024-01-01T00:00:00Z"}
+`
+	store := inMemory.NewInMemoryStore()
+	result, err := (&dump.Importer{Store: store}).Import(context.Background(), strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *result != (dump.Result{Posts: 1, Comments: 2}) {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	reply, err := store.GetCommentByID(context.Background(), "c2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reply.ParentCommentID != "c1" || !reply.CreatedAt.Equal(time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC)) {
+		t.Errorf("Unexpected reply: %+v", reply)
+	}
+
+	t.Run("MaxPending", func(t *testing.T) {
+		importer := &dump.Importer{Store: inMemory.NewInMemoryStore(), MaxPending: 1}
+		if _, err := importer.Import(context.Background(), strings.NewReader(data), nil); err == nil {
+			t.Errorf("Expected error when too many comments wait for their parents")
+		}
+	})
+}
+
+func TestOrphans(t *testing.T) {
+	data := `{"type":"post","id":"p1","title":"Post","content":"Content","createdAt":"2024-01-01T00:00:00Z"}
+{"type":"comment","id":"c1","postID":"missing","content":"orphan","createdAt":"2024-01-01T00:00:01Z"}
+{"type":"comment","id":"c2","postID":"p1","parentCommentID":"missing","content":"orphan","createdAt":"2024-01-01T00:00:02Z"}
+`
+	result, err := (&dump.Importer{Store: inMemory.NewInMemoryStore()}).Import(context.Background(), strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *result != (dump.Result{Posts: 1, Orphaned: 2}) {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+}
+
+// takenIDs имитирует хранилище, в котором ID постов ids заняты другим арендатором
+type takenIDs struct {
+	storage.DataStore
+	ids map[string]bool
+}
+
+func (s *takenIDs) ImportPost(ctx context.Context, post *types.Post) error {
+	if s.ids[post.ID] {
+		return storage.ErrAlreadyExists
+	}
+	return s.DataStore.ImportPost(ctx, post)
+}
+
+func TestConflicts(t *testing.T) {
+	data := `{"type":"user","id":"u1","name":"alice","createdAt":"2024-01-01T00:00:00Z"}
+{"type":"post","id":"p1","authorID":"u1","title":"Post","content":"Content","createdAt":"2024-01-01T00:00:00Z"}
+{"type":"comment","id":"c1","postID":"p1","authorID":"u1","content":"root","createdAt":"2024-01-01T00:00:01Z"}
+{"type":"comment","id":"c2","postID":"p1","parentCommentID":"c1","content":"reply","createdAt":"2024-01-01T00:00:02Z"}
+`
+	ctx := context.Background()
+	newStore := func(t *testing.T) storage.DataStore {
+		store := inMemory.NewInMemoryStore()
+		if err := store.ImportUser(ctx, &types.User{ID: "u2", Name: "alice"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := store.ImportPost(ctx, &types.Post{ID: "p1", Title: "Other", Content: "Other", AllowComments: true}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return store
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		store := newStore(t)
+		result, err := (&dump.Importer{Store: store, OnConflict: dump.ConflictSkip}).Import(ctx, strings.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *result != (dump.Result{Existing: 1, Skipped: 3}) {
+			t.Errorf("Unexpected import result: %+v", result)
+		}
+		if post, _ := store.GetPostByID(ctx, "p1"); post.Title != "Other" || len(post.Comments) != 0 {
+			t.Errorf("Expected existing post to stay unchanged, got %+v", post)
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		store := newStore(t)
+		result, err := (&dump.Importer{Store: store, OnConflict: dump.ConflictRename}).Import(ctx, strings.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *result != (dump.Result{Posts: 1, Comments: 2, Existing: 1, Renamed: 1}) {
+			t.Errorf("Unexpected import result: %+v", result)
+		}
+
+		root, err := store.GetCommentByID(ctx, "c1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// Ссылки на переименованный пост и совпавшего по имени пользователя обновлены
+		if root.PostID == "p1" || root.AuthorID != "u2" {
+			t.Errorf("Unexpected comment: %+v", root)
+		}
+		post, err := store.GetPostByID(ctx, root.PostID)
+		if err != nil || post.Title != "Post" || post.AuthorID != "u2" {
+			t.Errorf("Unexpected renamed post: %+v, %v", post, err)
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		_, err := (&dump.Importer{Store: newStore(t), OnConflict: dump.ConflictFail}).Import(ctx, strings.NewReader(data), nil)
+		if !errors.Is(err, dump.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("Foreign", func(t *testing.T) {
+		// ID поста занят другим арендатором: хранилище отказывает в сохранении, но пост не находится
+		store := &takenIDs{DataStore: inMemory.NewInMemoryStore(), ids: map[string]bool{"p1": true}}
+		result, err := (&dump.Importer{Store: store, OnConflict: dump.ConflictSkip}).Import(ctx, strings.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *result != (dump.Result{Users: 1, Skipped: 3, Foreign: 1}) {
+			t.Errorf("Unexpected import result: %+v", result)
+		}
+
+		store = &takenIDs{DataStore: inMemory.NewInMemoryStore(), ids: map[string]bool{"p1": true}}
+		_, err = (&dump.Importer{Store: store, OnConflict: dump.ConflictFail}).Import(ctx, strings.NewReader(data), nil)
+		if !errors.Is(err, dump.ErrConflict) || !strings.Contains(err.Error(), "another tenant") {
+			t.Errorf("Expected ErrConflict for another tenant, got %v", err)
+		}
+	})
+
+	t.Run("ParseConflictPolicy", func(t *testing.T) {
+		if policy, err := dump.ParseConflictPolicy("rename"); err != nil || policy != dump.ConflictRename {
+			t.Errorf("Unexpected policy %q: %v", policy, err)
+		}
+		if _, err := dump.ParseConflictPolicy("overwrite"); err == nil {
+			t.Errorf("Expected error for unknown policy")
+		}
+	})
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	source := inMemory.NewInMemoryStore()
+	fixture(t, source)
+	var buf bytes.Buffer
+	if _, err := dump.Export(ctx, source, &buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Импорт прерывается на третьей контрольной точке
+	interrupted := errors.New("interrupted")
+	var saved []byte
+	calls := 0
+	target := inMemory.NewInMemoryStore()
+	importer := &dump.Importer{
+		Store:           target,
+		CheckpointEvery: 2,
+		Checkpoint: func(progress *dump.Progress) error {
+			calls++
+			saved, _ = json.Marshal(progress)
+			if calls >= 3 {
+				return interrupted
+			}
+			return nil
+		},
+	}
+	if _, err := importer.Import(ctx, bytes.NewReader(buf.Bytes()), nil); !errors.Is(err, interrupted) {
+		t.Fatalf("Expected interruption, got %v", err)
+	}
+
+	var progress dump.Progress
+	if err := json.Unmarshal(saved, &progress); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if progress.Offset == 0 || progress.Offset >= int64(buf.Len()) {
+		t.Fatalf("Unexpected progress offset %d", progress.Offset)
+	}
+
+	importer.Checkpoint = nil
+	result, err := importer.Import(ctx, bytes.NewReader(buf.Bytes()), &progress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Users+result.Existing != 2 || result.Posts != 3 || result.Comments != 5 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+	if got, want := export(t, target), export(t, source); got != want {
+		t.Errorf("Resumed import mismatch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	admin := auth.WithAdmin(context.Background())
+
+	source := inMemory.NewInMemoryStore()
+	fixture(t, source)
+	storage.DataBase = source
+
+	t.Run("Forbidden", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ exportDump }`, Context: context.Background()})
+		if !result.HasErrors() {
+			t.Errorf("Expected error for non-admin export")
+		}
+		result = graphql.Do(graphql.Params{Schema: schema, RequestString: `mutation { importDump(data: "") { posts } }`, Context: context.Background()})
+		if !result.HasErrors() {
+			t.Errorf("Expected error for non-admin import")
+		}
+	})
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ exportDump }`, Context: admin})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	data := result.Data.(map[string]interface{})["exportDump"].(string)
+
+	target := inMemory.NewInMemoryStore()
+	storage.DataBase = target
+	result = graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  `mutation($data: String!) { importDump(data: $data, onConflict: FAIL) { users posts comments existing renamed skipped foreign orphaned } }`,
+		VariableValues: map[string]interface{}{"data": data},
+		Context:        admin,
+	})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	imported := result.Data.(map[string]interface{})["importDump"].(map[string]interface{})
+	if imported["users"] != 2 || imported["posts"] != 3 || imported["comments"] != 5 || imported["skipped"] != 0 {
+		t.Errorf("Unexpected import result: %v", imported)
+	}
+	if got, want := export(t, target), export(t, source); got != want {
+		t.Errorf("GraphQL round trip mismatch:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCLI(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dump.ndjson")
+
+	source := inMemory.NewInMemoryStore()
+	fixture(t, source)
+	stdout := &bytes.Buffer{}
+	cli := &commentsctl.CLI{Store: source, Tenants: registry, Stdout: stdout}
+
+	t.Run("ExportStdout", func(t *testing.T) {
+		if err := cli.Run(context.Background(), []string{"export"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(stdout.String(), `{"type":"header"`) {
+			t.Errorf("Expected NDJSON on stdout, got %q", stdout.String())
+		}
+	})
+
+	stdout.Reset()
+	if err := cli.Run(context.Background(), []string{"-o", "json", "export", "-file", path}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var exported dump.Result
+	if err := json.Unmarshal(stdout.Bytes(), &exported); err != nil || exported.Comments != 5 {
+		t.Errorf("Unexpected export output %q: %v", stdout.String(), err)
+	}
+
+	target := inMemory.NewInMemoryStore()
+	cli = &commentsctl.CLI{Store: target, Tenants: registry, Stdout: stdout}
+	stdout.Reset()
+	if err := cli.Run(context.Background(), []string{"-o", "json", "import", "-on-conflict", "fail", path}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var imported dump.Result
+	if err := json.Unmarshal(stdout.Bytes(), &imported); err != nil || imported != (dump.Result{Users: 2, Posts: 3, Comments: 5}) {
+		t.Errorf("Unexpected import output %q: %v", stdout.String(), err)
+	}
+	if _, err := os.Stat(path + ".progress"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected progress file to be removed, got %v", err)
+	}
+	if got, want := export(t, target), export(t, source); got != want {
+		t.Errorf("CLI round trip mismatch:\n%s\nwant:\n%s", got, want)
+	}
+
+	t.Run("Stdin", func(t *testing.T) {
+		data, _ := os.ReadFile(path)
+		cli := &commentsctl.CLI{Store: inMemory.NewInMemoryStore(), Tenants: registry, Stdin: bytes.NewReader(data), Stdout: stdout}
+		stdout.Reset()
+		if err := cli.Run(context.Background(), []string{"import"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.Contains(stdout.String(), "comments") {
+			t.Errorf("Unexpected output %q", stdout.String())
+		}
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		if err := cli.Run(context.Background(), []string{"import", "-on-conflict", "overwrite", path}); !errors.Is(err, commentsctl.ErrUsage) {
+			t.Errorf("Expected ErrUsage, got %v", err)
+		}
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := &postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+
+	t.Run("ListPosts", func(t *testing.T) {
+		createdAt := time.Now()
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE tenant_id = $1 ORDER BY created_at, id LIMIT $2")).
+			WithArgs(tenant.DefaultID, 10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).
+				AddRow("post-id", nil, "Post", "Content", createdAt, false, nil, nil).
+				AddRow("thread-id", nil, "", "", createdAt, true, "blog", "article-1"))
+		mock.ExpectCommit()
+
+		posts, err := store.ListPosts(ctx, storage.PostFilter{Limit: 10})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(posts) != 2 || posts[0].AllowComments || posts[1].Namespace != "blog" || posts[1].ExternalID != "article-1" {
+			t.Errorf("Unexpected posts: %v", posts)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ImportUserConflict", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users (id, name, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING")).
+			WithArgs("user-id", "alice", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		if err := store.ImportUser(ctx, &types.User{ID: "user-id", Name: "alice"}); !errors.Is(err, storage.ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ImportComment", func(t *testing.T) {
+		createdAt := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("parent-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING")).
+			WithArgs("comment-id", tenant.DefaultID, "post-id", "parent-id", nil, "hello", createdAt, types.CommentPublished).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := store.ImportComment(ctx, &types.Comment{ID: "comment-id", PostID: "post-id", ParentCommentID: "parent-id", Content: "hello", CreatedAt: createdAt, Status: types.CommentPublished})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("ImportCommentPostNotFound", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("missing", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		if err := store.ImportComment(ctx, &types.Comment{ID: "comment-id", PostID: "missing"}); !errors.Is(err, storage.ErrPostNotFound) {
+			t.Errorf("Expected ErrPostNotFound, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -0,0 +1,733 @@
+package e2e_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"graphql-comments/auth"
+	gql "graphql-comments/graphql"
+	"graphql-comments/graphql/complexity"
+	"graphql-comments/ratelimit"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"graphql-comments/webhook"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/handler"
+)
+
+const adminToken = "admin-token"
+
+// server запускает обработчик GraphQL с теми же схемой и middleware, что и main.go, поверх
+// пустого хранилища in-memory
+func server(t *testing.T) *httptest.Server {
+	t.Helper()
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.TokenSecret = "secret"
+	dispatcher := webhook.NewDispatcher(webhook.NewMemoryStore(), webhook.DefaultOptions)
+	gql.Events = dispatcher
+	gql.Webhooks = dispatcher
+	t.Cleanup(func() { gql.Events = nil })
+
+	config := gql.Schema
+	config.Extensions = append(config.Extensions, complexity.Extension{})
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	registry, err := tenant.NewRegistry(nil, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h := tenant.Middleware(registry)(
+		auth.Middleware(adminToken, gql.TokenSecret)(
+			ratelimit.ClientMiddleware(false)(
+				complexity.Middleware(&schema, complexity.Limits{})(
+					handler.New(&handler.Config{Schema: &schema}),
+				),
+			),
+		),
+	)
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+	return s
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// post отправляет документ GraphQL с токеном token и возвращает ответ сервера
+func post(t *testing.T, s *httptest.Server, token, query string, variables map[string]interface{}) *response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req, _ := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	result := &response{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result
+}
+
+// do выполняет документ и декодирует данные ответа в out; ошибки GraphQL завершают тест
+func do(t *testing.T, s *httptest.Server, token, query string, variables map[string]interface{}, out interface{}) {
+	t.Helper()
+	result := post(t, s, token, query, variables)
+	if len(result.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// fails проверяет, что документ завершается ошибкой с текстом message
+func fails(t *testing.T, s *httptest.Server, token, query string, variables map[string]interface{}, message string) {
+	t.Helper()
+	result := post(t, s, token, query, variables)
+	if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Message, message) {
+		t.Errorf("Expected error %q, got %v", message, result.Errors)
+	}
+}
+
+// introspection запрашивает типы схемы вместе с полями, аргументами и значениями перечислений
+const introspection = `{
+  __schema {
+    types {
+      kind name
+      fields { name type { ...TypeRef } args { name type { ...TypeRef } defaultValue } }
+      inputFields { name type { ...TypeRef } defaultValue }
+      enumValues { name }
+    }
+  }
+}
+fragment TypeRef on __Type { kind name ofType { kind name ofType { kind name ofType { kind name } } } }`
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+// String возвращает тип в нотации SDL
+func (r *typeRef) String() string {
+	switch r.Kind {
+	case "NON_NULL":
+		return r.OfType.String() + "!"
+	case "LIST":
+		return "[" + r.OfType.String() + "]"
+	}
+	return r.Name
+}
+
+type inputValue struct {
+	Name         string  `json:"name"`
+	Type         typeRef `json:"type"`
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// String возвращает аргумент или поле ввода в нотации SDL
+func (v *inputValue) String() string {
+	if v.DefaultValue != nil {
+		value := *v.DefaultValue
+		// graphql-go выводит значения перечислений по умолчанию как строки
+		base := &v.Type
+		for base.OfType != nil {
+			base = base.OfType
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil && base.Kind == "ENUM" {
+			value = unquoted
+		}
+		return fmt.Sprintf("%s: %s = %s", v.Name, v.Type.String(), value)
+	}
+	return fmt.Sprintf("%s: %s", v.Name, v.Type.String())
+}
+
+// runtimeSchema описывает схему сервера строками вида "Query.getComments(postID: ID!, page: Int): [Comment!]!"
+func runtimeSchema(t *testing.T, s *httptest.Server) []string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"query": introspection})
+	resp, err := s.Client().Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Schema struct {
+				Types []struct {
+					Kind   string `json:"kind"`
+					Name   string `json:"name"`
+					Fields []struct {
+						Name string       `json:"name"`
+						Type typeRef      `json:"type"`
+						Args []inputValue `json:"args"`
+					} `json:"fields"`
+					InputFields []inputValue `json:"inputFields"`
+					EnumValues  []struct {
+						Name string `json:"name"`
+					} `json:"enumValues"`
+				} `json:"types"`
+			} `json:"__schema"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var lines []string
+	for _, typ := range result.Data.Schema.Types {
+		if strings.HasPrefix(typ.Name, "__") || typ.Kind == "SCALAR" {
+			continue
+		}
+		for _, field := range typ.Fields {
+			args := make([]string, 0, len(field.Args))
+			for _, arg := range field.Args {
+				args = append(args, arg.String())
+			}
+			lines = append(lines, signature(typ.Name, field.Name, args, field.Type.String()))
+		}
+		for _, field := range typ.InputFields {
+			lines = append(lines, typ.Name+"."+field.String())
+		}
+		for _, value := range typ.EnumValues {
+			lines = append(lines, typ.Name+"."+value.Name)
+		}
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// fileSchema описывает схему из файла SDL в том же виде, что и runtimeSchema
+func fileSchema(t *testing.T, path string) []string {
+	t.Helper()
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	document, err := parser.Parse(parser.ParseParams{Source: string(source)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	inputValue := func(value *ast.InputValueDefinition) string {
+		line := fmt.Sprintf("%s: %s", value.Name.Value, printer.Print(value.Type))
+		if value.DefaultValue != nil {
+			line += fmt.Sprintf(" = %s", printer.Print(value.DefaultValue))
+		}
+		return line
+	}
+	var lines []string
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.ObjectDefinition:
+			for _, field := range definition.Fields {
+				args := make([]string, 0, len(field.Arguments))
+				for _, arg := range field.Arguments {
+					args = append(args, inputValue(arg))
+				}
+				lines = append(lines, signature(definition.Name.Value, field.Name.Value, args, fmt.Sprint(printer.Print(field.Type))))
+			}
+		case *ast.InputObjectDefinition:
+			for _, field := range definition.Fields {
+				lines = append(lines, definition.Name.Value+"."+inputValue(field))
+			}
+		case *ast.EnumDefinition:
+			for _, value := range definition.Values {
+				lines = append(lines, definition.Name.Value+"."+value.Name.Value)
+			}
+		}
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+func signature(typeName, field string, args []string, result string) string {
+	slices.Sort(args)
+	if len(args) == 0 {
+		return fmt.Sprintf("%s.%s: %s", typeName, field, result)
+	}
+	return fmt.Sprintf("%s.%s(%s): %s", typeName, field, strings.Join(args, ", "), result)
+}
+
+func TestSchema(t *testing.T) {
+	s := server(t)
+	runtime := runtimeSchema(t, s)
+	file := fileSchema(t, "../../graphql/schema.graphql")
+
+	for _, line := range file {
+		if !slices.Contains(runtime, line) {
+			t.Errorf("Missing in runtime schema: %s", line)
+		}
+	}
+	for _, line := range runtime {
+		if !slices.Contains(file, line) {
+			t.Errorf("Missing in schema.graphql: %s", line)
+		}
+	}
+}
+
+type user struct {
+	ID   string
+	Name string
+}
+
+type comment struct {
+	ID              string
+	PostID          string
+	ParentCommentID *string
+	AuthorID        *string
+	Content         string
+	ContentHTML     string
+	Replies         []string
+	Status          string
+	Mentions        []user
+}
+
+type pageInfo struct {
+	EndCursor   *string
+	HasNextPage bool
+}
+
+const commentFields = `id postID parentCommentID authorID content contentHTML replies status mentions { id name }`
+
+func TestAPI(t *testing.T) {
+	s := server(t)
+	ctx := context.Background()
+
+	var alice, bob struct {
+		RegisterUser struct {
+			User  user
+			Token string
+		}
+	}
+	register := `mutation($name: String!) { registerUser(name: $name) { user { id name } token } }`
+
+	t.Run("registerUser", func(t *testing.T) {
+		do(t, s, "", register, map[string]interface{}{"name": "alice"}, &alice)
+		do(t, s, "", register, map[string]interface{}{"name": "bob"}, &bob)
+		if alice.RegisterUser.User.Name != "alice" || alice.RegisterUser.Token == "" {
+			t.Errorf("Unexpected payload: %+v", alice.RegisterUser)
+		}
+		fails(t, s, "", register, map[string]interface{}{"name": "alice"}, "taken")
+		fails(t, s, "", register, map[string]interface{}{"name": "no spaces"}, "name")
+	})
+	aliceToken, bobToken := alice.RegisterUser.Token, bob.RegisterUser.Token
+
+	var hook struct {
+		RegisterWebhook struct {
+			ID     string
+			URL    string
+			Events []string
+		}
+	}
+	registerWebhook := `mutation($url: String!) { registerWebhook(url: $url, events: ["post.created", "comment.created"], secret: "s") { id url events } }`
+
+	t.Run("registerWebhook", func(t *testing.T) {
+		do(t, s, adminToken, registerWebhook, map[string]interface{}{"url": "https://example.com/hook"}, &hook)
+		if hook.RegisterWebhook.URL != "https://example.com/hook" || len(hook.RegisterWebhook.Events) != 2 {
+			t.Errorf("Unexpected webhook: %+v", hook.RegisterWebhook)
+		}
+		fails(t, s, adminToken, registerWebhook, map[string]interface{}{"url": "ftp://example.com"}, "url")
+		fails(t, s, aliceToken, registerWebhook, map[string]interface{}{"url": "https://example.com/hook"}, "forbidden")
+	})
+
+	type post struct {
+		ID            string
+		AuthorID      *string
+		Title         string
+		Content       string
+		ContentHTML   string
+		Comments      []string
+		AllowComments bool
+	}
+	var open, closed struct{ AddPost post }
+	addPost := `mutation($title: String!, $content: String!, $allowComments: Boolean) {
+		addPost(title: $title, content: $content, allowComments: $allowComments) { id authorID title content contentHTML comments allowComments }
+	}`
+
+	t.Run("addPost", func(t *testing.T) {
+		do(t, s, aliceToken, addPost, map[string]interface{}{"title": "Open", "content": "**Hello**"}, &open)
+		do(t, s, aliceToken, addPost, map[string]interface{}{"title": "Closed", "content": "Bye", "allowComments": false}, &closed)
+		if !open.AddPost.AllowComments || closed.AddPost.AllowComments {
+			t.Errorf("Expected only the first post to allow comments, got %+v and %+v", open.AddPost, closed.AddPost)
+		}
+		if open.AddPost.AuthorID == nil || *open.AddPost.AuthorID != alice.RegisterUser.User.ID {
+			t.Errorf("Expected author %s, got %v", alice.RegisterUser.User.ID, open.AddPost.AuthorID)
+		}
+		if !strings.Contains(open.AddPost.ContentHTML, "<strong>Hello</strong>") {
+			t.Errorf("Unexpected HTML: %q", open.AddPost.ContentHTML)
+		}
+		fails(t, s, aliceToken, addPost, map[string]interface{}{"title": "", "content": "Content"}, "title is empty")
+	})
+	postID := open.AddPost.ID
+
+	var top, reply struct{ AddComment comment }
+	addComment := `mutation($postID: ID!, $parentID: ID, $content: String!) {
+		addComment(postID: $postID, parentCommentID: $parentID, content: $content) { ` + commentFields + ` }
+	}`
+
+	t.Run("addComment", func(t *testing.T) {
+		do(t, s, aliceToken, addComment, map[string]interface{}{"postID": postID, "content": "First"}, &top)
+		do(t, s, bobToken, addComment, map[string]interface{}{"postID": postID, "parentID": top.AddComment.ID, "content": "Agree with @alice"}, &reply)
+		if top.AddComment.Status != "PUBLISHED" || top.AddComment.ParentCommentID != nil {
+			t.Errorf("Unexpected comment: %+v", top.AddComment)
+		}
+		if *reply.AddComment.ParentCommentID != top.AddComment.ID || len(reply.AddComment.Mentions) != 1 || reply.AddComment.Mentions[0].Name != "alice" {
+			t.Errorf("Unexpected reply: %+v", reply.AddComment)
+		}
+		fails(t, s, aliceToken, addComment, map[string]interface{}{"postID": closed.AddPost.ID, "content": "Hi"}, "not allowed")
+		fails(t, s, aliceToken, addComment, map[string]interface{}{"postID": postID, "parentID": "missing", "content": "Hi"}, "parent")
+		fails(t, s, aliceToken, addComment, map[string]interface{}{"postID": postID, "content": ""}, "empty")
+	})
+
+	var external struct{ AddCommentToExternal comment }
+	addCommentToExternal := `mutation($id: ID!) { addCommentToExternal(namespace: "blog", externalID: $id, content: "External") { ` + commentFields + ` } }`
+
+	t.Run("addCommentToExternal", func(t *testing.T) {
+		do(t, s, bobToken, addCommentToExternal, map[string]interface{}{"id": "page-1"}, &external)
+		if external.AddCommentToExternal.Content != "External" || external.AddCommentToExternal.PostID == "" {
+			t.Errorf("Unexpected comment: %+v", external.AddCommentToExternal)
+		}
+		fails(t, s, bobToken, addCommentToExternal, map[string]interface{}{"id": ""}, "externalID is empty")
+	})
+
+	// Еще 10 комментариев верхнего уровня занимают вторую страницу
+	for i := 0; i < storage.CommentsPageSize; i++ {
+		if _, err := storage.DataBase.AddComment(ctx, "", postID, "", fmt.Sprintf("Comment %d", i), types.CommentPublished); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	t.Run("getPosts", func(t *testing.T) {
+		var result struct{ GetPosts []post }
+		do(t, s, "", `{ getPosts { id title allowComments } }`, nil, &result)
+		if len(result.GetPosts) != 2 || result.GetPosts[0].ID != postID || result.GetPosts[1].ID != closed.AddPost.ID {
+			t.Errorf("Expected posts without threads in creation order, got %+v", result.GetPosts)
+		}
+	})
+
+	t.Run("getPostByID", func(t *testing.T) {
+		var result struct{ GetPostByID *post }
+		do(t, s, "", `query($id: ID!) { getPostByID(id: $id) { id title comments } }`, map[string]interface{}{"id": postID}, &result)
+		if result.GetPostByID == nil || len(result.GetPostByID.Comments) != storage.CommentsPageSize+1 || result.GetPostByID.Comments[0] != top.AddComment.ID {
+			t.Errorf("Unexpected post: %+v", result.GetPostByID)
+		}
+		fails(t, s, "", `{ getPostByID(id: "missing") { id } }`, nil, "not found")
+	})
+
+	t.Run("threadByExternalID", func(t *testing.T) {
+		var result struct {
+			ThreadByExternalID *struct {
+				ID         string
+				Namespace  string
+				ExternalID string
+				Comments   []string
+				Post       *post
+			}
+		}
+		do(t, s, "", `{ threadByExternalID(namespace: "blog", id: "page-1") { id namespace externalID comments post { id } } }`, nil, &result)
+		thread := result.ThreadByExternalID
+		if thread == nil || thread.ID != external.AddCommentToExternal.PostID || thread.Namespace != "blog" || thread.ExternalID != "page-1" || len(thread.Comments) != 1 || thread.Post != nil {
+			t.Errorf("Unexpected thread: %+v", thread)
+		}
+
+		do(t, s, "", `{ threadByExternalID(namespace: "blog", id: "page-2") { id } }`, nil, &result)
+		if result.ThreadByExternalID != nil {
+			t.Errorf("Expected no thread, got %+v", result.ThreadByExternalID)
+		}
+	})
+
+	t.Run("getComments", func(t *testing.T) {
+		var result struct{ GetComments []comment }
+		query := `query($postID: ID!, $page: Int) { getComments(postID: $postID, page: $page) { id content } }`
+
+		do(t, s, "", query, map[string]interface{}{"postID": postID}, &result)
+		if len(result.GetComments) != storage.CommentsPageSize || result.GetComments[0].ID != top.AddComment.ID {
+			t.Errorf("Expected the first page by default, got %+v", result.GetComments)
+		}
+		do(t, s, "", query, map[string]interface{}{"postID": postID, "page": 2}, &result)
+		if len(result.GetComments) != 1 || result.GetComments[0].Content != fmt.Sprintf("Comment %d", storage.CommentsPageSize-1) {
+			t.Errorf("Unexpected second page: %+v", result.GetComments)
+		}
+		do(t, s, "", query, map[string]interface{}{"postID": postID, "page": 3}, &result)
+		if len(result.GetComments) != 0 {
+			t.Errorf("Expected an empty page, got %+v", result.GetComments)
+		}
+		fails(t, s, "", query, map[string]interface{}{"postID": "missing"}, "post not found")
+	})
+
+	t.Run("getNumberOfCommentPages", func(t *testing.T) {
+		var result struct{ GetNumberOfCommentPages int }
+		query := `query($postID: ID!) { getNumberOfCommentPages(postID: $postID) }`
+
+		do(t, s, "", query, map[string]interface{}{"postID": postID}, &result)
+		if result.GetNumberOfCommentPages != 2 {
+			t.Errorf("Expected 2 pages, got %d", result.GetNumberOfCommentPages)
+		}
+		do(t, s, "", query, map[string]interface{}{"postID": closed.AddPost.ID}, &result)
+		if result.GetNumberOfCommentPages != 0 {
+			t.Errorf("Expected 0 pages, got %d", result.GetNumberOfCommentPages)
+		}
+	})
+
+	t.Run("getCommentByID", func(t *testing.T) {
+		var result struct{ GetCommentByID *comment }
+		do(t, s, "", `query($id: ID!) { getCommentByID(id: $id) { `+commentFields+` } }`, map[string]interface{}{"id": top.AddComment.ID}, &result)
+		if result.GetCommentByID == nil || len(result.GetCommentByID.Replies) != 1 || result.GetCommentByID.Replies[0] != reply.AddComment.ID {
+			t.Errorf("Unexpected comment: %+v", result.GetCommentByID)
+		}
+		fails(t, s, "", `{ getCommentByID(id: "missing") { id } }`, nil, "not found")
+	})
+
+	t.Run("getReplies", func(t *testing.T) {
+		var result struct{ GetReplies []comment }
+		do(t, s, "", `query($id: ID!) { getReplies(commentID: $id) { id content } }`, map[string]interface{}{"id": top.AddComment.ID}, &result)
+		if len(result.GetReplies) != 1 || result.GetReplies[0].ID != reply.AddComment.ID {
+			t.Errorf("Unexpected replies: %+v", result.GetReplies)
+		}
+	})
+
+	t.Run("commentsMentioning", func(t *testing.T) {
+		var result struct{ CommentsMentioning []comment }
+		do(t, s, "", `query($id: ID!) { commentsMentioning(userID: $id, first: 5) { id } }`, map[string]interface{}{"id": alice.RegisterUser.User.ID}, &result)
+		if len(result.CommentsMentioning) != 1 || result.CommentsMentioning[0].ID != reply.AddComment.ID {
+			t.Errorf("Unexpected comments: %+v", result.CommentsMentioning)
+		}
+	})
+
+	var notifications struct {
+		Notifications struct {
+			Edges []struct {
+				Cursor string
+				Node   struct {
+					ID        string
+					Type      string
+					ActorID   *string
+					CommentID string
+					Read      bool
+				}
+			}
+			PageInfo pageInfo
+		}
+		UnreadNotificationCount int
+	}
+	notificationsQuery := `{ notifications(first: 10) { edges { cursor node { id type actorID commentID read } } pageInfo { endCursor hasNextPage } } unreadNotificationCount }`
+
+	t.Run("notifications", func(t *testing.T) {
+		do(t, s, aliceToken, notificationsQuery, nil, &notifications)
+		edges := notifications.Notifications.Edges
+		if len(edges) != 1 || edges[0].Node.Type != "REPLY" || edges[0].Node.CommentID != reply.AddComment.ID || edges[0].Node.Read {
+			t.Errorf("Unexpected notifications: %+v", edges)
+		}
+		if notifications.UnreadNotificationCount != 1 || notifications.Notifications.PageInfo.HasNextPage {
+			t.Errorf("Unexpected count %d or page info %+v", notifications.UnreadNotificationCount, notifications.Notifications.PageInfo)
+		}
+		fails(t, s, "", `{ unreadNotificationCount }`, nil, "authentication required")
+	})
+
+	t.Run("markNotificationsRead", func(t *testing.T) {
+		var result struct{ MarkNotificationsRead int }
+		ids := []string{notifications.Notifications.Edges[0].Node.ID}
+		do(t, s, aliceToken, `mutation($ids: [ID!]!) { markNotificationsRead(ids: $ids) }`, map[string]interface{}{"ids": ids}, &result)
+		if result.MarkNotificationsRead != 1 {
+			t.Errorf("Expected 1 notification marked, got %d", result.MarkNotificationsRead)
+		}
+		do(t, s, aliceToken, notificationsQuery, nil, &notifications)
+		if notifications.UnreadNotificationCount != 0 || !notifications.Notifications.Edges[0].Node.Read {
+			t.Errorf("Expected read notifications, got %+v", notifications)
+		}
+	})
+
+	t.Run("reportComment", func(t *testing.T) {
+		var result struct{ ReportComment bool }
+		query := `mutation($id: ID!) { reportComment(id: $id, reason: "rude") }`
+		do(t, s, aliceToken, query, map[string]interface{}{"id": reply.AddComment.ID}, &result)
+		if !result.ReportComment {
+			t.Errorf("Expected true, got false")
+		}
+		fails(t, s, bobToken, query, map[string]interface{}{"id": reply.AddComment.ID}, "own comment")
+		fails(t, s, "", query, map[string]interface{}{"id": reply.AddComment.ID}, "authentication required")
+	})
+
+	t.Run("moderationQueue", func(t *testing.T) {
+		var result struct {
+			ModerationQueue struct {
+				Edges []struct {
+					Node struct {
+						Comment comment
+						Reports int
+						Reasons []string
+					}
+				}
+				PageInfo pageInfo
+			}
+		}
+		query := `{ moderationQueue(status: FLAGGED, first: 10) { edges { node { comment { id } reports reasons } } pageInfo { hasNextPage } } }`
+		do(t, s, adminToken, query, nil, &result)
+		edges := result.ModerationQueue.Edges
+		if len(edges) != 1 || edges[0].Node.Comment.ID != reply.AddComment.ID || edges[0].Node.Reports != 1 || edges[0].Node.Reasons[0] != "rude" {
+			t.Errorf("Unexpected queue: %+v", edges)
+		}
+		fails(t, s, aliceToken, query, nil, "forbidden")
+	})
+
+	t.Run("rejectComment", func(t *testing.T) {
+		var result struct{ RejectComment comment }
+		do(t, s, adminToken, `mutation($id: ID!) { rejectComment(id: $id, reason: "rude") { id status } }`, map[string]interface{}{"id": reply.AddComment.ID}, &result)
+		if result.RejectComment.Status != "REJECTED" {
+			t.Errorf("Expected REJECTED, got %s", result.RejectComment.Status)
+		}
+		fails(t, s, bobToken, `mutation($id: ID!) { rejectComment(id: $id) { id } }`, map[string]interface{}{"id": top.AddComment.ID}, "forbidden")
+	})
+
+	t.Run("approveComment", func(t *testing.T) {
+		var result struct{ ApproveComment comment }
+		do(t, s, adminToken, `mutation($id: ID!) { approveComment(id: $id) { id status } }`, map[string]interface{}{"id": reply.AddComment.ID}, &result)
+		if result.ApproveComment.Status != "PUBLISHED" {
+			t.Errorf("Expected PUBLISHED, got %s", result.ApproveComment.Status)
+		}
+	})
+
+	t.Run("auditLog", func(t *testing.T) {
+		var result struct {
+			AuditLog struct {
+				Edges []struct {
+					Node struct {
+						Actor    string
+						Action   string
+						TargetID string
+					}
+				}
+			}
+		}
+		query := `query($action: AuditAction) { auditLog(filter: { action: $action }, first: 10) { edges { node { actor action targetID } } } }`
+		do(t, s, adminToken, query, nil, &result)
+		actions := make([]string, 0)
+		for _, edge := range result.AuditLog.Edges {
+			actions = append(actions, edge.Node.Action)
+		}
+		if !slices.Equal(actions, []string{"APPROVE", "REJECT", "REPORT"}) {
+			t.Errorf("Expected newest entries first, got %v", actions)
+		}
+		do(t, s, adminToken, query, map[string]interface{}{"action": "REPORT"}, &result)
+		if len(result.AuditLog.Edges) != 1 || result.AuditLog.Edges[0].Node.Actor != alice.RegisterUser.User.ID {
+			t.Errorf("Unexpected entries: %+v", result.AuditLog.Edges)
+		}
+		fails(t, s, "", query, nil, "forbidden")
+	})
+
+	var deliveries struct {
+		WebhookDeliveries []struct {
+			ID        string
+			EventType string
+			Status    string
+			Attempts  int
+		}
+	}
+
+	t.Run("webhooks", func(t *testing.T) {
+		var result struct {
+			Webhooks []struct{ ID string }
+		}
+		do(t, s, adminToken, `{ webhooks { id } }`, nil, &result)
+		if len(result.Webhooks) != 1 || result.Webhooks[0].ID != hook.RegisterWebhook.ID {
+			t.Errorf("Unexpected webhooks: %+v", result.Webhooks)
+		}
+	})
+
+	t.Run("webhookDeliveries", func(t *testing.T) {
+		query := `query($id: ID!) { webhookDeliveries(webhookID: $id, status: PENDING, first: 50) { id eventType status attempts } }`
+		do(t, s, adminToken, query, map[string]interface{}{"id": hook.RegisterWebhook.ID}, &deliveries)
+		// Два поста, три комментария через мутации и одобренный повторно комментарий
+		if len(deliveries.WebhookDeliveries) != 6 || deliveries.WebhookDeliveries[0].Status != "PENDING" {
+			t.Errorf("Unexpected deliveries: %+v", deliveries.WebhookDeliveries)
+		}
+		fails(t, s, adminToken, query, map[string]interface{}{"id": "missing"}, "not found")
+	})
+
+	t.Run("redeliverWebhookDelivery", func(t *testing.T) {
+		var result struct {
+			RedeliverWebhookDelivery struct {
+				ID     string
+				Status string
+			}
+		}
+		id := deliveries.WebhookDeliveries[0].ID
+		do(t, s, adminToken, `mutation($id: ID!) { redeliverWebhookDelivery(id: $id) { id status } }`, map[string]interface{}{"id": id}, &result)
+		if result.RedeliverWebhookDelivery.ID != id || result.RedeliverWebhookDelivery.Status != "PENDING" {
+			t.Errorf("Unexpected delivery: %+v", result.RedeliverWebhookDelivery)
+		}
+	})
+
+	t.Run("deleteWebhook", func(t *testing.T) {
+		var result struct{ DeleteWebhook bool }
+		do(t, s, adminToken, `mutation($id: ID!) { deleteWebhook(id: $id) }`, map[string]interface{}{"id": hook.RegisterWebhook.ID}, &result)
+		if !result.DeleteWebhook {
+			t.Errorf("Expected true, got false")
+		}
+		var webhooks struct{ Webhooks []struct{ ID string } }
+		do(t, s, adminToken, `{ webhooks { id } }`, nil, &webhooks)
+		if len(webhooks.Webhooks) != 0 {
+			t.Errorf("Expected no webhooks, got %+v", webhooks.Webhooks)
+		}
+	})
+
+	var export struct{ ExportDump string }
+
+	t.Run("exportDump", func(t *testing.T) {
+		do(t, s, adminToken, `{ exportDump }`, nil, &export)
+		if lines := strings.Count(export.ExportDump, "\n"); lines < 2+3+4+storage.CommentsPageSize {
+			t.Errorf("Expected header, users, posts and comments, got %d lines", lines)
+		}
+		fails(t, s, aliceToken, `{ exportDump }`, nil, "forbidden")
+	})
+
+	t.Run("importDump", func(t *testing.T) {
+		var result struct {
+			ImportDump struct {
+				Users    int
+				Posts    int
+				Comments int
+				Existing int
+			}
+		}
+		query := `mutation($data: String!) { importDump(data: $data) { users posts comments existing } }`
+		do(t, s, adminToken, query, map[string]interface{}{"data": export.ExportDump}, &result)
+		if result.ImportDump.Users+result.ImportDump.Posts+result.ImportDump.Comments != 0 || result.ImportDump.Existing == 0 {
+			t.Errorf("Expected only existing records, got %+v", result.ImportDump)
+		}
+		fails(t, s, adminToken, `mutation { importDump(data: "not json", onConflict: FAIL) { users } }`, nil, "invalid record")
+	})
+}
@@ -0,0 +1,40 @@
+// Package gqltest содержит общие помощники тестов, выполняющих запросы к схеме GraphQL
+package gqltest
+
+import (
+	"context"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+// NewSchema заменяет storage.DataBase пустым хранилищем in-memory и создает схему
+// с запросами, мутациями и подписками
+func NewSchema(t *testing.T) graphql.Schema {
+	t.Helper()
+	storage.DataBase = inMemory.NewInMemoryStore()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        gql.QueryType,
+		Mutation:     gql.MutationType,
+		Subscription: gql.SubscriptionType,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return schema
+}
+
+// Do выполняет документ query и возвращает данные ответа, завершая тест при ошибках
+func Do(t *testing.T, schema graphql.Schema, ctx context.Context, query string, variables map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: query, VariableValues: variables, Context: ctx})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+	return result.Data.(map[string]interface{})
+}
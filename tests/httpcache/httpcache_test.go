@@ -0,0 +1,113 @@
+package httpcache_test
+
+import (
+	"context"
+	"graphql-comments/graphql"
+	"graphql-comments/graphql/httpcache"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/handler"
+)
+
+func newHandler(t *testing.T) http.Handler {
+	t.Helper()
+	storage.DataBase = inMemory.NewInMemoryStore()
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return httpcache.Middleware(10 * time.Second)(handler.New(&handler.Config{Schema: &schema}))
+}
+
+func get(h http.Handler, query string, header http.Header) *httptest.ResponseRecorder {
+	return getValues(h, url.Values{"query": {query}}, header)
+}
+
+func getValues(h http.Handler, values url.Values, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/graphql?"+values.Encode(), nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	h := newHandler(t)
+
+	w := get(h, `{ getPosts { id } }`, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=10" {
+		t.Fatalf("Unexpected response %d, headers %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Header().Get("Vary"), "Authorization") || w.Body.String() != `{"data":{"getPosts":[]}}` {
+		t.Errorf("Unexpected response %v %s", w.Header(), w.Body)
+	}
+
+	t.Run("NotModified", func(t *testing.T) {
+		w := get(h, `{ getPosts { id } }`, http.Header{"If-None-Match": {`"other", ` + etag}})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+			t.Errorf("Unexpected response %d %v %s", w.Code, w.Header(), w.Body)
+		}
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		if _, err := gql.AddPost(context.Background(), "title", "content", true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w := get(h, `{ getPosts { id } }`, http.Header{"If-None-Match": {etag}})
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+			t.Errorf("Unexpected response %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Authorized", func(t *testing.T) {
+		w := get(h, `{ getPosts { id } }`, http.Header{"Authorization": {"Bearer token"}})
+		if w.Code != http.StatusOK || w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "private, no-store" {
+			t.Errorf("Unexpected response %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		w := get(h, `{ getPostByID(id: "missing") { id } }`, nil)
+		if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" || !strings.Contains(w.Body.String(), "errors") {
+			t.Errorf("Unexpected response %v %s", w.Header(), w.Body)
+		}
+	})
+
+	t.Run("Mutation", func(t *testing.T) {
+		w := get(h, `mutation { addPost(title: "title", content: "content") { id } }`, nil)
+		if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+			t.Errorf("Unexpected headers %v", w.Header())
+		}
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ getPosts { id } }"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+			t.Errorf("Unexpected response %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("OperationName", func(t *testing.T) {
+		query := `query posts { getPosts { id } } mutation add { addPost(title: "t", content: "c") { id } }`
+		if w := getValues(h, url.Values{"query": {query}, "operationName": {"posts"}}, nil); w.Header().Get("ETag") == "" {
+			t.Errorf("Expected ETag for query operation, got %v", w.Header())
+		}
+		if w := getValues(h, url.Values{"query": {query}, "operationName": {"add"}}, nil); w.Header().Get("ETag") != "" {
+			t.Errorf("Unexpected ETag for mutation operation")
+		}
+	})
+}
@@ -0,0 +1,397 @@
+package importers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"graphql-comments/commentsctl"
+	"graphql-comments/dump"
+	"graphql-comments/importers"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+const disqusExport = `<?xml version="1.0" encoding="utf-8"?>
+<disqus xmlns="http://disqus.com" xmlns:dsq="http://disqus.com/disqus-internals">
+  <category dsq:id="1">
+    <forum>blog</forum>
+    <title>General</title>
+    <isDefault>true</isDefault>
+  </category>
+  <thread dsq:id="100">
+    <id>article-1</id>
+    <forum>blog</forum>
+    <category dsq:id="1"/>
+    <link>https://blog.example.com/article-1</link>
+    <title>First article</title>
+    <message/>
+    <createdAt>2015-03-01T10:00:00Z</createdAt>
+    <author><name>Editor</name><isAnonymous>false</isAnonymous><username>editor</username></author>
+    <isClosed>true</isClosed>
+    <isDeleted>false</isDeleted>
+  </thread>
+  <thread dsq:id="101">
+    <id/>
+    <link>https://blog.example.com/article-2</link>
+    <title>Second article</title>
+    <createdAt>2015-03-02T10:00:00Z</createdAt>
+    <isClosed>false</isClosed>
+    <isDeleted>false</isDeleted>
+  </thread>
+  <thread dsq:id="102">
+    <link>https://blog.example.com/deleted</link>
+    <title>Deleted</title>
+    <createdAt>2015-03-03T10:00:00Z</createdAt>
+    <isDeleted>true</isDeleted>
+  </thread>
+  <post dsq:id="201">
+    <message><![CDATA[<p>Great <b>post</b>!</p><p>See <a href="https://example.com" rel="nofollow">this</a></p>]]></message>
+    <createdAt>2015-03-01T11:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>John Smith</name><isAnonymous>false</isAnonymous><username>john</username></author>
+    <thread dsq:id="100"/>
+  </post>
+  <post dsq:id="203">
+    <message><![CDATA[<p>Reply to a deleted comment</p>]]></message>
+    <createdAt>2015-03-01T13:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Jane</name><isAnonymous>true</isAnonymous></author>
+    <thread dsq:id="100"/>
+    <parent dsq:id="202"/>
+  </post>
+  <post dsq:id="202">
+    <message><![CDATA[<p>removed</p>]]></message>
+    <createdAt>2015-03-01T12:00:00Z</createdAt>
+    <isDeleted>true</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Troll</name></author>
+    <thread dsq:id="100"/>
+    <parent dsq:id="201"/>
+  </post>
+  <post dsq:id="204">
+    <message><![CDATA[<p>Buy now</p>]]></message>
+    <createdAt>2015-03-01T14:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>true</isSpam>
+    <author><name>Spammer</name></author>
+    <thread dsq:id="100"/>
+  </post>
+  <post dsq:id="205">
+    <message><![CDATA[<p>Awaiting approval</p>]]></message>
+    <createdAt>2015-03-01T15:00:00Z</createdAt>
+    <isApproved>false</isApproved>
+    <author><name>john smith</name></author>
+    <thread dsq:id="100"/>
+  </post>
+  <post dsq:id="206">
+    <message>On a deleted thread</message>
+    <createdAt>2015-03-03T11:00:00Z</createdAt>
+    <author><name>Jane</name></author>
+    <thread dsq:id="102"/>
+  </post>
+  <post dsq:id="207">
+    <message>Unknown thread</message>
+    <createdAt>2015-03-04T11:00:00Z</createdAt>
+    <author><name>Jane</name></author>
+    <thread dsq:id="999"/>
+  </post>
+  <post dsq:id="208">
+    <message>Unknown parent</message>
+    <createdAt>2015-03-04T12:00:00Z</createdAt>
+    <author><name>Jane</name></author>
+    <thread dsq:id="100"/>
+    <parent dsq:id="998"/>
+  </post>
+</disqus>`
+
+const wordpressExport = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:excerpt="http://wordpress.org/export/1.2/excerpt/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wfw="http://wellformedweb.org/CommentAPI/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+	<title>Blog</title>
+	<link>https://blog.example.com</link>
+	<wp:wxr_version>1.2</wp:wxr_version>
+	<wp:author><wp:author_id>1</wp:author_id><wp:author_login><![CDATA[admin]]></wp:author_login></wp:author>
+	<item>
+		<title>Hello world</title>
+		<link>https://blog.example.com/hello-world/</link>
+		<dc:creator><![CDATA[admin]]></dc:creator>
+		<content:encoded><![CDATA[<p>Welcome to the blog.</p>]]></content:encoded>
+		<excerpt:encoded><![CDATA[]]></excerpt:encoded>
+		<wp:post_id>1</wp:post_id>
+		<wp:post_date><![CDATA[2020-01-01 12:00:00]]></wp:post_date>
+		<wp:post_date_gmt><![CDATA[2020-01-01 09:00:00]]></wp:post_date_gmt>
+		<wp:comment_status><![CDATA[open]]></wp:comment_status>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+		<wp:comment>
+			<wp:comment_id>10</wp:comment_id>
+			<wp:comment_author><![CDATA[Alice]]></wp:comment_author>
+			<wp:comment_date><![CDATA[2020-01-02 12:00:00]]></wp:comment_date>
+			<wp:comment_date_gmt><![CDATA[2020-01-02 09:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[First!
+Second line]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_type><![CDATA[comment]]></wp:comment_type>
+			<wp:comment_parent>0</wp:comment_parent>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>11</wp:comment_id>
+			<wp:comment_author><![CDATA[admin]]></wp:comment_author>
+			<wp:comment_date><![CDATA[2020-01-02 13:00:00]]></wp:comment_date>
+			<wp:comment_date_gmt><![CDATA[0000-00-00 00:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Thanks]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[0]]></wp:comment_approved>
+			<wp:comment_type><![CDATA[]]></wp:comment_type>
+			<wp:comment_parent>10</wp:comment_parent>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>12</wp:comment_id>
+			<wp:comment_author><![CDATA[Other blog]]></wp:comment_author>
+			<wp:comment_date_gmt><![CDATA[2020-01-03 09:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Linked]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[1]]></wp:comment_approved>
+			<wp:comment_type><![CDATA[pingback]]></wp:comment_type>
+			<wp:comment_parent>0</wp:comment_parent>
+		</wp:comment>
+		<wp:comment>
+			<wp:comment_id>13</wp:comment_id>
+			<wp:comment_author><![CDATA[Bot]]></wp:comment_author>
+			<wp:comment_date_gmt><![CDATA[2020-01-03 10:00:00]]></wp:comment_date_gmt>
+			<wp:comment_content><![CDATA[Spam]]></wp:comment_content>
+			<wp:comment_approved><![CDATA[spam]]></wp:comment_approved>
+			<wp:comment_parent>0</wp:comment_parent>
+		</wp:comment>
+	</item>
+	<item>
+		<title>Draft</title>
+		<wp:post_id>2</wp:post_id>
+		<wp:post_date_gmt><![CDATA[2020-02-01 09:00:00]]></wp:post_date_gmt>
+		<wp:status><![CDATA[draft]]></wp:status>
+		<wp:post_type><![CDATA[post]]></wp:post_type>
+	</item>
+	<item>
+		<title>About</title>
+		<link>https://blog.example.com/about/</link>
+		<wp:post_id>3</wp:post_id>
+		<wp:post_date_gmt><![CDATA[2020-03-01 09:00:00]]></wp:post_date_gmt>
+		<wp:comment_status><![CDATA[closed]]></wp:comment_status>
+		<wp:status><![CDATA[publish]]></wp:status>
+		<wp:post_type><![CDATA[page]]></wp:post_type>
+	</item>
+</channel>
+</rss>`
+
+func newStore() *inMemory.DataStoreInMemory {
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+	return store
+}
+
+func TestDisqus(t *testing.T) {
+	ctx := context.Background()
+	data, err := importers.ParseDisqus(strings.NewReader(disqusExport), importers.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		report := data.Report()
+		if report.Posts != 1 || report.Comments != 3 || report.Users != 3 || report.Orphaned != 2 || report.Reparented != 1 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+		expected := map[string]int{importers.SkipDeleted: 3, importers.SkipSpam: 1, importers.SkipEmpty: 1}
+		for reason, count := range expected {
+			if report.Skipped[reason] != count {
+				t.Errorf("Expected %d skipped as %s, got %v", count, reason, report.Skipped)
+			}
+		}
+		if report.Result != nil {
+			t.Errorf("Expected no import result in dry run")
+		}
+	})
+
+	store := newStore()
+	report, err := data.Import(ctx, &dump.Importer{Store: store})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *report.Result != (dump.Result{Users: 3, Posts: 1, Comments: 3}) {
+		t.Errorf("Unexpected import result: %+v", report.Result)
+	}
+
+	t.Run("Tree", func(t *testing.T) {
+		posts, _ := store.GetPosts(ctx)
+		if len(posts) != 1 {
+			t.Fatalf("Expected 1 post, got %d", len(posts))
+		}
+		post := posts[0]
+		if post.Title != "First article" || post.AllowComments || !post.CreatedAt.Equal(time.Date(2015, 3, 1, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected post: %+v", post)
+		}
+		if len(post.Comments) != 1 {
+			t.Fatalf("Expected 1 top-level published comment, got %v", post.Comments)
+		}
+
+		root, _ := store.GetCommentByID(ctx, post.Comments[0])
+		if root.Content != "Great **post**!\n\nSee [this](https://example.com)" {
+			t.Errorf("Unexpected content %q", root.Content)
+		}
+		author, err := store.GetUserByID(ctx, root.AuthorID)
+		if err != nil || author.Name != "John_Smith" {
+			t.Errorf("Unexpected author: %+v, %v", author, err)
+		}
+
+		// Ответ на удаленный комментарий прикреплен к его родителю
+		if len(root.Replies) != 1 {
+			t.Fatalf("Expected 1 reply, got %v", root.Replies)
+		}
+		reply, _ := store.GetCommentByID(ctx, root.Replies[0])
+		if reply.Content != "Reply to a deleted comment" || !reply.CreatedAt.Equal(time.Date(2015, 3, 1, 13, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected reply: %+v", reply)
+		}
+
+		comments, _ := store.ListComments(ctx, storage.CommentFilter{})
+		pending := 0
+		for _, comment := range comments {
+			if comment.Status == types.CommentPending {
+				pending++
+				if comment.AuthorID != root.AuthorID {
+					t.Errorf("Expected authors with the same name to be merged")
+				}
+			}
+		}
+		if pending != 1 {
+			t.Errorf("Expected 1 pending comment, got %d", pending)
+		}
+	})
+
+	t.Run("Repeat", func(t *testing.T) {
+		report, err := data.Import(ctx, &dump.Importer{Store: store, OnConflict: dump.ConflictFail})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *report.Result != (dump.Result{Existing: 7}) {
+			t.Errorf("Unexpected import result: %+v", report.Result)
+		}
+	})
+
+	t.Run("Threads", func(t *testing.T) {
+		data, err := importers.ParseDisqus(strings.NewReader(disqusExport), importers.Options{Namespace: "blog"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		store := newStore()
+		if _, err := data.Import(ctx, &dump.Importer{Store: store}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		thread, err := store.GetThread(ctx, "blog", "article-1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if thread.Title != "" || thread.AuthorID != "" || len(thread.Comments) != 1 {
+			t.Errorf("Unexpected thread: %+v", thread)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := importers.ParseDisqus(strings.NewReader("<disqus><thread>"), importers.Options{}); err == nil {
+			t.Errorf("Expected error for truncated export")
+		}
+	})
+}
+
+func TestWordPress(t *testing.T) {
+	ctx := context.Background()
+	data, err := importers.ParseWordPress(strings.NewReader(wordpressExport), importers.Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	report := data.Report()
+	if report.Posts != 1 || report.Comments != 2 || report.Users != 2 || report.Orphaned != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	expected := map[string]int{importers.SkipPingback: 1, importers.SkipSpam: 1, importers.SkipUnpublished: 1, importers.SkipEmpty: 1}
+	for reason, count := range expected {
+		if report.Skipped[reason] != count {
+			t.Errorf("Expected %d skipped as %s, got %v", count, reason, report.Skipped)
+		}
+	}
+
+	store := newStore()
+	if _, err := data.Import(ctx, &dump.Importer{Store: store}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	posts, _ := store.GetPosts(ctx)
+	if len(posts) != 1 {
+		t.Fatalf("Expected 1 post, got %d", len(posts))
+	}
+	post := posts[0]
+	if post.Title != "Hello world" || post.Content != "Welcome to the blog." || !post.AllowComments || !post.CreatedAt.Equal(time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected post: %+v", post)
+	}
+	if author, err := store.GetUserByID(ctx, post.AuthorID); err != nil || author.Name != "admin" {
+		t.Errorf("Unexpected post author: %+v, %v", author, err)
+	}
+
+	root, _ := store.GetCommentByID(ctx, post.Comments[0])
+	if root.Content != "First!\nSecond line" || !root.CreatedAt.Equal(time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected comment: %+v", root)
+	}
+	comments, _ := store.ListComments(ctx, storage.CommentFilter{PostID: post.ID})
+	if len(comments) != 2 || comments[1].ParentCommentID != root.ID || comments[1].Status != types.CommentPending {
+		t.Errorf("Unexpected comments: %+v", comments)
+	}
+	// Без времени в UTC используется местное время сайта
+	if !comments[1].CreatedAt.Equal(time.Date(2020, 1, 2, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected reply time %v", comments[1].CreatedAt)
+	}
+	if comments[1].AuthorID != post.AuthorID {
+		t.Errorf("Expected the post author to be reused for the reply")
+	}
+}
+
+func TestCLI(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store := newStore()
+	stdout := &bytes.Buffer{}
+
+	cli := &commentsctl.CLI{Store: store, Tenants: registry, Stdin: strings.NewReader(wordpressExport), Stdout: stdout}
+	if err := cli.Run(context.Background(), []string{"-o", "json", "import-wordpress", "-dry-run"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var report importers.Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil || report.Comments != 2 || report.Result != nil {
+		t.Errorf("Unexpected dry run output %q: %v", stdout.String(), err)
+	}
+	if posts, _ := store.GetPosts(context.Background()); len(posts) != 0 {
+		t.Errorf("Expected dry run not to write, got %d posts", len(posts))
+	}
+
+	stdout.Reset()
+	cli.Stdin = strings.NewReader(disqusExport)
+	if err := cli.Run(context.Background(), []string{"import-disqus", "-namespace", "blog"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "skipped deleted") || !strings.Contains(stdout.String(), "imported:") {
+		t.Errorf("Unexpected output %q", stdout.String())
+	}
+	if _, err := store.GetThread(context.Background(), "blog", "article-1"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -1,18 +1,23 @@
 package inMemory_test
 
 import (
+	"context"
+	"errors"
 	"graphql-comments/storage"
 	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
 	"testing"
 	_ "time"
 )
 
 func TestAddPost(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("AddPost", func(t *testing.T) {
-		post, err := store.AddPost("Test Title", "Test Content", true)
+		post, err := store.AddPost(ctx, "", "Test Title", "Test Content", true)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -21,7 +26,7 @@ func TestAddPost(t *testing.T) {
 			t.Errorf("Post fields do not match the input")
 		}
 
-		if _, ok := store.Posts[post.ID]; !ok {
+		if _, ok := store.Tenants[tenant.DefaultID].Posts[post.ID]; !ok {
 			t.Errorf("Post was not added to the store")
 		}
 	})
@@ -29,12 +34,13 @@ func TestAddPost(t *testing.T) {
 
 func TestGetPostByID(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
-	post, _ := store.AddPost("Test Title", "Test Content", true)
+	post, _ := store.AddPost(ctx, "", "Test Title", "Test Content", true)
 
 	t.Run("GetPostByID", func(t *testing.T) {
-		retrievedPost, err := store.GetPostByID(post.ID)
+		retrievedPost, err := store.GetPostByID(ctx, post.ID)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -45,7 +51,7 @@ func TestGetPostByID(t *testing.T) {
 	})
 
 	t.Run("GetPostByIDWithNonexistentID", func(t *testing.T) {
-		_, err := store.GetPostByID("nonexistent-id")
+		_, err := store.GetPostByID(ctx, "nonexistent-id")
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
@@ -54,12 +60,13 @@ func TestGetPostByID(t *testing.T) {
 
 func TestAddComment(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
-	post, _ := store.AddPost("Test Title", "Test Content", true)
+	post, _ := store.AddPost(ctx, "", "Test Title", "Test Content", true)
 
 	t.Run("AddComment", func(t *testing.T) {
-		comment, err := store.AddComment(post.ID, "", "Test Comment")
+		comment, err := store.AddComment(ctx, "", post.ID, "", "Test Comment", types.CommentPublished)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -68,25 +75,47 @@ func TestAddComment(t *testing.T) {
 			t.Errorf("Comment content does not match the input")
 		}
 
-		if _, ok := store.Comments[comment.ID]; !ok {
+		if _, ok := store.Tenants[tenant.DefaultID].Comments[comment.ID]; !ok {
 			t.Errorf("Comment was not added to the store")
 		}
 	})
 
 	t.Run("AddCommentWithNonexistentPostID", func(t *testing.T) {
-		_, err := store.AddComment("nonexistent-id", "", "Test Comment")
+		_, err := store.AddComment(ctx, "", "nonexistent-id", "", "Test Comment", types.CommentPublished)
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
 	})
+
+	t.Run("ReplyToCommentOfAnotherPost", func(t *testing.T) {
+		parent, _ := store.AddComment(ctx, "", post.ID, "", "Parent", types.CommentPublished)
+		other, _ := store.AddPost(ctx, "", "Other Title", "Other Content", true)
+
+		_, err := store.AddComment(ctx, "", other.ID, parent.ID, "Reply", types.CommentPublished)
+		if !errors.Is(err, storage.ErrParentNotFound) {
+			t.Errorf("Expected ErrParentNotFound, got %v", err)
+		}
+	})
+
+	t.Run("ReplyToCommentOfAnotherTenant", func(t *testing.T) {
+		parent, _ := store.AddComment(ctx, "", post.ID, "", "Parent", types.CommentPublished)
+		otherTenant := tenant.WithTenant(ctx, &tenant.Tenant{ID: "other"})
+		other, _ := store.AddPost(otherTenant, "", "Other Title", "Other Content", true)
+
+		_, err := store.AddComment(otherTenant, "", other.ID, parent.ID, "Reply", types.CommentPublished)
+		if !errors.Is(err, storage.ErrParentNotFound) {
+			t.Errorf("Expected ErrParentNotFound, got %v", err)
+		}
+	})
 }
 
 func TestGetPosts(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("GetPostsReturnsEmptySliceWhenNoPosts", func(t *testing.T) {
-		posts, err := store.GetPosts()
+		posts, err := store.GetPosts(ctx)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -98,10 +127,10 @@ func TestGetPosts(t *testing.T) {
 	})
 
 	t.Run("GetPosts", func(t *testing.T) {
-		post1, _ := store.AddPost("Title 1", "Content 1", true)
-		post2, _ := store.AddPost("Title 2", "Content 2", true)
+		post1, _ := store.AddPost(ctx, "", "Title 1", "Content 1", true)
+		post2, _ := store.AddPost(ctx, "", "Title 2", "Content 2", true)
 
-		posts, err := store.GetPosts()
+		posts, err := store.GetPosts(ctx)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -119,22 +148,23 @@ func TestGetPosts(t *testing.T) {
 
 func TestGetComments(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("GetComments", func(t *testing.T) {
-		post, _ := store.AddPost("Title", "Content", true)
+		post, _ := store.AddPost(ctx, "", "Title", "Content", true)
 
-		_, err := store.AddComment(post.ID, "", "Comment 1")
+		_, err := store.AddComment(ctx, "", post.ID, "", "Comment 1", types.CommentPublished)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
 
-		_, err = store.AddComment(post.ID, "", "Comment 2")
+		_, err = store.AddComment(ctx, "", post.ID, "", "Comment 2", types.CommentPublished)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
 
-		comments, err := store.GetComments(post.ID, 1)
+		comments, err := store.GetComments(ctx, post.ID, 1)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -146,11 +176,11 @@ func TestGetComments(t *testing.T) {
 	})
 
 	t.Run("GetCommentsWhenNoComments", func(t *testing.T) {
-		post, _ := store.AddPost("Title", "Content", true)
-		store.AddComment(post.ID, "", "Comment 1")
-		store.AddComment(post.ID, "", "Comment 2")
+		post, _ := store.AddPost(ctx, "", "Title", "Content", true)
+		store.AddComment(ctx, "", post.ID, "", "Comment 1", types.CommentPublished)
+		store.AddComment(ctx, "", post.ID, "", "Comment 2", types.CommentPublished)
 
-		comments, err := store.GetComments(post.ID, 1)
+		comments, err := store.GetComments(ctx, post.ID, 1)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -162,7 +192,7 @@ func TestGetComments(t *testing.T) {
 	})
 
 	t.Run("GetCommentsWithNonexistentPostID", func(t *testing.T) {
-		_, err := store.GetComments("nonexistent-id", 1)
+		_, err := store.GetComments(ctx, "nonexistent-id", 1)
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
@@ -171,13 +201,14 @@ func TestGetComments(t *testing.T) {
 
 func TestGetCommentByID(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("GetCommentByID", func(t *testing.T) {
-		post, _ := store.AddPost("Title", "Content", true)
-		comment, _ := store.AddComment(post.ID, "", "Comment")
+		post, _ := store.AddPost(ctx, "", "Title", "Content", true)
+		comment, _ := store.AddComment(ctx, "", post.ID, "", "Comment", types.CommentPublished)
 
-		retrievedComment, err := store.GetCommentByID(comment.ID)
+		retrievedComment, err := store.GetCommentByID(ctx, comment.ID)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -188,7 +219,7 @@ func TestGetCommentByID(t *testing.T) {
 	})
 
 	t.Run("GetCommentByIDWithNonexistentID", func(t *testing.T) {
-		_, err := store.GetCommentByID("nonexistent-id")
+		_, err := store.GetCommentByID(ctx, "nonexistent-id")
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
@@ -197,19 +228,20 @@ func TestGetCommentByID(t *testing.T) {
 
 func TestGetNumberOfCommentPages(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("GetNumberOfCommentPages", func(t *testing.T) {
-		post, _ := store.AddPost("Title", "Content", true)
+		post, _ := store.AddPost(ctx, "", "Title", "Content", true)
 
 		for i := 0; i < storage.CommentsPageSize*3; i++ {
-			_, err := store.AddComment(post.ID, "", "Comment")
+			_, err := store.AddComment(ctx, "", post.ID, "", "Comment", types.CommentPublished)
 			if err != nil {
 				t.Errorf("Unexpected error: %v", err)
 			}
 		}
 
-		numPages, err := store.GetNumberOfCommentPages(post.ID)
+		numPages, err := store.GetNumberOfCommentPages(ctx, post.ID)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -221,7 +253,7 @@ func TestGetNumberOfCommentPages(t *testing.T) {
 	})
 
 	t.Run("GetNumberOfCommentPagesWithNonexistentPostID", func(t *testing.T) {
-		_, err := store.GetNumberOfCommentPages("nonexistent-id")
+		_, err := store.GetNumberOfCommentPages(ctx, "nonexistent-id")
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
@@ -230,14 +262,15 @@ func TestGetNumberOfCommentPages(t *testing.T) {
 
 func TestGetReplies(t *testing.T) {
 	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
 	storage.DataBase = store
 
 	t.Run("GetReplies", func(t *testing.T) {
-		post, _ := store.AddPost("Title", "Content", true)
-		comment1, _ := store.AddComment(post.ID, "", "Comment 1")
-		comment2, _ := store.AddComment(post.ID, comment1.ID, "Comment 2")
+		post, _ := store.AddPost(ctx, "", "Title", "Content", true)
+		comment1, _ := store.AddComment(ctx, "", post.ID, "", "Comment 1", types.CommentPublished)
+		comment2, _ := store.AddComment(ctx, "", post.ID, comment1.ID, "Comment 2", types.CommentPublished)
 
-		replies, err := store.GetReplies(comment1.ID)
+		replies, err := store.GetReplies(ctx, comment1.ID)
 
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
@@ -253,9 +286,72 @@ func TestGetReplies(t *testing.T) {
 	})
 
 	t.Run("GetRepliesWithNonexistentCommentID", func(t *testing.T) {
-		_, err := store.GetReplies("nonexistent-id")
+		_, err := store.GetReplies(ctx, "nonexistent-id")
 		if err == nil {
 			t.Errorf("Expected error, got nil")
 		}
 	})
 }
+
+func TestPendingComment(t *testing.T) {
+	store := inMemory.NewInMemoryStore()
+	ctx := context.Background()
+	storage.DataBase = store
+
+	post, _ := store.AddPost(ctx, "", "Title", "Content", true)
+	parent, _ := store.AddComment(ctx, "", post.ID, "", "Parent", types.CommentPublished)
+	pending, err := store.AddComment(ctx, "", post.ID, "", "Pending", types.CommentPending)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.AddComment(ctx, "", post.ID, parent.ID, "Pending reply", types.CommentPending)
+
+	t.Run("HiddenFromLists", func(t *testing.T) {
+		comments, _ := store.GetComments(ctx, post.ID, 1)
+		if len(comments) != 1 || comments[0].ID != parent.ID {
+			t.Errorf("Expected only published comment, got %v", comments)
+		}
+
+		replies, _ := store.GetReplies(ctx, parent.ID)
+		if len(replies) != 0 {
+			t.Errorf("Expected no replies, got %v", replies)
+		}
+	})
+
+	t.Run("GetCommentByID", func(t *testing.T) {
+		comment, err := store.GetCommentByID(ctx, pending.ID)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		} else if comment.Status != types.CommentPending {
+			t.Errorf("Expected pending comment, got %s", comment.Status)
+		}
+	})
+}
+
+func TestCanceledContext(t *testing.T) {
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+
+	post, _ := store.AddPost(context.Background(), "", "Title", "Content", true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("AddCommentWithCanceledContext", func(t *testing.T) {
+		_, err := store.AddComment(ctx, "", post.ID, "", "Comment", types.CommentPublished)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+
+		if len(store.Tenants[tenant.DefaultID].Comments) != 0 {
+			t.Errorf("Comment was added with canceled context")
+		}
+	})
+
+	t.Run("GetPostsWithCanceledContext", func(t *testing.T) {
+		_, err := store.GetPosts(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
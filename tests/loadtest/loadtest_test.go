@@ -0,0 +1,132 @@
+package loadtest_test
+
+import (
+	"context"
+	"graphql-comments/graphql"
+	"graphql-comments/loadtest"
+	"graphql-comments/seed"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/handler"
+)
+
+// newHandler возвращает обработчик GraphQL над хранилищем с несколькими постами
+func newHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+	config := seed.DefaultConfig
+	config.Posts = 3
+	if _, err := seed.Generate(context.Background(), store, config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return handler.New(&handler.Config{Schema: &schema})
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(newHandler(t))
+	defer server.Close()
+
+	mix := loadtest.DefaultMix
+	report, err := loadtest.Run(context.Background(), loadtest.Config{URL: server.URL, Concurrency: 4, Requests: 200, Mix: mix, Seed: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Total.Requests != 200 || report.Total.Errors != 0 {
+		t.Errorf("Unexpected total: %+v, errors: %v", report.Total, report.ErrorMessages)
+	}
+	sum := 0
+	for _, stats := range report.Scenarios {
+		sum += stats.Requests
+		if stats.P50 > stats.P90 || stats.P90 > stats.P99 || stats.P99 > stats.Max || stats.Max == 0 {
+			t.Errorf("Unordered percentiles: %+v", stats)
+		}
+	}
+	if sum != 200 || len(report.Scenarios) != len(mix) {
+		t.Errorf("Unexpected scenarios: %+v", report.Scenarios)
+	}
+	if report.Throughput <= 0 {
+		t.Errorf("Unexpected throughput %f", report.Throughput)
+	}
+
+	t.Run("Duration", func(t *testing.T) {
+		report, err := loadtest.Run(context.Background(), loadtest.Config{
+			URL:         server.URL,
+			Concurrency: 2,
+			Duration:    100 * time.Millisecond,
+			Mix:         map[string]int{loadtest.ScenarioPosts: 1},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if report.Total.Requests == 0 || len(report.Scenarios) != 1 || report.Scenarios[0].Scenario != loadtest.ScenarioPosts {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	graphqlHandler := newHandler(t)
+	// Начальный запрос списка постов проходит, затем ответы чередуют ошибку HTTP и ошибку GraphQL
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := calls.Add(1); {
+		case n == 1:
+			graphqlHandler.ServeHTTP(w, r)
+		case n%2 == 0:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":null,"errors":[{"message":"post not found"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	report, err := loadtest.Run(context.Background(), loadtest.Config{URL: server.URL, Concurrency: 1, Requests: 10, Mix: map[string]int{loadtest.ScenarioPost: 1}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Total.Requests != 10 || report.Total.Errors != 10 || report.Total.ErrorRate != 1 {
+		t.Errorf("Unexpected total: %+v", report.Total)
+	}
+	if report.ErrorMessages["HTTP 503"] != 5 || report.ErrorMessages["post not found"] != 5 {
+		t.Errorf("Unexpected error messages: %v", report.ErrorMessages)
+	}
+
+	t.Run("NoPosts", func(t *testing.T) {
+		server := httptest.NewServer(newHandler(t))
+		defer server.Close()
+		storage.DataBase = inMemory.NewInMemoryStore()
+
+		if _, err := loadtest.Run(context.Background(), loadtest.Config{URL: server.URL, Requests: 1}); err == nil {
+			t.Errorf("Expected error for a server without posts")
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		invalid := []loadtest.Config{
+			{Requests: 1},
+			{URL: server.URL},
+			{URL: server.URL, Requests: 1, Mix: map[string]int{"unknown": 1}},
+			{URL: server.URL, Requests: 1, Mix: map[string]int{loadtest.ScenarioPosts: 0}},
+		}
+		for _, config := range invalid {
+			if err := config.Validate(); err == nil {
+				t.Errorf("Expected error for %+v", config)
+			}
+		}
+	})
+}
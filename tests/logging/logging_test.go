@@ -0,0 +1,139 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"graphql-comments/logging"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/slowlog"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+
+	var requestID string
+	handler := logging.Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = logging.RequestID(r.Context())
+		logging.FromContext(r.Context()).Info("inside")
+	}))
+
+	t.Run("HonorsRequestIDHeader", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set(logging.RequestIDHeader, "abc-123")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if requestID != "abc-123" {
+			t.Errorf("Expected request ID abc-123, got %q", requestID)
+		}
+
+		if got := rec.Header().Get(logging.RequestIDHeader); got != "abc-123" {
+			t.Errorf("Expected response header abc-123, got %q", got)
+		}
+
+		if !strings.Contains(buf.String(), `"request_id":"abc-123"`) {
+			t.Errorf("Log entry does not contain request ID: %s", buf.String())
+		}
+	})
+
+	t.Run("GeneratesRequestID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set(logging.RequestIDHeader, "bad id\n")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if requestID == "" || requestID == "bad id\n" {
+			t.Errorf("Expected generated request ID, got %q", requestID)
+		}
+	})
+}
+
+func TestRedact(t *testing.T) {
+	vars := map[string]interface{}{
+		"postID":  "post-id",
+		"Content": "secret",
+		"input": map[string]interface{}{
+			"content": "secret",
+			"items":   []interface{}{map[string]interface{}{"content": "secret"}},
+		},
+	}
+
+	redacted := logging.Redact(vars, map[string]struct{}{"content": {}})
+	out, _ := json.Marshal(redacted)
+
+	if strings.Contains(string(out), "secret") {
+		t.Errorf("Content was not redacted: %s", out)
+	}
+
+	if !strings.Contains(string(out), "post-id") {
+		t.Errorf("Non-redacted field is missing: %s", out)
+	}
+
+	if vars["Content"] != "secret" {
+		t.Errorf("Redact modified the input")
+	}
+}
+
+func TestGraphQLResultCallback(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), logging.New(&buf, slog.LevelInfo))
+
+	callback := logging.GraphQLResultCallback([]string{"content"})
+	callback(ctx, &graphql.Params{
+		RequestString:  `mutation { addComment(postID: "1", content: $content) { id } }`,
+		VariableValues: map[string]interface{}{"content": "hello"},
+	}, &graphql.Result{}, []byte(`{"data":{}}`))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log entry is not JSON: %v", err)
+	}
+
+	if entry["operation"] != "addComment" {
+		t.Errorf("Expected operation addComment, got %v", entry["operation"])
+	}
+
+	if entry["result_size"] != float64(11) {
+		t.Errorf("Expected result_size 11, got %v", entry["result_size"])
+	}
+
+	if strings.Contains(buf.String(), "hello") {
+		t.Errorf("Variables were not redacted: %s", buf.String())
+	}
+}
+
+func TestSlowLog(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), logging.New(&buf, slog.LevelInfo))
+
+	store := slowlog.NewSlowLogStore(inMemory.NewInMemoryStore(), 0)
+	storage.DataBase = store
+
+	if _, err := store.GetPosts(ctx); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `"operation":"GetPosts"`) {
+		t.Errorf("Slow call was not logged: %s", buf.String())
+	}
+
+	buf.Reset()
+	store.Threshold = time.Hour
+	store.GetPosts(ctx)
+
+	if buf.Len() != 0 {
+		t.Errorf("Fast call was logged: %s", buf.String())
+	}
+}
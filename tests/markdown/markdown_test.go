@@ -0,0 +1,143 @@
+package markdown_test
+
+import (
+	"context"
+	"graphql-comments/graphql"
+	"graphql-comments/markdown"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/types"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestRender(t *testing.T) {
+	renderer := markdown.NewRenderer(10)
+
+	cases := []struct {
+		name     string
+		content  string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Emphasis",
+			content:  "*a* **b**",
+			contains: []string{"<em>a</em>", "<strong>b</strong>"},
+		},
+		{
+			name:     "Link",
+			content:  "[site](https://example.com)",
+			contains: []string{`<a href="https://example.com" rel="nofollow ugc">site</a>`},
+		},
+		{
+			name:     "Autolink",
+			content:  "see https://example.com",
+			contains: []string{`<a href="https://example.com" rel="nofollow ugc">https://example.com</a>`},
+		},
+		{
+			name:     "CodeBlock",
+			content:  "```go\nfmt.Println(\"<b>\")\n```",
+			contains: []string{`<pre><code class="language-go">fmt.Println(&#34;&lt;b&gt;&#34;)`},
+		},
+		{
+			name:     "RawHTML",
+			content:  "a <script>alert(1)</script> <b onclick=\"x\">b</b>",
+			excludes: []string{"<script", "onclick", "<b"},
+		},
+		{
+			name:     "HTMLBlock",
+			content:  "<div>\n<iframe src=\"https://evil.example\"></iframe>\n</div>",
+			excludes: []string{"<div", "<iframe"},
+		},
+		{
+			name:     "JavascriptLink",
+			content:  "[x](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+		{
+			name:     "Image",
+			content:  "![tracker](https://example.com/pixel.png)",
+			excludes: []string{"<img"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			html, err := renderer.Render(c.content)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, s := range c.contains {
+				if !strings.Contains(html, s) {
+					t.Errorf("Expected %q in %q", s, html)
+				}
+			}
+			for _, s := range c.excludes {
+				if strings.Contains(html, s) {
+					t.Errorf("Unexpected %q in %q", s, html)
+				}
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	renderer := markdown.NewRenderer(2)
+
+	renderer.Render("a")
+	renderer.Render("a")
+	if renderer.Len() != 1 {
+		t.Errorf("Expected 1 cached result, got %d", renderer.Len())
+	}
+
+	renderer.Render("b")
+	renderer.Render("c")
+	if renderer.Len() != 2 {
+		t.Errorf("Expected cache to be bounded by 2, got %d", renderer.Len())
+	}
+
+	disabled := markdown.NewRenderer(0)
+	disabled.Render("a")
+	if disabled.Len() != 0 {
+		t.Errorf("Expected cache to be disabled, got %d", disabled.Len())
+	}
+}
+
+func TestContentHTML(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.Markdown = markdown.NewRenderer(10)
+	ctx := context.Background()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	post, _ := storage.DataBase.AddPost(ctx, "", "Title", "# Header", true)
+	comment, _ := storage.DataBase.AddComment(ctx, "", post.ID, "", "*hi*", types.CommentPublished)
+
+	result := graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  `query($postID: ID!, $commentID: ID!) { getPostByID(id: $postID) { contentHTML } getCommentByID(id: $commentID) { content contentHTML } }`,
+		VariableValues: map[string]interface{}{"postID": post.ID, "commentID": comment.ID},
+		Context:        ctx,
+	})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	data := result.Data.(map[string]interface{})
+	if html := data["getPostByID"].(map[string]interface{})["contentHTML"]; html != "<h1>Header</h1>\n" {
+		t.Errorf("Unexpected post HTML: %q", html)
+	}
+	commentData := data["getCommentByID"].(map[string]interface{})
+	if commentData["content"] != "*hi*" || commentData["contentHTML"] != "<p><em>hi</em></p>\n" {
+		t.Errorf("Unexpected comment: %v", commentData)
+	}
+	if gql.Markdown.Len() != 2 {
+		t.Errorf("Expected 2 cached results, got %d", gql.Markdown.Len())
+	}
+}
@@ -0,0 +1,190 @@
+package mentions_test
+
+import (
+	"context"
+	"fmt"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/mentions"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"Single", "hi @alice!", []string{"alice"}},
+		{"StartOfContent", "@alice, look", []string{"alice"}},
+		{"Several", "@alice and @bob_2", []string{"alice", "bob_2"}},
+		{"Duplicates", "@alice @Alice @alice", []string{"alice"}},
+		{"Email", "write to alice@example.com", []string{}},
+		{"DoubleAt", "@@alice", []string{}},
+		{"TooLong", "@" + strings.Repeat("a", 33), []string{}},
+		{"CodeSpan", "use `@alice` or @bob", []string{"bob"}},
+		{"DoubleBacktickCodeSpan", "``a ` @alice`` @bob", []string{"bob"}},
+		{"UnclosedBacktick", "it's ` @alice", []string{"alice"}},
+		{"FencedBlock", "@alice\n```\n@bob\n```\n@carol", []string{"alice", "carol"}},
+		{"TildeFence", "~~~go\n@bob\n~~~\n", []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := mentions.Parse(c.content, storage.MaxMentionsPerComment, storage.MaxUserNameLength)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+
+	t.Run("Limit", func(t *testing.T) {
+		got := mentions.Parse("@a @b @c @d", 2, storage.MaxUserNameLength)
+		if !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("Expected [a b], got %v", got)
+		}
+	})
+}
+
+func TestMentions(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	ctx := context.Background()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	users := make(map[string]string)
+	for i := 0; i < storage.MaxMentionsPerComment+2; i++ {
+		name := fmt.Sprintf("user%d", i)
+		user, err := storage.DataBase.AddUser(ctx, name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		users[name] = user.ID
+	}
+	author := auth.WithUser(ctx, users["user0"])
+
+	post, _ := storage.DataBase.AddPost(author, users["user0"], "Title", "Content", true)
+
+	addComment := func(content string) map[string]interface{} {
+		t.Helper()
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `mutation($postID: ID!, $content: String!) { addComment(postID: $postID, content: $content) { id mentions { id name } } }`,
+			VariableValues: map[string]interface{}{"postID": post.ID, "content": content},
+			Context:        author,
+		})
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		return result.Data.(map[string]interface{})["addComment"].(map[string]interface{})
+	}
+
+	t.Run("ResolvesKnownUsers", func(t *testing.T) {
+		comment := addComment("thanks @User1 and @nobody, see `@user2`")
+
+		mentioned := comment["mentions"].([]interface{})
+		if len(mentioned) != 1 || mentioned[0].(map[string]interface{})["id"] != users["user1"] {
+			t.Errorf("Expected only user1 to be mentioned, got %v", mentioned)
+		}
+
+		notifications, _ := storage.DataBase.GetNotifications(ctx, users["user1"], storage.NotificationFilter{})
+		if len(notifications) != 1 || notifications[0].Type != "MENTION" || notifications[0].CommentID != comment["id"] {
+			t.Errorf("Unexpected notifications: %v", notifications)
+		}
+		notifications, _ = storage.DataBase.GetNotifications(ctx, users["user2"], storage.NotificationFilter{})
+		if len(notifications) != 0 {
+			t.Errorf("Expected no notifications for mention in code, got %v", notifications)
+		}
+	})
+
+	t.Run("Bounded", func(t *testing.T) {
+		var content strings.Builder
+		for i := 1; i < storage.MaxMentionsPerComment+2; i++ {
+			fmt.Fprintf(&content, "@user%d ", i)
+		}
+		comment := addComment(content.String())
+
+		if mentioned := comment["mentions"].([]interface{}); len(mentioned) != storage.MaxMentionsPerComment {
+			t.Errorf("Expected %d mentions, got %d", storage.MaxMentionsPerComment, len(mentioned))
+		}
+	})
+
+	t.Run("UnknownNamesNotCounted", func(t *testing.T) {
+		var content strings.Builder
+		for i := 0; i < storage.MaxMentionsPerComment; i++ {
+			fmt.Fprintf(&content, "@nobody%d ", i)
+		}
+		content.WriteString("@user3")
+		comment := addComment(content.String())
+
+		mentioned := comment["mentions"].([]interface{})
+		if len(mentioned) != 1 || mentioned[0].(map[string]interface{})["id"] != users["user3"] {
+			t.Errorf("Expected user3 to be mentioned, got %v", mentioned)
+		}
+	})
+
+	t.Run("UnknownNamesBounded", func(t *testing.T) {
+		var content strings.Builder
+		for i := 0; i < 2*storage.MaxMentionsPerComment; i++ {
+			fmt.Fprintf(&content, "@nobody%d ", i)
+		}
+		content.WriteString("@user3")
+		comment := addComment(content.String())
+
+		if mentioned := comment["mentions"].([]interface{}); len(mentioned) != 0 {
+			t.Errorf("Expected names beyond the parse limit to be ignored, got %v", mentioned)
+		}
+	})
+
+	t.Run("CommentsMentioning", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `query($userID: ID!) { commentsMentioning(userID: $userID) { id content } }`,
+			VariableValues: map[string]interface{}{"userID": users["user1"]},
+			Context:        ctx,
+		})
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+
+		comments := result.Data.(map[string]interface{})["commentsMentioning"].([]interface{})
+		if len(comments) != 2 {
+			t.Fatalf("Expected 2 comments, got %v", comments)
+		}
+		if content := comments[1].(map[string]interface{})["content"].(string); !strings.HasPrefix(content, "thanks") {
+			t.Errorf("Expected newest comments first, got %v", comments)
+		}
+	})
+}
+
+func TestPostgresMentions(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comment_mentions (comment_id, user_id) SELECT $1, UNNEST($2::VARCHAR[]) ON CONFLICT DO NOTHING")).
+		WithArgs("comment-id", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := store.AddMentions(context.Background(), "comment-id", []string{"a", "b"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,302 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/gqltest"
+	"graphql-comments/tests/sqltest"
+	"graphql-comments/types"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+func queue(t *testing.T, schema graphql.Schema, status string) []interface{} {
+	t.Helper()
+
+	data := gqltest.Do(t, schema, auth.WithAdmin(context.Background()), `query($status: ModerationQueue!) {
+		moderationQueue(status: $status) { edges { node { comment { id status } reports reasons } } }
+	}`, map[string]interface{}{"status": status})
+	return data["moderationQueue"].(map[string]interface{})["edges"].([]interface{})
+}
+
+func TestModeration(t *testing.T) {
+	schema := gqltest.NewSchema(t)
+	ctx := context.Background()
+	admin := auth.WithAdmin(ctx)
+
+	author, _ := storage.DataBase.AddUser(ctx, "author")
+	reader, _ := storage.DataBase.AddUser(ctx, "reader")
+	post, _ := storage.DataBase.AddPost(ctx, author.ID, "Title", "Content", true)
+	pending, _ := storage.DataBase.AddComment(ctx, reader.ID, post.ID, "", "buy cheap pills", types.CommentPending)
+	published, _ := storage.DataBase.AddComment(ctx, author.ID, post.ID, "", "hello", types.CommentPublished)
+
+	t.Run("RequiresAdmin", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{
+			Schema:        schema,
+			RequestString: `mutation($id: ID!) { approveComment(id: $id) { id } }`,
+			VariableValues: map[string]interface{}{
+				"id": pending.ID,
+			},
+			Context: auth.WithUser(ctx, reader.ID),
+		})
+		if !result.HasErrors() || result.Errors[0].Extensions["code"] != "FORBIDDEN" {
+			t.Errorf("Expected FORBIDDEN, got %v", result.Errors)
+		}
+	})
+
+	t.Run("PendingQueue", func(t *testing.T) {
+		edges := queue(t, schema, types.QueuePending)
+		if len(edges) != 1 {
+			t.Fatalf("Expected 1 pending comment, got %v", edges)
+		}
+		comment := edges[0].(map[string]interface{})["node"].(map[string]interface{})["comment"].(map[string]interface{})
+		if comment["id"] != pending.ID {
+			t.Errorf("Unexpected comment in queue: %v", comment)
+		}
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		data := gqltest.Do(t, schema, admin, `mutation($id: ID!) { approveComment(id: $id, reason: "looks fine") { id status } }`,
+			map[string]interface{}{"id": pending.ID})
+		if status := data["approveComment"].(map[string]interface{})["status"]; status != types.CommentPublished {
+			t.Errorf("Expected published comment, got %v", status)
+		}
+
+		if edges := queue(t, schema, types.QueuePending); len(edges) != 0 {
+			t.Errorf("Expected empty queue, got %v", edges)
+		}
+		comments, _ := storage.DataBase.GetComments(ctx, post.ID, 1)
+		if len(comments) != 2 || comments[1].ID != pending.ID {
+			t.Errorf("Expected approved comment to be listed, got %v", comments)
+		}
+		notifications, _ := storage.DataBase.GetNotifications(ctx, author.ID, storage.NotificationFilter{})
+		if len(notifications) != 1 || notifications[0].CommentID != pending.ID {
+			t.Errorf("Expected post author to be notified on approval, got %v", notifications)
+		}
+		if _, ham := gql.Classifiers.For(tenant.DefaultID).Trained(); ham == 0 {
+			t.Errorf("Expected classifier to learn from approval")
+		}
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		report := `mutation($id: ID!, $reason: String!) { reportComment(id: $id, reason: $reason) }`
+		gqltest.Do(t, schema, auth.WithUser(ctx, reader.ID), report, map[string]interface{}{"id": published.ID, "reason": "rude"})
+		// Повторная жалоба не учитывается
+		gqltest.Do(t, schema, auth.WithUser(ctx, reader.ID), report, map[string]interface{}{"id": published.ID, "reason": "rude"})
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  report,
+			VariableValues: map[string]interface{}{"id": published.ID, "reason": "mine"},
+			Context:        auth.WithUser(ctx, author.ID),
+		})
+		if !result.HasErrors() {
+			t.Errorf("Expected error when reporting own comment")
+		}
+
+		edges := queue(t, schema, types.QueueFlagged)
+		if len(edges) != 1 {
+			t.Fatalf("Expected 1 flagged comment, got %v", edges)
+		}
+		node := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+		if node["reports"] != 1 || len(node["reasons"].([]interface{})) != 1 {
+			t.Errorf("Expected aggregated report, got %v", node)
+		}
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		gqltest.Do(t, schema, admin, `mutation($id: ID!) { rejectComment(id: $id, reason: "abuse") { id } }`,
+			map[string]interface{}{"id": published.ID})
+
+		if edges := queue(t, schema, types.QueueFlagged); len(edges) != 0 {
+			t.Errorf("Expected empty queue, got %v", edges)
+		}
+		comments, _ := storage.DataBase.GetComments(ctx, post.ID, 1)
+		if len(comments) != 1 || comments[0].ID != pending.ID {
+			t.Errorf("Expected rejected comment to be hidden, got %v", comments)
+		}
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		data := gqltest.Do(t, schema, admin, `{ auditLog(first: 2) { edges { node { actor action targetID reason } } pageInfo { endCursor hasNextPage } } }`, nil)
+		page := data["auditLog"].(map[string]interface{})
+		edges := page["edges"].([]interface{})
+		if len(edges) != 2 {
+			t.Fatalf("Expected 2 entries, got %v", edges)
+		}
+		latest := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+		if latest["action"] != types.AuditReject || latest["actor"] != gql.AdminActor || latest["reason"] != "abuse" {
+			t.Errorf("Unexpected entry: %v", latest)
+		}
+		pageInfo := page["pageInfo"].(map[string]interface{})
+		if pageInfo["hasNextPage"] != true {
+			t.Errorf("Expected next page")
+		}
+
+		data = gqltest.Do(t, schema, admin, `query($after: String) { auditLog(after: $after) { edges { node { action } } } }`,
+			map[string]interface{}{"after": pageInfo["endCursor"]})
+		if edges := data["auditLog"].(map[string]interface{})["edges"].([]interface{}); len(edges) != 1 {
+			t.Errorf("Expected 1 entry on the second page, got %v", edges)
+		}
+
+		data = gqltest.Do(t, schema, admin, `query($actor: String) { auditLog(filter: {actor: $actor, action: REPORT}) { edges { node { targetID } } } }`,
+			map[string]interface{}{"actor": reader.ID})
+		edges = data["auditLog"].(map[string]interface{})["edges"].([]interface{})
+		if len(edges) != 1 || edges[0].(map[string]interface{})["node"].(map[string]interface{})["targetID"] != published.ID {
+			t.Errorf("Unexpected filtered entries: %v", edges)
+		}
+	})
+
+	t.Run("Immutable", func(t *testing.T) {
+		entries, _ := storage.DataBase.GetAuditLog(ctx, storage.AuditFilter{})
+		entries[0].Reason = "changed"
+
+		entries, _ = storage.DataBase.GetAuditLog(ctx, storage.AuditFilter{})
+		if entries[0].Reason != "abuse" {
+			t.Errorf("Audit entry was modified")
+		}
+	})
+}
+
+func TestPostgresModerateComment(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+		WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2 FOR UPDATE")).
+		WithArgs("comment-id", tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at", "status"}).
+			AddRow("comment-id", "post-id", nil, nil, "Content", time.Now(), types.CommentPending))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE comments SET status = $1 WHERE id = $2")).
+		WithArgs(types.CommentPublished, "comment-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM comment_reports WHERE comment_id = $1")).
+		WithArgs("comment-id").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log (id, tenant_id, actor, action, target_id, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)")).
+		WithArgs(sqlmock.AnyArg(), tenant.DefaultID, "admin", types.AuditApprove, "comment-id", "ok", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2")).
+		WithArgs("comment-id", tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at", "status"}).
+			AddRow("comment-id", "post-id", nil, nil, "Content", time.Now(), types.CommentPublished))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE parent_comment_id = $1 AND tenant_id = $2 AND status = 'PUBLISHED'")).
+		WithArgs("comment-id", tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	comment, err := store.ModerateComment(ctx, "admin", "comment-id", types.CommentPublished, "ok")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if comment.Status != types.CommentPublished {
+		t.Errorf("Expected published comment, got %s", comment.Status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresReportComment(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+
+	t.Run("New", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("comment-id", tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comment_reports (comment_id, user_id, reason, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING")).
+			WithArgs("comment-id", "user-id", "spam", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO audit_log (id, tenant_id, actor, action, target_id, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, "user-id", types.AuditReport, "comment-id", "spam", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := store.ReportComment(ctx, &types.Report{CommentID: "comment-id", UserID: "user-id", Reason: "spam"}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("comment-id", tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comment_reports (comment_id, user_id, reason, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		if err := store.ReportComment(ctx, &types.Report{CommentID: "comment-id", UserID: "user-id", Reason: "spam"}); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("CommentOfAnotherTenant", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, "other")
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2)")).
+			WithArgs("comment-id", "other").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		other := tenant.WithTenant(ctx, &tenant.Tenant{ID: "other"})
+		err := store.ReportComment(other, &types.Report{CommentID: "comment-id", UserID: "user-id", Reason: "spam"})
+		if !errors.Is(err, storage.ErrCommentNotFound) {
+			t.Errorf("Expected %v, got %v", storage.ErrCommentNotFound, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestPostgresGetAuditLog(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, tenant_id, actor, action, target_id, reason, created_at FROM audit_log WHERE tenant_id = $1 AND action = $2 AND target_id = $3 ORDER BY created_at DESC, id DESC LIMIT $4")).
+		WithArgs(tenant.DefaultID, types.AuditReport, "comment-id", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "actor", "action", "target_id", "reason", "created_at"}).
+			AddRow("entry-id", tenant.DefaultID, "user-id", types.AuditReport, "comment-id", "spam", time.Now()))
+
+	entries, err := store.GetAuditLog(context.Background(), storage.AuditFilter{Action: types.AuditReport, TargetID: "comment-id", Limit: 10})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	} else if len(entries) != 1 || entries[0].Actor != "user-id" {
+		t.Errorf("Unexpected entries: %v", entries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
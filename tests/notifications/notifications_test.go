@@ -0,0 +1,326 @@
+package notifications_test
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tests/gqltest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+const secret = "test-secret"
+
+func newSchema(t *testing.T) graphql.Schema {
+	schema := gqltest.NewSchema(t)
+	gql.TokenSecret = secret
+	return schema
+}
+
+// register регистрирует пользователя и возвращает контекст его запросов
+func register(t *testing.T, schema graphql.Schema, name string) (context.Context, string) {
+	t.Helper()
+
+	data := gqltest.Do(t, schema, context.Background(), `mutation($name: String!) { registerUser(name: $name) { user { id } token } }`,
+		map[string]interface{}{"name": name})
+	payload := data["registerUser"].(map[string]interface{})
+	userID, err := auth.ParseToken(secret, payload["token"].(string))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if userID != payload["user"].(map[string]interface{})["id"] {
+		t.Fatalf("Token was issued for %q", userID)
+	}
+	return auth.WithUser(context.Background(), userID), userID
+}
+
+func addComment(t *testing.T, schema graphql.Schema, ctx context.Context, postID, parentCommentID string) string {
+	t.Helper()
+
+	variables := map[string]interface{}{"postID": postID}
+	if parentCommentID != "" {
+		variables["parentCommentID"] = parentCommentID
+	}
+	data := gqltest.Do(t, schema, ctx, `mutation($postID: ID!, $parentCommentID: ID) {
+		addComment(postID: $postID, parentCommentID: $parentCommentID, content: "Comment") { id }
+	}`, variables)
+	return data["addComment"].(map[string]interface{})["id"].(string)
+}
+
+func TestTokens(t *testing.T) {
+	token := auth.IssueToken(secret, "user-id")
+
+	t.Run("Valid", func(t *testing.T) {
+		userID, err := auth.ParseToken(secret, token)
+		if err != nil || userID != "user-id" {
+			t.Errorf("Expected user-id, got %q (%v)", userID, err)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		if _, err := auth.ParseToken("other-secret", token); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("ForgedUser", func(t *testing.T) {
+		forged := "other-user" + token[len("user-id"):]
+		if _, err := auth.ParseToken(secret, forged); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestNotifications(t *testing.T) {
+	schema := newSchema(t)
+	alice, aliceID := register(t, schema, "alice")
+	bob, bobID := register(t, schema, "bob")
+
+	data := gqltest.Do(t, schema, alice, `mutation { addPost(title: "Title", content: "Content") { id authorID } }`, nil)
+	post := data["addPost"].(map[string]interface{})
+	postID := post["id"].(string)
+	if post["authorID"] != aliceID {
+		t.Fatalf("Expected post author %q, got %v", aliceID, post["authorID"])
+	}
+
+	t.Run("RegisterTakenName", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: `mutation { registerUser(name: "Alice") { token } }`, Context: context.Background()})
+		if !result.HasErrors() {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("RequiresUser", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ unreadNotificationCount }`, Context: context.Background()})
+		if !result.HasErrors() || result.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+			t.Errorf("Expected UNAUTHENTICATED error, got %v", result.Errors)
+		}
+	})
+
+	bobComment := addComment(t, schema, bob, postID, "")
+
+	t.Run("CommentOnPost", func(t *testing.T) {
+		data := gqltest.Do(t, schema, alice, `{ notifications { edges { node { type actorID postID commentID read } } } }`, nil)
+		edges := data["notifications"].(map[string]interface{})["edges"].([]interface{})
+		if len(edges) != 1 {
+			t.Fatalf("Expected 1 notification, got %v", edges)
+		}
+		node := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+		if node["type"] != "POST_COMMENT" || node["actorID"] != bobID || node["commentID"] != bobComment || node["read"] != false {
+			t.Errorf("Unexpected notification: %v", node)
+		}
+	})
+
+	t.Run("Reply", func(t *testing.T) {
+		addComment(t, schema, alice, postID, bobComment)
+
+		data := gqltest.Do(t, schema, bob, `{ notifications { edges { node { type actorID } } } unreadNotificationCount }`, nil)
+		edges := data["notifications"].(map[string]interface{})["edges"].([]interface{})
+		if len(edges) != 1 {
+			t.Fatalf("Expected 1 notification, got %v", edges)
+		}
+		node := edges[0].(map[string]interface{})["node"].(map[string]interface{})
+		if node["type"] != "REPLY" || node["actorID"] != aliceID {
+			t.Errorf("Unexpected notification: %v", node)
+		}
+		if data["unreadNotificationCount"] != 1 {
+			t.Errorf("Expected 1 unread notification, got %v", data["unreadNotificationCount"])
+		}
+
+		// Автор поста не получает уведомлений о собственных комментариях
+		data = gqltest.Do(t, schema, alice, `{ unreadNotificationCount }`, nil)
+		if data["unreadNotificationCount"] != 1 {
+			t.Errorf("Expected 1 unread notification, got %v", data["unreadNotificationCount"])
+		}
+	})
+
+	t.Run("ReplyToPostAuthorIsNotDuplicated", func(t *testing.T) {
+		aliceComment := addComment(t, schema, alice, postID, "")
+		addComment(t, schema, bob, postID, aliceComment)
+
+		data := gqltest.Do(t, schema, alice, `{ notifications(first: 1) { edges { node { type } } } unreadNotificationCount }`, nil)
+		node := data["notifications"].(map[string]interface{})["edges"].([]interface{})[0].(map[string]interface{})["node"].(map[string]interface{})
+		if node["type"] != "REPLY" {
+			t.Errorf("Expected REPLY notification, got %v", node)
+		}
+		if data["unreadNotificationCount"] != 2 {
+			t.Errorf("Expected 2 unread notifications, got %v", data["unreadNotificationCount"])
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			addComment(t, schema, bob, postID, "")
+		}
+
+		query := `query($after: String) {
+			notifications(first: 2, after: $after) { edges { cursor node { id } } pageInfo { endCursor hasNextPage } }
+		}`
+		seen := make(map[string]bool)
+		var after interface{}
+		for pages := 1; ; pages++ {
+			data := gqltest.Do(t, schema, alice, query, map[string]interface{}{"after": after})
+			connection := data["notifications"].(map[string]interface{})
+			for _, edge := range connection["edges"].([]interface{}) {
+				id := edge.(map[string]interface{})["node"].(map[string]interface{})["id"].(string)
+				if seen[id] {
+					t.Fatalf("Notification %s returned twice", id)
+				}
+				seen[id] = true
+			}
+
+			pageInfo := connection["pageInfo"].(map[string]interface{})
+			if pageInfo["hasNextPage"] == false {
+				if pages != 3 {
+					t.Errorf("Expected 3 pages, got %d", pages)
+				}
+				break
+			}
+			after = pageInfo["endCursor"]
+		}
+		if len(seen) != 5 {
+			t.Errorf("Expected 5 notifications, got %d", len(seen))
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: `{ notifications(after: "unknown") { pageInfo { hasNextPage } } }`, Context: alice})
+		if !result.HasErrors() {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("MarkRead", func(t *testing.T) {
+		data := gqltest.Do(t, schema, alice, `{ notifications(first: 2) { edges { node { id } } } }`, nil)
+		ids := make([]interface{}, 0)
+		for _, edge := range data["notifications"].(map[string]interface{})["edges"].([]interface{}) {
+			ids = append(ids, edge.(map[string]interface{})["node"].(map[string]interface{})["id"])
+		}
+
+		// Чужие уведомления не отмечаются
+		data = gqltest.Do(t, schema, bob, `mutation($ids: [ID!]!) { markNotificationsRead(ids: $ids) }`, map[string]interface{}{"ids": ids})
+		if data["markNotificationsRead"] != 0 {
+			t.Errorf("Expected 0 marked notifications, got %v", data["markNotificationsRead"])
+		}
+
+		data = gqltest.Do(t, schema, alice, `mutation($ids: [ID!]!) { markNotificationsRead(ids: $ids) }`, map[string]interface{}{"ids": ids})
+		if data["markNotificationsRead"] != 2 {
+			t.Errorf("Expected 2 marked notifications, got %v", data["markNotificationsRead"])
+		}
+
+		data = gqltest.Do(t, schema, alice, `{ unreadNotificationCount notifications(unreadOnly: true) { edges { node { id read } } } }`, nil)
+		if data["unreadNotificationCount"] != 3 {
+			t.Errorf("Expected 3 unread notifications, got %v", data["unreadNotificationCount"])
+		}
+		for _, edge := range data["notifications"].(map[string]interface{})["edges"].([]interface{}) {
+			node := edge.(map[string]interface{})["node"].(map[string]interface{})
+			if node["read"] != false || node["id"] == ids[0] || node["id"] == ids[1] {
+				t.Errorf("Unexpected unread notification: %v", node)
+			}
+		}
+	})
+}
+
+func TestNotificationAddedSubscription(t *testing.T) {
+	schema := newSchema(t)
+	alice, _ := register(t, schema, "alice")
+	bob, bobID := register(t, schema, "bob")
+
+	data := gqltest.Do(t, schema, alice, `mutation { addPost(title: "Title", content: "Content") { id } }`, nil)
+	postID := data["addPost"].(map[string]interface{})["id"].(string)
+
+	t.Run("RequiresUser", func(t *testing.T) {
+		results := graphql.Subscribe(graphql.Params{Schema: schema, RequestString: `subscription { notificationAdded { id } }`, Context: context.Background()})
+		result := <-results
+		if !result.HasErrors() {
+			t.Errorf("Expected error, got %v", result.Data)
+		}
+	})
+
+	t.Run("ReceivesNotification", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(alice)
+		defer cancel()
+
+		results := graphql.Subscribe(graphql.Params{
+			Schema:        schema,
+			RequestString: `subscription { notificationAdded { type actorID postID } }`,
+			Context:       ctx,
+		})
+
+		// Подписка регистрируется асинхронно, поэтому комментарии добавляются, пока не придет уведомление
+		deadline := time.After(2 * time.Second)
+		for {
+			addComment(t, schema, bob, postID, "")
+			select {
+			case result := <-results:
+				if result.HasErrors() {
+					t.Fatalf("Unexpected errors: %v", result.Errors)
+				}
+				notification := result.Data.(map[string]interface{})["notificationAdded"].(map[string]interface{})
+				if notification["type"] != "POST_COMMENT" || notification["actorID"] != bobID || notification["postID"] != postID {
+					t.Errorf("Unexpected notification: %v", notification)
+				}
+				return
+			case <-time.After(10 * time.Millisecond):
+			case <-deadline:
+				t.Fatalf("Notification was not delivered")
+			}
+		}
+	})
+}
+
+func TestPostgresNotifications(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+
+	t.Run("GetNotificationsAfterCursor", func(t *testing.T) {
+		createdAt := time.Now()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT created_at FROM notifications WHERE id = $1 AND user_id = $2")).
+			WithArgs("cursor-id", "user-id").
+			WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, type, actor_id, post_id, comment_id, created_at, read_at FROM notifications "+
+			"WHERE user_id = $1 AND (created_at, id) < ($2, $3) AND read_at IS NULL ORDER BY created_at DESC, id DESC LIMIT $4")).
+			WithArgs("user-id", createdAt, "cursor-id", 11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "actor_id", "post_id", "comment_id", "created_at", "read_at"}).
+				AddRow("notification-id", "user-id", "REPLY", nil, "post-id", "comment-id", createdAt, nil))
+
+		notifications, err := store.GetNotifications(ctx, "user-id", storage.NotificationFilter{After: "cursor-id", Limit: 11, UnreadOnly: true})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(notifications) != 1 || notifications[0].ActorID != "" || notifications[0].ReadAt != nil {
+			t.Errorf("Unexpected notifications: %v", notifications)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("MarkNotificationsRead", func(t *testing.T) {
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND id = ANY($3) AND read_at IS NULL")).
+			WithArgs(sqlmock.AnyArg(), "user-id", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		marked, err := store.MarkNotificationsRead(ctx, "user-id", []string{"a", "b", "c"})
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if marked != 2 {
+			t.Errorf("Expected 2 marked notifications, got %d", marked)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
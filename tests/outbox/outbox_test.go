@@ -0,0 +1,229 @@
+package outbox_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"graphql-comments/events"
+	"graphql-comments/outbox"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type recorder struct {
+	events []events.Event
+	failOn string
+}
+
+func (r *recorder) Publish(ctx context.Context, event events.Event) error {
+	if event.ID == r.failOn {
+		return errors.New("sink is unavailable")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func payload(event events.Event) []byte {
+	data, _ := json.Marshal(event)
+	return data
+}
+
+func TestRelay(t *testing.T) {
+	first := events.New(events.PostCreated, map[string]interface{}{"id": "post-id"})
+	second := events.New(events.CommentCreated, map[string]interface{}{"id": "comment-id"})
+	third := events.New(events.CommentCreated, map[string]interface{}{"id": "reply-id"})
+
+	expectBatch := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT seq, payload FROM outbox WHERE published_at IS NULL ORDER BY seq LIMIT $1")).
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"seq", "payload"}).
+				AddRow(1, payload(first)).
+				AddRow(2, payload(second)).
+				AddRow(3, payload(third)))
+	}
+
+	t.Run("PublishesInOrder", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+
+		sink := &recorder{}
+		relay := outbox.NewRelay(db, sink, 10, time.Second)
+
+		expectBatch(mock)
+		for seq := 1; seq <= 3; seq++ {
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET published_at = $2 WHERE seq = $1")).
+				WithArgs(seq, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		n, err := relay.Process(context.Background())
+		if err != nil || n != 3 {
+			t.Errorf("Expected 3 published events, got %d, %v", n, err)
+		}
+
+		if len(sink.events) != 3 || sink.events[0].ID != first.ID || sink.events[2].ID != third.ID {
+			t.Errorf("Events were published out of order")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("StopsOnFailure", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+
+		sink := &recorder{failOn: second.ID}
+		relay := outbox.NewRelay(db, sink, 10, time.Second)
+
+		expectBatch(mock)
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET published_at = $2 WHERE seq = $1")).
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := relay.Process(context.Background())
+		if err == nil || n != 1 {
+			t.Errorf("Expected 1 published event and error, got %d, %v", n, err)
+		}
+
+		if len(sink.events) != 1 {
+			t.Errorf("Events after the failed one were published")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("SkipsWhenAnotherRelayHoldsLock", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+
+		relay := outbox.NewRelay(db, &recorder{}, 10, time.Second)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+			WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+		mock.ExpectRollback()
+
+		if n, err := relay.Process(context.Background()); err != nil || n != 0 {
+			t.Errorf("Expected nothing to be published, got %d, %v", n, err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestWriterSinks(t *testing.T) {
+	ctx := context.Background()
+	event := events.New(events.PostCreated, map[string]interface{}{"id": "post-id"})
+
+	t.Run("Writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		events.NewWriterSink(&buf).Publish(ctx, event)
+
+		var got events.Event
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.ID != event.ID {
+			t.Errorf("Unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		sink, err := events.NewFileSink(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sink.Publish(ctx, event)
+		sink.Publish(ctx, event)
+		sink.Close()
+
+		data, _ := os.ReadFile(path)
+		if lines := strings.Count(string(data), "\n"); lines != 2 {
+			t.Errorf("Expected 2 lines, got %d", lines)
+		}
+	})
+
+	t.Run("Fanout", func(t *testing.T) {
+		ok, failing := &recorder{}, &recorder{failOn: event.ID}
+		err := events.Fanout{failing, ok}.Publish(ctx, event)
+
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+
+		if len(ok.events) != 1 {
+			t.Errorf("Event was not published to the remaining sinks")
+		}
+	})
+}
+
+func TestNATSSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "PING"):
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB "):
+				body, _ := reader.ReadString('\n')
+				received <- strings.TrimSpace(line) + " " + strings.TrimSpace(body)
+			}
+		}
+	}()
+
+	sink, err := events.NewNATSSink("nats://"+listener.Addr().String(), "comments.", time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	event := events.New(events.CommentCreated, nil)
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if !strings.HasPrefix(msg, "PUB comments.comment.created ") || !strings.Contains(msg, event.ID) {
+			t.Errorf("Unexpected message: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Message was not received")
+	}
+}
@@ -0,0 +1,243 @@
+package persisted_test
+
+import (
+	"context"
+	"encoding/json"
+	"graphql-comments/graphql"
+	"graphql-comments/graphql/complexity"
+	"graphql-comments/graphql/persisted"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/handler"
+)
+
+const query = `{ getPosts { id } }`
+
+func newServer(t *testing.T, cfg persisted.Config) http.Handler {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg.Schema = &schema
+	return persisted.Middleware(cfg)(handler.New(&handler.Config{Schema: &schema}))
+}
+
+func post(t *testing.T, server http.Handler, body map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", "application/json")
+	return serve(t, server, req)
+}
+
+func serve(t *testing.T, server http.Handler, req *http.Request) map[string]interface{} {
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Response is not JSON: %v: %s", err, rec.Body.String())
+	}
+	return response
+}
+
+func errorCode(response map[string]interface{}) string {
+	errs, _ := response["errors"].([]interface{})
+	if len(errs) == 0 {
+		return ""
+	}
+	extensions, _ := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})
+	code, _ := extensions["code"].(string)
+	return code
+}
+
+func extensions(hash string) map[string]interface{} {
+	return map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+	}
+}
+
+func TestAutomaticPersistedQueries(t *testing.T) {
+	server := newServer(t, persisted.Config{Mode: persisted.ModeAPQ, Store: persisted.NewMemoryStore(10)})
+	hash := persisted.Hash(query)
+
+	t.Run("UnknownHash", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"extensions": extensions(hash)})
+		if code := errorCode(response); code != "PERSISTED_QUERY_NOT_FOUND" {
+			t.Errorf("Expected PERSISTED_QUERY_NOT_FOUND, got %v", response)
+		}
+	})
+
+	t.Run("HashMismatch", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"query": query, "extensions": extensions(persisted.Hash("other"))})
+		if code := errorCode(response); code != "PERSISTED_QUERY_HASH_MISMATCH" {
+			t.Errorf("Expected PERSISTED_QUERY_HASH_MISMATCH, got %v", response)
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"query": query, "extensions": extensions(hash)})
+		if _, ok := response["errors"]; ok {
+			t.Errorf("Unexpected errors: %v", response)
+		}
+	})
+
+	t.Run("ExecuteByHash", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"extensions": extensions(hash)})
+		if _, ok := response["errors"]; ok {
+			t.Errorf("Unexpected errors: %v", response)
+		}
+
+		if data, _ := response["data"].(map[string]interface{}); data["getPosts"] == nil {
+			t.Errorf("Expected getPosts in response, got %v", response)
+		}
+	})
+
+	t.Run("NotPersistedWhenRejected", func(t *testing.T) {
+		server := newServer(t, persisted.Config{Mode: persisted.ModeAPQ, Store: persisted.NewMemoryStore(10), Limits: complexity.Limits{MaxDepth: 2}})
+		for name, rejected := range map[string]string{
+			"Invalid":  `{ unknownField }`,
+			"TooDeep":  `{ getPosts { id comments { id } } }`,
+			"TooLong":  `{ getPosts { id } }` + strings.Repeat(" ", persisted.MaxQueryLength),
+			"Unparsed": `{ getPosts {`,
+		} {
+			hash := persisted.Hash(rejected)
+			post(t, server, map[string]interface{}{"query": rejected, "extensions": extensions(hash)})
+			if code := errorCode(post(t, server, map[string]interface{}{"extensions": extensions(hash)})); code != "PERSISTED_QUERY_NOT_FOUND" {
+				t.Errorf("%s: expected query not to be persisted, got %v", name, code)
+			}
+		}
+	})
+
+	t.Run("ExecuteByHashWithGet", func(t *testing.T) {
+		ext, _ := json.Marshal(extensions(hash))
+		req := httptest.NewRequest(http.MethodGet, "/graphql?extensions="+url.QueryEscape(string(ext)), nil)
+		response := serve(t, server, req)
+
+		if data, _ := response["data"].(map[string]interface{}); data["getPosts"] == nil {
+			t.Errorf("Expected getPosts in response, got %v", response)
+		}
+	})
+}
+
+func TestAllowlist(t *testing.T) {
+	manifest, err := persisted.NewManifest(persisted.ManifestOperation{Name: "Posts", Type: "query", Body: query})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := newServer(t, persisted.Config{Mode: persisted.ModeAllowlist, Store: manifest})
+
+	t.Run("RegisteredByHash", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"extensions": extensions(persisted.Hash(query))})
+		if _, ok := response["errors"]; ok {
+			t.Errorf("Unexpected errors: %v", response)
+		}
+	})
+
+	t.Run("RegisteredByText", func(t *testing.T) {
+		response := post(t, server, map[string]interface{}{"query": query})
+		if _, ok := response["errors"]; ok {
+			t.Errorf("Unexpected errors: %v", response)
+		}
+	})
+
+	t.Run("NotRegistered", func(t *testing.T) {
+		other := `{ getPosts { id title } }`
+		response := post(t, server, map[string]interface{}{"query": other})
+		if code := errorCode(response); code != "PERSISTED_QUERY_NOT_IN_LIST" {
+			t.Errorf("Expected PERSISTED_QUERY_NOT_IN_LIST, got %v", response)
+		}
+
+		response = post(t, server, map[string]interface{}{"query": other, "extensions": extensions(persisted.Hash(other))})
+		if code := errorCode(response); code != "PERSISTED_QUERY_NOT_IN_LIST" {
+			t.Errorf("Expected PERSISTED_QUERY_NOT_IN_LIST, got %v", response)
+		}
+	})
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Valid", func(t *testing.T) {
+		path := filepath.Join(dir, "valid.json")
+		os.WriteFile(path, []byte(`{"format":"apollo-persisted-query-manifest","version":1,"operations":[{"id":"`+persisted.Hash(query)+`","name":"Posts","type":"query","body":"`+query+`"}]}`), 0o644)
+
+		manifest, err := persisted.LoadManifest(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if got, ok, _ := manifest.Get(context.Background(), persisted.Hash(query)); !ok || got != query {
+			t.Errorf("Operation was not loaded")
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.json")
+		os.WriteFile(path, []byte(`{"operations":[{"id":"deadbeef","body":"`+query+`"}]}`), 0o644)
+
+		if _, err := persisted.LoadManifest(path); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := persisted.NewMemoryStore(2)
+
+	store.Put(ctx, "a", "query a")
+	store.Put(ctx, "b", "query b")
+	store.Get(ctx, "a")
+	store.Put(ctx, "c", "query c")
+
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Errorf("Least recently used query was not evicted")
+	}
+
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Errorf("Recently used query was evicted")
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := persisted.NewPostgresStore(db, 100)
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO persisted_queries (hash, query) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING")).
+		WithArgs("hash", query).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM persisted_queries WHERE hash IN (SELECT hash FROM persisted_queries ORDER BY created_at DESC, hash OFFSET $1)")).
+		WithArgs(100).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT query FROM persisted_queries WHERE hash = $1")).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"query"}).AddRow(query))
+
+	if err := store.Put(ctx, "hash", query); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	got, ok, err := store.Get(ctx, "hash")
+	if err != nil || !ok || got != query {
+		t.Errorf("Expected stored query, got %q, %v, %v", got, ok, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,9 +1,16 @@
 package postgres_test
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"graphql-comments/events"
 	"graphql-comments/storage"
 	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/sqltest"
+	"graphql-comments/types"
 	"regexp"
 	"testing"
 	"time"
@@ -24,14 +31,23 @@ func TestAddPost(t *testing.T) {
 	defer db.Close()
 
 	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
 	storage.DataBase = &store
 
 	t.Run("AddPost", func(t *testing.T) {
-		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO posts (id, title, content, created_at) VALUES ($1, $2, $3, $4)")).
-			WithArgs(sqlmock.AnyArg(), "Test Title", "Test Content", sqlmock.AnyArg()).
+		sqltest.ExpectUnusedPostID(mock, tenant.DefaultID)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO posts (id, tenant_id, author_id, title, content, created_at, allow_comments) VALUES ($1, $2, $3, $4, $5, $6, $7)")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, nil, "Test Title", "Test Content", sqlmock.AnyArg(), true).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)")).
+			WithArgs(sqlmock.AnyArg(), events.PostCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-		_, err := store.AddPost("Test Title", "Test Content", true)
+		_, err := store.AddPost(ctx, "", "Test Title", "Test Content", true)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
 		}
@@ -40,6 +56,28 @@ func TestAddPost(t *testing.T) {
 			t.Errorf("there were unfulfilled expectations: %s", err)
 		}
 	})
+
+	t.Run("AddPostRollsBackWhenOutboxFails", func(t *testing.T) {
+		sqltest.ExpectUnusedPostID(mock, tenant.DefaultID)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO posts (id, tenant_id, author_id, title, content, created_at, allow_comments) VALUES ($1, $2, $3, $4, $5, $6, $7)")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, nil, "Test Title", "Test Content", sqlmock.AnyArg(), true).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)")).
+			WillReturnError(errors.New("outbox is unavailable"))
+		mock.ExpectRollback()
+
+		_, err := store.AddPost(ctx, "", "Test Title", "Test Content", true)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
 }
 
 func TestAddComment(t *testing.T) {
@@ -47,24 +85,90 @@ func TestAddComment(t *testing.T) {
 	defer db.Close()
 
 	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
 	storage.DataBase = &store
 
 	t.Run("AddCommentToPost", func(t *testing.T) {
-		row := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "allow_comments"}).AddRow("post-id", "Test Title", "Test Content", time.Now(), true)
-		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, content, created_at, allow_comments FROM posts WHERE id = $1")).
-			WithArgs("post-id").WillReturnRows(row)
+		row := sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).AddRow("post-id", nil, "Test Title", "Test Content", time.Now(), true, nil, nil)
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(row)
 
 		row = sqlmock.NewRows([]string{"id"}).AddRow("post-id")
-		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1")).
-			WithArgs("post-id").WillReturnRows(row)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(row)
+		mock.ExpectCommit()
+		sqltest.ExpectUnusedCommentID(mock, tenant.DefaultID)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, NULL, $4, $5, $6, $7)")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, "post-id", nil, "Test Comment", sqlmock.AnyArg(), types.CommentPublished).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4)")).
+			WithArgs(sqlmock.AnyArg(), events.CommentCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		_, err := store.AddComment(ctx, "", "post-id", "", "Test Comment", types.CommentPublished)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("AddPendingComment", func(t *testing.T) {
+		row := sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).AddRow("post-id", nil, "Test Title", "Test Content", time.Now(), true, nil, nil)
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(row)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+		sqltest.ExpectUnusedCommentID(mock, tenant.DefaultID)
 
-		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comments (id, post_id, parent_comment_id, content, created_at) VALUES ($1, $2, NULL, $3, $4)")).
-			WithArgs(sqlmock.AnyArg(), "post-id", "Test Comment", sqlmock.AnyArg()).
+		// Событие о комментарии на модерации не записывается
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO comments (id, tenant_id, post_id, parent_comment_id, author_id, content, created_at, status) VALUES ($1, $2, $3, NULL, $4, $5, $6, $7)")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, "post-id", nil, "Spam", sqlmock.AnyArg(), types.CommentPending).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
-		_, err := store.AddComment("post-id", "", "Test Comment")
+		comment, err := store.AddComment(ctx, "", "post-id", "", "Spam", types.CommentPending)
 		if err != nil {
 			t.Errorf("Unexpected error: %v", err)
+		} else if comment.Status != types.CommentPending {
+			t.Errorf("Expected pending comment, got %s", comment.Status)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("ReplyToParentOfAnotherTenantOrPost", func(t *testing.T) {
+		row := sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).AddRow("post-id", nil, "Test Title", "Test Content", time.Now(), true, nil, nil)
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(row)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).
+			WithArgs("post-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+		sqltest.ExpectUnusedCommentID(mock, tenant.DefaultID)
+
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1 AND tenant_id = $2 AND post_id = $3)")).
+			WithArgs("foreign-id", tenant.DefaultID, "post-id").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		_, err := store.AddComment(ctx, "", "post-id", "foreign-id", "Reply", types.CommentPublished)
+		if !errors.Is(err, storage.ErrParentNotFound) {
+			t.Errorf("Expected ErrParentNotFound, got %v", err)
 		}
 
 		if err := mock.ExpectationsWereMet(); err != nil {
@@ -78,19 +182,184 @@ func TestGetPosts(t *testing.T) {
 	defer db.Close()
 
 	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+	storage.DataBase = &store
+
+	rows := sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "true"}).
+		AddRow("post-id", nil, "Test Title", "Test Content", time.Now(), "true")
+
+	sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments FROM posts WHERE tenant_id = $1 AND namespace IS NULL")).
+		WithArgs(tenant.DefaultID).WillReturnRows(rows)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).WithArgs("post-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("comment-id"))
+	mock.ExpectCommit()
+
+	_, err := store.GetPosts(ctx)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetComments(t *testing.T) {
+	db, mock, _ := NewMock()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+	storage.DataBase = &store
+
+	columns := []string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at"}
+	query := regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED' ORDER BY created_at, id")
+	newRows := func() *sqlmock.Rows {
+		rows := sqlmock.NewRows(columns)
+		for i := 0; i < storage.CommentsPageSize+2; i++ {
+			rows.AddRow(fmt.Sprintf("comment-%d", i), "post-id", nil, nil, "Content", time.Now())
+		}
+		return rows
+	}
+
+	t.Run("FirstPage", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(query).WithArgs("post-id", tenant.DefaultID).WillReturnRows(newRows())
+		mock.ExpectCommit()
+
+		comments, err := store.GetComments(ctx, "post-id", 1)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(comments) != storage.CommentsPageSize || comments[0].ID != "comment-0" {
+			t.Errorf("Expected %d comments starting with comment-0, got %d", storage.CommentsPageSize, len(comments))
+		}
+	})
+
+	t.Run("LastPage", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(query).WithArgs("post-id", tenant.DefaultID).WillReturnRows(newRows())
+		mock.ExpectCommit()
+
+		comments, err := store.GetComments(ctx, "post-id", 2)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(comments) != 2 || comments[0].ID != fmt.Sprintf("comment-%d", storage.CommentsPageSize) {
+			t.Errorf("Unexpected comments: %v", comments)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetNumberOfCommentPages(t *testing.T) {
+	db, mock, _ := NewMock()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
 	storage.DataBase = &store
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "true"}).
-		AddRow("post-id", "Test Title", "Test Content", time.Now(), "true")
+	query := regexp.QuoteMeta("SELECT COUNT(*) FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")
+	for count, want := range map[int]int{0: 0, 1: 1, storage.CommentsPageSize: 1, storage.CommentsPageSize + 1: 2} {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(query).WithArgs("post-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+		mock.ExpectCommit()
+
+		pages, err := store.GetNumberOfCommentPages(ctx, "post-id")
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if pages != want {
+			t.Errorf("Expected %d pages for %d comments, got %d", want, count, pages)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGetReplies(t *testing.T) {
+	db, mock, _ := NewMock()
+	defer db.Close()
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, title, content, created_at, allow_comments FROM posts")).WillReturnRows(rows)
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+	storage.DataBase = &store
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1")).WithArgs("post-id").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("comment-id"))
+	sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE parent_comment_id = $1 AND tenant_id = $2 AND status = 'PUBLISHED' ORDER BY created_at, id")).
+		WithArgs("comment-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("reply-id"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2")).
+		WithArgs("reply-id", tenant.DefaultID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at", "status"}).
+			AddRow("reply-id", "post-id", "comment-id", nil, "Reply", time.Now(), types.CommentPublished))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE parent_comment_id = $1 AND tenant_id = $2 AND status = 'PUBLISHED'")).
+		WithArgs("reply-id", tenant.DefaultID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
 
-	_, err := store.GetPosts()
+	replies, err := store.GetReplies(ctx, "comment-id")
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
+	if len(replies) != 1 || replies[0].ID != "reply-id" || replies[0].ParentCommentID != "comment-id" {
+		t.Errorf("Unexpected replies: %v", replies)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, mock, _ := NewMock()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{
+		DB: db,
+		Timeouts: storage.Timeouts{
+			Default:    time.Second,
+			Operations: map[string]time.Duration{storage.OpGetPosts: 10 * time.Millisecond},
+		},
+	}
+	storage.DataBase = &store
+	ctx := context.Background()
+
+	sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments FROM posts")).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments"}))
+
+	start := time.Now()
+	_, err := store.GetPosts(ctx)
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Query was not canceled by timeout, took %v", elapsed)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	db, mock, _ := NewMock()
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	storage.DataBase = &store
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := store.GetPostByID(ctx, "post-id")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -0,0 +1,225 @@
+package ratelimit_test
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/ratelimit"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := ratelimit.NewMemoryLimiter()
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	t.Run("AllowsBurst", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			result, err := limiter.Allow(ctx, "client-1", limit)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if !result.Allowed {
+				t.Errorf("Request %d was not allowed", i)
+			}
+		}
+	})
+
+	t.Run("RejectsAfterBurst", func(t *testing.T) {
+		result, err := limiter.Allow(ctx, "client-1", limit)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if result.Allowed {
+			t.Errorf("Expected request to be rejected")
+		}
+
+		if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+			t.Errorf("Unexpected retry after: %v", result.RetryAfter)
+		}
+	})
+
+	t.Run("KeysAreIndependent", func(t *testing.T) {
+		result, _ := limiter.Allow(ctx, "client-2", limit)
+		if !result.Allowed {
+			t.Errorf("Request for another key was not allowed")
+		}
+	})
+
+	t.Run("DisabledLimit", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			result, _ := limiter.Allow(ctx, "client-3", ratelimit.Limit{})
+			if !result.Allowed {
+				t.Errorf("Request was rejected by disabled limit")
+			}
+		}
+	})
+}
+
+func TestPostgresLimiter(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	limiter := ratelimit.NewPostgresLimiter(db)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING")).
+		WithArgs("client-1", float64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE")).
+		WithArgs("client-1").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.0, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1")).
+		WithArgs("client-1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := limiter.Allow(ctx, "client-1", ratelimit.Limit{Rate: 0.1, Burst: 5})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if result.Allowed {
+		t.Errorf("Expected request to be rejected")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.RateLimiter = ratelimit.NewMemoryLimiter()
+	gql.RateLimits = ratelimit.Policy{Query: ratelimit.Limit{Rate: 1, Burst: 1}}
+	defer func() { gql.RateLimiter = nil }()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    gql.QueryType,
+		Mutation: gql.MutationType,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := ratelimit.WithClient(context.Background(), "ip:127.0.0.1")
+	do := func() *graphql.Result {
+		return graphql.Do(graphql.Params{Schema: schema, RequestString: "{ getPosts { id } }", Context: ctx})
+	}
+
+	if result := do(); result.HasErrors() {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+
+	result := do()
+	if !result.HasErrors() {
+		t.Fatalf("Expected RATE_LIMITED error")
+	}
+
+	if code := result.Errors[0].Extensions["code"]; code != "RATE_LIMITED" {
+		t.Errorf("Expected code RATE_LIMITED, got %v", code)
+	}
+
+	if _, ok := result.Errors[0].Extensions["retryAfter"]; !ok {
+		t.Errorf("Error does not contain retryAfter")
+	}
+}
+
+func TestGraphQLRateLimitPerOperation(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.RateLimiter = ratelimit.NewMemoryLimiter()
+	gql.RateLimits = ratelimit.Policy{Query: ratelimit.Limit{Rate: 1, Burst: 1}}
+	defer func() { gql.RateLimiter = nil }()
+
+	schema, err := graphql.NewSchema(gql.Schema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := ratelimit.WithClient(context.Background(), "ip:127.0.0.1")
+	do := func() *graphql.Result {
+		return graphql.Do(graphql.Params{Schema: schema, RequestString: "{ a: getPosts { id } b: getPosts { id } }", Context: ctx})
+	}
+
+	if result := do(); result.HasErrors() {
+		t.Errorf("Root fields of one operation must spend one token: %v", result.Errors)
+	}
+	if result := do(); !result.HasErrors() || result.Errors[0].Extensions["code"] != "RATE_LIMITED" {
+		t.Errorf("Expected RATE_LIMITED error, got %v", result.Errors)
+	}
+}
+
+func TestGraphQLRateLimitPerPost(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.RateLimiter = ratelimit.NewMemoryLimiter()
+	gql.RateLimits = ratelimit.Policy{
+		Mutation: ratelimit.Limit{Rate: 100, Burst: 100},
+		Post:     ratelimit.Limit{Rate: 1, Burst: 1},
+	}
+	defer func() { gql.RateLimiter = nil }()
+
+	schema, err := graphql.NewSchema(gql.Schema)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := ratelimit.WithClient(context.Background(), "ip:127.0.0.1")
+	post, err := storage.DataBase.AddPost(ctx, "", "Title", "Content", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	do := func(postID string) *graphql.Result {
+		return graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `mutation($postID: ID!) { addComment(postID: $postID, content: "hello") { id } }`,
+			VariableValues: map[string]interface{}{"postID": postID},
+			Context:        ctx,
+		})
+	}
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			result := do("missing")
+			if !result.HasErrors() || result.Errors[0].Message != storage.ErrPostNotFound.Error() {
+				t.Errorf("Expected %v, got %v", storage.ErrPostNotFound, result.Errors)
+			}
+		}
+	})
+
+	t.Run("TooLongPostID", func(t *testing.T) {
+		result := do(strings.Repeat("p", storage.MaxIDLength+1))
+		if !result.HasErrors() || !strings.Contains(result.Errors[0].Message, "postID is too long") {
+			t.Errorf("Expected postID is too long error, got %v", result.Errors)
+		}
+	})
+
+	t.Run("ExistingPost", func(t *testing.T) {
+		if result := do(post.ID); result.HasErrors() {
+			t.Errorf("Unexpected errors: %v", result.Errors)
+		}
+		if result := do(post.ID); !result.HasErrors() || result.Errors[0].Extensions["code"] != "RATE_LIMITED" {
+			t.Errorf("Expected RATE_LIMITED error, got %v", result.Errors)
+		}
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx := ratelimit.WithClient(context.Background(), "ip:127.0.0.1")
+	if client := ratelimit.Client(ctx); client != "ip:127.0.0.1" {
+		t.Errorf("Unexpected client %q", client)
+	}
+	if client := ratelimit.Client(auth.WithUser(ctx, "user-1")); client != "user:user-1" {
+		t.Errorf("Unexpected client %q", client)
+	}
+}
@@ -0,0 +1,198 @@
+package rest_test
+
+import (
+	"context"
+	"encoding/json"
+	"graphql-comments/graphql"
+	"graphql-comments/ratelimit"
+	"graphql-comments/rest"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type errorBody struct {
+	Error struct {
+		Code    string                 `json:"code"`
+		Message string                 `json:"message"`
+		Details map[string]interface{} `json:"details"`
+	} `json:"error"`
+}
+
+func serve(handler http.Handler, ctx context.Context, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, status int, body interface{}) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	if err := json.NewDecoder(rec.Body).Decode(body); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	var body errorBody
+	decode(t, rec, status, &body)
+	if body.Error.Code != code || body.Error.Message == "" {
+		t.Errorf("Expected error %s, got %+v", code, body.Error)
+	}
+}
+
+func TestPosts(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	handler := rest.NewHandler()
+	ctx := context.Background()
+
+	post, _ := storage.DataBase.AddPost(ctx, "", "Title", "**Content**", true)
+
+	t.Run("List", func(t *testing.T) {
+		var body struct{ Posts []*rest.Post }
+		decode(t, serve(handler, ctx, http.MethodGet, "/api/v1/posts", ""), http.StatusOK, &body)
+		if len(body.Posts) != 1 || body.Posts[0].ID != post.ID {
+			t.Errorf("Unexpected posts: %v", body.Posts)
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		var body rest.Post
+		decode(t, serve(handler, ctx, http.MethodGet, "/api/v1/posts/"+post.ID, ""), http.StatusOK, &body)
+		if body.Title != "Title" || !strings.Contains(body.ContentHTML, "<strong>Content</strong>") {
+			t.Errorf("Unexpected post: %+v", body)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		expectError(t, serve(handler, ctx, http.MethodGet, "/api/v1/posts/missing", ""), http.StatusNotFound, "NOT_FOUND")
+		expectError(t, serve(handler, ctx, http.MethodGet, "/api/v1/unknown", ""), http.StatusNotFound, "NOT_FOUND")
+	})
+
+	t.Run("OtherTenant", func(t *testing.T) {
+		other := tenant.WithTenant(ctx, &tenant.Tenant{ID: "other"})
+		expectError(t, serve(handler, other, http.MethodGet, "/api/v1/posts/"+post.ID, ""), http.StatusNotFound, "NOT_FOUND")
+	})
+}
+
+func TestComments(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	handler := rest.NewHandler()
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: tenant.DefaultID, Limits: tenant.Limits{CommentsPageSize: 2}})
+
+	post, _ := storage.DataBase.AddPost(ctx, "", "Title", "Content", true)
+	target := "/api/v1/posts/" + post.ID + "/comments"
+
+	var first rest.Comment
+	t.Run("Add", func(t *testing.T) {
+		for _, content := range []string{"one", "two", "three"} {
+			var body rest.Comment
+			decode(t, serve(handler, ctx, http.MethodPost, target, `{"content": "`+content+`"}`), http.StatusCreated, &body)
+			if body.Content != content || body.Status != types.CommentPublished {
+				t.Errorf("Unexpected comment: %+v", body)
+			}
+			if first.ID == "" {
+				first = body
+			}
+		}
+	})
+
+	t.Run("Paginate", func(t *testing.T) {
+		var page rest.CommentPage
+		decode(t, serve(handler, ctx, http.MethodGet, target, ""), http.StatusOK, &page)
+		if len(page.Comments) != 2 || page.NextCursor == nil {
+			t.Fatalf("Expected first page with cursor, got %+v", page)
+		}
+
+		var next rest.CommentPage
+		decode(t, serve(handler, ctx, http.MethodGet, target+"?cursor="+*page.NextCursor, ""), http.StatusOK, &next)
+		if len(next.Comments) != 1 || next.Comments[0].Content != "three" || next.NextCursor != nil {
+			t.Errorf("Expected last page, got %+v", next)
+		}
+
+		expectError(t, serve(handler, ctx, http.MethodGet, target+"?cursor=abc", ""), http.StatusBadRequest, "BAD_REQUEST")
+	})
+
+	t.Run("Replies", func(t *testing.T) {
+		var reply rest.Comment
+		decode(t, serve(handler, ctx, http.MethodPost, target, `{"content": "reply", "parentCommentID": "`+first.ID+`"}`), http.StatusCreated, &reply)
+
+		var body struct{ Replies []*rest.Comment }
+		decode(t, serve(handler, ctx, http.MethodGet, "/api/v1/comments/"+first.ID+"/replies", ""), http.StatusOK, &body)
+		if len(body.Replies) != 1 || body.Replies[0].ID != reply.ID {
+			t.Errorf("Unexpected replies: %v", body.Replies)
+		}
+
+		pending, _ := storage.DataBase.AddComment(ctx, "", post.ID, "", "pending", types.CommentPending)
+		expectError(t, serve(handler, ctx, http.MethodGet, "/api/v1/comments/"+pending.ID+"/replies", ""), http.StatusNotFound, "NOT_FOUND")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		expectError(t, serve(handler, ctx, http.MethodPost, target, `{"content": ""}`), http.StatusUnprocessableEntity, "INVALID_INPUT")
+		expectError(t, serve(handler, ctx, http.MethodPost, target, `{"text": "hello"}`), http.StatusBadRequest, "BAD_REQUEST")
+		expectError(t, serve(handler, ctx, http.MethodPost, "/api/v1/posts/missing/comments", `{"content": "hello"}`), http.StatusNotFound, "NOT_FOUND")
+
+		closed, _ := storage.DataBase.AddPost(ctx, "", "Closed", "Content", false)
+		expectError(t, serve(handler, ctx, http.MethodPost, "/api/v1/posts/"+closed.ID+"/comments", `{"content": "hello"}`), http.StatusForbidden, "COMMENTS_CLOSED")
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.RateLimiter = ratelimit.NewMemoryLimiter()
+	gql.RateLimits = ratelimit.Policy{Query: ratelimit.Limit{Rate: 0.001, Burst: 1}}
+	defer func() { gql.RateLimiter, gql.RateLimits = nil, ratelimit.Policy{} }()
+
+	handler := rest.NewHandler()
+	ctx := context.Background()
+
+	serve(handler, ctx, http.MethodGet, "/api/v1/posts", "")
+	rec := serve(handler, ctx, http.MethodGet, "/api/v1/posts", "")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected Retry-After header")
+	}
+
+	var body errorBody
+	decode(t, rec, http.StatusTooManyRequests, &body)
+	if body.Error.Code != "RATE_LIMITED" || body.Error.Details["retryAfter"] == nil {
+		t.Errorf("Unexpected error: %+v", body.Error)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	var document struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	decode(t, serve(rest.NewHandler(), context.Background(), http.MethodGet, "/api/v1/openapi.json", ""), http.StatusOK, &document)
+
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3 document, got %q", document.OpenAPI)
+	}
+	for path, methods := range map[string][]string{
+		"/posts":                 {"get"},
+		"/posts/{id}":            {"get"},
+		"/posts/{id}/comments":   {"get", "post"},
+		"/comments/{id}/replies": {"get"},
+	} {
+		for _, method := range methods {
+			if _, ok := document.Paths[path][method]; !ok {
+				t.Errorf("Expected %s %s to be documented", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
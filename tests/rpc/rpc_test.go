@@ -0,0 +1,222 @@
+package rpc_test
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/rpc"
+	"graphql-comments/rpc/commentspb"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const secret = "secret"
+
+func newClient(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	storage.DataBase = inMemory.NewInMemoryStore()
+	registry, err := tenant.NewRegistry([]*tenant.Tenant{{ID: "acme", APIKeys: []string{"acme-key"}}}, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := rpc.NewServer(registry, "admin-token", secret)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if status.Code(err) != code {
+		t.Errorf("Expected %s, got %v", code, err)
+	}
+}
+
+func TestCommentService(t *testing.T) {
+	client := commentspb.NewCommentServiceClient(newClient(t))
+	ctx := context.Background()
+
+	user, _ := storage.DataBase.AddUser(ctx, "alice")
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+auth.IssueToken(secret, user.ID))
+
+	post, err := client.AddPost(userCtx, &commentspb.AddPostRequest{Title: "Title", Content: "Content"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if post.AuthorId != user.ID || !post.AllowComments {
+		t.Errorf("Unexpected post: %v", post)
+	}
+
+	comment, err := client.AddComment(userCtx, &commentspb.AddCommentRequest{PostId: post.Id, Content: "hello"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if comment.Status != commentspb.CommentStatus_COMMENT_STATUS_PUBLISHED {
+		t.Errorf("Expected published comment, got %v", comment.Status)
+	}
+	reply, err := client.AddComment(ctx, &commentspb.AddCommentRequest{PostId: post.Id, ParentCommentId: comment.Id, Content: "reply"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		posts, err := client.GetPosts(ctx, &commentspb.GetPostsRequest{})
+		if err != nil || len(posts.Posts) != 1 {
+			t.Errorf("Unexpected posts: %v, %v", posts, err)
+		}
+
+		comments, err := client.GetComments(ctx, &commentspb.GetCommentsRequest{PostId: post.Id})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(comments.Comments) != 1 || comments.Comments[0].Id != comment.Id || comments.Pages != 1 {
+			t.Errorf("Unexpected comments: %v", comments)
+		}
+
+		replies, err := client.GetReplies(ctx, &commentspb.GetRepliesRequest{CommentId: comment.Id})
+		if err != nil || len(replies.Replies) != 1 || replies.Replies[0].Id != reply.Id {
+			t.Errorf("Unexpected replies: %v, %v", replies, err)
+		}
+
+		got, err := client.GetComment(ctx, &commentspb.GetCommentRequest{Id: comment.Id})
+		if err != nil || got.AuthorId != user.ID {
+			t.Errorf("Unexpected comment: %v, %v", got, err)
+		}
+
+		found, err := client.GetUser(ctx, &commentspb.GetUserRequest{Key: &commentspb.GetUserRequest_Name{Name: "alice"}})
+		if err != nil || found.Id != user.ID {
+			t.Errorf("Unexpected user: %v, %v", found, err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := client.AddComment(ctx, &commentspb.AddCommentRequest{PostId: post.Id, Content: ""})
+		expectCode(t, err, codes.InvalidArgument)
+		_, err = client.AddPost(ctx, &commentspb.AddPostRequest{Title: "", Content: "Content"})
+		expectCode(t, err, codes.InvalidArgument)
+		_, err = client.GetPost(ctx, &commentspb.GetPostRequest{Id: "missing"})
+		expectCode(t, err, codes.NotFound)
+		_, err = client.GetUser(ctx, &commentspb.GetUserRequest{})
+		expectCode(t, err, codes.InvalidArgument)
+
+		closed, _ := client.AddPost(ctx, &commentspb.AddPostRequest{Title: "Closed", Content: "Content", AllowComments: new(bool)})
+		_, err = client.AddComment(ctx, &commentspb.AddCommentRequest{PostId: closed.Id, Content: "hello"})
+		expectCode(t, err, codes.FailedPrecondition)
+
+		pending, _ := storage.DataBase.AddComment(ctx, "", post.Id, "", "pending", types.CommentPending)
+		_, err = client.GetComment(ctx, &commentspb.GetCommentRequest{Id: pending.ID})
+		expectCode(t, err, codes.NotFound)
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		acme := metadata.AppendToOutgoingContext(ctx, "x-api-key", "acme-key")
+		_, err := client.GetPost(acme, &commentspb.GetPostRequest{Id: post.Id})
+		expectCode(t, err, codes.NotFound)
+
+		unknown := metadata.AppendToOutgoingContext(ctx, "x-api-key", "wrong")
+		_, err = client.GetPosts(unknown, &commentspb.GetPostsRequest{})
+		expectCode(t, err, codes.Unauthenticated)
+	})
+}
+
+func TestWatchPost(t *testing.T) {
+	client := commentspb.NewCommentServiceClient(newClient(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, err := client.AddPost(ctx, &commentspb.AddPostRequest{Title: "Title", Content: "Content"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stream, err := client.WatchPost(ctx, &commentspb.WatchPostRequest{PostId: post.Id})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Заголовки приходят после оформления подписки
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Комментарий на модерации не рассылается
+	storage.DataBase.AddComment(ctx, "", post.Id, "", "pending", types.CommentPending)
+	comment, err := client.AddComment(ctx, &commentspb.AddCommentRequest{PostId: post.Id, Content: "hello"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	received, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if received.Id != comment.Id || received.Content != "hello" {
+		t.Errorf("Unexpected comment: %v", received)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		stream, err := client.WatchPost(ctx, &commentspb.WatchPostRequest{PostId: "missing"})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		expectCode(t, err, codes.NotFound)
+	})
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := newClient(t)
+	ctx := context.Background()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: commentspb.CommentService_ServiceDesc.ServiceName})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING, got %v", resp.Status)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var found bool
+	for _, service := range reply.GetListServicesResponse().GetService() {
+		found = found || service.Name == commentspb.CommentService_ServiceDesc.ServiceName
+	}
+	if !found {
+		t.Errorf("Expected CommentService to be listed, got %v", reply)
+	}
+}
@@ -0,0 +1,128 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"graphql-comments/graphql"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+const source = `
+enum Color {
+    RED
+    GREEN
+}
+
+type Item {
+    id: ID!
+    color: Color!
+    label: String!
+    children: [Item!]!
+}
+
+input ItemFilter {
+    color: Color = RED
+}
+
+type Query {
+    items(filter: ItemFilter, first: Int = 10): [Item!]!
+}
+
+type Mutation {
+    addItem(color: Color = GREEN): Item!
+}
+`
+
+func resolvers() gql.Resolvers {
+	return gql.Resolvers{
+		"Item.label": func(params graphql.ResolveParams) (interface{}, error) {
+			return "item " + params.Source.(map[string]interface{})["id"].(string), nil
+		},
+		"Query.items": func(params graphql.ResolveParams) (interface{}, error) {
+			filter, _ := params.Args["filter"].(map[string]interface{})
+			return []map[string]interface{}{
+				{"id": "1", "color": filter["color"], "children": []map[string]interface{}{}},
+			}, nil
+		},
+		"Mutation.addItem": func(params graphql.ResolveParams) (interface{}, error) {
+			return map[string]interface{}{"id": "2", "color": params.Args["color"], "children": []map[string]interface{}{}}, nil
+		},
+	}
+}
+
+func TestBuildSchema(t *testing.T) {
+	config, err := gql.BuildSchema(source, resolvers())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Query == nil || config.Mutation == nil || config.Subscription != nil || len(config.Types) != 5 {
+		t.Fatalf("Unexpected config: %+v", config)
+	}
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		`{ items(filter: {}) { id color label children { id } } }`: `{"items":[{"children":[],"color":"RED","id":"1","label":"item 1"}]}`,
+		`mutation { addItem { id color } }`:                        `{"addItem":{"color":"GREEN","id":"2"}}`,
+	}
+	for query, expected := range tests {
+		t.Run(query, func(t *testing.T) {
+			result := graphql.Do(graphql.Params{Schema: schema, RequestString: query})
+			if result.HasErrors() {
+				t.Fatalf("Unexpected errors: %v", result.Errors)
+			}
+			if data := marshal(t, result.Data); data != expected {
+				t.Errorf("Expected %s, got %s", expected, data)
+			}
+		})
+	}
+}
+
+func TestBuildSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		resolvers func(gql.Resolvers)
+		message   string
+	}{
+		{"MissingResolver", source, func(r gql.Resolvers) { delete(r, "Mutation.addItem") }, "field Mutation.addItem has no resolver"},
+		{"UnboundResolver", source, func(r gql.Resolvers) { r["Item.name"] = r["Item.label"] }, "resolver Item.name is not bound to any field"},
+		{"UnknownType", source + "type Extra { owner: Owner }", func(gql.Resolvers) {}, "field Extra.owner: Owner is not an output type"},
+		{"InputType", source + "type Extra { items(item: Item): Int }", func(gql.Resolvers) {}, "argument Extra.items(item): Item is not an input type"},
+		{"DuplicateType", source + "enum Color { BLUE }", func(gql.Resolvers) {}, "type Color is defined more than once"},
+		{"Syntax", "type Query {", func(gql.Resolvers) {}, "Syntax Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := resolvers()
+			tt.resolvers(r)
+			_, err := gql.BuildSchema(tt.source, r)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected error %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+// TestSchema проверяет, что схема сервиса строится по schema.graphql
+func TestSchema(t *testing.T) {
+	if _, err := graphql.NewSchema(gql.Schema); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if gql.QueryType != gql.Schema.Query || gql.MutationType != gql.Schema.Mutation || gql.SubscriptionType != gql.Schema.Subscription {
+		t.Errorf("Root types do not match the schema")
+	}
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,145 @@
+package seed_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"graphql-comments/commentsctl"
+	"graphql-comments/seed"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+
+	config := seed.Config{
+		Posts:            5,
+		Users:            3,
+		MinComments:      10,
+		MaxComments:      20,
+		DepthWeights:     []float64{1, 1, 1},
+		MinContentLength: 30,
+		MaxContentLength: 40,
+		Seed:             42,
+	}
+	result, err := seed.Generate(ctx, store, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Users != 3 || result.Posts != 5 || result.Comments < 50 || result.Comments > 100 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(result.Depths) != 3 || result.Depths[0]+result.Depths[1]+result.Depths[2] != result.Comments || result.Depths[2] == 0 {
+		t.Errorf("Unexpected depths: %v", result.Depths)
+	}
+
+	comments, err := store.ListComments(ctx, storage.CommentFilter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(comments) != result.Comments {
+		t.Errorf("Expected %d comments, got %d", result.Comments, len(comments))
+	}
+	byID := make(map[string]*types.Comment, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+	for _, comment := range comments {
+		if n := len(comment.Content); n < 30 || n > 40 {
+			t.Errorf("Content length %d is out of range", n)
+		}
+		depth := 0
+		for parent := byID[comment.ParentCommentID]; parent != nil; parent = byID[parent.ParentCommentID] {
+			if parent.PostID != comment.PostID {
+				t.Errorf("Reply %s belongs to another post than its parent", comment.ID)
+			}
+			depth++
+		}
+		if depth > 2 {
+			t.Errorf("Comment %s is nested %d levels deep", comment.ID, depth)
+		}
+	}
+
+	t.Run("Reproducible", func(t *testing.T) {
+		again := inMemory.NewInMemoryStore()
+		storage.DataBase = again
+		repeated, err := seed.Generate(ctx, again, config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if repeated.Comments != result.Comments || repeated.Depths[1] != result.Depths[1] {
+			t.Errorf("Expected the same result for the same seed, got %+v and %+v", repeated, result)
+		}
+
+		// Повторный запуск использует уже созданных пользователей
+		repeated, err = seed.Generate(ctx, again, config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if repeated.Users != 0 || repeated.Posts != 5 {
+			t.Errorf("Unexpected result: %+v", repeated)
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		store := inMemory.NewInMemoryStore()
+		storage.DataBase = store
+		if _, err := seed.Generate(ctx, store, seed.Config{Posts: 1, MinComments: 3, MaxComments: 3, DepthWeights: []float64{1}, MinContentLength: 1, MaxContentLength: 1}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		comments, _ := store.ListComments(ctx, storage.CommentFilter{})
+		for _, comment := range comments {
+			if comment.AuthorID != "" || comment.ParentCommentID != "" {
+				t.Errorf("Expected anonymous top-level comment, got %+v", comment)
+			}
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		invalid := []seed.Config{
+			{Posts: -1, DepthWeights: []float64{1}, MinContentLength: 1, MaxContentLength: 1},
+			{MinComments: 5, MaxComments: 1, DepthWeights: []float64{1}, MinContentLength: 1, MaxContentLength: 1},
+			{DepthWeights: []float64{0, 0}, MinContentLength: 1, MaxContentLength: 1},
+			{DepthWeights: []float64{1}, MinContentLength: 1, MaxContentLength: storage.MaxCommentLength + 1},
+		}
+		for _, config := range invalid {
+			if err := config.Validate(); err == nil {
+				t.Errorf("Expected error for %+v", config)
+			}
+		}
+		if err := seed.DefaultConfig.Validate(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestCLI(t *testing.T) {
+	registry, err := tenant.NewRegistry(nil, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store := inMemory.NewInMemoryStore()
+	storage.DataBase = store
+	stdout := &bytes.Buffer{}
+	cli := &commentsctl.CLI{Store: store, Tenants: registry, Stdout: stdout}
+
+	args := []string{"-o", "json", "seed", "-posts", "2", "-users", "0", "-min-comments", "4", "-max-comments", "4", "-depth", "1, 1"}
+	if err := cli.Run(context.Background(), args); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var result seed.Result
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil || result.Posts != 2 || result.Comments != 8 || len(result.Depths) != 2 {
+		t.Errorf("Unexpected output %q: %v", stdout.String(), err)
+	}
+
+	if err := cli.Run(context.Background(), []string{"seed", "-depth", "a,b"}); !errors.Is(err, commentsctl.ErrUsage) {
+		t.Errorf("Expected ErrUsage, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package spam_test
+
+import (
+	"context"
+	"graphql-comments/auth"
+	"graphql-comments/graphql"
+	"graphql-comments/spam"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func check(t *testing.T, filter spam.Filter, candidate *spam.Candidate) spam.Verdict {
+	t.Helper()
+
+	decision, err := filter.Check(context.Background(), candidate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return decision.Verdict
+}
+
+func TestBlockedWords(t *testing.T) {
+	filter := spam.NewBlockedWords([]string{"casino"})
+
+	cases := []struct {
+		content string
+		want    spam.Verdict
+	}{
+		{"best CASINO online", spam.Reject},
+		{"casino!", spam.Reject},
+		{"casinos are not blocked", spam.Allow},
+		{"nice post", spam.Allow},
+	}
+	for _, c := range cases {
+		t.Run(c.content, func(t *testing.T) {
+			if got := check(t, filter, &spam.Candidate{Content: c.content}); got != c.want {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestLinkLimit(t *testing.T) {
+	filter := &spam.LinkLimit{Pending: 2, Max: 3}
+
+	cases := []struct {
+		name    string
+		content string
+		want    spam.Verdict
+	}{
+		{"NoLinks", "hello", spam.Allow},
+		{"OneLink", "see https://example.com", spam.Allow},
+		{"Pending", "http://a.example www.b.example", spam.Pending},
+		{"Reject", "http://a.example https://b.example www.c.example", spam.Reject},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := check(t, filter, &spam.Candidate{Content: c.content}); got != c.want {
+				t.Errorf("Expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	ctx := context.Background()
+	filter := spam.NewDuplicates(50 * time.Millisecond)
+	candidate := &spam.Candidate{Author: "a", PostID: "p", Content: "Hello  world"}
+
+	if got := check(t, filter, candidate); got != spam.Allow {
+		t.Errorf("Expected first comment to be allowed, got %v", got)
+	}
+	if got := check(t, filter, candidate); got != spam.Allow {
+		t.Errorf("Expected uncommitted comment to be allowed again, got %v", got)
+	}
+
+	filter.Commit(ctx, candidate)
+	if got := check(t, filter, &spam.Candidate{Author: "a", PostID: "p", Content: "hello world"}); got != spam.Reject {
+		t.Errorf("Expected duplicate to be rejected, got %v", got)
+	}
+	if got := check(t, filter, &spam.Candidate{Author: "b", PostID: "p", Content: "hello world"}); got != spam.Allow {
+		t.Errorf("Expected other author to be allowed, got %v", got)
+	}
+	if got := check(t, filter, &spam.Candidate{Author: "a", PostID: "q", Content: "hello world"}); got != spam.Allow {
+		t.Errorf("Expected other post to be allowed, got %v", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := check(t, filter, candidate); got != spam.Allow {
+		t.Errorf("Expected comment to be allowed after window, got %v", got)
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	classifiers := spam.NewClassifiers()
+	classifier := classifiers.For(tenant.DefaultID)
+	filter := &spam.BayesFilter{Classifiers: classifiers, PendingScore: 0.7, RejectScore: 0.99, MinTraining: 3}
+
+	candidate := &spam.Candidate{Content: "cheap pills buy now"}
+	if got := check(t, filter, candidate); got != spam.Allow {
+		t.Errorf("Expected untrained classifier to allow, got %v", got)
+	}
+
+	for _, content := range []string{"buy cheap pills now", "cheap pills discount", "buy now limited offer pills"} {
+		classifier.Train(content, true)
+	}
+	for _, content := range []string{"great article thanks", "I disagree with the second point", "thanks for the detailed explanation"} {
+		classifier.Train(content, false)
+	}
+
+	if score := classifier.Score("cheap pills buy now"); score < 0.9 {
+		t.Errorf("Expected high spam score, got %f", score)
+	}
+	if score := classifier.Score("thanks for the article"); score > 0.1 {
+		t.Errorf("Expected low spam score, got %f", score)
+	}
+	if got := check(t, filter, candidate); got == spam.Allow {
+		t.Errorf("Expected spam to be held or rejected")
+	}
+
+	// Решения модераторов арендатора не влияют на других арендаторов
+	other := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "other"})
+	if decision, err := filter.Check(other, candidate); err != nil || decision.Verdict != spam.Allow {
+		t.Errorf("Expected untrained classifier of other tenant to allow, got %v %v", decision, err)
+	}
+	if got := check(t, filter, &spam.Candidate{Content: "thanks for the article"}); got != spam.Allow {
+		t.Errorf("Expected ham to be allowed, got %v", got)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := spam.Chain{&spam.LinkLimit{Pending: 1}, spam.NewBlockedWords([]string{"casino"})}
+
+	decision, err := chain.Check(context.Background(), &spam.Candidate{Content: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decision.Verdict != spam.Pending || decision.Filter != "link_limit" {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+
+	decision, _ = chain.Check(context.Background(), &spam.Candidate{Content: "https://casino.example casino"})
+	if decision.Verdict != spam.Reject || decision.Filter != "blocked_words" {
+		t.Errorf("Expected later reject to take precedence, got %+v", decision)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	gql.ContentFilter = spam.NewChain(spam.Options{BlockedWords: []string{"casino"}, PendingLinks: 1, DuplicateWindow: time.Minute}, nil)
+	defer func() { gql.ContentFilter = nil }()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	author := auth.WithUser(context.Background(), "author")
+	other := auth.WithUser(context.Background(), "other")
+	post, _ := storage.DataBase.AddPost(author, "", "Title", "Content", true)
+
+	addComment := func(content string) *graphql.Result {
+		return graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `mutation($postID: ID!, $content: String!) { addComment(postID: $postID, content: $content) { id status } }`,
+			VariableValues: map[string]interface{}{"postID": post.ID, "content": content},
+			Context:        author,
+		})
+	}
+	getComment := func(ctx context.Context, id string) *graphql.Result {
+		return graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `query($id: ID!) { getCommentByID(id: $id) { id status } }`,
+			VariableValues: map[string]interface{}{"id": id},
+			Context:        ctx,
+		})
+	}
+
+	t.Run("Rejected", func(t *testing.T) {
+		result := addComment("visit my casino")
+		if !result.HasErrors() {
+			t.Fatalf("Expected error, got %v", result.Data)
+		}
+		if code := result.Errors[0].Extensions["code"]; code != "COMMENT_REJECTED" {
+			t.Errorf("Expected COMMENT_REJECTED, got %v", code)
+		}
+		if len(storage.DataBase.(*inMemory.DataStoreInMemory).Tenants[tenant.DefaultID].Comments) != 0 {
+			t.Errorf("Rejected comment was stored")
+		}
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		result := addComment("see https://example.com")
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		comment := result.Data.(map[string]interface{})["addComment"].(map[string]interface{})
+		if comment["status"] != types.CommentPending {
+			t.Fatalf("Expected pending comment, got %v", comment)
+		}
+		id := comment["id"].(string)
+
+		if result := getComment(author, id); result.HasErrors() {
+			t.Errorf("Expected author to see pending comment: %v", result.Errors)
+		}
+		if result := getComment(auth.WithAdmin(context.Background()), id); result.HasErrors() {
+			t.Errorf("Expected admin to see pending comment: %v", result.Errors)
+		}
+		if result := getComment(other, id); !result.HasErrors() {
+			t.Errorf("Expected pending comment to be hidden, got %v", result.Data)
+		}
+		if comments, _ := storage.DataBase.GetComments(other, post.ID, 1); len(comments) != 0 {
+			t.Errorf("Expected pending comment to be excluded from list, got %v", comments)
+		}
+	})
+
+	t.Run("ResentAfterFailedSave", func(t *testing.T) {
+		storage.DataBase.SetAllowComments(author, "author", post.ID, false)
+		if result := addComment("fine"); !result.HasErrors() {
+			t.Fatalf("Expected error for closed post, got %v", result.Data)
+		}
+		storage.DataBase.SetAllowComments(author, "author", post.ID, true)
+
+		if result := addComment("fine"); result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		if result := addComment("fine"); !result.HasErrors() || result.Errors[0].Extensions["filter"] != "duplicates" {
+			t.Errorf("Expected saved comment to be rejected as duplicate, got %v", result.Errors)
+		}
+	})
+
+	t.Run("Published", func(t *testing.T) {
+		result := addComment(strings.Repeat("fine ", 3))
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		comment := result.Data.(map[string]interface{})["addComment"].(map[string]interface{})
+		if comment["status"] != types.CommentPublished {
+			t.Errorf("Expected published comment, got %v", comment)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+// Package sqltest содержит общие помощники тестов хранилищ PostgreSQL на sqlmock
+package sqltest
+
+import (
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ExpectTenantTx ожидает начало транзакции арендатора tenantID, в которой хранилище
+// выполняет запросы к таблицам с row-level security
+func ExpectTenantTx(mock sqlmock.Sqlmock, tenantID string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+		WithArgs(tenantID).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// ExpectUnusedPostID ожидает проверку того, что сгенерированный ID поста арендатора
+// tenantID не занят
+func ExpectUnusedPostID(mock sqlmock.Sqlmock, tenantID string) {
+	ExpectTenantTx(mock, tenantID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+		WithArgs(sqlmock.AnyArg(), tenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}))
+	mock.ExpectRollback()
+}
+
+// ExpectUnusedCommentID ожидает проверку того, что сгенерированный ID комментария
+// арендатора tenantID не занят
+func ExpectUnusedCommentID(mock sqlmock.Sqlmock, tenantID string) {
+	ExpectTenantTx(mock, tenantID)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, post_id, parent_comment_id, author_id, content, created_at, status FROM comments WHERE id = $1 AND tenant_id = $2")).
+		WithArgs(sqlmock.AnyArg(), tenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "parent_comment_id", "author_id", "content", "created_at", "status"}))
+	mock.ExpectRollback()
+}
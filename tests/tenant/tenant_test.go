@@ -0,0 +1,244 @@
+package tenant_test
+
+import (
+	"context"
+	"encoding/json"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/sqltest"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+func newRegistry(t *testing.T, fallbackID string) *tenant.Registry {
+	registry, err := tenant.NewRegistry([]*tenant.Tenant{
+		{ID: "acme", APIKeys: []string{"acme-key"}, Hosts: []string{"comments.acme.com"}},
+		{ID: "globex", APIKeys: []string{"globex-key"}, Hosts: []string{"globex.com"}},
+	}, fallbackID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return registry
+}
+
+func TestResolve(t *testing.T) {
+	registry := newRegistry(t, tenant.DefaultID)
+
+	tests := []struct {
+		name    string
+		host    string
+		headers map[string]string
+		want    string
+	}{
+		{"APIKey", "globex.com", map[string]string{tenant.APIKeyHeader: "acme-key"}, "acme"},
+		{"APIKeyWithHeader", "globex.com", map[string]string{tenant.APIKeyHeader: "acme-key", tenant.IDHeader: "acme"}, "acme"},
+		{"Host", "COMMENTS.acme.com:8084", nil, "acme"},
+		{"Fallback", "localhost:8084", nil, tenant.DefaultID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			req.Host = tt.host
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			got, err := registry.Resolve(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("Expected tenant %q, got %q", tt.want, got.ID)
+			}
+		})
+	}
+
+	t.Run("HeaderWithoutMatchingKey", func(t *testing.T) {
+		for name, headers := range map[string]map[string]string{
+			"NoKey":       {tenant.IDHeader: "acme"},
+			"OtherTenant": {tenant.APIKeyHeader: "acme-key", tenant.IDHeader: "globex"},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			for header, value := range headers {
+				req.Header.Set(header, value)
+			}
+			if _, err := registry.Resolve(req); err != tenant.ErrUnknownTenant {
+				t.Errorf("Expected ErrUnknownTenant for %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		for name, value := range map[string]string{tenant.APIKeyHeader: "wrong", tenant.IDHeader: "initech"} {
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			req.Header.Set(name, value)
+			if _, err := registry.Resolve(req); err != tenant.ErrUnknownTenant {
+				t.Errorf("Expected ErrUnknownTenant for %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("NoFallback", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		if _, err := newRegistry(t, "").Resolve(req); err != tenant.ErrUnknownTenant {
+			t.Errorf("Expected ErrUnknownTenant, got %v", err)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		_, err := tenant.NewRegistry([]*tenant.Tenant{
+			{ID: "acme", APIKeys: []string{"key"}},
+			{ID: "globex", APIKeys: []string{"key"}},
+		}, tenant.DefaultID)
+		if err == nil {
+			t.Errorf("Expected error for duplicate API key")
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	var resolved string
+	handler := tenant.Middleware(newRegistry(t, ""))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resolved = tenant.ID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set(tenant.APIKeyHeader, "globex-key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if resolved != "globex" {
+		t.Errorf("Expected tenant globex, got %q", resolved)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", rec.Code)
+	}
+	var body struct {
+		Errors []struct {
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Extensions["code"] != "UNKNOWN_TENANT" {
+		t.Errorf("Expected UNKNOWN_TENANT error, got %v", body.Errors)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	acme := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "acme"})
+	globex := tenant.WithTenant(context.Background(), &tenant.Tenant{
+		ID:     "globex",
+		Limits: tenant.Limits{MaxCommentLength: 10, CommentsPageSize: 1},
+	})
+
+	post, err := storage.DataBase.AddPost(acme, "", "Title", "Content", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := storage.DataBase.AddComment(acme, "", post.ID, "", "hello", "PUBLISHED"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("Posts", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `query($id: ID!) { getPostByID(id: $id) { id } }`,
+			VariableValues: map[string]interface{}{"id": post.ID},
+			Context:        globex,
+		})
+		if !result.HasErrors() {
+			t.Errorf("Expected post of another tenant to be hidden, got %v", result.Data)
+		}
+
+		posts, err := storage.DataBase.GetPosts(globex)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected no posts for globex, got %v", posts)
+		}
+		if posts, _ := storage.DataBase.GetPosts(acme); len(posts) != 1 {
+			t.Errorf("Expected 1 post for acme, got %v", posts)
+		}
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		if _, err := storage.DataBase.AddComment(globex, "", post.ID, "", "hello", "PUBLISHED"); err == nil {
+			t.Errorf("Expected error commenting on post of another tenant")
+		}
+		if _, err := storage.DataBase.GetComments(globex, post.ID, 1); err == nil {
+			t.Errorf("Expected error listing comments of another tenant")
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		own, _ := storage.DataBase.AddPost(globex, "", "Title", "Content", true)
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `mutation($postID: ID!, $content: String!) { addComment(postID: $postID, content: $content) { id } }`,
+			VariableValues: map[string]interface{}{"postID": own.ID, "content": strings.Repeat("a", 11)},
+			Context:        globex,
+		})
+		if !result.HasErrors() || !strings.Contains(result.Errors[0].Message, "maximum 10 chars") {
+			t.Errorf("Expected tenant comment length limit, got %v", result.Errors)
+		}
+
+		storage.DataBase.AddComment(globex, "", own.ID, "", "first", "PUBLISHED")
+		storage.DataBase.AddComment(globex, "", own.ID, "", "second", "PUBLISHED")
+		comments, err := storage.DataBase.GetComments(globex, own.ID, 1)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(comments) != 1 {
+			t.Errorf("Expected tenant page size 1, got %d comments", len(comments))
+		}
+	})
+}
+
+func TestPostgresTenantScope(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "acme"})
+
+	sqltest.ExpectTenantTx(mock, "acme")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+		WithArgs("post-id", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).
+			AddRow("post-id", nil, "Title", "Content", time.Now(), true, nil, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).
+		WithArgs("post-id", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	if _, err := store.GetPostByID(ctx, "post-id"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
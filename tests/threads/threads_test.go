@@ -0,0 +1,190 @@
+package threads_test
+
+import (
+	"context"
+	"errors"
+	gql "graphql-comments/graphql"
+	"graphql-comments/spam"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/storage/postgres"
+	"graphql-comments/tenant"
+	"graphql-comments/tests/gqltest"
+	"graphql-comments/tests/sqltest"
+	"graphql-comments/types"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	threadQuery = `query($namespace: String!, $id: ID!) {
+		threadByExternalID(namespace: $namespace, id: $id) { id namespace externalID comments post { id } }
+	}`
+	addComment = `mutation($namespace: String!, $externalID: ID!, $content: String!, $parentCommentID: ID) {
+		addCommentToExternal(namespace: $namespace, externalID: $externalID, content: $content, parentCommentID: $parentCommentID) { id postID }
+	}`
+)
+
+func TestExternalThreads(t *testing.T) {
+	schema := gqltest.NewSchema(t)
+	ctx := context.Background()
+	article := map[string]interface{}{"namespace": "articles", "id": "how-to-42"}
+
+	t.Run("NoThreadYet", func(t *testing.T) {
+		data := gqltest.Do(t, schema, ctx, threadQuery, article)
+		if data["threadByExternalID"] != nil {
+			t.Errorf("Expected no thread before the first comment, got %v", data["threadByExternalID"])
+		}
+	})
+
+	var threadID, firstID string
+	t.Run("CreatedLazily", func(t *testing.T) {
+		data := gqltest.Do(t, schema, ctx, addComment, map[string]interface{}{"namespace": "articles", "externalID": "how-to-42", "content": "first"})
+		comment := data["addCommentToExternal"].(map[string]interface{})
+		threadID, firstID = comment["postID"].(string), comment["id"].(string)
+
+		data = gqltest.Do(t, schema, ctx, addComment, map[string]interface{}{
+			"namespace": "articles", "externalID": "how-to-42", "content": "reply", "parentCommentID": firstID,
+		})
+		if postID := data["addCommentToExternal"].(map[string]interface{})["postID"]; postID != threadID {
+			t.Errorf("Expected comment in thread %s, got %v", threadID, postID)
+		}
+
+		thread := gqltest.Do(t, schema, ctx, threadQuery, article)["threadByExternalID"].(map[string]interface{})
+		if thread["id"] != threadID || thread["namespace"] != "articles" || thread["externalID"] != "how-to-42" {
+			t.Errorf("Unexpected thread: %v", thread)
+		}
+		if comments := thread["comments"].([]interface{}); len(comments) != 1 || comments[0] != firstID {
+			t.Errorf("Expected 1 top-level comment, got %v", comments)
+		}
+		if thread["post"] != nil {
+			t.Errorf("Expected external thread not to be a post, got %v", thread["post"])
+		}
+	})
+
+	t.Run("NotListedAsPost", func(t *testing.T) {
+		posts, err := storage.DataBase.GetPosts(ctx)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("Expected threads to be excluded from posts, got %v", posts)
+		}
+	})
+
+	t.Run("PostAsThread", func(t *testing.T) {
+		post, _ := storage.DataBase.AddPost(ctx, "", "Title", "Content", true)
+		gqltest.Do(t, schema, ctx, addComment, map[string]interface{}{"namespace": types.PostNamespace, "externalID": post.ID, "content": "hello"})
+
+		thread := gqltest.Do(t, schema, ctx, threadQuery, map[string]interface{}{"namespace": types.PostNamespace, "id": post.ID})["threadByExternalID"].(map[string]interface{})
+		if thread["externalID"] != post.ID || thread["post"].(map[string]interface{})["id"] != post.ID {
+			t.Errorf("Expected post to be a thread, got %v", thread)
+		}
+		if comments := thread["comments"].([]interface{}); len(comments) != 1 {
+			t.Errorf("Expected 1 comment, got %v", comments)
+		}
+	})
+
+	t.Run("TenantIsolation", func(t *testing.T) {
+		other := tenant.WithTenant(ctx, &tenant.Tenant{ID: "other"})
+		if data := gqltest.Do(t, schema, other, threadQuery, article); data["threadByExternalID"] != nil {
+			t.Errorf("Expected thread of another tenant to be hidden, got %v", data["threadByExternalID"])
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, variables := range []map[string]interface{}{
+			{"namespace": "", "externalID": "id", "content": "hello"},
+			{"namespace": strings.Repeat("n", storage.MaxNamespaceLength+1), "externalID": "id", "content": "hello"},
+			{"namespace": "articles", "externalID": strings.Repeat("i", storage.MaxExternalIDLength+1), "content": "hello"},
+			{"namespace": "articles", "externalID": "new", "content": ""},
+		} {
+			result := graphql.Do(graphql.Params{Schema: schema, RequestString: addComment, VariableValues: variables, Context: ctx})
+			if !result.HasErrors() {
+				t.Errorf("Expected error for %v", variables)
+			}
+		}
+		if _, err := storage.DataBase.GetThread(ctx, "articles", "new"); !errors.Is(err, storage.ErrThreadNotFound) {
+			t.Errorf("Expected no thread for rejected comment, got %v", err)
+		}
+	})
+
+	t.Run("RejectedBySpamFilter", func(t *testing.T) {
+		gql.ContentFilter = spam.NewChain(spam.Options{BlockedWords: []string{"casino"}}, nil)
+		defer func() { gql.ContentFilter = nil }()
+
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: addComment, Context: ctx,
+			VariableValues: map[string]interface{}{"namespace": "articles", "externalID": "spam", "content": "visit my casino"}})
+		if !result.HasErrors() {
+			t.Fatalf("Expected error, got %v", result.Data)
+		}
+		if _, err := storage.DataBase.GetThread(ctx, "articles", "spam"); !errors.Is(err, storage.ErrThreadNotFound) {
+			t.Errorf("Expected no thread for spam comment, got %v", err)
+		}
+	})
+}
+
+func TestPostgresThreads(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	store := postgres.DataStorePostgres{DB: db}
+	ctx := context.Background()
+	storage.DataBase = inMemory.NewInMemoryStore()
+
+	t.Run("GetThreadNotFound", func(t *testing.T) {
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM posts WHERE tenant_id = $1 AND namespace = $2 AND external_id = $3")).
+			WithArgs(tenant.DefaultID, "articles", "how-to-42").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		if _, err := store.GetThread(ctx, "articles", "how-to-42"); !errors.Is(err, storage.ErrThreadNotFound) {
+			t.Errorf("Expected ErrThreadNotFound, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+
+	t.Run("AddThread", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.tenant_id', $1, true)")).
+			WithArgs(tenant.DefaultID).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO posts (id, tenant_id, title, content, created_at, allow_comments, namespace, external_id) VALUES ($1, $2, '', '', $3, TRUE, $4, $5) ON CONFLICT (tenant_id, namespace, external_id) DO NOTHING")).
+			WithArgs(sqlmock.AnyArg(), tenant.DefaultID, sqlmock.AnyArg(), "articles", "how-to-42").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		sqltest.ExpectTenantTx(mock, tenant.DefaultID)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM posts WHERE tenant_id = $1 AND namespace = $2 AND external_id = $3")).
+			WithArgs(tenant.DefaultID, "articles", "how-to-42").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("thread-id"))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id, author_id, title, content, created_at, allow_comments, namespace, external_id FROM posts WHERE id = $1 AND tenant_id = $2")).
+			WithArgs("thread-id", tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id", "title", "content", "created_at", "allow_comments", "namespace", "external_id"}).
+				AddRow("thread-id", nil, "", "", time.Now(), true, "articles", "how-to-42"))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM comments WHERE post_id = $1 AND tenant_id = $2 AND parent_comment_id IS NULL AND status = 'PUBLISHED'")).
+			WithArgs("thread-id", tenant.DefaultID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+
+		thread, err := store.AddThread(ctx, "articles", "how-to-42")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if thread.ID != "thread-id" || thread.Namespace != "articles" || thread.ExternalID != "how-to-42" {
+			t.Errorf("Unexpected thread: %+v", thread)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("there were unfulfilled expectations: %s", err)
+		}
+	})
+}
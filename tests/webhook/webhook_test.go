@@ -0,0 +1,329 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"graphql-comments/auth"
+	"graphql-comments/events"
+	"graphql-comments/graphql"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/graphql-go/graphql"
+)
+
+const secret = "test-secret"
+
+type receiver struct {
+	server   *httptest.Server
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+	mu       sync.Mutex
+}
+
+func newReceiver(status int) *receiver {
+	r := &receiver{status: status}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := r.status
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return r
+}
+
+func newDispatcher(t *testing.T, url string, options webhook.Options) (*webhook.Dispatcher, *webhook.Webhook) {
+	store := webhook.NewMemoryStore()
+	dispatcher := webhook.NewDispatcher(store, options)
+
+	hook, err := webhook.NewWebhook(url, []string{events.CommentCreated}, secret)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.CreateWebhook(context.Background(), hook)
+	return dispatcher, hook
+}
+
+func TestDelivery(t *testing.T) {
+	ctx := context.Background()
+	r := newReceiver(http.StatusOK)
+	defer r.server.Close()
+
+	dispatcher, hook := newDispatcher(t, r.server.URL, webhook.DefaultOptions)
+
+	t.Run("DeliversSignedPayload", func(t *testing.T) {
+		event := events.New(events.CommentCreated, map[string]interface{}{"id": "comment-id"})
+		if err := dispatcher.Publish(ctx, event); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if n, err := dispatcher.Process(ctx); err != nil || n != 1 {
+			t.Fatalf("Expected 1 delivery, got %d, %v", n, err)
+		}
+
+		if len(r.requests) != 1 {
+			t.Fatalf("Expected 1 request, got %d", len(r.requests))
+		}
+
+		if !webhook.Verify(secret, r.bodies[0], r.requests[0].Header.Get(webhook.SignatureHeader)) {
+			t.Errorf("Signature does not match payload")
+		}
+
+		if got := r.requests[0].Header.Get(webhook.EventHeader); got != events.CommentCreated {
+			t.Errorf("Expected event header %s, got %s", events.CommentCreated, got)
+		}
+
+		var payload events.Event
+		json.Unmarshal(r.bodies[0], &payload)
+		if payload.ID != event.ID || payload.Data["id"] != "comment-id" {
+			t.Errorf("Unexpected payload: %s", r.bodies[0])
+		}
+
+		deliveries, _ := dispatcher.Store.ListDeliveries(ctx, webhook.DeliveryFilter{WebhookID: hook.ID})
+		if len(deliveries) != 1 || deliveries[0].Status != webhook.StatusSucceeded || deliveries[0].ResponseStatus != http.StatusOK {
+			t.Errorf("Delivery was not recorded as succeeded")
+		}
+	})
+
+	t.Run("SameEventIsDeliveredOnce", func(t *testing.T) {
+		event := events.New(events.CommentCreated, nil)
+		dispatcher.Publish(ctx, event)
+		dispatcher.Publish(ctx, event)
+
+		if n, _ := dispatcher.Process(ctx); n != 1 {
+			t.Errorf("Expected 1 delivery, got %d", n)
+		}
+	})
+
+	t.Run("IgnoresUnsubscribedEvents", func(t *testing.T) {
+		dispatcher.Publish(ctx, events.New(events.PostCreated, nil))
+
+		if n, _ := dispatcher.Process(ctx); n != 0 {
+			t.Errorf("Expected 0 deliveries, got %d", n)
+		}
+	})
+}
+
+func TestRetriesAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	r := newReceiver(http.StatusInternalServerError)
+	defer r.server.Close()
+
+	options := webhook.DefaultOptions
+	options.MaxAttempts = 3
+	options.BaseBackoff = 0
+	dispatcher, hook := newDispatcher(t, r.server.URL, options)
+
+	dispatcher.Publish(ctx, events.New(events.CommentCreated, nil))
+	for i := 0; i < options.MaxAttempts; i++ {
+		dispatcher.Process(ctx)
+	}
+
+	deliveries, _ := dispatcher.Store.ListDeliveries(ctx, webhook.DeliveryFilter{WebhookID: hook.ID})
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(deliveries))
+	}
+
+	delivery := deliveries[0]
+	if delivery.Status != webhook.StatusDead || delivery.Attempts != 3 || delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("Expected dead delivery after 3 attempts, got %s after %d", delivery.Status, delivery.Attempts)
+	}
+
+	if n, _ := dispatcher.Process(ctx); n != 0 {
+		t.Errorf("Dead delivery was retried")
+	}
+
+	t.Run("Redeliver", func(t *testing.T) {
+		r.mu.Lock()
+		r.status = http.StatusNoContent
+		r.mu.Unlock()
+		if _, err := dispatcher.Redeliver(ctx, delivery.ID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		dispatcher.Process(ctx)
+
+		redelivered, _ := dispatcher.Store.GetDelivery(ctx, delivery.ID)
+		if redelivered.Status != webhook.StatusSucceeded {
+			t.Errorf("Expected succeeded delivery, got %s", redelivered.Status)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	ctx := context.Background()
+	r := newReceiver(http.StatusBadGateway)
+	defer r.server.Close()
+
+	options := webhook.DefaultOptions
+	options.BaseBackoff = time.Minute
+	dispatcher, hook := newDispatcher(t, r.server.URL, options)
+
+	dispatcher.Publish(ctx, events.New(events.CommentCreated, nil))
+	dispatcher.Process(ctx)
+
+	if n, _ := dispatcher.Process(ctx); n != 0 {
+		t.Errorf("Delivery was retried before backoff elapsed")
+	}
+
+	deliveries, _ := dispatcher.Store.ListDeliveries(ctx, webhook.DeliveryFilter{WebhookID: hook.ID})
+	if wait := time.Until(deliveries[0].NextAttemptAt); wait < 50*time.Second {
+		t.Errorf("Expected next attempt in about a minute, got %v", wait)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	store := webhook.NewMemoryStore()
+	dispatcher := webhook.NewDispatcher(store, webhook.DefaultOptions)
+	ctxA := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "a"})
+	ctxB := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "b"})
+
+	hookA, _ := webhook.NewWebhook("https://a.example.com", []string{events.CommentCreated}, secret)
+	hookB, _ := webhook.NewWebhook("https://b.example.com", []string{events.CommentCreated}, secret)
+	store.CreateWebhook(ctxA, hookA)
+	store.CreateWebhook(ctxB, hookB)
+
+	if _, err := store.GetWebhook(ctxB, hookA.ID); err != webhook.ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+	if webhooks, _ := store.ListWebhooks(ctxB); len(webhooks) != 1 || webhooks[0].ID != hookB.ID {
+		t.Errorf("Unexpected webhooks: %v", webhooks)
+	}
+
+	// Событие из outbox публикуется без арендатора в контексте
+	event := events.New(events.CommentCreated, nil)
+	event.TenantID = "a"
+	if err := dispatcher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	deliveries, _ := store.ListDeliveries(ctxA, webhook.DeliveryFilter{})
+	if len(deliveries) != 1 || deliveries[0].WebhookID != hookA.ID || deliveries[0].TenantID != "a" {
+		t.Errorf("Unexpected deliveries of tenant a: %v", deliveries)
+	}
+	if deliveries, _ := store.ListDeliveries(ctxB, webhook.DeliveryFilter{}); len(deliveries) != 0 {
+		t.Errorf("Unexpected deliveries of tenant b: %v", deliveries)
+	}
+}
+
+func TestNewWebhook(t *testing.T) {
+	cases := []struct {
+		url    string
+		events []string
+		secret string
+	}{
+		{"ftp://example.com", []string{events.PostCreated}, secret},
+		{"/relative", []string{events.PostCreated}, secret},
+		{"https://example.com", nil, secret},
+		{"https://example.com", []string{"post.deleted.forever"}, secret},
+		{"https://example.com", []string{events.PostCreated}, ""},
+	}
+
+	for _, c := range cases {
+		if _, err := webhook.NewWebhook(c.url, c.events, c.secret); err == nil {
+			t.Errorf("Expected error for %+v", c)
+		}
+	}
+}
+
+func TestGraphQLWebhooks(t *testing.T) {
+	storage.DataBase = inMemory.NewInMemoryStore()
+	dispatcher := webhook.NewDispatcher(webhook.NewMemoryStore(), webhook.DefaultOptions)
+	gql.Events = dispatcher
+	gql.Webhooks = dispatcher
+	defer func() { gql.Events = nil }()
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: gql.QueryType, Mutation: gql.MutationType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	register := `mutation { registerWebhook(url: "https://example.com/hook", events: ["post.created"], secret: "s") { id events } }`
+
+	t.Run("RequiresAdmin", func(t *testing.T) {
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: register, Context: context.Background()})
+		if !result.HasErrors() || result.Errors[0].Extensions["code"] != "FORBIDDEN" {
+			t.Errorf("Expected FORBIDDEN error, got %v", result.Errors)
+		}
+	})
+
+	t.Run("RegisterAndDeliver", func(t *testing.T) {
+		ctx := auth.WithAdmin(context.Background())
+		result := graphql.Do(graphql.Params{Schema: schema, RequestString: register, Context: ctx})
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+		id := result.Data.(map[string]interface{})["registerWebhook"].(map[string]interface{})["id"].(string)
+
+		result = graphql.Do(graphql.Params{Schema: schema, RequestString: `mutation { addPost(title: "Title", content: "Content") { id } }`, Context: ctx})
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+
+		result = graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  `query($id: ID!) { webhookDeliveries(webhookID: $id) { eventType status } }`,
+			VariableValues: map[string]interface{}{"id": id},
+			Context:        ctx,
+		})
+		if result.HasErrors() {
+			t.Fatalf("Unexpected errors: %v", result.Errors)
+		}
+
+		deliveries := result.Data.(map[string]interface{})["webhookDeliveries"].([]interface{})
+		if len(deliveries) != 1 {
+			t.Fatalf("Expected 1 delivery, got %v", deliveries)
+		}
+
+		delivery := deliveries[0].(map[string]interface{})
+		if delivery["eventType"] != events.PostCreated || delivery["status"] != webhook.StatusPending {
+			t.Errorf("Unexpected delivery: %v", delivery)
+		}
+	})
+}
+
+func TestPostgresEnqueue(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store := webhook.NewPostgresStore(db)
+	now := time.Now()
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_deliveries (id, tenant_id, webhook_id, event_id, event_type, payload, status, attempts, last_error, response_status, next_attempt_at, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT (webhook_id, event_id) DO NOTHING")).
+		WithArgs("delivery-id", "tenant-a", "webhook-id", "event-id", events.PostCreated, []byte("{}"), webhook.StatusPending, 0, "", 0, now, now, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := store.EnqueueDelivery(context.Background(), &webhook.Delivery{
+		ID:            "delivery-id",
+		TenantID:      "tenant-a",
+		WebhookID:     "webhook-id",
+		EventID:       "event-id",
+		EventType:     events.PostCreated,
+		Payload:       []byte("{}"),
+		Status:        webhook.StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,189 @@
+package widget_test
+
+import (
+	"context"
+	"graphql-comments/storage"
+	inMemory "graphql-comments/storage/in-memory"
+	"graphql-comments/tenant"
+	"graphql-comments/types"
+	"graphql-comments/widget"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfField = regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`)
+
+func newHandler(t *testing.T) *widget.Handler {
+	storage.DataBase = inMemory.NewInMemoryStore()
+
+	handler, err := widget.NewHandler("secret")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return handler
+}
+
+// view загружает страницу виджета и возвращает ответ и CSRF-токен формы
+func view(t *testing.T, handler http.Handler, target string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	match := csrfField.FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		return rec, ""
+	}
+	return rec, match[1]
+}
+
+func submit(handler http.Handler, target string, cookies []*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestView(t *testing.T) {
+	handler := newHandler(t)
+	ctx := context.Background()
+
+	user, _ := storage.DataBase.AddUser(ctx, "alice")
+	post, _ := storage.DataBase.AddPost(ctx, user.ID, "Title", "Content", true)
+	parent, _ := storage.DataBase.AddComment(ctx, user.ID, post.ID, "", "**hello** <script>alert(1)</script>", types.CommentPublished)
+	storage.DataBase.AddComment(ctx, "", post.ID, parent.ID, "nested reply", types.CommentPublished)
+	storage.DataBase.AddComment(ctx, "", post.ID, "", "awaiting moderation", types.CommentPending)
+
+	rec, token := view(t, handler, "/embed/"+post.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{"<strong>hello</strong>", "alice", "Anonymous", "nested reply", `id="comment-` + parent.ID + `"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected page to contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"<script>", "awaiting moderation"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("Expected page not to contain %q", unwanted)
+		}
+	}
+	if token == "" {
+		t.Errorf("Expected form with CSRF token")
+	}
+	if len(rec.Result().Cookies()) != 1 {
+		t.Errorf("Expected CSRF cookie, got %v", rec.Result().Cookies())
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		if rec, _ := view(t, handler, "/embed/missing"); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("ClosedComments", func(t *testing.T) {
+		closed, _ := storage.DataBase.AddPost(ctx, "", "Closed", "Content", false)
+		rec, _ := view(t, handler, "/embed/"+closed.ID)
+		if strings.Contains(rec.Body.String(), "<form") {
+			t.Errorf("Expected no comment form for closed post")
+		}
+	})
+}
+
+func TestPagination(t *testing.T) {
+	handler := newHandler(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: tenant.DefaultID, Limits: tenant.Limits{CommentsPageSize: 2}})
+
+	post, _ := storage.DataBase.AddPost(ctx, "", "Title", "Content", true)
+	for _, content := range []string{"one", "two", "three"} {
+		storage.DataBase.AddComment(ctx, "", post.ID, "", content, types.CommentPublished)
+	}
+	// Комментарии на модерации не добавляют страниц
+	for _, content := range []string{"four", "five"} {
+		storage.DataBase.AddComment(ctx, "", post.ID, "", content, types.CommentPending)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/embed/"+post.ID+"?page=2", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "three") || strings.Contains(body, "one") {
+		t.Errorf("Expected second page with the third comment, got %s", body)
+	}
+	if !strings.Contains(body, "Page 2 of 2") || !strings.Contains(body, `href="?page=1"`) {
+		t.Errorf("Expected link to the first page")
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	handler := newHandler(t)
+	ctx := context.Background()
+	post, _ := storage.DataBase.AddPost(ctx, "", "Title", "Content", true)
+	target := "/embed/" + post.ID
+
+	rec, token := view(t, handler, target)
+	cookies := rec.Result().Cookies()
+
+	t.Run("MissingToken", func(t *testing.T) {
+		rec := submit(handler, target, cookies, url.Values{"content": {"hello"}})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", rec.Code)
+		}
+	})
+
+	t.Run("MissingCookie", func(t *testing.T) {
+		rec := submit(handler, target, nil, url.Values{"content": {"hello"}, "csrf_token": {token}})
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", rec.Code)
+		}
+	})
+
+	var commentID string
+	t.Run("Comment", func(t *testing.T) {
+		rec := submit(handler, target, cookies, url.Values{"content": {"hello"}, "csrf_token": {token}})
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status 303, got %d: %s", rec.Code, rec.Body.String())
+		}
+
+		comments, _ := storage.DataBase.GetComments(ctx, post.ID, 1)
+		if len(comments) != 1 || comments[0].Content != "hello" {
+			t.Fatalf("Expected comment to be added, got %v", comments)
+		}
+		commentID = comments[0].ID
+		if location := rec.Header().Get("Location"); location != target+"?page=1#comment-"+commentID {
+			t.Errorf("Unexpected redirect: %s", location)
+		}
+	})
+
+	t.Run("Reply", func(t *testing.T) {
+		rec := submit(handler, target, cookies, url.Values{"content": {"reply"}, "parentCommentID": {commentID}, "page": {"1"}, "csrf_token": {token}})
+		if rec.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status 303, got %d", rec.Code)
+		}
+
+		replies, _ := storage.DataBase.GetReplies(ctx, commentID)
+		if len(replies) != 1 || replies[0].Content != "reply" {
+			t.Errorf("Expected reply to be added, got %v", replies)
+		}
+	})
+
+	t.Run("InvalidComment", func(t *testing.T) {
+		rec := submit(handler, target, cookies, url.Values{"content": {""}, "csrf_token": {token}})
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected status 422, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "content is empty") {
+			t.Errorf("Expected error message on the page")
+		}
+	})
+}
@@ -2,14 +2,53 @@ package types
 
 import "time"
 
+// Типы уведомлений
+const (
+	NotificationReply       = "REPLY"
+	NotificationPostComment = "POST_COMMENT"
+	NotificationMention     = "MENTION"
+)
+
+// Статусы комментариев
+const (
+	CommentPublished = "PUBLISHED"
+	CommentPending   = "PENDING"
+	CommentRejected  = "REJECTED"
+)
+
+// Очереди модерации
+const (
+	QueuePending = "PENDING"
+	QueueFlagged = "FLAGGED"
+)
+
+// Действия, записываемые в журнал аудита
+const (
+	AuditApprove = "APPROVE"
+	AuditReject  = "REJECT"
+	AuditReport  = "REPORT"
+	AuditDelete  = "DELETE"
+	AuditLock    = "LOCK"
+	AuditUnlock  = "UNLOCK"
+)
+
+// PostNamespace пространство имен веток комментариев, которыми являются посты;
+// внешним идентификатором поста служит его ID
+const PostNamespace = "post"
+
 // Post структура для хранения постов
 type Post struct {
 	ID            string
+	AuthorID      string
 	Title         string
 	Content       string
 	CreatedAt     time.Time
 	Comments      []string
 	AllowComments bool
+	// Namespace и ExternalID задают внешний ресурс, к которому привязана ветка
+	// комментариев; у постов, созданных через addPost, они пусты
+	Namespace  string
+	ExternalID string
 }
 
 // Comment структура для хранения комментариев
@@ -17,7 +56,70 @@ type Comment struct {
 	ID              string
 	PostID          string
 	ParentCommentID string
+	AuthorID        string
 	Content         string
 	CreatedAt       time.Time
 	Replies         []string
+	// Status статус модерации; комментарии в статусе CommentPending видны только автору
+	Status string
+}
+
+// User структура для хранения пользователей
+type User struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// Notification структура для хранения уведомлений пользователя UserID о комментарии CommentID,
+// оставленном пользователем ActorID
+type Notification struct {
+	ID        string
+	UserID    string
+	Type      string
+	ActorID   string
+	PostID    string
+	CommentID string
+	CreatedAt time.Time
+	ReadAt    *time.Time
+}
+
+// Report жалоба пользователя UserID на комментарий CommentID
+type Report struct {
+	CommentID string
+	UserID    string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// ModerationItem комментарий в очереди модерации вместе с нерассмотренными жалобами на него
+type ModerationItem struct {
+	Comment        *Comment
+	Reports        int
+	Reasons        []string
+	LastReportedAt *time.Time
+}
+
+// AuditEntry запись журнала аудита о действии Action пользователя Actor над комментарием
+// или постом TargetID
+type AuditEntry struct {
+	ID        string
+	TenantID  string
+	Actor     string
+	Action    string
+	TargetID  string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// Stats сводная статистика арендатора. Пользователи общие для всех арендаторов,
+// поэтому Users учитывает всех пользователей.
+type Stats struct {
+	Posts     int
+	Threads   int
+	Published int
+	Pending   int
+	Rejected  int
+	Reports   int
+	Users     int
 }
@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"graphql-comments/events"
+	"graphql-comments/logging"
+	"graphql-comments/tenant"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const maxErrorLength = 512
+
+// Options настройки доставки
+type Options struct {
+	// MaxAttempts число попыток, после которого доставка отправляется в dead letter
+	MaxAttempts int
+	// BaseBackoff задержка перед второй попыткой; каждая следующая вдвое больше
+	BaseBackoff time.Duration
+	// MaxBackoff ограничивает задержку между попытками
+	MaxBackoff time.Duration
+	// PollInterval период опроса очереди
+	PollInterval time.Duration
+	// Timeout ограничивает время одного HTTP-запроса
+	Timeout time.Duration
+	// BatchSize число доставок, обрабатываемых за один проход
+	BatchSize int
+}
+
+// DefaultOptions настройки доставки по умолчанию
+var DefaultOptions = Options{
+	MaxAttempts:  8,
+	BaseBackoff:  10 * time.Second,
+	MaxBackoff:   time.Hour,
+	PollInterval: time.Second,
+	Timeout:      10 * time.Second,
+	BatchSize:    50,
+}
+
+// Dispatcher ставит события в очередь доставки подписанным webhook'ам
+// и отправляет их с повторными попытками
+type Dispatcher struct {
+	Store   Store
+	Client  *http.Client
+	Options Options
+}
+
+// NewDispatcher создает Dispatcher
+func NewDispatcher(store Store, options Options) *Dispatcher {
+	return &Dispatcher{
+		Store:   store,
+		Client:  &http.Client{Timeout: options.Timeout},
+		Options: options,
+	}
+}
+
+// Publish реализует events.Publisher: создает доставку для каждого webhook'а арендатора
+// события, подписанного на него. События без арендатора относятся к арендатору ctx
+func (d *Dispatcher) Publish(ctx context.Context, event events.Event) error {
+	if event.TenantID != "" {
+		ctx = tenant.WithTenant(ctx, &tenant.Tenant{ID: event.TenantID})
+	}
+	webhooks, err := d.Store.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event.Type) {
+			continue
+		}
+
+		delivery := &Delivery{
+			ID:            uuid.NewString(),
+			TenantID:      webhook.TenantID,
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := d.Store.EnqueueDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run обрабатывает очередь доставок, пока не будет отменен ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Process(ctx); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("webhook delivery failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process выполняет один проход по очереди и возвращает число обработанных доставок
+func (d *Dispatcher) Process(ctx context.Context) (int, error) {
+	now := time.Now()
+	// Аренда должна пережить HTTP-запрос, иначе доставку может взять другой обработчик
+	leaseUntil := now.Add(2 * d.Options.Timeout)
+
+	deliveries, err := d.Store.ClaimDeliveries(ctx, now, leaseUntil, d.Options.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *Delivery) {
+			defer wg.Done()
+			if err := d.deliver(ctx, delivery); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries), firstErr
+}
+
+// deliver выполняет одну попытку доставки и сохраняет ее результат
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) error {
+	ctx = tenant.WithTenant(ctx, &tenant.Tenant{ID: delivery.TenantID})
+	webhook, err := d.Store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	status, sendErr := d.send(ctx, webhook, delivery)
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.UpdatedAt = time.Now()
+	if sendErr == nil {
+		delivery.Status = StatusSucceeded
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = truncate(sendErr.Error(), maxErrorLength)
+		if delivery.Attempts >= d.Options.MaxAttempts {
+			delivery.Status = StatusDead
+			logging.FromContext(ctx).Warn("webhook delivery moved to dead letter",
+				"webhook_id", webhook.ID, "delivery_id", delivery.ID, "attempts", delivery.Attempts, "error", sendErr)
+		} else {
+			delivery.NextAttemptAt = delivery.UpdatedAt.Add(d.backoff(delivery.Attempts))
+		}
+	}
+
+	return d.Store.UpdateDelivery(ctx, delivery)
+}
+
+func (d *Dispatcher) send(ctx context.Context, webhook *Webhook, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, delivery.Payload))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff возвращает задержку перед попыткой, следующей за attempts-й
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.Options.BaseBackoff
+	for i := 1; i < attempts && delay < d.Options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.Options.MaxBackoff {
+		delay = d.Options.MaxBackoff
+	}
+	return delay
+}
+
+// Redeliver возвращает доставку, в том числе из dead letter, в очередь
+func (d *Dispatcher) Redeliver(ctx context.Context, id string) (*Delivery, error) {
+	delivery, err := d.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery.Status = StatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := d.Store.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
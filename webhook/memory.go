@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"context"
+	"graphql-comments/tenant"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит подписки и доставки в памяти процесса
+type MemoryStore struct {
+	webhooks   map[string]*Webhook
+	deliveries map[string]*Delivery
+	mu         sync.Mutex
+}
+
+// NewMemoryStore создает in-memory хранилище
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		webhooks:   make(map[string]*Webhook),
+		deliveries: make(map[string]*Delivery),
+	}
+}
+
+func (s *MemoryStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook.TenantID = tenant.ID(ctx)
+	copied := *webhook
+	s.webhooks[webhook.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok || webhook.TenantID != tenant.ID(ctx) {
+		return nil, ErrWebhookNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (s *MemoryStore) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		if webhook.TenantID != tenant.ID(ctx) {
+			continue
+		}
+		copied := *webhook
+		webhooks = append(webhooks, &copied)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if webhook, ok := s.webhooks[id]; !ok || webhook.TenantID != tenant.ID(ctx) {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) EnqueueDelivery(ctx context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Повторная публикация того же события не должна приводить к повторной доставке
+	for _, existing := range s.deliveries {
+		if existing.WebhookID == delivery.WebhookID && existing.EventID == delivery.EventID {
+			return nil
+		}
+	}
+
+	copied := *delivery
+	s.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]*Delivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.Status == StatusPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*Delivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = leaseUntil
+		copied := *delivery
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+	copied := *delivery
+	s.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok || delivery.TenantID != tenant.ID(ctx) {
+		return nil, ErrDeliveryNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (s *MemoryStore) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]*Delivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.TenantID != tenant.ID(ctx) {
+			continue
+		}
+		if filter.WebhookID != "" && delivery.WebhookID != filter.WebhookID {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		copied := *delivery
+		deliveries = append(deliveries, &copied)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
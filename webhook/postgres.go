@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"graphql-comments/tenant"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const webhookColumns = "id, tenant_id, url, events, secret, created_at"
+
+const deliveryColumns = "id, tenant_id, webhook_id, event_id, event_type, payload, status, attempts, last_error, response_status, next_attempt_at, created_at, updated_at"
+
+// PostgresStore хранит подписки в таблице webhooks, а очередь и журнал доставок —
+// в таблице webhook_deliveries
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresStore создает хранилище поверх PostgreSQL
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) CreateWebhook(ctx context.Context, webhook *Webhook) error {
+	webhook.TenantID = tenant.ID(ctx)
+	_, err := s.DB.ExecContext(ctx,
+		"INSERT INTO webhooks ("+webhookColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		webhook.ID, webhook.TenantID, webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.CreatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := s.DB.QueryRowContext(ctx,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx),
+	).Scan(&webhook.ID, &webhook.TenantID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *PostgresStore) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE tenant_id = $1 ORDER BY created_at", tenant.ID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*Webhook, 0)
+	for rows.Next() {
+		webhook := &Webhook{}
+		if err := rows.Scan(&webhook.ID, &webhook.TenantID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id string) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx))
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) EnqueueDelivery(ctx context.Context, delivery *Delivery) error {
+	_, err := s.DB.ExecContext(ctx,
+		"INSERT INTO webhook_deliveries ("+deliveryColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT (webhook_id, event_id) DO NOTHING",
+		delivery.ID, delivery.TenantID, delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload, delivery.Status,
+		delivery.Attempts, delivery.LastError, delivery.ResponseStatus, delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*Delivery, error) {
+	rows, err := s.DB.QueryContext(ctx, `UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $3 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns,
+		now, leaseUntil, StatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func (s *PostgresStore) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	result, err := s.DB.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = $2, attempts = $3, last_error = $4, response_status = $5, next_attempt_at = $6, updated_at = $7 WHERE id = $1",
+		delivery.ID, delivery.Status, delivery.Attempts, delivery.LastError, delivery.ResponseStatus, delivery.NextAttemptAt, delivery.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	return deliveries[0], nil
+}
+
+func (s *PostgresStore) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	var (
+		conditions = []string{"tenant_id = $1"}
+		args       = []interface{}{tenant.ID(ctx)}
+	)
+	if filter.WebhookID != "" {
+		args = append(args, filter.WebhookID)
+		conditions = append(conditions, "webhook_id = $"+strconv.Itoa(len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, "status = $"+strconv.Itoa(len(args)))
+	}
+
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE " + strings.Join(conditions, " AND ") + " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]*Delivery, error) {
+	defer rows.Close()
+
+	deliveries := make([]*Delivery, 0)
+	for rows.Next() {
+		d := &Delivery{}
+		if err := rows.Scan(&d.ID, &d.TenantID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.LastError, &d.ResponseStatus, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"graphql-comments/events"
+	"net/url"
+	"time"
+)
+
+// Заголовки, с которыми отправляются доставки
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// MaxDeliveriesPageSize максимальное число доставок, возвращаемых за один запрос журнала
+const MaxDeliveriesPageSize = 100
+
+// Статусы доставки
+const (
+	StatusPending   = "PENDING"
+	StatusSucceeded = "SUCCEEDED"
+	StatusDead      = "DEAD"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Webhook подписка внешнего сервиса на события арендатора TenantID
+type Webhook struct {
+	ID        string
+	TenantID  string
+	URL       string
+	Events    []string
+	Secret    string
+	CreatedAt time.Time
+}
+
+// NewWebhook проверяет параметры подписки и создает webhook
+func NewWebhook(rawURL string, eventTypes []string, secret string) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	switch {
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return nil, errors.New("url must be an absolute http(s) URL")
+	case len(eventTypes) == 0:
+		return nil, errors.New("events are empty")
+	case secret == "":
+		return nil, errors.New("secret is empty")
+	}
+
+	for _, eventType := range eventTypes {
+		if !events.Known(eventType) {
+			return nil, fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	return &Webhook{
+		ID:        uuid.NewString(),
+		URL:       rawURL,
+		Events:    eventTypes,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// Subscribed сообщает, подписан ли webhook на события типа eventType
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery попытка доставить событие на webhook
+type Delivery struct {
+	ID             string
+	TenantID       string
+	WebhookID      string
+	EventID        string
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	LastError      string
+	ResponseStatus int
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// DeliveryFilter условия выборки журнала доставок
+type DeliveryFilter struct {
+	WebhookID string
+	Status    string
+	Limit     int
+}
+
+// Store хранит подписки и очередь доставок. Подписки и журнал доставок видны только
+// арендатору запроса; ClaimDeliveries и UpdateDelivery обслуживают очередь всех арендаторов.
+type Store interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+
+	EnqueueDelivery(ctx context.Context, delivery *Delivery) error
+	// ClaimDeliveries выбирает до limit доставок, время попытки которых наступило к now,
+	// и откладывает их следующую попытку до leaseUntil, чтобы другие обработчики
+	// не взяли их повторно. Если обработчик завершится аварийно, доставка будет
+	// повторена после истечения аренды.
+	ClaimDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error)
+}
+
+// Sign возвращает значение заголовка X-Webhook-Signature для тела запроса body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись signature тела запроса body
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,59 @@
+package widget
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	csrfCookie = "comments_csrf"
+	csrfField  = "csrf_token"
+)
+
+// csrfToken возвращает токен формы для клиента, при необходимости выдавая ему cookie.
+// Токен формы — подпись значения cookie, поэтому сторонний сайт не может его подобрать.
+func (h *Handler) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && len(cookie.Value) == 2*sha256.Size {
+		return h.sign(cookie.Value), nil
+	}
+
+	nonce := make([]byte, sha256.Size)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	value := hex.EncodeToString(nonce)
+
+	cookie := &http.Cookie{
+		Name:     csrfCookie,
+		Value:    value,
+		Path:     Prefix,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	// Виджет во фрейме стороннего сайта получает cookie только с SameSite=None,
+	// который браузеры принимают лишь по HTTPS
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		cookie.Secure = true
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, cookie)
+	return h.sign(value), nil
+}
+
+// checkCSRF сообщает, соответствует ли токен формы cookie клиента
+func (h *Handler) checkCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(r.PostFormValue(csrfField)), []byte(h.sign(cookie.Value)))
+}
+
+func (h *Handler) sign(value string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,264 @@
+package widget
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"errors"
+	"fmt"
+	"graphql-comments/graphql"
+	"graphql-comments/logging"
+	"graphql-comments/ratelimit"
+	"graphql-comments/storage"
+	"graphql-comments/types"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Prefix путь, по которому обслуживается виджет
+const Prefix = "/embed/"
+
+// MaxDepth ограничивает глубину отображаемого дерева ответов
+const MaxDepth = 8
+
+// maxFormSize ограничивает размер тела формы комментария
+const maxFormSize = 64 << 10
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var pageTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"args": func(p *page, c *comment) node { return node{Page: p, Comment: c} },
+}).ParseFS(templates, "templates/*.html"))
+
+// Handler отрисовывает ветку комментариев поста в HTML для встраивания в статические
+// сайты и принимает комментарии из формы, работающей без JavaScript:
+//
+//	GET  /embed/{postID}?page=N  страница комментариев верхнего уровня с ответами
+//	POST /embed/{postID}         добавление комментария или ответа
+type Handler struct {
+	mux    *http.ServeMux
+	secret []byte
+}
+
+// NewHandler создает обработчик виджета. CSRF-токены форм подписываются секретом secret;
+// если он пуст, секрет генерируется случайно и токены перестают действовать после перезапуска.
+func NewHandler(secret string) (*Handler, error) {
+	h := &Handler{mux: http.NewServeMux(), secret: []byte(secret)}
+	if secret == "" {
+		h.secret = make([]byte, 32)
+		if _, err := rand.Read(h.secret); err != nil {
+			return nil, err
+		}
+	}
+	h.mux.HandleFunc("GET "+Prefix+"{postID}", h.view)
+	h.mux.HandleFunc("POST "+Prefix+"{postID}", h.submit)
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// form данные формы комментария, возвращаемые пользователю при ошибке
+type form struct {
+	ParentCommentID string
+	Content         string
+	Error           string
+}
+
+// page данные шаблона страницы виджета
+type page struct {
+	Post     *types.Post
+	Comments []*comment
+	Page     int
+	Pages    int
+	CSRF     string
+	Notice   string
+	Form     form
+}
+
+// comment комментарий с отрисованным содержимым и ответами
+type comment struct {
+	*types.Comment
+	Author  string
+	HTML    template.HTML
+	Replies []*comment
+}
+
+func (p *page) PrevPage() int { return p.Page - 1 }
+func (p *page) NextPage() int { return p.Page + 1 }
+
+// node данные шаблонов комментария и формы: страница и комментарий, к которому они
+// относятся (nil для формы комментария верхнего уровня)
+type node struct {
+	Page    *page
+	Comment *comment
+}
+
+// ParentID возвращает ID комментария, ответом на который станет комментарий из формы
+func (n node) ParentID() string {
+	if n.Comment == nil {
+		return ""
+	}
+	return n.Comment.ID
+}
+
+func (h *Handler) view(w http.ResponseWriter, r *http.Request) {
+	number, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	var notice string
+	if r.URL.Query().Get("notice") == "pending" {
+		notice = "Your comment is awaiting moderation."
+	}
+	h.render(w, r, http.StatusOK, number, notice, form{})
+}
+
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	if !h.checkCSRF(r) {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+	postID := r.PathValue("postID")
+	number, _ := strconv.Atoi(r.PostFormValue("page"))
+	submitted := form{
+		ParentCommentID: r.PostFormValue("parentCommentID"),
+		Content:         r.PostFormValue("content"),
+	}
+
+	newComment, err := gql.AddComment(ctx, postID, submitted.ParentCommentID, submitted.Content)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		var limited *ratelimit.Error
+		if errors.As(err, &limited) {
+			status = http.StatusTooManyRequests
+		}
+		submitted.Error = err.Error()
+		h.render(w, r, status, number, "", submitted)
+		return
+	}
+
+	// Новый комментарий верхнего уровня оказывается на последней странице
+	if newComment.ParentCommentID == "" {
+		if total, err := pages(ctx, postID); err == nil {
+			number = total
+		}
+	}
+	query := url.Values{"page": {strconv.Itoa(max(number, 1))}}
+	if newComment.Status != types.CommentPublished {
+		query.Set("notice", "pending")
+	}
+	location := fmt.Sprintf("%s%s?%s#comment-%s", Prefix, url.PathEscape(postID), query.Encode(), newComment.ID)
+	http.Redirect(w, r, location, http.StatusSeeOther)
+}
+
+// render отрисовывает страницу number ветки комментариев поста
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, status, number int, notice string, submitted form) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	post, err := storage.DataBase.GetPostByID(ctx, r.PathValue("postID"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	csrf, err := h.csrfToken(w, r)
+	if err != nil {
+		logger.Error("failed to issue CSRF token", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	total, err := pages(ctx, post.ID)
+	if err != nil {
+		logger.Error("failed to count comment pages", "post_id", post.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	data := &page{Post: post, Page: number, Pages: total, CSRF: csrf, Notice: notice, Form: submitted}
+	if data.Page > data.Pages {
+		data.Page = data.Pages
+	}
+	if data.Page < 1 {
+		data.Page = 1
+	}
+
+	comments, err := storage.DataBase.GetComments(ctx, post.ID, data.Page)
+	if err != nil {
+		logger.Error("failed to load comments", "post_id", post.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	authors := make(map[string]string)
+	if data.Comments, err = tree(ctx, comments, authors, 1); err != nil {
+		logger.Error("failed to load comments", "post_id", post.ID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pageTemplate.ExecuteTemplate(w, "thread.html", data); err != nil {
+		logger.Error("failed to render widget", "post_id", post.ID, "error", err)
+	}
+}
+
+// pages возвращает число страниц опубликованных комментариев верхнего уровня поста, не меньше одной
+func pages(ctx context.Context, postID string) (int, error) {
+	total, err := storage.DataBase.GetNumberOfCommentPages(ctx, postID)
+	if err != nil {
+		return 0, err
+	}
+	return max(total, 1), nil
+}
+
+// tree загружает ответы на комментарии до глубины MaxDepth и отрисовывает их содержимое
+func tree(ctx context.Context, comments []*types.Comment, authors map[string]string, depth int) ([]*comment, error) {
+	views := make([]*comment, 0, len(comments))
+	for _, c := range comments {
+		html, err := gql.Markdown.Render(c.Content)
+		if err != nil {
+			return nil, err
+		}
+		view := &comment{Comment: c, Author: author(ctx, c.AuthorID, authors), HTML: template.HTML(html)}
+
+		if depth < MaxDepth && len(c.Replies) > 0 {
+			replies, err := storage.DataBase.GetReplies(ctx, c.ID)
+			if err != nil {
+				return nil, err
+			}
+			if view.Replies, err = tree(ctx, replies, authors, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// author возвращает имя автора комментария, запоминая уже загруженные имена в authors
+func author(ctx context.Context, id string, authors map[string]string) string {
+	if id == "" {
+		return "Anonymous"
+	}
+	if name, ok := authors[id]; ok {
+		return name
+	}
+	name := "Unknown"
+	if user, err := storage.DataBase.GetUserByID(ctx, id); err == nil {
+		name = user.Name
+	}
+	authors[id] = name
+	return name
+}